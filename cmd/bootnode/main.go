@@ -28,7 +28,6 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/crypto"
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/p2p/discover"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
 	"github.com/etvchaineum/go-etvchaineum/p2p/nat"
 	"github.com/etvchaineum/go-etvchaineum/p2p/netutil"
@@ -118,17 +117,22 @@ func main() {
 		}
 	}
 
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, nodeKey)
+	ln.SetFallbackIP(net.IP{127, 0, 0, 1})
+	ln.SetFallbackUDP(realaddr.Port)
+	if !realaddr.IP.IsUnspecified() {
+		ln.SetStaticIP(realaddr.IP)
+	}
+	cfg := discover.Config{
+		PrivateKey:  nodeKey,
+		NetRestrict: restrictList,
+	}
 	if *runv5 {
-		if _, err := discv5.ListenUDP(nodeKey, conn, "", restrictList); err != nil {
+		if _, err := discover.ListenV5(conn, ln, cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
 	} else {
-		db, _ := enode.OpenDB("")
-		ln := enode.NewLocalNode(db, nodeKey)
-		cfg := discover.Config{
-			PrivateKey:  nodeKey,
-			NetRestrict: restrictList,
-		}
 		if _, err := discover.ListenUDP(conn, ln, cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
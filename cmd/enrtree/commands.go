@@ -0,0 +1,187 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of go-etvchaineum.
+//
+// go-etvchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-etvchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-etvchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/etvchaineum/go-etvchaineum/accounts/keystore"
+	"github.com/etvchaineum/go-etvchaineum/cmd/utils"
+	"github.com/etvchaineum/go-etvchaineum/console"
+	"github.com/etvchaineum/go-etvchaineum/p2p/dnsdisc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var commandSign = cli.Command{
+	Name:      "sign",
+	Usage:     "sign a node tree",
+	ArgsUsage: "<tree-dir> <keyfile>",
+	Description: `
+Sign the tree in the given directory with the key in the keyfile.
+
+The directory must contain a nodes.json file holding a JSON array of node
+records, in 'enr:' text form or hex-encoded as returned by admin_nodeInfo.
+The signature, sequence number and URL of the tree are written to the
+enrtree-info.json file, which may also list the URLs of linked trees.
+
+The sequence number is incremented whenever the tree changed since it was
+last signed. It can be set explicitly using the --seq flag.`,
+	Flags: []cli.Flag{
+		passphraseFlag,
+		domainFlag,
+		seqFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			utils.Fatalf("Need tree directory and keyfile as arguments")
+		}
+		dir, keyfile := ctx.Args().Get(0), ctx.Args().Get(1)
+		info, nodes := loadTreeDir(dir)
+
+		domain := ctx.String(domainFlag.Name)
+		if domain == "" && info.URL != "" {
+			domain, _, _ = dnsdisc.ParseURL(info.URL)
+		}
+		if domain == "" {
+			utils.Fatalf("Tree domain unknown, set it using --domain")
+		}
+		if ctx.IsSet(seqFlag.Name) {
+			info.Seq = ctx.Uint(seqFlag.Name)
+		} else if _, err := makeTree(info, nodes); err != nil || info.Sig == "" {
+			info.Seq++
+		}
+
+		// Create and sign the tree.
+		key := loadKey(ctx, keyfile)
+		tree, err := dnsdisc.MakeTree(info.Seq, nodes, info.Links)
+		if err != nil {
+			utils.Fatalf("Can't create tree: %v", err)
+		}
+		url, err := tree.Sign(key.PrivateKey, domain)
+		if err != nil {
+			utils.Fatalf("Can't sign tree: %v", err)
+		}
+		info.URL, info.Sig = url, tree.Signature()
+		writeJSON(filepath.Join(dir, infoFileName), info)
+
+		fmt.Println("Signed tree with sequence number", info.Seq)
+		fmt.Println(url)
+		return nil
+	},
+}
+
+var commandToTXT = cli.Command{
+	Name:      "to-txt",
+	Usage:     "export a signed node tree as DNS TXT records",
+	ArgsUsage: "<tree-dir> [<output-file>]",
+	Description: `
+Print the DNS TXT records of the signed tree in the given directory as a JSON
+object mapping record names to their content. The root record is stored at the
+domain the tree was signed for, all other records at subdomains of it.
+
+The records are written to standard output unless an output file is given.`,
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 1 {
+			utils.Fatalf("Need tree directory as argument")
+		}
+		dir, output := ctx.Args().Get(0), ctx.Args().Get(1)
+		if output == "" {
+			output = "-"
+		}
+		info, nodes := loadTreeDir(dir)
+		if info.URL == "" || info.Sig == "" {
+			utils.Fatalf("Tree is not signed, sign it first")
+		}
+		tree, err := makeTree(info, nodes)
+		if err != nil {
+			utils.Fatalf("Invalid tree: %v", err)
+		}
+		domain, _, _ := dnsdisc.ParseURL(info.URL)
+		writeJSON(output, tree.ToTXT(domain))
+		return nil
+	},
+}
+
+var commandSync = cli.Command{
+	Name:      "sync",
+	Usage:     "download a node tree from DNS",
+	ArgsUsage: "<enrtree-url> [<tree-dir>]",
+	Description: `
+Download the complete tree at the given enrtree:// URL and store it in the given
+directory, which defaults to the domain of the tree. Linked trees are listed in
+the tree info file but not downloaded.`,
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 1 {
+			utils.Fatalf("Need enrtree:// URL as argument")
+		}
+		url, dir := ctx.Args().Get(0), ctx.Args().Get(1)
+		domain, _, err := dnsdisc.ParseURL(url)
+		if err != nil {
+			utils.Fatalf("Invalid URL: %v", err)
+		}
+		if dir == "" {
+			dir = domain
+		}
+		client, err := dnsdisc.NewClient(dnsdisc.Config{})
+		if err != nil {
+			utils.Fatalf("Can't create DNS client: %v", err)
+		}
+		tree, err := client.SyncTree(url)
+		if err != nil {
+			utils.Fatalf("Sync failed: %v", err)
+		}
+		info := &treeInfo{URL: url, Seq: tree.Seq(), Sig: tree.Signature(), Links: tree.Links()}
+		writeTreeDir(dir, info, tree.Nodes())
+
+		fmt.Printf("Synced tree with %d nodes and %d links (seq %d) to %s\n", len(tree.Nodes()), len(info.Links), info.Seq, dir)
+		return nil
+	},
+}
+
+// loadKey reads and decrypts the keyfile at the given path.
+func loadKey(ctx *cli.Context, keyfile string) *keystore.Key {
+	keyjson, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		utils.Fatalf("Failed to read the keyfile at '%s': %v", keyfile, err)
+	}
+	key, err := keystore.DecryptKey(keyjson, getPassphrase(ctx))
+	if err != nil {
+		utils.Fatalf("Error decrypting key: %v", err)
+	}
+	return key
+}
+
+// getPassphrase obtains a passphrase given by the user. It first checks the
+// --passwordfile command line flag and ultimately prompts the user for a
+// passphrase.
+func getPassphrase(ctx *cli.Context) string {
+	if file := ctx.String(passphraseFlag.Name); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read passphrase file '%s': %v", file, err)
+		}
+		return strings.TrimRight(string(content), "\r\n")
+	}
+	passphrase, err := console.Stdin.PromptPassword("Passphrase: ")
+	if err != nil {
+		utils.Fatalf("Failed to read passphrase: %v", err)
+	}
+	return passphrase
+}
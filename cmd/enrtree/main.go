@@ -0,0 +1,63 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of go-etvchaineum.
+//
+// go-etvchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-etvchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-etvchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+// enrtree is a tool for creating, signing and exporting DNS node lists.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/etvchaineum/go-etvchaineum/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var app *cli.App
+
+func init() {
+	app = utils.NewApp(gitCommit, "an Etvchain DNS node list tool")
+	app.Commands = []cli.Command{
+		commandSign,
+		commandToTXT,
+		commandSync,
+	}
+}
+
+// Commonly used command line flags.
+var (
+	passphraseFlag = cli.StringFlag{
+		Name:  "passwordfile",
+		Usage: "the file that contains the passphrase for the keyfile",
+	}
+	domainFlag = cli.StringFlag{
+		Name:  "domain",
+		Usage: "the domain name the tree is published at",
+	}
+	seqFlag = cli.UintFlag{
+		Name:  "seq",
+		Usage: "override the sequence number of the tree",
+	}
+)
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
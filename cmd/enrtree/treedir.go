@@ -0,0 +1,159 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of go-etvchaineum.
+//
+// go-etvchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-etvchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-etvchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/etvchaineum/go-etvchaineum/cmd/utils"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+	"github.com/etvchaineum/go-etvchaineum/p2p/dnsdisc"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enr"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+const (
+	infoFileName  = "enrtree-info.json"
+	nodesFileName = "nodes.json"
+)
+
+// treeInfo is the content of the tree info file. It holds everything except
+// the node records.
+type treeInfo struct {
+	URL   string   `json:"url,omitempty"`
+	Seq   uint     `json:"seq"`
+	Sig   string   `json:"signature,omitempty"`
+	Links []string `json:"links,omitempty"`
+}
+
+// loadTreeDir reads the tree info and node records in the given directory. The
+// info file is optional, a missing file yields an empty info.
+func loadTreeDir(dir string) (*treeInfo, []*enode.Node) {
+	info := new(treeInfo)
+	if err := readJSON(filepath.Join(dir, infoFileName), info); err != nil && !os.IsNotExist(err) {
+		utils.Fatalf("Failed to read tree info: %v", err)
+	}
+	var records []string
+	if err := readJSON(filepath.Join(dir, nodesFileName), &records); err != nil {
+		utils.Fatalf("Failed to read node records: %v", err)
+	}
+	nodes := make([]*enode.Node, len(records))
+	for i, r := range records {
+		n, err := parseRecord(r)
+		if err != nil {
+			utils.Fatalf("Invalid node record %d in %s: %v", i, nodesFileName, err)
+		}
+		nodes[i] = n
+	}
+	return info, nodes
+}
+
+// writeTreeDir stores the tree info and node records in the given directory.
+func writeTreeDir(dir string, info *treeInfo, nodes []*enode.Node) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		utils.Fatalf("Failed to create tree directory: %v", err)
+	}
+	records := make([]string, len(nodes))
+	for i, n := range nodes {
+		records[i] = formatRecord(n)
+	}
+	writeJSON(filepath.Join(dir, infoFileName), info)
+	writeJSON(filepath.Join(dir, nodesFileName), records)
+}
+
+// parseRecord decodes a node record given in text form, either "enr:" followed
+// by URL-safe base64 or hex with "0x" prefix as returned by admin_nodeInfo.
+func parseRecord(text string) (*enode.Node, error) {
+	var (
+		enc []byte
+		err error
+	)
+	switch {
+	case strings.HasPrefix(text, "enr:"):
+		enc, err = base64.RawURLEncoding.DecodeString(text[4:])
+	case strings.HasPrefix(text, "0x"):
+		enc, err = hexutil.Decode(text)
+	default:
+		return nil, fmt.Errorf("missing 'enr:' or '0x' prefix")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(enc, &r); err != nil {
+		return nil, err
+	}
+	return enode.New(enode.ValidSchemes, &r)
+}
+
+// formatRecord returns the "enr:" text form of a node record.
+func formatRecord(n *enode.Node) string {
+	enc, err := rlp.EncodeToBytes(n.Record())
+	if err != nil {
+		utils.Fatalf("Can't encode record of node %v: %v", n.ID(), err)
+	}
+	return "enr:" + base64.RawURLEncoding.EncodeToString(enc)
+}
+
+// makeTree creates the tree described by the given info and nodes, verifying
+// and assigning the signature in info if the info contains a URL.
+func makeTree(info *treeInfo, nodes []*enode.Node) (*dnsdisc.Tree, error) {
+	tree, err := dnsdisc.MakeTree(info.Seq, nodes, info.Links)
+	if err != nil {
+		return nil, err
+	}
+	if info.URL != "" && info.Sig != "" {
+		_, pubkey, err := dnsdisc.ParseURL(info.URL)
+		if err != nil {
+			return nil, err
+		}
+		if err := tree.SetSignature(pubkey, info.Sig); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+func readJSON(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(file string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode %s: %v", file, err)
+	}
+	if file == "-" {
+		os.Stdout.Write(data)
+		fmt.Println()
+		return
+	}
+	if err := ioutil.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		utils.Fatalf("Failed to write %s: %v", file, err)
+	}
+}
@@ -21,8 +21,10 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 
 	"github.com/etvchaineum/go-etvchaineum/cmd/utils"
+	"github.com/etvchaineum/go-etvchaineum/core/vm"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -110,6 +112,10 @@ var (
 		Name:  "nostack",
 		Usage: "disable stack output",
 	}
+	TracerFlag = cli.StringFlag{
+		Name:  "tracer",
+		Usage: "name of the built-in tracer to output the result of (" + strings.Join(vm.NativeTracers(), ", ") + ")",
+	}
 )
 
 func init() {
@@ -133,6 +139,7 @@ func init() {
 		ReceiverFlag,
 		DisableMemoryFlag,
 		DisableStackFlag,
+		TracerFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
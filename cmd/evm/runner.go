@@ -82,6 +82,7 @@ func runCmd(ctx *cli.Context) error {
 	var (
 		tracer        vm.Tracer
 		debugLogger   *vm.StructLogger
+		nativeTracer  vm.NativeTracer
 		statedb       *state.StateDB
 		chainConfig   *params.ChainConfig
 		sender        = common.BytesToAddress([]byte("sender"))
@@ -90,6 +91,12 @@ func runCmd(ctx *cli.Context) error {
 	)
 	if ctx.GlobalBool(MachineFlag.Name) {
 		tracer = vm.NewJSONLogger(logconfig, os.Stdout)
+	} else if name := ctx.GlobalString(TracerFlag.Name); name != "" {
+		var err error
+		if nativeTracer, err = vm.NewNativeTracer(name, nil); err != nil {
+			utils.Fatalf("Failed to create tracer: %v", err)
+		}
+		tracer = nativeTracer
 	} else if ctx.GlobalBool(DebugFlag.Name) {
 		debugLogger = vm.NewStructLogger(logconfig)
 		tracer = debugLogger
@@ -172,7 +179,7 @@ func runCmd(ctx *cli.Context) error {
 		BlockNumber: new(big.Int).SetUint64(genesisConfig.Number),
 		EVMConfig: vm.Config{
 			Tracer: tracer,
-			Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name) || nativeTracer != nil,
 		},
 	}
 
@@ -224,6 +231,13 @@ func runCmd(ctx *cli.Context) error {
 		f.Close()
 	}
 
+	if nativeTracer != nil {
+		result, err := nativeTracer.GetResult()
+		if err != nil {
+			utils.Fatalf("Failed to retrieve trace result: %v", err)
+		}
+		fmt.Println(string(result))
+	}
 	if ctx.GlobalBool(DebugFlag.Name) {
 		if debugLogger != nil {
 			fmt.Fprintln(os.Stderr, "#### TRACE ####")
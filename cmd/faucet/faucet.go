@@ -54,7 +54,6 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/node"
 	"github.com/etvchaineum/go-etvchaineum/p2p"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
 	"github.com/etvchaineum/go-etvchaineum/p2p/nat"
 	"github.com/etvchaineum/go-etvchaineum/params"
@@ -145,9 +144,9 @@ func main() {
 		log.Crit("Failed to parse genesis block json", "err", err)
 	}
 	// Convert the bootnodes to internal enode representations
-	var enodes []*discv5.Node
+	var enodes []*enode.Node
 	for _, boot := range strings.Split(*bootFlag, ",") {
-		if url, err := discv5.ParseNode(boot); err == nil {
+		if url, err := enode.ParseV4(boot); err == nil {
 			enodes = append(enodes, url)
 		} else {
 			log.Error("Failed to parse bootnode URL", "url", boot, "err", err)
@@ -212,7 +211,7 @@ type faucet struct {
 	lock sync.RWMutex // Lock protecting the faucet's internals
 }
 
-func newFaucet(genesis *core.Genesis, port int, enodes []*discv5.Node, network uint64, stats string, ks *keystore.KeyStore, index []byte) (*faucet, error) {
+func newFaucet(genesis *core.Genesis, port int, enodes []*enode.Node, network uint64, stats string, ks *keystore.KeyStore, index []byte) (*faucet, error) {
 	// Assemble the raw devp2p protocol stack
 	stack, err := node.New(&node.Config{
 		Name:    "gech",
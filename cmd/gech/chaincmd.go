@@ -27,6 +27,7 @@ import (
 
 	"github.com/etvchaineum/go-etvchaineum/cmd/utils"
 	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
 	"github.com/etvchaineum/go-etvchaineum/console"
 	"github.com/etvchaineum/go-etvchaineum/core"
 	"github.com/etvchaineum/go-etvchaineum/core/state"
@@ -162,11 +163,20 @@ Remove blockchain and state databases`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
+			utils.ExcludeCodeFlag,
+			utils.ExcludeStorageFlag,
+			utils.DumpStartFlag,
+			utils.DumpLimitFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The arguments are interpreted as block numbers or hashes.
-Use "etvchaineum dump 0" to dump the genesis block.`,
+Use "etvchaineum dump 0" to dump the genesis block.
+
+The state is streamed as line-delimited JSON while walking the account trie: the
+first line holds the state root, every further line a single account. Accounts
+are ordered by their hashed key, a dump can be paged through with --start and
+--limit; if the limit is reached, the key to continue from is printed to stderr.`,
 	}
 )
 
@@ -245,8 +255,10 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*echdb.LDBDatabase)
-
+	db, ok := chainDb.(*echdb.LDBDatabase)
+	if !ok {
+		return nil // Database wrapped with a freezer, no LevelDB stats available
+	}
 	stats, err := db.LDB().GetProperty("leveldb.stats")
 	if err != nil {
 		utils.Fatalf("Failed to read database stats: %v", err)
@@ -336,7 +348,7 @@ func importPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*echdb.LDBDatabase)
+	diskdb := utils.MakeChainDatabase(ctx, stack)
 
 	start := time.Now()
 	if err := utils.ImportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -352,7 +364,7 @@ func exportPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*echdb.LDBDatabase)
+	diskdb := utils.MakeChainDatabase(ctx, stack)
 
 	start := time.Now()
 	if err := utils.ExportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -400,12 +412,14 @@ func copyDb(ctx *cli.Context) error {
 	fmt.Printf("Database copy done in %v\n", time.Since(start))
 
 	// Compact the entire database to remove any sync overhead
-	start = time.Now()
-	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*echdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
-		utils.Fatalf("Compaction failed: %v", err)
+	if db, ok := chainDb.(*echdb.LDBDatabase); ok {
+		start = time.Now()
+		fmt.Println("Compacting entire database...")
+		if err = db.LDB().CompactRange(util.Range{}); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+		fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 	}
-	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
 	return nil
 }
@@ -458,7 +472,20 @@ func dump(ctx *cli.Context) error {
 			if err != nil {
 				utils.Fatalf("could not create new state: %v", err)
 			}
-			fmt.Printf("%s\n", state.Dump())
+			var (
+				excludeCode    = ctx.GlobalBool(utils.ExcludeCodeFlag.Name)
+				excludeStorage = ctx.GlobalBool(utils.ExcludeStorageFlag.Name)
+				limit          = ctx.GlobalInt(utils.DumpLimitFlag.Name)
+				start          []byte
+			)
+			if ctx.GlobalIsSet(utils.DumpStartFlag.Name) {
+				if start, err = hexutil.Decode(ctx.GlobalString(utils.DumpStartFlag.Name)); err != nil {
+					utils.Fatalf("invalid start key: %v", err)
+				}
+			}
+			if next := state.IterativeDump(excludeCode, excludeStorage, start, limit, json.NewEncoder(os.Stdout)); next != nil {
+				fmt.Fprintf(os.Stderr, "Dump limit reached, continue with --%s=%s\n", utils.DumpStartFlag.Name, hexutil.Encode(next))
+			}
 		}
 	}
 	chainDb.Close()
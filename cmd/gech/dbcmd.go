@@ -0,0 +1,152 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of go-etvchaineum.
+//
+// go-etvchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-etvchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-etvchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/cmd/utils"
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/rawdb"
+	"github.com/etvchaineum/go-etvchaineum/core/state/pruner"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/olekukonko/tablewriter"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Description: `
+The db command groups the low level maintenance operations that work directly
+on the key-value store of the chain database.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Inspect the storage size for each type of data in the database",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectDatabase),
+				Category:  "DATABASE COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.SyncModeFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+				},
+				Description: `
+The inspect command iterates over the entire chain database once and reports
+the number of entries and the total key and value size stored for each of the
+data types of the database schema (headers, bodies, receipts, transaction
+lookups, bloombits, preimages, trie nodes, etc). Entries which do not belong
+to any known data type are reported as unaccounted.`,
+			},
+			{
+				Name:      "prune-state",
+				Usage:     "Delete the state data not reachable from the most recent blocks",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(pruneState),
+				Category:  "DATABASE COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.SyncModeFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					utils.PruneRetainFlag,
+					utils.BloomFilterSizeFlag,
+				},
+				Description: `
+The prune-state command deletes all the trie nodes and contract codes which are
+not reachable from the state of the genesis block or of the last --prune.retain
+canonical blocks. Only full nodes keep historical state on disk, archive nodes
+should not be pruned.
+
+The reachable state is first marked in a bloom filter of --bloomfilter.size
+megabytes, which is persisted to the data directory before anything is deleted.
+A larger filter reduces the number of stale entries falsely retained.
+
+The node must be stopped during pruning. If the pruning is interrupted, the
+deletion is finished before the chain database is used again, either when the
+node is started or when the command is run again.`,
+			},
+		},
+	}
+)
+
+// inspectDatabase walks the chain database and prints the storage used by
+// each category of data.
+func inspectDatabase(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	stats, err := rawdb.InspectDatabase(db)
+	if err != nil {
+		utils.Fatalf("Failed to inspect database: %v", err)
+	}
+	var (
+		rows  [][]string
+		count uint64
+		total common.StorageSize
+	)
+	for _, stat := range stats {
+		rows = append(rows, []string{stat.Name, fmt.Sprintf("%d", stat.Count), stat.Size.String()})
+		count += stat.Count
+		total += stat.Size
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Category", "Items", "Size"})
+	table.SetFooter([]string{"Total", fmt.Sprintf("%d", count), total.String()})
+	table.AppendBulk(rows)
+	table.Render()
+
+	return nil
+}
+
+// pruneState deletes the state data of the chain database which is not reachable
+// from the most recent blocks anymore, and compacts the database afterwards.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	var (
+		retain    = ctx.GlobalUint64(utils.PruneRetainFlag.Name)
+		bloomSize = ctx.GlobalUint64(utils.BloomFilterSizeFlag.Name)
+	)
+	if err := pruner.NewPruner(db, stack.ResolvePath(""), bloomSize).Prune(retain); err != nil {
+		utils.Fatalf("Failed to prune state: %v", err)
+	}
+	// Deletions only free disk space once the database is compacted
+	if ldb, ok := db.(*echdb.LDBDatabase); ok {
+		start := time.Now()
+		log.Info("Compacting database")
+		if err := ldb.LDB().CompactRange(util.Range{}); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+		log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
@@ -59,6 +59,7 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.DashboardEnabledFlag,
@@ -75,6 +76,8 @@ var (
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolRemoteJournalFlag,
+		utils.TxPoolRemoteJournalLimitFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -82,8 +85,14 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolAllowSendersFlag,
+		utils.TxPoolDenySendersFlag,
+		utils.TxPoolAllowRecipientsFlag,
+		utils.TxPoolDenyRecipientsFlag,
+		utils.TxPoolNoContractCreationFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.TxLookupLimitFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
@@ -100,6 +109,8 @@ var (
 		utils.MinerThreadsFlag,
 		utils.MinerLegacyThreadsFlag,
 		utils.MinerNotifyFlag,
+		utils.MinerStratumFlag,
+		utils.MinerStratumDifficultyFlag,
 		utils.MinerGasTargetFlag,
 		utils.MinerLegacyGasTargetFlag,
 		utils.MinerGasLimitFlag,
@@ -111,10 +122,14 @@ var (
 		utils.MinerLegacyExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerOrderingFlag,
+		utils.MinerPrioritySendersFlag,
+		utils.MinerSenderGasCapFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
 		utils.NetrestrictFlag,
+		utils.DiscoveryDNSFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DeveloperFlag,
@@ -184,6 +199,8 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See dbcmd.go:
+		dbCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -331,6 +348,16 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 			}
 		}
 	}()
+	// Configure the transaction ordering of the miner if requested
+	if ctx.GlobalIsSet(utils.MinerOrderingFlag.Name) || ctx.GlobalIsSet(utils.MinerPrioritySendersFlag.Name) || ctx.GlobalIsSet(utils.MinerSenderGasCapFlag.Name) {
+		var etvchaineum *ech.Etvchain
+		if err := stack.Service(&etvchaineum); err != nil {
+			utils.Fatalf("Etvchain service not running: %v", err)
+		}
+		if err := etvchaineum.Miner().SetOrdering(utils.MakeMinerOrdering(ctx)); err != nil {
+			utils.Fatalf("Failed to set transaction ordering: %v", err)
+		}
+	}
 	// Start auxiliary services if enabled
 	if ctx.GlobalBool(utils.MiningEnabledFlag.Name) || ctx.GlobalBool(utils.DeveloperFlag.Name) {
 		// Mining only makes sense if a full Etvchain node is running
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -33,10 +34,12 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/common"
 	"github.com/etvchaineum/go-etvchaineum/common/fdlimit"
 	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/consensus/bft"
 	"github.com/etvchaineum/go-etvchaineum/consensus/clique"
 	"github.com/etvchaineum/go-etvchaineum/consensus/echash"
 	"github.com/etvchaineum/go-etvchaineum/core"
 	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/state/pruner"
 	"github.com/etvchaineum/go-etvchaineum/core/vm"
 	"github.com/etvchaineum/go-etvchaineum/crypto"
 	"github.com/etvchaineum/go-etvchaineum/dashboard"
@@ -49,9 +52,10 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/metrics"
 	"github.com/etvchaineum/go-etvchaineum/metrics/influxdb"
+	"github.com/etvchaineum/go-etvchaineum/miner"
 	"github.com/etvchaineum/go-etvchaineum/node"
 	"github.com/etvchaineum/go-etvchaineum/p2p"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
+	"github.com/etvchaineum/go-etvchaineum/p2p/dnsdisc"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
 	"github.com/etvchaineum/go-etvchaineum/p2p/nat"
 	"github.com/etvchaineum/go-etvchaineum/p2p/netutil"
@@ -119,6 +123,10 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments, enables moving them out of the chain database",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -176,6 +184,21 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	TxLookupLimitFlag = cli.Uint64Flag{
+		Name:  "txlookuplimit",
+		Usage: "Number of recent blocks to maintain transaction lookup indices for (0 = entire chain)",
+		Value: 0,
+	}
+	PruneRetainFlag = cli.Uint64Flag{
+		Name:  "prune.retain",
+		Usage: "Number of recent blocks whose state to retain during offline state pruning",
+		Value: 128,
+	}
+	BloomFilterSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Usage: "Megabytes of memory allocated to the bloom filter marking state during pruning",
+		Value: 2048,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -263,6 +286,15 @@ var (
 		Usage: "Time interval to regenerate the local transaction journal",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolRemoteJournalFlag = cli.StringFlag{
+		Name:  "txpool.remotejournal",
+		Usage: "Disk snapshot for remote transactions to survive node restarts (disabled if empty)",
+	}
+	TxPoolRemoteJournalLimitFlag = cli.Uint64Flag{
+		Name:  "txpool.remotejournallimit",
+		Usage: "Maximum number of remote transactions to snapshot",
+		Value: core.DefaultTxPoolConfig.RemoteJournalLimit,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -298,6 +330,26 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: ech.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolAllowSendersFlag = cli.StringFlag{
+		Name:  "txpool.allowsenders",
+		Usage: "Comma separated accounts allowed to submit transactions (default = anyone)",
+	}
+	TxPoolDenySendersFlag = cli.StringFlag{
+		Name:  "txpool.denysenders",
+		Usage: "Comma separated accounts whose transactions are rejected",
+	}
+	TxPoolAllowRecipientsFlag = cli.StringFlag{
+		Name:  "txpool.allowrecipients",
+		Usage: "Comma separated accounts transactions may be sent to (default = anyone)",
+	}
+	TxPoolDenyRecipientsFlag = cli.StringFlag{
+		Name:  "txpool.denyrecipients",
+		Usage: "Comma separated accounts transactions are rejected to",
+	}
+	TxPoolNoContractCreationFlag = cli.BoolFlag{
+		Name:  "txpool.nocreate",
+		Usage: "Rejects all contract creation transactions",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -343,6 +395,14 @@ var (
 		Name:  "miner.notify",
 		Usage: "Comma separated HTTP URL list to notify of new work packages",
 	}
+	MinerStratumFlag = cli.StringFlag{
+		Name:  "miner.stratum",
+		Usage: "Listening address of the stratum mining server for remote miners (e.g. :8008)",
+	}
+	MinerStratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "miner.stratumdiff",
+		Usage: "Difficulty of the shares accepted by the stratum mining server (default = 2^32)",
+	}
 	MinerGasTargetFlag = cli.Uint64Flag{
 		Name:  "miner.gastarget",
 		Usage: "Target gas floor for mined blocks",
@@ -395,6 +455,19 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: `Transaction ordering strategy for mined blocks ("price", "priority" or "fifo")`,
+		Value: miner.DefaultOrderingConfig.Strategy,
+	}
+	MinerPrioritySendersFlag = cli.StringFlag{
+		Name:  "miner.prioritysenders",
+		Usage: "Comma separated accounts whose transactions are included first by the priority ordering",
+	}
+	MinerSenderGasCapFlag = cli.Uint64Flag{
+		Name:  "miner.sendergascap",
+		Usage: "Maximum gas the transactions of a single sender may use in a mined block (0 = unlimited)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -424,6 +497,22 @@ var (
 		Name:  "nocompaction",
 		Usage: "Disables db compaction after import",
 	}
+	ExcludeCodeFlag = cli.BoolFlag{
+		Name:  "nocode",
+		Usage: "Exclude contract code from state dumps (save db iterations)",
+	}
+	ExcludeStorageFlag = cli.BoolFlag{
+		Name:  "nostorage",
+		Usage: "Exclude storage entries from state dumps (save db iterations)",
+	}
+	DumpStartFlag = cli.StringFlag{
+		Name:  "start",
+		Usage: "Hashed account key (hex) to start the state dump from",
+	}
+	DumpLimitFlag = cli.IntFlag{
+		Name:  "limit",
+		Usage: "Maximum number of accounts to dump (0 = unlimited)",
+	}
 	// RPC settings
 	RPCEnabledFlag = cli.BoolFlag{
 		Name:  "rpc",
@@ -551,6 +640,11 @@ var (
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	DiscoveryDNSFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists used for P2P discovery",
+		Value: "",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
@@ -743,9 +837,9 @@ func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 		return // already set, don't apply defaults.
 	}
 
-	cfg.BootstrapNodesV5 = make([]*discv5.Node, 0, len(urls))
+	cfg.BootstrapNodesV5 = make([]*enode.Node, 0, len(urls))
 	for _, url := range urls {
-		node, err := discv5.ParseNode(url)
+		node, err := enode.ParseV4(url)
 		if err != nil {
 			log.Error("Bootstrap URL invalid", "enode", url, "err", err)
 			continue
@@ -975,6 +1069,15 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NetRestrict = list
 	}
+	if urls := ctx.GlobalString(DiscoveryDNSFlag.Name); urls != "" {
+		cfg.DiscoveryDNS = nil
+		for _, url := range strings.Split(urls, ",") {
+			if _, _, err := dnsdisc.ParseURL(url); err != nil {
+				Fatalf("Option %q: invalid URL %q: %v", DiscoveryDNSFlag.Name, url, err)
+			}
+			cfg.DiscoveryDNS = append(cfg.DiscoveryDNS, url)
+		}
+	}
 
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
@@ -1049,6 +1152,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolRemoteJournalFlag.Name) {
+		cfg.RemoteJournal = ctx.GlobalString(TxPoolRemoteJournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolRemoteJournalLimitFlag.Name) {
+		cfg.RemoteJournalLimit = ctx.GlobalUint64(TxPoolRemoteJournalLimitFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1070,6 +1179,53 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolAllowSendersFlag.Name) {
+		cfg.AllowedSenders = splitAccounts(ctx, TxPoolAllowSendersFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolDenySendersFlag.Name) {
+		cfg.DeniedSenders = splitAccounts(ctx, TxPoolDenySendersFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolAllowRecipientsFlag.Name) {
+		cfg.AllowedRecipients = splitAccounts(ctx, TxPoolAllowRecipientsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolDenyRecipientsFlag.Name) {
+		cfg.DeniedRecipients = splitAccounts(ctx, TxPoolDenyRecipientsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolNoContractCreationFlag.Name) {
+		cfg.NoContractCreation = ctx.GlobalBool(TxPoolNoContractCreationFlag.Name)
+	}
+}
+
+// splitAccounts parses the comma separated list of accounts of the given flag.
+func splitAccounts(ctx *cli.Context, name string) []common.Address {
+	var accounts []common.Address
+	for _, account := range strings.Split(ctx.GlobalString(name), ",") {
+		trimmed := strings.TrimSpace(account)
+		if !common.IsHexAddress(trimmed) {
+			Fatalf("Invalid account in --%s: %s", name, trimmed)
+		}
+		accounts = append(accounts, common.HexToAddress(trimmed))
+	}
+	return accounts
+}
+
+// MakeMinerOrdering creates the transaction ordering configuration of the miner
+// from the command line flags.
+func MakeMinerOrdering(ctx *cli.Context) miner.OrderingConfig {
+	config := miner.DefaultOrderingConfig
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		config.Strategy = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerPrioritySendersFlag.Name) {
+		config.PrioritySenders = splitAccounts(ctx, MinerPrioritySendersFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerSenderGasCapFlag.Name) {
+		config.SenderGasCap = ctx.GlobalUint64(MinerSenderGasCapFlag.Name)
+	}
+	if _, err := miner.NewTxOrdering(config, nil); err != nil {
+		Fatalf("Invalid --%s: %v", MinerOrderingFlag.Name, err)
+	}
+	return config
 }
 
 func setEthash(ctx *cli.Context, cfg *ech.Config) {
@@ -1091,6 +1247,19 @@ func setEthash(ctx *cli.Context, cfg *ech.Config) {
 	if ctx.GlobalIsSet(EthashDatasetsOnDiskFlag.Name) {
 		cfg.Ethash.DatasetsOnDisk = ctx.GlobalInt(EthashDatasetsOnDiskFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerStratumFlag.Name) {
+		addr := ctx.GlobalString(MinerStratumFlag.Name)
+		// The engine only logs a failure to listen, refuse to start without the server
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			Fatalf("Failed to listen on stratum address %s: %v", addr, err)
+		}
+		listener.Close()
+		cfg.Ethash.StratumAddr = addr
+	}
+	if ctx.GlobalIsSet(MinerStratumDifficultyFlag.Name) {
+		cfg.Ethash.StratumDifficulty = ctx.GlobalUint64(MinerStratumDifficultyFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *ech.Config) {
@@ -1205,6 +1374,10 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ech.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
+		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1323,6 +1496,18 @@ func RegisterEthService(stack *node.Node, cfg *ech.Config) {
 		})
 	} else {
 		err = stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+			// Finish an interrupted state pruning before the chain is touched
+			if datadir := ctx.ResolvePath(""); datadir != "" && pruner.PruningPending(datadir) {
+				db, err := ctx.OpenDatabase("chaindata", cfg.DatabaseCache, cfg.DatabaseHandles)
+				if err != nil {
+					return nil, err
+				}
+				err = pruner.RecoverPruning(datadir, db)
+				db.Close()
+				if err != nil {
+					return nil, fmt.Errorf("failed to finish interrupted state pruning: %v", err)
+				}
+			}
 			fullNode, err := ech.New(ctx, cfg)
 			if fullNode != nil && cfg.LightServ > 0 {
 				ls, _ := les.NewLesServer(fullNode, cfg)
@@ -1413,14 +1598,28 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) echdb.Database {
 		cache   = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 		handles = makeDatabaseHandles()
 	)
-	name := "chaindata"
-	if ctx.GlobalString(SyncModeFlag.Name) == "light" {
-		name = "lightchaindata"
+	var (
+		chainDb echdb.Database
+		err     error
+	)
+	switch {
+	case ctx.GlobalString(SyncModeFlag.Name) == "light":
+		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles)
+	case ctx.GlobalIsSet(AncientFlag.Name):
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ctx.GlobalString(AncientFlag.Name))
+	default:
+		chainDb, err = stack.OpenDatabase("chaindata", cache, handles)
 	}
-	chainDb, err := stack.OpenDatabase(name, cache, handles)
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	// Finish an interrupted state pruning, processing blocks on top of a partially
+	// swept database would corrupt it for good
+	if datadir := stack.ResolvePath(""); datadir != "" && ctx.GlobalString(SyncModeFlag.Name) != "light" {
+		if err := pruner.RecoverPruning(datadir, chainDb); err != nil {
+			Fatalf("Failed to finish interrupted state pruning: %v", err)
+		}
+	}
 	return chainDb
 }
 
@@ -1450,6 +1649,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var engine consensus.Engine
 	if config.Clique != nil {
 		engine = clique.New(config.Clique, chainDb)
+	} else if config.BFT != nil {
+		engine = bft.New(config.BFT, chainDb)
 	} else {
 		engine = echash.NewFaker()
 		if !ctx.GlobalBool(FakePoWFlag.Name) {
@@ -1471,6 +1672,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		TrieCleanLimit: ech.DefaultConfig.TrieCleanCache,
 		TrieDirtyLimit: ech.DefaultConfig.TrieDirtyCache,
 		TrieTimeLimit:  ech.DefaultConfig.TrieTimeout,
+		TxLookupLimit:  ctx.GlobalUint64(TxLookupLimitFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cache.TrieCleanLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
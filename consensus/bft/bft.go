@@ -0,0 +1,779 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bft implements a Byzantine fault tolerant consensus engine with
+// immediate finality.
+//
+// A fixed set of validators agrees on every block in rounds of three phases. The
+// proposer of the round broadcasts its block (preprepare), the validators accept
+// it (prepare) and, once a quorum of them did so, commit to it (commit). A block
+// is final as soon as it carries the commit seals of a quorum of validators, so
+// there are never competing forks to reorganise to. If a round doesn't complete
+// in time, the validators move on to the next round with a different proposer.
+//
+// The validator set is stored in the extra-data of every header and changed via
+// votes cast by the proposers, similarly to how clique handles its signers.
+package bft
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/accounts"
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/consensus/misc"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/params"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+	"github.com/etvchaineum/go-etvchaineum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the validator snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent validator snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+
+	defaultEpoch          = 30000 // Default number of blocks after which to checkpoint and reset the pending votes
+	defaultRequestTimeout = 10000 // Default milliseconds before a consensus round is abandoned
+)
+
+// BFT protocol constants.
+var (
+	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for proposer vanity
+
+	nonceAuthVote = hexutil.MustDecode("0xffffffffffffffff") // Magic nonce number to vote on adding a new validator
+	nonceDropVote = hexutil.MustDecode("0x0000000000000000") // Magic nonce number to vote on removing a validator
+
+	// mixDigest is the fixed mix digest of the BFT blocks, identifying them as such.
+	mixDigest = crypto.Keccak256Hash([]byte("bft consensus"))
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	// difficulty is the fixed difficulty of every block, as there are no forks
+	// to choose from with immediate finality.
+	difficulty = big.NewInt(1)
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errInvalidCheckpointBeneficiary is returned if a checkpoint/epoch transition
+	// block has a beneficiary set to non-zeroes.
+	errInvalidCheckpointBeneficiary = errors.New("beneficiary in checkpoint block non-zero")
+
+	// errInvalidVote is returned if a nonce value is someching else that the two
+	// allowed constants of 0x00..0 or 0xff..f.
+	errInvalidVote = errors.New("vote nonce not 0x00..0 or 0xff..f")
+
+	// errInvalidCheckpointVote is returned if a checkpoint/epoch transition block
+	// has a vote nonce set to non-zeroes.
+	errInvalidCheckpointVote = errors.New("vote nonce in checkpoint block non-zero")
+
+	// errInvalidExtraData is returned if a block's extra-data section is not a
+	// vanity prefix followed by the RLP encoded consensus fields.
+	errInvalidExtraData = errors.New("invalid extra-data")
+
+	// errMismatchingValidators is returned if a block contains a list of
+	// validators different than the one the local node calculated.
+	errMismatchingValidators = errors.New("mismatching validator list")
+
+	// errInvalidMixDigest is returned if a block's mix digest is not the BFT one.
+	errInvalidMixDigest = errors.New("invalid mix digest")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// ErrInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidVotingChain is returned if a validator list is attempted to be
+	// modified via out-of-range or non-contiguous headers.
+	errInvalidVotingChain = errors.New("invalid voting chain")
+
+	// errUnauthorizedValidator is returned if a header is proposed or committed
+	// by a non-authorized entity.
+	errUnauthorizedValidator = errors.New("unauthorized validator")
+
+	// errInvalidCommittedSeals is returned if a committed seal of a header is
+	// invalid or duplicated.
+	errInvalidCommittedSeals = errors.New("invalid committed seals")
+
+	// errInsufficientCommittedSeals is returned if a header isn't committed by a
+	// quorum of the validators.
+	errInsufficientCommittedSeals = errors.New("insufficient committed seals")
+
+	// errNotStarted is returned if the consensus messages are handled or blocks
+	// sealed before the engine is started.
+	errNotStarted = errors.New("engine not started")
+)
+
+// SignerFn is a signer callback function to request a hash to be signed by a
+// backing account.
+type SignerFn func(accounts.Account, []byte) ([]byte, error)
+
+// bftExtra is the consensus data stored in the extra-data of the headers after
+// the vanity prefix.
+type bftExtra struct {
+	Validators     []common.Address // Validators agreeing on the block, in ascending order
+	Seal           []byte           // Signature of the proposer over the seal hash
+	CommittedSeals [][]byte         // Commit signatures of the validators over the seal hash
+}
+
+// extractExtra decodes the consensus fields from the extra-data of a header.
+func extractExtra(header *types.Header) (*bftExtra, error) {
+	if len(header.Extra) < extraVanity {
+		return nil, errInvalidExtraData
+	}
+	extra := new(bftExtra)
+	if err := rlp.DecodeBytes(header.Extra[extraVanity:], extra); err != nil {
+		return nil, errInvalidExtraData
+	}
+	return extra, nil
+}
+
+// encodeExtra assembles the extra-data of a header from the vanity prefix and the
+// consensus fields.
+func encodeExtra(vanity []byte, extra *bftExtra) ([]byte, error) {
+	payload, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, extraVanity, extraVanity+len(payload))
+	copy(blob, vanity)
+	return append(blob, payload...), nil
+}
+
+// sigHash returns the hash which is used as input for both the proposer and the
+// committed seals. It is the hash of the entire header with the seals stripped
+// from the extra-data.
+func sigHash(header *types.Header) (hash common.Hash) {
+	extra, err := extractExtra(header)
+	if err != nil {
+		return common.Hash{}
+	}
+	stripped := types.CopyHeader(header)
+	stripped.Extra, _ = encodeExtra(header.Extra[:extraVanity], &bftExtra{Validators: extra.Validators})
+
+	return stripped.Hash()
+}
+
+// commitHash returns the hash the validators sign to commit to a block with the
+// given seal hash, distinct from the proposer seal.
+func commitHash(hash common.Hash) []byte {
+	return crypto.Keccak256(hash.Bytes(), []byte{msgCommit})
+}
+
+// recoverAddress extracts the Etvchain account address from a signature.
+func recoverAddress(hash []byte, sig []byte) (common.Address, error) {
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// ecrecover extracts the Etvchain account address of the proposer from a sealed
+// header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	extra, err := extractExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer, err := recoverAddress(sigHash(header).Bytes(), extra.Seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// quorum returns the number of validators that need to agree on a block from a
+// set of the given size, tolerating less than a third of them being faulty.
+func quorum(validators int) int {
+	return (2*validators + 2) / 3
+}
+
+// BFT is the Byzantine fault tolerant consensus engine, sealing blocks by the
+// agreement of a quorum of validators.
+type BFT struct {
+	config *params.BFTConfig // Consensus engine configuration parameters
+	db     echdb.Database    // Database to store and retrieve snapshot checkpoints
+
+	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
+	signatures *lru.ARCCache // Signatures of recent blocks to speed up verification
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+
+	signer common.Address // Etvchain address of the signing key
+	signFn SignerFn       // Signer function to authorize hashes with
+	lock   sync.RWMutex   // Protects the signer fields
+
+	peers *peerSet      // Remote nodes the consensus messages are exchanged with
+	known *lru.ARCCache // Hashes of the consensus messages already seen
+
+	core     *stateMachine // Consensus state machine, running while the engine is started
+	coreLock sync.Mutex    // Protects the consensus state machine
+}
+
+// New creates a BFT consensus engine with the initial validators set to the ones
+// in the genesis block.
+func New(config *params.BFTConfig, db echdb.Database) *BFT {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = defaultEpoch
+	}
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = defaultRequestTimeout
+	}
+	// Allocate the snapshot caches and create the engine
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+	known, _ := lru.NewARC(knownMessages)
+
+	// Restore the proposals the validator was pushing before a restart
+	proposals, err := misc.LoadProposals(db, proposalsKey)
+	if err != nil {
+		log.Warn("Failed to load BFT proposals", "err", err)
+	} else if len(proposals) > 0 {
+		log.Info("Loaded BFT proposals", "proposals", len(proposals))
+	}
+	return &BFT{
+		config:     &conf,
+		db:         db,
+		recents:    recents,
+		signatures: signatures,
+		proposals:  proposals,
+		peers:      newPeerSet(),
+		known:      known,
+	}
+}
+
+// Author implements consensus.Engine, returning the Etvchain address recovered
+// from the proposer seal in the header's extra-data section.
+func (b *BFT) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, b.signatures)
+}
+
+// VerifyHeader checks whetvchain a header conforms to the consensus rules.
+func (b *BFT) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return b.verifyHeader(chain, header, nil, true)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// mechod returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (b *BFT) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := b.verifyHeader(chain, header, headers[:i], true)
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whetvchain a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database. Proposals are verified without requiring
+// the committed seals, which are only gathered during the agreement on them.
+func (b *BFT) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header, committed bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return consensus.ErrFutureBlock
+	}
+	// Checkpoint blocks need to enforce zero beneficiary
+	checkpoint := (number % b.config.Epoch) == 0
+	if checkpoint && header.Coinbase != (common.Address{}) {
+		return errInvalidCheckpointBeneficiary
+	}
+	// Nonces must be 0x00..0 or 0xff..f, zeroes enforced on checkpoints
+	if !bytes.Equal(header.Nonce[:], nonceAuthVote) && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidVote
+	}
+	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidCheckpointVote
+	}
+	// Ensure that the extra-data contains the consensus fields
+	if _, err := extractExtra(header); err != nil {
+		return err
+	}
+	if header.MixDigest != mixDigest {
+		return errInvalidMixDigest
+	}
+	// Ensure that the block doesn't contain any uncles which are meaningless in BFT
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	if number > 0 && (header.Difficulty == nil || header.Difficulty.Cmp(difficulty) != 0) {
+		return errInvalidDifficulty
+	}
+	// If all checks passed, validate any special fields for hard forks
+	if err := misc.VerifyForkHashes(chain.Config(), header, false); err != nil {
+		return err
+	}
+	// All basic checks passed, verify cascading fields
+	return b.verifyCascadingFields(chain, header, parents, committed)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on a batch of previous headers.
+func (b *BFT) verifyCascadingFields(chain consensus.ChainReader, header *types.Header, parents []*types.Header, committed bool) error {
+	// The genesis block is the always valid dead-end
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	// Ensure that the block's timestamp isn't too close to it's parent
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time.Uint64()+b.config.Period > header.Time.Uint64() {
+		return ErrInvalidTimestamp
+	}
+	// Ensure the block lists the validators agreeing on it
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, parents)
+	if err != nil {
+		return err
+	}
+	extra, _ := extractExtra(header)
+	if !equalValidators(extra.Validators, snap.validators()) {
+		return errMismatchingValidators
+	}
+	// All basic checks passed, verify the seals and return
+	return b.verifySeals(snap, header, committed)
+}
+
+// equalValidators checks whetvchain two validator lists are identical.
+func equalValidators(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot retrieves the validator snapshot at a given point in time.
+func (b *BFT) snapshot(chain consensus.ChainReader, number uint64, hash common.Hash, parents []*types.Header) (*Snapshot, error) {
+	// Search for a snapshot in memory or on disk for checkpoints
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		if s, ok := b.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(b.config, b.signatures, b.db, hash); err == nil {
+				log.Trace("Loaded validator snapshot from disk", "number", number, "hash", hash)
+				snap = s
+				break
+			}
+		}
+		// If we're at the genesis, snapshot the initial validator set
+		if number == 0 {
+			genesis := chain.GetHeaderByNumber(0)
+			if genesis == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			extra, err := extractExtra(genesis)
+			if err != nil {
+				return nil, err
+			}
+			snap = newSnapshot(b.config, b.signatures, 0, genesis.Hash(), extra.Validators)
+			if err := snap.store(b.db); err != nil {
+				return nil, err
+			}
+			log.Info("Stored genesis validator snapshot to disk", "validators", len(extra.Validators))
+			break
+		}
+		// No snapshot for this header, gather the header and move backward
+		var header *types.Header
+		if len(parents) > 0 {
+			// If we have explicit parents, pick from there (enforced)
+			header = parents[len(parents)-1]
+			if header.Hash() != hash || header.Number.Uint64() != number {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			parents = parents[:len(parents)-1]
+		} else {
+			// No explicit parents (or no more left), reach out to the database
+			header = chain.GetHeader(hash, number)
+			if header == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+		}
+		headers = append(headers, header)
+		number, hash = number-1, header.ParentHash
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	b.recents.Add(snap.Hash, snap)
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
+		if err = snap.store(b.db); err != nil {
+			return nil, err
+		}
+		log.Trace("Stored validator snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+	}
+	return snap, err
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (b *BFT) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking whetvchain the header was
+// proposed by a validator and committed by a quorum of them.
+func (b *BFT) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	// Verifying the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	return b.verifySeals(snap, header, true)
+}
+
+// verifySeals checks whetvchain the header was proposed by a validator of the
+// given snapshot and, if requested, committed by a quorum of them.
+func (b *BFT) verifySeals(snap *Snapshot, header *types.Header, committed bool) error {
+	proposer, err := ecrecover(header, b.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[proposer]; !ok {
+		return errUnauthorizedValidator
+	}
+	if !committed {
+		return nil
+	}
+	extra, err := extractExtra(header)
+	if err != nil {
+		return err
+	}
+	var (
+		hash       = commitHash(sigHash(header))
+		committers = make(map[common.Address]struct{})
+	)
+	for _, seal := range extra.CommittedSeals {
+		validator, err := recoverAddress(hash, seal)
+		if err != nil {
+			return errInvalidCommittedSeals
+		}
+		if _, ok := snap.Validators[validator]; !ok {
+			return errUnauthorizedValidator
+		}
+		if _, ok := committers[validator]; ok {
+			return errInvalidCommittedSeals
+		}
+		committers[validator] = struct{}{}
+	}
+	if len(committers) < quorum(len(snap.Validators)) {
+		return errInsufficientCommittedSeals
+	}
+	return nil
+}
+
+// verifyProposal checks whetvchain a block proposed for agreement conforms to the
+// consensus rules, apart from the committed seals it cannot have yet. The state
+// transition is verified when the committed block is imported.
+func (b *BFT) verifyProposal(chain consensus.ChainReader, block *types.Block) error {
+	if err := b.verifyHeader(chain, block.Header(), nil, false); err != nil {
+		return err
+	}
+	if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+		return errors.New("transaction root hash mismatch")
+	}
+	return b.VerifyUncles(chain, block)
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (b *BFT) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	// If the block isn't a checkpoint, cast a random vote (good enough for now)
+	header.Coinbase = common.Address{}
+	header.Nonce = types.BlockNonce{}
+
+	number := header.Number.Uint64()
+	// Assemble the validator snapshot to check which votes make sense
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	if number%b.config.Epoch != 0 {
+		b.lock.RLock()
+
+		// Gather all the proposals that make sense voting on
+		addresses := make([]common.Address, 0, len(b.proposals))
+		for address, authorize := range b.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		// If there's pending proposals, cast a vote on them
+		if len(addresses) > 0 {
+			header.Coinbase = addresses[rand.Intn(len(addresses))]
+			if b.proposals[header.Coinbase] {
+				copy(header.Nonce[:], nonceAuthVote)
+			} else {
+				copy(header.Nonce[:], nonceDropVote)
+			}
+		}
+		b.lock.RUnlock()
+	}
+	header.Difficulty = new(big.Int).Set(difficulty)
+	header.MixDigest = mixDigest
+
+	// Ensure the extra data has the vanity and the agreeing validators
+	vanity := header.Extra
+	if len(vanity) > extraVanity {
+		vanity = vanity[:extraVanity]
+	}
+	if header.Extra, err = encodeExtra(vanity, &bftExtra{Validators: snap.validators()}); err != nil {
+		return err
+	}
+	// Ensure the timestamp has the correct delay
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(b.config.Period))
+	if header.Time.Int64() < time.Now().Unix() {
+		header.Time = big.NewInt(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given, and returns the final block.
+func (b *BFT) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// No block rewards in BFT, so the state remains as is and uncles are dropped
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+
+	// Assemble and return the final block for sealing
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+// Authorize injects a private key into the consensus engine to propose blocks
+// and sign consensus messages with.
+func (b *BFT) Authorize(signer common.Address, signFn SignerFn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.signer = signer
+	b.signFn = signFn
+}
+
+// sign signs a hash with the local validator key.
+func (b *BFT) sign(hash []byte) (common.Address, []byte, error) {
+	b.lock.RLock()
+	signer, signFn := b.signer, b.signFn
+	b.lock.RUnlock()
+
+	if signFn == nil {
+		return common.Address{}, nil, errUnauthorizedValidator
+	}
+	sig, err := signFn(accounts.Account{Address: signer}, hash)
+	return signer, sig, err
+}
+
+// Seal implements consensus.Engine, signing the block as its proposer and handing
+// it to the consensus state machine. The sealed block is delivered once a quorum
+// of the validators committed to it, if ever.
+func (b *BFT) Seal(chain consensus.ChainReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	b.coreLock.Lock()
+	c := b.core
+	b.coreLock.Unlock()
+	if c == nil {
+		return errNotStarted
+	}
+	// Bail out if we're unauthorized to propose a block
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	b.lock.RLock()
+	signer := b.signer
+	b.lock.RUnlock()
+
+	if _, authorized := snap.Validators[signer]; !authorized {
+		return errUnauthorizedValidator
+	}
+	// Sign the block as its proposer and wait for its time to hand it over
+	extra, err := extractExtra(header)
+	if err != nil {
+		return err
+	}
+	if _, extra.Seal, err = b.sign(sigHash(header).Bytes()); err != nil {
+		return err
+	}
+	if header.Extra, err = encodeExtra(header.Extra[:extraVanity], extra); err != nil {
+		return err
+	}
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now()) // nolint: gosimple
+
+	log.Trace("Waiting for slot to propose", "number", number, "delay", common.PrettyDuration(delay))
+	go func() {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		c.request(&sealRequest{block: block.WithSeal(header), results: results, stop: stop})
+	}()
+	return nil
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
+// that a new block should have, which is constant for BFT.
+func (b *BFT) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(difficulty)
+}
+
+// SealHash returns the hash of a block prior to it being sealed.
+func (b *BFT) SealHash(header *types.Header) common.Hash {
+	return sigHash(header)
+}
+
+// Start implements consensus.Handler, starting the consensus state machine which
+// processes the messages of the other validators.
+func (b *BFT) Start(chain consensus.ChainReader) error {
+	b.coreLock.Lock()
+	defer b.coreLock.Unlock()
+
+	if b.core != nil {
+		return nil
+	}
+	b.core = newStateMachine(b, chain)
+	return nil
+}
+
+// Stop implements consensus.Handler, stopping the consensus state machine.
+func (b *BFT) Stop() error {
+	b.coreLock.Lock()
+	defer b.coreLock.Unlock()
+
+	if b.core != nil {
+		b.core.stop()
+		b.core = nil
+	}
+	return nil
+}
+
+// NewChainHead implements consensus.Handler, moving the agreement on to the block
+// following the new head.
+func (b *BFT) NewChainHead(head *types.Header) {
+	b.coreLock.Lock()
+	c := b.core
+	b.coreLock.Unlock()
+
+	if c != nil {
+		c.newHead(head)
+	}
+}
+
+// Close implements consensus.Engine, stopping the consensus state machine.
+func (b *BFT) Close() error {
+	return b.Stop()
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling the validator voting.
+func (b *BFT) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "bft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, bft: b},
+		Public:    false,
+	}}
+}
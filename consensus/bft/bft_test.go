@@ -0,0 +1,447 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/accounts"
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/core/vm"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/p2p"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+	"github.com/etvchaineum/go-etvchaineum/params"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+// testerValidator is a validator node with its own chain and consensus engine.
+type testerValidator struct {
+	key    *ecdsa.PrivateKey
+	addr   common.Address
+	engine *BFT
+	chain  *core.BlockChain
+}
+
+// newTesterNetwork creates the given number of validators sharing the same
+// genesis block, ordered by address so the proposer rotation is predictable.
+func newTesterNetwork(t *testing.T, n int) []*testerValidator {
+	validators := make([]*testerValidator, n)
+	for i := range validators {
+		key, _ := crypto.GenerateKey()
+		validators[i] = &testerValidator{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return validatorsAscending{validators[i].addr, validators[j].addr}.Less(0, 1)
+	})
+	addrs := make([]common.Address, n)
+	for i, validator := range validators {
+		addrs[i] = validator.addr
+	}
+	extra, err := encodeExtra(nil, &bftExtra{Validators: addrs})
+	if err != nil {
+		t.Fatalf("failed to encode genesis extra-data: %v", err)
+	}
+	config := *params.TestChainConfig
+	config.BFT = &params.BFTConfig{Period: 0, Epoch: 30000, RequestTimeout: 200}
+
+	genesis := &core.Genesis{Config: &config, ExtraData: extra, GasLimit: params.GenesisGasLimit}
+	for _, validator := range validators {
+		db := echdb.NewMemDatabase()
+		genesis.MustCommit(db)
+
+		validator.engine = New(config.BFT, db)
+		key := validator.key
+		validator.engine.Authorize(validator.addr, func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+		if validator.chain, err = core.NewBlockChain(db, nil, &config, validator.engine, vm.Config{}, nil); err != nil {
+			t.Fatalf("failed to create validator chain: %v", err)
+		}
+	}
+	return validators
+}
+
+// connect links the consensus protocols of two validators.
+func connect(a, b *testerValidator) {
+	connectFiltered(a, b, nil)
+}
+
+// connectFiltered links the consensus protocols of two validators, dropping the
+// messages matching the filter on their way.
+func connectFiltered(a, b *testerValidator, drop func(*message) bool) {
+	rwa, rwb := p2p.MsgPipe()
+	go a.engine.runPeer(p2p.NewPeer(enode.PubkeyToIDV4(&b.key.PublicKey), "b", nil), &filterRW{rwa, drop})
+	go b.engine.runPeer(p2p.NewPeer(enode.PubkeyToIDV4(&a.key.PublicKey), "a", nil), &filterRW{rwb, drop})
+}
+
+// filterRW is a message pipe end silently dropping the consensus messages read
+// from it which match a filter.
+type filterRW struct {
+	p2p.MsgReadWriter
+	drop func(*message) bool
+}
+
+func (rw *filterRW) ReadMsg() (p2p.Msg, error) {
+	for {
+		msg, err := rw.MsgReadWriter.ReadMsg()
+		if err != nil || rw.drop == nil {
+			return msg, err
+		}
+		var payload []byte
+		if err := msg.Decode(&payload); err != nil {
+			return msg, err
+		}
+		if decoded, err := decodeMessage(payload); err == nil && rw.drop(decoded) {
+			continue
+		}
+		blob, _ := rlp.EncodeToBytes(payload)
+		return p2p.Msg{Code: msg.Code, Size: uint32(len(blob)), Payload: bytes.NewReader(blob)}, nil
+	}
+}
+
+// newBlock assembles a proposal of the given validator on top of its chain head,
+// tagged with the validator index so the proposals of the validators differ.
+func (v *testerValidator) newBlock(t *testing.T, tag byte) *types.Block {
+	parent := v.chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Extra:      []byte{tag},
+	}
+	if err := v.engine.Prepare(v.chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	statedb, err := v.chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	block, err := v.engine.Finalize(v.chain, header, statedb, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to finalize block: %v", err)
+	}
+	return block
+}
+
+// sealBlock requests all the online validators to seal a block on top of their
+// chain heads and waits for the committed block, importing it everywhere.
+func sealBlock(t *testing.T, online []*testerValidator) *types.Block {
+	var (
+		results = make(chan *types.Block, len(online))
+		stop    = make(chan struct{})
+	)
+	defer close(stop)
+
+	for i, validator := range online {
+		if err := validator.engine.Seal(validator.chain, validator.newBlock(t, byte(i)), results, stop); err != nil {
+			t.Fatalf("validator %d: failed to seal block: %v", i, err)
+		}
+	}
+	var block *types.Block
+	select {
+	case block = <-results:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("block not committed")
+	}
+	for i, validator := range online {
+		if _, err := validator.chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("validator %d: failed to import committed block: %v", i, err)
+		}
+		validator.engine.NewChainHead(block.Header())
+	}
+	return block
+}
+
+// Tests that the validators agree on consecutive blocks, rotating the proposer.
+func TestCommit(t *testing.T) {
+	validators := newTesterNetwork(t, 4)
+	for i := 0; i < len(validators); i++ {
+		for j := i + 1; j < len(validators); j++ {
+			connect(validators[i], validators[j])
+		}
+	}
+	for _, validator := range validators {
+		defer validator.chain.Stop()
+		validator.engine.Start(validator.chain)
+		defer validator.engine.Stop()
+	}
+	for number := uint64(1); number <= 4; number++ {
+		block := sealBlock(t, validators)
+		if block.NumberU64() != number {
+			t.Fatalf("committed block number mismatch: have %d, want %d", block.NumberU64(), number)
+		}
+		proposer, err := validators[0].engine.Author(block.Header())
+		if err != nil {
+			t.Fatalf("block %d: failed to recover proposer: %v", number, err)
+		}
+		if want := validators[number%4].addr; proposer != want {
+			t.Errorf("block %d: proposer mismatch: have %x, want %x", number, proposer, want)
+		}
+		extra, _ := extractExtra(block.Header())
+		if len(extra.CommittedSeals) < quorum(len(validators)) {
+			t.Errorf("block %d: committed seals mismatch: have %d, want at least %d", number, len(extra.CommittedSeals), quorum(len(validators)))
+		}
+	}
+}
+
+// Tests that the validators move on to the next round and proposer if the
+// proposer of the current round is offline.
+func TestRoundChange(t *testing.T) {
+	validators := newTesterNetwork(t, 4)
+	for _, validator := range validators {
+		defer validator.chain.Stop()
+	}
+	// Take the proposer of the first block offline
+	offline := validators[1]
+	online := []*testerValidator{validators[0], validators[2], validators[3]}
+	for i := 0; i < len(online); i++ {
+		for j := i + 1; j < len(online); j++ {
+			connect(online[i], online[j])
+		}
+	}
+	for _, validator := range online {
+		validator.engine.Start(validator.chain)
+		defer validator.engine.Stop()
+	}
+	block := sealBlock(t, online)
+
+	proposer, err := validators[0].engine.Author(block.Header())
+	if err != nil {
+		t.Fatalf("failed to recover proposer: %v", err)
+	}
+	if proposer == offline.addr {
+		t.Fatalf("block proposed by offline validator")
+	}
+	extra, _ := extractExtra(block.Header())
+	if len(extra.CommittedSeals) != len(online) {
+		t.Errorf("committed seals mismatch: have %d, want %d", len(extra.CommittedSeals), len(online))
+	}
+}
+
+// Tests that a block locked on by the validators is committed and imported if it
+// is re-proposed after its original proposer went offline.
+func TestLockedBlockReproposal(t *testing.T) {
+	validators := newTesterNetwork(t, 4)
+	for _, validator := range validators {
+		defer validator.chain.Stop()
+	}
+	// Drop the commits of the first round, signalling once every validator locked.
+	// The filter outlives the test in the peer goroutines, so it must not hold on
+	// to the validators and their chains.
+	var (
+		total     = len(validators)
+		lock      sync.Mutex
+		committed = make(map[common.Address]struct{})
+		locked    = make(chan struct{})
+		signal    sync.Once
+	)
+	drop := func(msg *message) bool {
+		if msg.Code != msgCommit || msg.Round != 0 {
+			return false
+		}
+		lock.Lock()
+		defer lock.Unlock()
+
+		if signer, err := recoverAddress(commitHash(msg.Digest), msg.CommittedSeal); err == nil {
+			committed[signer] = struct{}{}
+			if len(committed) == total {
+				signal.Do(func() { close(locked) })
+			}
+		}
+		return true
+	}
+	for i := 0; i < len(validators); i++ {
+		for j := i + 1; j < len(validators); j++ {
+			connectFiltered(validators[i], validators[j], drop)
+		}
+	}
+	for _, validator := range validators {
+		validator.engine.Start(validator.chain)
+		defer validator.engine.Stop()
+	}
+	var (
+		results = make(chan *types.Block, len(validators))
+		stop    = make(chan struct{})
+	)
+	defer close(stop)
+
+	for i, validator := range validators {
+		if err := validator.engine.Seal(validator.chain, validator.newBlock(t, byte(i)), results, stop); err != nil {
+			t.Fatalf("validator %d: failed to seal block: %v", i, err)
+		}
+	}
+	select {
+	case <-locked:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("proposal not locked on")
+	}
+	// Take the original proposer offline, the next one has to re-propose its block
+	proposer := validators[1]
+	proposer.engine.Stop()
+
+	var block *types.Block
+	select {
+	case block = <-results:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("locked block not committed")
+	}
+	if author, err := validators[0].engine.Author(block.Header()); err != nil || author != proposer.addr {
+		t.Fatalf("proposer mismatch: have %x, want %x (err %v)", author, proposer.addr, err)
+	}
+	extra, _ := extractExtra(block.Header())
+	if len(extra.CommittedSeals) < quorum(len(validators)) {
+		t.Errorf("committed seals mismatch: have %d, want at least %d", len(extra.CommittedSeals), quorum(len(validators)))
+	}
+	for i, validator := range []*testerValidator{validators[0], validators[2], validators[3]} {
+		if _, err := validator.chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("validator %d: failed to import committed block: %v", i, err)
+		}
+	}
+}
+
+// Tests that blocks without valid and distinct commit seals of a quorum of the
+// validators are rejected.
+func TestCommittedSeals(t *testing.T) {
+	validators := newTesterNetwork(t, 4)
+	for i := 0; i < len(validators); i++ {
+		for j := i + 1; j < len(validators); j++ {
+			connect(validators[i], validators[j])
+		}
+	}
+	for _, validator := range validators {
+		defer validator.chain.Stop()
+		validator.engine.Start(validator.chain)
+		defer validator.engine.Stop()
+	}
+	block := sealBlock(t, validators)
+	extra, _ := extractExtra(block.Header())
+
+	snap, err := validators[0].engine.snapshot(validators[0].chain, 0, validators[0].chain.Genesis().Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve genesis snapshot: %v", err)
+	}
+
+	tests := []struct {
+		seals [][]byte
+		err   error
+	}{
+		{extra.CommittedSeals[:quorum(len(validators))], nil},
+		{extra.CommittedSeals[:quorum(len(validators))-1], errInsufficientCommittedSeals},
+		{append([][]byte{extra.CommittedSeals[0]}, extra.CommittedSeals[:quorum(len(validators))-1]...), errInvalidCommittedSeals},
+		{[][]byte{extra.Seal, extra.CommittedSeals[0], extra.CommittedSeals[1]}, errUnauthorizedValidator},
+	}
+	for i, tt := range tests {
+		tampered := *extra
+		tampered.CommittedSeals = tt.seals
+
+		header := block.Header()
+		header.Extra, _ = encodeExtra(header.Extra[:extraVanity], &tampered)
+
+		if err := validators[0].engine.verifySeals(snap, header, true); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that consensus messages are only processed and relayed if they are
+// signed by a validator.
+func TestForeignMessages(t *testing.T) {
+	validators := newTesterNetwork(t, 4)
+	for _, validator := range validators {
+		defer validator.chain.Stop()
+	}
+	engine := validators[0].engine
+	engine.Start(validators[0].chain)
+	defer engine.Stop()
+
+	// Attach a remote node capturing the relayed messages
+	local, remote := p2p.MsgPipe()
+	defer local.Close()
+	engine.peers.register(enode.ID{1}, local)
+
+	sign := func(key *ecdsa.PrivateKey, msg *message) []byte {
+		msg.Signature, _ = crypto.Sign(msg.sigHash(), key)
+		payload, _ := rlp.EncodeToBytes(msg)
+		return payload
+	}
+	received := make(chan struct{}, 1)
+	go func() {
+		for {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+			received <- struct{}{}
+		}
+	}()
+	relayed := func() bool {
+		select {
+		case <-received:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}
+	outsider, _ := crypto.GenerateKey()
+	if err := engine.handleMsg(sign(outsider, &message{Code: msgPrepare, Height: 1})); err != errUnauthorizedValidator {
+		t.Fatalf("message of non-validator: error mismatch: have %v, want %v", err, errUnauthorizedValidator)
+	}
+	if relayed() {
+		t.Fatalf("message of non-validator relayed")
+	}
+	if err := engine.handleMsg(sign(validators[1].key, &message{Code: msgPrepare, Height: 1})); err != nil {
+		t.Fatalf("message of validator rejected: %v", err)
+	}
+	if !relayed() {
+		t.Fatalf("message of validator not relayed")
+	}
+}
+
+// Tests that the proposals made through the API survive restarts.
+func TestProposalPersistence(t *testing.T) {
+	db := echdb.NewMemDatabase()
+	config := &params.BFTConfig{Epoch: 30000}
+
+	api := &API{bft: New(config, db)}
+	if err := api.Propose(common.Address{1}, true); err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	if err := api.Propose(common.Address{2}, false); err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	if err := api.Discard(common.Address{1}); err != nil {
+		t.Fatalf("failed to discard: %v", err)
+	}
+	restarted := &API{bft: New(config, db)}
+	if proposals := restarted.Proposals(); len(proposals) != 1 || proposals[common.Address{2}] {
+		t.Fatalf("proposals mismatch after restart: %v", proposals)
+	}
+	if _, ok := restarted.Proposals()[common.Address{2}]; !ok {
+		t.Fatalf("proposal missing after restart")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/p2p"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	protocolName       = "bft"
+	protocolVersion    = 1
+	protocolLength     = 1
+	protocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+
+	// consensusMsg is the single message code of the protocol, carrying a signed
+	// consensus message.
+	consensusMsg = 0x00
+
+	// knownMessages is the number of recent consensus messages remembered to avoid
+	// processing and relaying them multiple times.
+	knownMessages = 4096
+)
+
+// Consensus message types exchanged by the validators.
+const (
+	msgPreprepare  byte = iota // Proposal of a block by the proposer of the round
+	msgPrepare                 // Acceptance of the proposal by a validator
+	msgCommit                  // Commitment of a validator to the prepared proposal
+	msgRoundChange             // Request to move on to a later round
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errInvalidMsgCode = errors.New("invalid message code")
+)
+
+// message is a signed consensus message of a validator about the agreement on
+// the block at a given height in a given round.
+type message struct {
+	Code          byte        // Type of the consensus message
+	Height        uint64      // Number of the block being agreed on
+	Round         uint64      // Round of the agreement
+	Digest        common.Hash // Seal hash of the block the message is about
+	Block         []byte      // RLP encoded proposed block (preprepare only)
+	CommittedSeal []byte      // Commit signature of the validator (commit only)
+	Signature     []byte      // Signature of the validator over the message
+
+	sender common.Address // Validator recovered from the signature
+}
+
+// sigHash returns the hash of the message the validator signs.
+func (m *message) sigHash() []byte {
+	blob, _ := rlp.EncodeToBytes([]interface{}{m.Code, m.Height, m.Round, m.Digest, m.Block, m.CommittedSeal})
+	return crypto.Keccak256(blob)
+}
+
+// decodeMessage parses a signed consensus message and recovers its sender.
+func decodeMessage(payload []byte) (*message, error) {
+	msg := new(message)
+	if err := rlp.DecodeBytes(payload, msg); err != nil {
+		return nil, err
+	}
+	if msg.Code > msgRoundChange {
+		return nil, fmt.Errorf("unknown consensus message type %d", msg.Code)
+	}
+	sender, err := recoverAddress(msg.sigHash(), msg.Signature)
+	if err != nil {
+		return nil, err
+	}
+	msg.sender = sender
+	return msg, nil
+}
+
+// peerSet is the set of remote nodes consensus messages are exchanged with.
+type peerSet struct {
+	peers map[enode.ID]p2p.MsgReadWriter
+	lock  sync.RWMutex
+}
+
+// newPeerSet creates an empty peer set.
+func newPeerSet() *peerSet {
+	return &peerSet{peers: make(map[enode.ID]p2p.MsgReadWriter)}
+}
+
+// register adds a remote node to the set.
+func (ps *peerSet) register(id enode.ID, rw p2p.MsgReadWriter) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.peers[id] = rw
+}
+
+// unregister removes a remote node from the set.
+func (ps *peerSet) unregister(id enode.ID) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	delete(ps.peers, id)
+}
+
+// broadcast sends a consensus message to all the remote nodes asynchronously.
+func (ps *peerSet) broadcast(payload []byte) {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	for id, rw := range ps.peers {
+		go func(id enode.ID, rw p2p.MsgReadWriter) {
+			if err := p2p.Send(rw, consensusMsg, payload); err != nil {
+				log.Trace("Failed to send consensus message", "peer", id, "err", err)
+			}
+		}(id, rw)
+	}
+}
+
+// Protocols returns the p2p protocol the validators exchange the consensus
+// messages over.
+func (b *BFT) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    protocolName,
+		Version: protocolVersion,
+		Length:  protocolLength,
+		Run:     b.runPeer,
+	}}
+}
+
+// runPeer is the protocol handler of a remote node, receiving its consensus
+// messages until the connection is torn down.
+func (b *BFT) runPeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+	b.peers.register(peer.ID(), rw)
+	defer b.peers.unregister(peer.ID())
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if msg.Size > protocolMaxMsgSize {
+			msg.Discard()
+			return errMsgTooLarge
+		}
+		if msg.Code != consensusMsg {
+			msg.Discard()
+			return errInvalidMsgCode
+		}
+		var payload []byte
+		if err := msg.Decode(&payload); err != nil {
+			return err
+		}
+		if err := b.handleMsg(payload); err != nil {
+			log.Trace("Failed to handle consensus message", "peer", peer.ID(), "err", err)
+			if err != errNotStarted {
+				peer.Report(p2p.RepProtocolError)
+			}
+		}
+	}
+}
+
+// handleMsg processes a consensus message received from a remote node, relaying
+// it to the other nodes if it's the first time it's seen. Only messages signed
+// by a validator are processed and relayed, anything else is dropped.
+func (b *BFT) handleMsg(payload []byte) error {
+	hash := crypto.Keccak256Hash(payload)
+	if b.known.Contains(hash) {
+		return nil
+	}
+	b.known.Add(hash, struct{}{})
+
+	b.coreLock.Lock()
+	c := b.core
+	b.coreLock.Unlock()
+
+	if c == nil {
+		return errNotStarted
+	}
+	msg, err := decodeMessage(payload)
+	if err != nil {
+		return err
+	}
+	// Messages about blocks already in the chain are of no use to anyone
+	head := c.chain.CurrentHeader()
+	if msg.Height <= head.Number.Uint64() {
+		return nil
+	}
+	if err := b.verifySender(c.chain, head, msg); err != nil {
+		return err
+	}
+	c.deliver(msg)
+	b.peers.broadcast(payload)
+	return nil
+}
+
+// verifySender checks that the sender of a consensus message is a validator of
+// the block the message is about. Messages for heights beyond the block after
+// the chain head are checked against the latest known validator set.
+func (b *BFT) verifySender(chain consensus.ChainReader, head *types.Header, msg *message) error {
+	snap, err := b.snapshot(chain, head.Number.Uint64(), head.Hash(), nil)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[msg.sender]; !ok {
+		return errUnauthorizedValidator
+	}
+	return nil
+}
+
+// broadcast signs a consensus message of the local validator and sends it to all
+// the remote nodes.
+func (b *BFT) broadcast(msg *message) error {
+	sender, sig, err := b.sign(msg.sigHash())
+	if err != nil {
+		return err
+	}
+	msg.Signature, msg.sender = sig, sender
+
+	payload, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return err
+	}
+	b.known.Add(crypto.Keccak256Hash(payload), struct{}{})
+	b.peers.broadcast(payload)
+	return nil
+}
@@ -0,0 +1,566 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+const (
+	// maxBacklog is the maximum number of consensus messages for future heights
+	// and rounds kept until the local validator catches up with them.
+	maxBacklog = 1024
+
+	// maxTimeoutShift caps the exponential backoff of the round timeouts.
+	maxTimeoutShift = 6
+)
+
+// sealRequest is a block the local validator proposes, along with the channels
+// to deliver it on once committed and to abandon it.
+type sealRequest struct {
+	block   *types.Block
+	results chan<- *types.Block
+	stop    <-chan struct{}
+}
+
+// chainInserter is the part of a chain committed blocks can be imported into,
+// implemented by the full blockchain the state machine usually runs on.
+type chainInserter interface {
+	InsertChain(chain types.Blocks) (int, error)
+}
+
+// roundState is the progress of the agreement in the current round.
+type roundState int
+
+const (
+	stateAcceptRequest roundState = iota // Waiting for the proposal of the round
+	statePreprepared                     // Proposal accepted, waiting for a quorum of prepares
+	statePrepared                        // Proposal prepared, waiting for a quorum of commits
+	stateCommitted                       // Proposal committed, waiting for the block import
+)
+
+// stateMachine is the consensus state machine of the local validator. All its
+// fields are owned by its event loop, apart from the channels feeding it.
+type stateMachine struct {
+	bft   *BFT
+	chain consensus.ChainReader
+
+	msgCh     chan *message
+	requestCh chan *sealRequest
+	headCh    chan *types.Header
+	quit      chan struct{}
+	done      chan struct{}
+
+	height uint64      // Number of the block being agreed on
+	round  uint64      // Round of the agreement
+	parent common.Hash // Hash of the parent of the block being agreed on
+	snap   *Snapshot   // Validator snapshot of the parent
+
+	state        roundState                                  // Progress of the current round
+	proposal     *types.Block                                // Proposal accepted in the current round
+	prepares     map[common.Hash]map[common.Address]struct{} // Prepares of the current round by digest
+	commits      map[common.Hash]map[common.Address][]byte   // Commit seals of the current round by digest
+	roundChanges map[uint64]map[common.Address]struct{}      // Round change requests by target round
+	sentChange   uint64                                      // Highest round the local validator requested
+	locked       *types.Block                                // Block prepared at this height, must be re-proposed
+
+	pending *sealRequest // Block the local validator would like to propose
+	backlog []*message   // Messages for future heights and rounds
+	timeout *time.Timer  // Timer abandoning the current round
+}
+
+// newStateMachine creates a consensus state machine on top of the given chain and
+// starts agreeing on the block following its head.
+func newStateMachine(bft *BFT, chain consensus.ChainReader) *stateMachine {
+	c := &stateMachine{
+		bft:       bft,
+		chain:     chain,
+		msgCh:     make(chan *message, maxBacklog),
+		requestCh: make(chan *sealRequest),
+		headCh:    make(chan *types.Header, 1),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+		timeout:   time.NewTimer(0),
+	}
+	<-c.timeout.C
+
+	go c.loop()
+	return c
+}
+
+// stop terminates the event loop of the state machine.
+func (c *stateMachine) stop() {
+	close(c.quit)
+	<-c.done
+}
+
+// deliver feeds a consensus message into the state machine.
+func (c *stateMachine) deliver(msg *message) {
+	select {
+	case c.msgCh <- msg:
+	case <-c.quit:
+	}
+}
+
+// request feeds a block the local validator would like to propose into the state
+// machine.
+func (c *stateMachine) request(req *sealRequest) {
+	select {
+	case c.requestCh <- req:
+	case <-req.stop:
+	case <-c.quit:
+	}
+}
+
+// newHead notifies the state machine of a new chain head.
+func (c *stateMachine) newHead(head *types.Header) {
+	select {
+	case c.headCh <- head:
+	case <-c.quit:
+	}
+}
+
+// loop is the event loop of the state machine.
+func (c *stateMachine) loop() {
+	defer close(c.done)
+	defer c.timeout.Stop()
+
+	c.startHeight(c.chain.CurrentHeader())
+	for {
+		select {
+		case msg := <-c.msgCh:
+			c.handleMessage(msg)
+
+		case req := <-c.requestCh:
+			c.handleRequest(req)
+
+		case head := <-c.headCh:
+			if head.Number.Uint64() >= c.height {
+				c.startHeight(head)
+			}
+
+		case <-c.timeout.C:
+			c.handleTimeout()
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// self returns the address of the local validator.
+func (c *stateMachine) self() common.Address {
+	c.bft.lock.RLock()
+	defer c.bft.lock.RUnlock()
+
+	return c.bft.signer
+}
+
+// broadcast signs and sends a consensus message to the other validators, and
+// handles it locally too.
+func (c *stateMachine) broadcast(msg *message) {
+	if err := c.bft.broadcast(msg); err != nil {
+		log.Warn("Failed to broadcast consensus message", "code", msg.Code, "err", err)
+		return
+	}
+	c.handleMessage(msg)
+}
+
+// startHeight moves the agreement on to the block following the given head.
+func (c *stateMachine) startHeight(head *types.Header) {
+	snap, err := c.bft.snapshot(c.chain, head.Number.Uint64(), head.Hash(), nil)
+	if err != nil {
+		log.Error("Failed to retrieve validator snapshot", "number", head.Number, "hash", head.Hash(), "err", err)
+		return
+	}
+	c.height, c.parent, c.snap = head.Number.Uint64()+1, head.Hash(), snap
+	c.roundChanges = make(map[uint64]map[common.Address]struct{})
+	c.sentChange = 0
+	c.locked = nil
+
+	if c.pending != nil && c.pending.block.NumberU64() != c.height {
+		c.pending = nil
+	}
+	c.startRound(0)
+}
+
+// startRound moves the agreement on to the given round, proposing the block if
+// the local validator is the proposer of it.
+func (c *stateMachine) startRound(round uint64) {
+	log.Debug("Starting consensus round", "number", c.height, "round", round)
+
+	c.round = round
+	c.state = stateAcceptRequest
+	c.proposal = nil
+	c.prepares = make(map[common.Hash]map[common.Address]struct{})
+	c.commits = make(map[common.Hash]map[common.Address][]byte)
+	for r := range c.roundChanges {
+		if r <= round {
+			delete(c.roundChanges, r)
+		}
+	}
+	c.resetTimer()
+	c.processBacklog()
+
+	if c.state == stateAcceptRequest && c.snap.proposer(c.height, c.round) == c.self() {
+		c.propose()
+	}
+}
+
+// resetTimer restarts the timer of the current round, backing off exponentially
+// with the number of failed rounds.
+func (c *stateMachine) resetTimer() {
+	shift := c.round
+	if shift > maxTimeoutShift {
+		shift = maxTimeoutShift
+	}
+	timeout := time.Duration(c.bft.config.Period)*time.Second + time.Duration(c.bft.config.RequestTimeout)*time.Millisecond<<shift
+
+	if !c.timeout.Stop() {
+		select {
+		case <-c.timeout.C:
+		default:
+		}
+	}
+	c.timeout.Reset(timeout)
+}
+
+// activeRequest returns the block the local validator would like to propose at
+// the current height, if any and not abandoned yet.
+func (c *stateMachine) activeRequest() *sealRequest {
+	if c.pending == nil {
+		return nil
+	}
+	select {
+	case <-c.pending.stop:
+		c.pending = nil
+		return nil
+	default:
+	}
+	if c.pending.block.NumberU64() != c.height || c.pending.block.ParentHash() != c.parent {
+		return nil
+	}
+	return c.pending
+}
+
+// propose broadcasts the block of the local validator, or the block locked on in
+// an earlier round, as the proposal of the current round.
+func (c *stateMachine) propose() {
+	block := c.locked
+	if block == nil {
+		req := c.activeRequest()
+		if req == nil {
+			return
+		}
+		block = req.block
+	}
+	payload, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode proposal", "err", err)
+		return
+	}
+	log.Debug("Proposing block", "number", c.height, "round", c.round, "hash", sigHash(block.Header()))
+	c.broadcast(&message{Code: msgPreprepare, Height: c.height, Round: c.round, Digest: sigHash(block.Header()), Block: payload})
+}
+
+// handleRequest stores a block the local validator would like to propose, and
+// proposes it right away if it's the proposer of the current round.
+func (c *stateMachine) handleRequest(req *sealRequest) {
+	// The chain head might have moved without a notification, catch up with it
+	if number := req.block.NumberU64(); number > c.height {
+		if head := c.chain.CurrentHeader(); head.Number.Uint64()+1 == number {
+			c.startHeight(head)
+		}
+	}
+	c.pending = req
+	if c.state == stateAcceptRequest && c.snap != nil && c.snap.proposer(c.height, c.round) == c.self() {
+		c.propose()
+	}
+}
+
+// handleMessage processes a consensus message with a verified signature.
+func (c *stateMachine) handleMessage(msg *message) {
+	if c.snap == nil || msg.Height < c.height {
+		return
+	}
+	if msg.Height > c.height {
+		c.postpone(msg)
+		return
+	}
+	if _, ok := c.snap.Validators[msg.sender]; !ok {
+		log.Trace("Dropping consensus message of non-validator", "sender", msg.sender)
+		return
+	}
+	if msg.Code == msgRoundChange {
+		c.handleRoundChange(msg)
+		return
+	}
+	if msg.Round < c.round {
+		return
+	}
+	if msg.Round > c.round {
+		c.postpone(msg)
+		return
+	}
+	switch msg.Code {
+	case msgPreprepare:
+		c.handlePreprepare(msg)
+	case msgPrepare:
+		c.handlePrepare(msg)
+	case msgCommit:
+		c.handleCommit(msg)
+	}
+}
+
+// postpone stores a message for a future height or round, dropping the oldest
+// one if the backlog is full.
+func (c *stateMachine) postpone(msg *message) {
+	if len(c.backlog) >= maxBacklog {
+		c.backlog = c.backlog[1:]
+	}
+	c.backlog = append(c.backlog, msg)
+}
+
+// processBacklog handles the postponed messages that became current, keeping the
+// ones still in the future.
+func (c *stateMachine) processBacklog() {
+	backlog := c.backlog
+	c.backlog = nil
+
+	for _, msg := range backlog {
+		c.handleMessage(msg)
+	}
+}
+
+// handlePreprepare accepts the proposal of the round if it's proposed by the
+// right validator and is a valid block on top of the local chain head.
+func (c *stateMachine) handlePreprepare(msg *message) {
+	if c.state != stateAcceptRequest {
+		return
+	}
+	if proposer := c.snap.proposer(c.height, c.round); msg.sender != proposer {
+		log.Debug("Dropping proposal of wrong proposer", "number", c.height, "round", c.round, "have", msg.sender, "want", proposer)
+		return
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(msg.Block, block); err != nil {
+		log.Debug("Dropping undecodable proposal", "err", err)
+		return
+	}
+	if block.NumberU64() != c.height || block.ParentHash() != c.parent || sigHash(block.Header()) != msg.Digest {
+		log.Debug("Dropping mismatching proposal", "number", block.Number(), "parent", block.ParentHash())
+		return
+	}
+	if c.locked != nil && sigHash(c.locked.Header()) != msg.Digest {
+		log.Debug("Dropping proposal conflicting with locked block", "number", c.height, "round", c.round)
+		return
+	}
+	if err := c.bft.verifyProposal(c.chain, block); err != nil {
+		log.Debug("Dropping invalid proposal", "number", c.height, "round", c.round, "err", err)
+		return
+	}
+	c.proposal = block
+	c.state = statePreprepared
+
+	c.broadcast(&message{Code: msgPrepare, Height: c.height, Round: c.round, Digest: msg.Digest})
+	c.checkPrepared()
+	c.checkCommitted()
+}
+
+// handlePrepare tallies the acceptance of a proposal by a validator.
+func (c *stateMachine) handlePrepare(msg *message) {
+	if c.prepares[msg.Digest] == nil {
+		c.prepares[msg.Digest] = make(map[common.Address]struct{})
+	}
+	c.prepares[msg.Digest][msg.sender] = struct{}{}
+	c.checkPrepared()
+}
+
+// checkPrepared locks on the proposal and commits to it once a quorum of the
+// validators accepted it.
+func (c *stateMachine) checkPrepared() {
+	if c.state != statePreprepared {
+		return
+	}
+	digest := sigHash(c.proposal.Header())
+	if len(c.prepares[digest]) < quorum(len(c.snap.Validators)) {
+		return
+	}
+	c.prepare(digest)
+}
+
+// prepare locks on the proposal and broadcasts the commitment of the local
+// validator to it.
+func (c *stateMachine) prepare(digest common.Hash) {
+	c.state = statePrepared
+	c.locked = c.proposal
+
+	_, seal, err := c.bft.sign(commitHash(digest))
+	if err != nil {
+		log.Warn("Failed to sign commit seal", "err", err)
+		return
+	}
+	c.broadcast(&message{Code: msgCommit, Height: c.height, Round: c.round, Digest: digest, CommittedSeal: seal})
+}
+
+// handleCommit tallies the commitment of a validator to a proposal.
+func (c *stateMachine) handleCommit(msg *message) {
+	if signer, err := recoverAddress(commitHash(msg.Digest), msg.CommittedSeal); err != nil || signer != msg.sender {
+		log.Debug("Dropping commit with invalid seal", "sender", msg.sender)
+		return
+	}
+	if c.commits[msg.Digest] == nil {
+		c.commits[msg.Digest] = make(map[common.Address][]byte)
+	}
+	c.commits[msg.Digest][msg.sender] = msg.CommittedSeal
+	c.checkCommitted()
+}
+
+// checkCommitted finalizes the proposal once a quorum of the validators committed
+// to it, delivering it if the local validator is the proposer of the round.
+func (c *stateMachine) checkCommitted() {
+	if c.state != statePreprepared && c.state != statePrepared {
+		return
+	}
+	digest := sigHash(c.proposal.Header())
+	if len(c.commits[digest]) < quorum(len(c.snap.Validators)) {
+		return
+	}
+	// A quorum committed, so a quorum prepared too, commit ourselves if not yet
+	// (our own commit re-enters here in the prepared state)
+	if c.state == statePreprepared {
+		c.prepare(digest)
+		return
+	}
+	c.state = stateCommitted
+
+	// Attach the committed seals in validator order and deliver the block
+	validators := make([]common.Address, 0, len(c.commits[digest]))
+	for validator := range c.commits[digest] {
+		validators = append(validators, validator)
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return bytes.Compare(validators[i][:], validators[j][:]) < 0
+	})
+	header := c.proposal.Header()
+	extra, err := extractExtra(header)
+	if err != nil {
+		log.Error("Failed to decode committed block", "err", err)
+		return
+	}
+	for _, validator := range validators {
+		extra.CommittedSeals = append(extra.CommittedSeals, c.commits[digest][validator])
+	}
+	if header.Extra, err = encodeExtra(header.Extra[:extraVanity], extra); err != nil {
+		log.Error("Failed to encode committed block", "err", err)
+		return
+	}
+	block := c.proposal.WithSeal(header)
+	log.Info("Committed block", "number", c.height, "round", c.round, "hash", block.Hash(), "seals", len(validators))
+
+	// Only the proposer of the round finalizes the block, as the other validators
+	// might have collected other commit seals, ending up with a different hash.
+	// The block might be a locked one re-proposed after its proposer went offline,
+	// so hand it to our miner even if it's not the block we asked to seal.
+	if c.snap.proposer(c.height, c.round) != c.self() {
+		return
+	}
+	if req := c.activeRequest(); req != nil {
+		select {
+		case req.results <- block:
+		default:
+			log.Warn("Sealing result is not read by miner", "sealhash", digest)
+		}
+		return
+	}
+	c.insert(block)
+}
+
+// insert imports a committed block into the local chain if there's no miner
+// waiting for it, and moves the agreement on to the next height.
+func (c *stateMachine) insert(block *types.Block) {
+	inserter, ok := c.chain.(chainInserter)
+	if !ok {
+		log.Warn("Committed block can't be imported locally", "number", block.Number(), "hash", block.Hash())
+		return
+	}
+	go func() {
+		if _, err := inserter.InsertChain(types.Blocks{block}); err != nil {
+			log.Warn("Failed to import committed block", "number", block.Number(), "hash", block.Hash(), "err", err)
+			return
+		}
+		c.newHead(block.Header())
+	}()
+}
+
+// handleRoundChange tallies the requests to move on to a later round, joining
+// them once enough validators asked for it and moving on once a quorum did.
+func (c *stateMachine) handleRoundChange(msg *message) {
+	if msg.Round <= c.round {
+		return
+	}
+	if c.roundChanges[msg.Round] == nil {
+		c.roundChanges[msg.Round] = make(map[common.Address]struct{})
+	}
+	c.roundChanges[msg.Round][msg.sender] = struct{}{}
+
+	var (
+		votes      = len(c.roundChanges[msg.Round])
+		validators = len(c.snap.Validators)
+		faulty     = validators - quorum(validators)
+	)
+	// If more validators asked than could be faulty, the round is lost anyway
+	if votes > faulty && msg.Round > c.sentChange {
+		c.sendRoundChange(msg.Round)
+		return
+	}
+	if votes >= quorum(validators) {
+		c.startRound(msg.Round)
+	}
+}
+
+// handleTimeout requests moving on to the next round, as the current one failed
+// to complete in time.
+func (c *stateMachine) handleTimeout() {
+	round := c.round
+	if c.sentChange > round {
+		round = c.sentChange
+	}
+	log.Debug("Consensus round timed out", "number", c.height, "round", c.round)
+	c.sendRoundChange(round + 1)
+	c.resetTimer()
+}
+
+// sendRoundChange broadcasts the request of the local validator to move on to
+// the given round.
+func (c *stateMachine) sendRoundChange(round uint64) {
+	c.sentChange = round
+
+	var digest common.Hash
+	if c.locked != nil {
+		digest = sigHash(c.locked.Header())
+	}
+	c.broadcast(&message{Code: msgRoundChange, Height: c.height, Round: round, Digest: digest})
+}
@@ -17,8 +17,12 @@
 package clique
 
 import (
+	"fmt"
+
 	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
 	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/consensus/misc"
 	"github.com/etvchaineum/go-etvchaineum/core/types"
 	"github.com/etvchaineum/go-etvchaineum/rpc"
 )
@@ -101,19 +105,52 @@ func (api *API) Proposals() map[common.Address]bool {
 }
 
 // Propose injects a new authorization proposal that the signer will attempt to
-// push through.
-func (api *API) Propose(address common.Address, auth bool) {
+// push through. The proposals are persisted, so they survive restarts.
+func (api *API) Propose(address common.Address, auth bool) error {
 	api.clique.lock.Lock()
 	defer api.clique.lock.Unlock()
 
-	api.clique.proposals[address] = auth
+	return misc.SetProposal(api.clique.db, proposalsKey, api.clique.proposals, address, auth)
 }
 
 // Discard drops a currently running proposal, stopping the signer from casting
 // further votes (either for or against).
-func (api *API) Discard(address common.Address) {
+func (api *API) Discard(address common.Address) error {
 	api.clique.lock.Lock()
 	defer api.clique.lock.Unlock()
 
-	delete(api.clique.proposals, address)
+	return misc.DropProposal(api.clique.db, proposalsKey, api.clique.proposals, address)
+}
+
+// Status retrieves the sealing activity of the signers over the given number of
+// recent blocks (64 if omitted): the blocks each signer sealed in-turn and
+// out-of-turn, the in-turn slots it missed and the last block it sealed.
+func (api *API) Status(blocks *hexutil.Uint64) (*Status, error) {
+	n := uint64(defaultStatusBlocks)
+	if blocks != nil {
+		n = uint64(*blocks)
+	}
+	if n == 0 || n > maxStatusBlocks {
+		return nil, fmt.Errorf("block count must be between 1 and %d", maxStatusBlocks)
+	}
+	return api.clique.status(api.chain, api.chain.CurrentHeader(), n)
+}
+
+// GetVoteHistory retrieves the votes cast and the signer changes caused by them
+// in the given range of blocks (both inclusive). If the end is omitted, the range
+// ends with the current block.
+func (api *API) GetVoteHistory(from rpc.BlockNumber, to *rpc.BlockNumber) (*VoteHistory, error) {
+	head := api.chain.CurrentHeader().Number.Uint64()
+
+	start, end := head, head
+	if from != rpc.LatestBlockNumber && from != rpc.PendingBlockNumber {
+		start = uint64(from.Int64())
+	}
+	if to != nil && *to != rpc.LatestBlockNumber && *to != rpc.PendingBlockNumber {
+		end = uint64(to.Int64())
+	}
+	if end > head {
+		return nil, errUnknownBlock
+	}
+	return api.clique.voteHistory(api.chain, start, end)
 }
@@ -205,12 +205,15 @@ type Clique struct {
 	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
 	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 
-	proposals map[common.Address]bool // Current list of proposals we are pushing
+	proposals map[common.Address]bool // Current list of proposals we are pushing (persisted in db)
 
 	signer common.Address // Etvchain address of the signing key
 	signFn SignerFn       // Signer function to authorize hashes with
 	lock   sync.RWMutex   // Protects the signer fields
 
+	reported   uint64     // Last canonical block reported in the signer metrics
+	reportLock sync.Mutex // Protects the reported block
+
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
 }
@@ -227,12 +230,19 @@ func New(config *params.CliqueConfig, db echdb.Database) *Clique {
 	recents, _ := lru.NewARC(inmemorySnapshots)
 	signatures, _ := lru.NewARC(inmemorySignatures)
 
+	// Restore the proposals the signer was pushing before a restart
+	proposals, err := misc.LoadProposals(db, proposalsKey)
+	if err != nil {
+		log.Warn("Failed to load clique proposals", "err", err)
+	} else if len(proposals) > 0 {
+		log.Info("Loaded clique proposals", "proposals", len(proposals))
+	}
 	return &Clique{
 		config:     &conf,
 		db:         db,
 		recents:    recents,
 		signatures: signatures,
-		proposals:  make(map[common.Address]bool),
+		proposals:  proposals,
 	}
 }
 
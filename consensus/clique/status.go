@@ -0,0 +1,183 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"fmt"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/metrics"
+)
+
+const (
+	// defaultStatusBlocks is the number of recent blocks the signer status is
+	// gathered from if not requested otherwise.
+	defaultStatusBlocks = 64
+
+	// maxStatusBlocks is the maximum number of recent blocks the signer status
+	// can be gathered from, as every header needs to be replayed.
+	maxStatusBlocks = 8192
+)
+
+// SignerStatus is the sealing activity of a single signer over a range of blocks.
+type SignerStatus struct {
+	Authorized   bool              `json:"authorized"`   // Whetvchain the signer is authorized at the end of the range
+	Sealed       uint64            `json:"sealed"`       // Number of blocks sealed by the signer
+	InTurn       uint64            `json:"inTurn"`       // Number of blocks sealed in-turn
+	OutOfTurn    uint64            `json:"outOfTurn"`    // Number of blocks sealed out-of-turn
+	MissedTurns  uint64            `json:"missedTurns"`  // Number of in-turn slots sealed by someone else
+	InTurnRatio  float64           `json:"inTurnRatio"`  // Ratio of the sealed blocks that were in-turn
+	Difficulties map[uint64]uint64 `json:"difficulties"` // Number of sealed blocks by difficulty
+	LastSeen     uint64            `json:"lastSeen"`     // Last block sealed by the signer, 0 if none in the range
+}
+
+// Status is the liveness of the signers over a range of recent blocks.
+type Status struct {
+	From    uint64                           `json:"from"`    // First block of the range (inclusive)
+	To      uint64                           `json:"to"`      // Last block of the range (inclusive)
+	Signers map[common.Address]*SignerStatus `json:"signers"` // Activity of each signer seen or authorized
+}
+
+// status gathers the sealing activity of the signers from the given number of
+// canonical blocks ending with the given head, attributing every block to its
+// signer and every in-turn slot sealed by someone else to the in-turn signer.
+func (c *Clique) status(chain consensus.ChainReader, head *types.Header, blocks uint64) (*Status, error) {
+	to := head.Number.Uint64()
+
+	from := uint64(1)
+	if to >= blocks {
+		from = to - blocks + 1
+	}
+	status := &Status{
+		From:    from,
+		To:      to,
+		Signers: make(map[common.Address]*SignerStatus),
+	}
+	signer := func(address common.Address) *SignerStatus {
+		if status.Signers[address] == nil {
+			status.Signers[address] = &SignerStatus{Difficulties: make(map[uint64]uint64)}
+		}
+		return status.Signers[address]
+	}
+	parent := chain.GetHeaderByNumber(from - 1)
+	if parent == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := c.snapshot(chain, parent.Number.Uint64(), parent.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for number := from; number <= to; number++ {
+		header := chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		if header.ParentHash != snap.Hash {
+			return nil, errInvalidVotingChain
+		}
+		sealer, err := ecrecover(header, c.signatures)
+		if err != nil {
+			return nil, err
+		}
+		stats := signer(sealer)
+		stats.Sealed++
+		stats.Difficulties[header.Difficulty.Uint64()]++
+		stats.LastSeen = number
+
+		if snap.inturn(number, sealer) {
+			stats.InTurn++
+		} else {
+			stats.OutOfTurn++
+			if signers := snap.signers(); len(signers) > 0 {
+				signer(signers[number%uint64(len(signers))]).MissedTurns++
+			}
+		}
+		if snap, err = snap.apply([]*types.Header{header}); err != nil {
+			return nil, fmt.Errorf("block %d: %v", number, err)
+		}
+	}
+	for address := range snap.Signers {
+		signer(address).Authorized = true
+	}
+	for _, stats := range status.Signers {
+		if stats.Sealed > 0 {
+			stats.InTurnRatio = float64(stats.InTurn) / float64(stats.Sealed)
+		}
+	}
+	return status, nil
+}
+
+// maxReportBlocks is the maximum number of canonical blocks reported in the
+// signer metrics at once, if the chain advanced by more since the last report
+// only the most recent ones are reported.
+const maxReportBlocks = 1024
+
+// ReportChainHead implements consensus.ChainHeadReporter, updating the per signer
+// metrics with the canonical blocks up to the new chain head. Every block number
+// is only accounted once, reorgs to blocks already reported are ignored.
+func (c *Clique) ReportChainHead(chain consensus.ChainReader, head *types.Header) {
+	if !metrics.Enabled {
+		return
+	}
+	c.reportLock.Lock()
+	defer c.reportLock.Unlock()
+
+	number := head.Number.Uint64()
+	if number <= c.reported {
+		return
+	}
+	from := c.reported + 1
+	if c.reported == 0 || number-c.reported > maxReportBlocks {
+		from = number
+	}
+	for n := from; n <= number; n++ {
+		header := head
+		if n < number {
+			if header = chain.GetHeaderByNumber(n); header == nil {
+				continue
+			}
+		}
+		snap, err := c.snapshot(chain, n-1, header.ParentHash, nil)
+		if err != nil {
+			log.Debug("Failed to report clique signer", "number", n, "err", err)
+			continue
+		}
+		sealer, err := ecrecover(header, c.signatures)
+		if err != nil {
+			log.Debug("Failed to report clique signer", "number", n, "err", err)
+			continue
+		}
+		reportSealer(snap, header, sealer)
+	}
+	c.reported = number
+}
+
+// reportSealer updates the per signer metrics with a canonical header, tracking
+// the last block sealed by each signer and the in-turn slots missed.
+func reportSealer(snap *Snapshot, header *types.Header, sealer common.Address) {
+	number := header.Number.Uint64()
+	metrics.GetOrRegisterGauge(fmt.Sprintf("clique/signer/%s/lastseen", sealer.Hex()), nil).Update(int64(number))
+
+	if signers := snap.signers(); len(signers) > 0 {
+		if expected := signers[number%uint64(len(signers))]; expected != sealer {
+			metrics.GetOrRegisterCounter(fmt.Sprintf("clique/signer/%s/missed", expected.Hex()), nil).Inc(1)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/metrics"
+)
+
+// Tests that the signer status attributes every block to its signer and every
+// in-turn slot sealed out-of-turn to the signer that missed it.
+func TestSignerStatus(t *testing.T) {
+	accounts := newTesterAccountPool()
+
+	// Order the signers by address, so their turns are known: signer i is in-turn
+	// for the blocks where number % 3 == i
+	names := []string{"A", "B", "C"}
+	sort.Slice(names, func(i, j int) bool {
+		ai, aj := accounts.address(names[i]), accounts.address(names[j])
+		return bytes.Compare(ai[:], aj[:]) < 0
+	})
+	s0, s1, s2 := names[0], names[1], names[2]
+
+	votes := []testerVote{
+		{signer: s1}, // 1: in-turn
+		{signer: s2}, // 2: in-turn
+		{signer: s0}, // 3: in-turn
+		{signer: s1}, // 4: in-turn
+		{signer: s0}, // 5: out-of-turn, s2 missed
+		{signer: s1}, // 6: out-of-turn, s0 missed
+	}
+	inturn := []bool{true, true, true, true, false, false}
+
+	chain, engine, _ := newTesterChain(t, accounts, names, votes, func(j int) *big.Int {
+		if inturn[j] {
+			return diffInTurn
+		}
+		return diffNoTurn
+	})
+	defer chain.Stop()
+
+	api := &API{chain: chain, clique: engine}
+
+	// Gather the status of the entire chain
+	status, err := api.Status(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve signer status: %v", err)
+	}
+	if status.From != 1 || status.To != 6 {
+		t.Fatalf("status range mismatch: have %d-%d, want 1-6", status.From, status.To)
+	}
+	want := map[string]*SignerStatus{
+		s0: {Authorized: true, Sealed: 2, InTurn: 1, OutOfTurn: 1, MissedTurns: 1, InTurnRatio: 0.5, Difficulties: map[uint64]uint64{1: 1, 2: 1}, LastSeen: 5},
+		s1: {Authorized: true, Sealed: 3, InTurn: 2, OutOfTurn: 1, MissedTurns: 0, InTurnRatio: 2.0 / 3, Difficulties: map[uint64]uint64{1: 1, 2: 2}, LastSeen: 6},
+		s2: {Authorized: true, Sealed: 1, InTurn: 1, OutOfTurn: 0, MissedTurns: 1, InTurnRatio: 1, Difficulties: map[uint64]uint64{2: 1}, LastSeen: 2},
+	}
+	for name, stats := range want {
+		if have := status.Signers[accounts.address(name)]; !reflect.DeepEqual(have, stats) {
+			t.Errorf("signer %s: status mismatch: have %+v, want %+v", name, have, stats)
+		}
+	}
+	// Gather the status of the last few blocks only
+	blocks := hexutil.Uint64(3)
+	if status, err = api.Status(&blocks); err != nil {
+		t.Fatalf("failed to retrieve partial signer status: %v", err)
+	}
+	want = map[string]*SignerStatus{
+		s0: {Authorized: true, Sealed: 1, InTurn: 0, OutOfTurn: 1, MissedTurns: 1, InTurnRatio: 0, Difficulties: map[uint64]uint64{1: 1}, LastSeen: 5},
+		s1: {Authorized: true, Sealed: 2, InTurn: 1, OutOfTurn: 1, MissedTurns: 0, InTurnRatio: 0.5, Difficulties: map[uint64]uint64{1: 1, 2: 1}, LastSeen: 6},
+		s2: {Authorized: true, Sealed: 0, InTurn: 0, OutOfTurn: 0, MissedTurns: 1, InTurnRatio: 0, Difficulties: map[uint64]uint64{}, LastSeen: 0},
+	}
+	for name, stats := range want {
+		if have := status.Signers[accounts.address(name)]; !reflect.DeepEqual(have, stats) {
+			t.Errorf("signer %s: partial status mismatch: have %+v, want %+v", name, have, stats)
+		}
+	}
+	// Ensure invalid block counts are rejected
+	for _, n := range []hexutil.Uint64{0, maxStatusBlocks + 1} {
+		if _, err := api.Status(&n); err == nil {
+			t.Errorf("block count %d: expected error", n)
+		}
+	}
+}
+
+// Tests that the signer metrics are updated once for every canonical block, no
+// matter how many times the chain head is reported.
+func TestReportChainHead(t *testing.T) {
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	accounts := newTesterAccountPool()
+
+	names := []string{"A", "B", "C"}
+	sort.Slice(names, func(i, j int) bool {
+		ai, aj := accounts.address(names[i]), accounts.address(names[j])
+		return bytes.Compare(ai[:], aj[:]) < 0
+	})
+	s0, s1, s2 := names[0], names[1], names[2]
+
+	votes := []testerVote{
+		{signer: s1}, // 1: in-turn
+		{signer: s0}, // 2: out-of-turn, s2 missed
+		{signer: s2}, // 3: out-of-turn, s0 missed
+		{signer: s1}, // 4: in-turn
+	}
+	chain, engine, blocks := newTesterChain(t, accounts, names, votes, func(j int) *big.Int {
+		if j == 1 || j == 2 {
+			return diffNoTurn
+		}
+		return diffInTurn
+	})
+	defer chain.Stop()
+
+	engine.reported = 1 // Pretend the first block was already reported
+	for _, block := range []*types.Block{blocks[2], blocks[2], blocks[1], blocks[3]} {
+		engine.ReportChainHead(chain, block.Header())
+	}
+	missed := func(name string) int64 {
+		return metrics.GetOrRegisterCounter(fmt.Sprintf("clique/signer/%s/missed", accounts.address(name).Hex()), nil).Count()
+	}
+	lastseen := func(name string) int64 {
+		return metrics.GetOrRegisterGauge(fmt.Sprintf("clique/signer/%s/lastseen", accounts.address(name).Hex()), nil).Value()
+	}
+	for name, want := range map[string]int64{s0: 1, s1: 0, s2: 1} {
+		if have := missed(name); have != want {
+			t.Errorf("signer %s: missed turns mismatch: have %d, want %d", name, have, want)
+		}
+	}
+	for name, want := range map[string]int64{s0: 2, s1: 4, s2: 3} {
+		if have := lastseen(name); have != want {
+			t.Errorf("signer %s: last seen mismatch: have %d, want %d", name, have, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+)
+
+// maxVoteHistoryBlocks is the maximum number of blocks the vote history can be
+// requested for at once, as it is regenerated by replaying every header.
+const maxVoteHistoryBlocks = 8192
+
+// proposalsKey is the database key the proposals of the local signer are stored
+// under, so they survive restarts.
+var proposalsKey = []byte("clique-proposals")
+
+// errInvalidHistoryRange is returned if the vote history is requested for an
+// empty or too large block range.
+var errInvalidHistoryRange = errors.New("invalid vote history range")
+
+// VoteRecord is a single vote cast by a signer in a block of the chain.
+type VoteRecord struct {
+	Block     uint64         `json:"block"`     // Block number the vote was cast in
+	Hash      common.Hash    `json:"hash"`      // Block hash the vote was cast in
+	Signer    common.Address `json:"signer"`    // Authorized signer that cast this vote
+	Address   common.Address `json:"address"`   // Account being voted on to change its authorization
+	Authorize bool           `json:"authorize"` // Whetvchain to authorize or deauthorize the voted account
+	Tallied   bool           `json:"tallied"`   // Whetvchain the vote was meaningful and thus counted
+}
+
+// SignerChange is a change of the signer set caused by a passed proposal.
+type SignerChange struct {
+	Block      uint64         `json:"block"`      // Block number the change took effect in
+	Hash       common.Hash    `json:"hash"`       // Block hash the change took effect in
+	Address    common.Address `json:"address"`    // Account whose authorization changed
+	Authorized bool           `json:"authorized"` // Whetvchain the account was added or removed
+}
+
+// VoteHistory is the log of the votes cast and the resulting signer changes over
+// a range of blocks.
+type VoteHistory struct {
+	From    uint64          `json:"from"`    // First block of the range (inclusive)
+	To      uint64          `json:"to"`      // Last block of the range (inclusive)
+	Votes   []*VoteRecord   `json:"votes"`   // Votes cast in chronological order
+	Changes []*SignerChange `json:"changes"` // Signer changes in chronological order
+}
+
+// voteHistory regenerates the votes and signer changes of the canonical blocks
+// in the given range, by replaying the headers one by one on top of the voting
+// snapshot preceding the range.
+func (c *Clique) voteHistory(chain consensus.ChainReader, from, to uint64) (*VoteHistory, error) {
+	if to < from || to-from >= maxVoteHistoryBlocks {
+		return nil, errInvalidHistoryRange
+	}
+	history := &VoteHistory{
+		From:    from,
+		To:      to,
+		Votes:   []*VoteRecord{},
+		Changes: []*SignerChange{},
+	}
+	// The genesis block contains no votes, start from the snapshot it defines
+	if from == 0 {
+		from = 1
+	}
+	if to < from {
+		return history, nil
+	}
+	parent := chain.GetHeaderByNumber(from - 1)
+	if parent == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := c.snapshot(chain, parent.Number.Uint64(), parent.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for number := from; number <= to; number++ {
+		header := chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		if header.ParentHash != snap.Hash {
+			return nil, errInvalidVotingChain
+		}
+		next, err := snap.apply([]*types.Header{header})
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", number, err)
+		}
+		hash := header.Hash()
+
+		// Record the vote cast by the header, if any
+		if header.Coinbase != (common.Address{}) {
+			signer, err := ecrecover(header, c.signatures)
+			if err != nil {
+				return nil, err
+			}
+			authorize := bytes.Equal(header.Nonce[:], nonceAuthVote)
+			history.Votes = append(history.Votes, &VoteRecord{
+				Block:     number,
+				Hash:      hash,
+				Signer:    signer,
+				Address:   header.Coinbase,
+				Authorize: authorize,
+				Tallied:   snap.validVote(header.Coinbase, authorize),
+			})
+		}
+		// Record any change of the signer set caused by the header, in address
+		// order to keep the replay deterministic
+		for _, signer := range next.signers() {
+			if _, ok := snap.Signers[signer]; !ok {
+				history.Changes = append(history.Changes, &SignerChange{Block: number, Hash: hash, Address: signer, Authorized: true})
+			}
+		}
+		for _, signer := range snap.signers() {
+			if _, ok := next.Signers[signer]; !ok {
+				history.Changes = append(history.Changes, &SignerChange{Block: number, Hash: hash, Address: signer, Authorized: false})
+			}
+		}
+		snap = next
+	}
+	return history, nil
+}
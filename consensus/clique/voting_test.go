@@ -0,0 +1,220 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/core/vm"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/params"
+	"github.com/etvchaineum/go-etvchaineum/rpc"
+)
+
+// newTesterChain creates a clique chain with the given initial signers and the
+// given votes cast in consecutive blocks. The difficulty function, if set, is
+// used to assign the block difficulties, which are otherwise all in-turn.
+func newTesterChain(t *testing.T, accounts *testerAccountPool, signers []string, votes []testerVote, difficulty func(int) *big.Int) (*core.BlockChain, *Clique, []*types.Block) {
+	// Create the genesis block with the initial set of signers
+	genesis := &core.Genesis{
+		ExtraData: make([]byte, extraVanity+common.AddressLength*len(signers)+extraSeal),
+	}
+	accounts.checkpoint(&types.Header{Extra: genesis.ExtraData}, signers)
+
+	db := echdb.NewMemDatabase()
+	genesis.Commit(db)
+
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+
+	engine := New(config.Clique, db)
+	engine.fakeDiff = difficulty == nil
+
+	blocks, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, len(votes), func(j int, gen *core.BlockGen) {
+		gen.SetCoinbase(accounts.address(votes[j].voted))
+		if votes[j].auth {
+			var nonce types.BlockNonce
+			copy(nonce[:], nonceAuthVote)
+			gen.SetNonce(nonce)
+		}
+	})
+	for j, block := range blocks {
+		header := block.Header()
+		if j > 0 {
+			header.ParentHash = blocks[j-1].Hash()
+		}
+		header.Extra = make([]byte, extraVanity+extraSeal)
+		header.Difficulty = diffInTurn
+		if difficulty != nil {
+			header.Difficulty = difficulty(j)
+		}
+		accounts.sign(header, votes[j].signer)
+		blocks[j] = block.WithSeal(header)
+	}
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		chain.Stop()
+		t.Fatalf("failed to import block %d: %v", n, err)
+	}
+	return chain, engine, blocks
+}
+
+// Tests that the proposals of the local signer survive a restart of the engine.
+func TestProposalPersistence(t *testing.T) {
+	db := echdb.NewMemDatabase()
+	config := &params.CliqueConfig{Period: 1, Epoch: 30000}
+
+	var (
+		added   = common.Address{0x01}
+		dropped = common.Address{0x02}
+		removed = common.Address{0x03}
+	)
+	api := &API{clique: New(config, db)}
+	for address, auth := range map[common.Address]bool{added: true, dropped: false, removed: true} {
+		if err := api.Propose(address, auth); err != nil {
+			t.Fatalf("failed to propose %x: %v", address, err)
+		}
+	}
+	if err := api.Discard(removed); err != nil {
+		t.Fatalf("failed to discard %x: %v", removed, err)
+	}
+	// Restart the engine and ensure the proposals are restored
+	api = &API{clique: New(config, db)}
+
+	want := map[common.Address]bool{added: true, dropped: false}
+	if have := api.Proposals(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("restored proposals mismatch: have %v, want %v", have, want)
+	}
+}
+
+// failingDatabase is an in-memory database rejecting all writes while failing.
+type failingDatabase struct {
+	*echdb.MemDatabase
+	failing bool
+}
+
+func (db *failingDatabase) Put(key []byte, value []byte) error {
+	if db.failing {
+		return errors.New("write failed")
+	}
+	return db.MemDatabase.Put(key, value)
+}
+
+// Tests that proposals failing to be persisted are rolled back, so the signer
+// doesn't vote on anything that would be lost on a restart.
+func TestProposalRollback(t *testing.T) {
+	db := &failingDatabase{MemDatabase: echdb.NewMemDatabase()}
+	api := &API{clique: New(&params.CliqueConfig{Period: 1, Epoch: 30000}, db)}
+
+	var (
+		kept    = common.Address{0x01}
+		changed = common.Address{0x02}
+		added   = common.Address{0x03}
+	)
+	for _, address := range []common.Address{kept, changed} {
+		if err := api.Propose(address, true); err != nil {
+			t.Fatalf("failed to propose %x: %v", address, err)
+		}
+	}
+	db.failing = true
+	if err := api.Propose(added, true); err == nil {
+		t.Errorf("unpersisted proposal of new address succeeded")
+	}
+	if err := api.Propose(changed, false); err == nil {
+		t.Errorf("unpersisted proposal change succeeded")
+	}
+	if err := api.Discard(kept); err == nil {
+		t.Errorf("unpersisted discard succeeded")
+	}
+	want := map[common.Address]bool{kept: true, changed: true}
+	if have := api.Proposals(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("proposals mismatch after failed writes: have %v, want %v", have, want)
+	}
+}
+
+// Tests that the vote history is regenerated correctly from the headers, both
+// for the entire chain and for a subrange of it.
+func TestVoteHistory(t *testing.T) {
+	accounts := newTesterAccountPool()
+	votes := []testerVote{
+		{signer: "A", voted: "B", auth: true}, // Passes, B added
+		{signer: "B"},
+		{signer: "A", voted: "C", auth: true},
+		{signer: "B", voted: "C", auth: true}, // Passes, C added
+		{signer: "C", voted: "A"},
+		{signer: "A", voted: "C", auth: true}, // Pointless, C already authorized
+	}
+	chain, engine, blocks := newTesterChain(t, accounts, []string{"A"}, votes, nil)
+	defer chain.Stop()
+
+	api := &API{chain: chain, clique: engine}
+
+	// Retrieve the entire history and ensure every vote and change is reported
+	history, err := api.GetVoteHistory(rpc.BlockNumber(0), nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve vote history: %v", err)
+	}
+	if history.From != 0 || history.To != uint64(len(blocks)) {
+		t.Fatalf("history range mismatch: have %d-%d, want 0-%d", history.From, history.To, len(blocks))
+	}
+	wantVotes := []*VoteRecord{
+		{Block: 1, Hash: blocks[0].Hash(), Signer: accounts.address("A"), Address: accounts.address("B"), Authorize: true, Tallied: true},
+		{Block: 3, Hash: blocks[2].Hash(), Signer: accounts.address("A"), Address: accounts.address("C"), Authorize: true, Tallied: true},
+		{Block: 4, Hash: blocks[3].Hash(), Signer: accounts.address("B"), Address: accounts.address("C"), Authorize: true, Tallied: true},
+		{Block: 5, Hash: blocks[4].Hash(), Signer: accounts.address("C"), Address: accounts.address("A"), Authorize: false, Tallied: true},
+		{Block: 6, Hash: blocks[5].Hash(), Signer: accounts.address("A"), Address: accounts.address("C"), Authorize: true, Tallied: false},
+	}
+	if !reflect.DeepEqual(history.Votes, wantVotes) {
+		t.Errorf("votes mismatch:\nhave %+v\nwant %+v", history.Votes, wantVotes)
+	}
+	wantChanges := []*SignerChange{
+		{Block: 1, Hash: blocks[0].Hash(), Address: accounts.address("B"), Authorized: true},
+		{Block: 4, Hash: blocks[3].Hash(), Address: accounts.address("C"), Authorized: true},
+	}
+	if !reflect.DeepEqual(history.Changes, wantChanges) {
+		t.Errorf("signer changes mismatch:\nhave %+v\nwant %+v", history.Changes, wantChanges)
+	}
+	// Retrieve a subrange and ensure it's replayed from the correct snapshot
+	to := rpc.BlockNumber(4)
+	history, err = api.GetVoteHistory(rpc.BlockNumber(3), &to)
+	if err != nil {
+		t.Fatalf("failed to retrieve partial vote history: %v", err)
+	}
+	if !reflect.DeepEqual(history.Votes, wantVotes[1:3]) {
+		t.Errorf("partial votes mismatch:\nhave %+v\nwant %+v", history.Votes, wantVotes[1:3])
+	}
+	if !reflect.DeepEqual(history.Changes, wantChanges[1:]) {
+		t.Errorf("partial signer changes mismatch:\nhave %+v\nwant %+v", history.Changes, wantChanges[1:])
+	}
+	// Ensure invalid ranges are rejected
+	if _, err := api.GetVoteHistory(rpc.BlockNumber(5), &to); err != errInvalidHistoryRange {
+		t.Errorf("reversed range error mismatch: have %v, want %v", err, errInvalidHistoryRange)
+	}
+	future := rpc.BlockNumber(len(blocks) + 1)
+	if _, err := api.GetVoteHistory(rpc.BlockNumber(0), &future); err != errUnknownBlock {
+		t.Errorf("future range error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/common"
 	"github.com/etvchaineum/go-etvchaineum/core/state"
 	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/p2p"
 	"github.com/etvchaineum/go-etvchaineum/params"
 	"github.com/etvchaineum/go-etvchaineum/rpc"
 )
@@ -114,3 +115,33 @@ type PoW interface {
 	// Hashrate returns the current mining hashrate of a PoW consensus engine.
 	Hashrate() float64
 }
+
+// ChainHeadReporter is a consensus engine reporting statistics of the canonical
+// blocks, which needs to be notified of every new chain head.
+type ChainHeadReporter interface {
+	Engine
+
+	// ReportChainHead notifies the engine of a new canonical chain head.
+	ReportChainHead(chain ChainReader, head *types.Header)
+}
+
+// Handler is a consensus engine exchanging its own messages with the other nodes
+// of the network in order to seal blocks, which needs to be running alongside the
+// block production.
+type Handler interface {
+	Engine
+
+	// Start starts processing the consensus messages on top of the given chain.
+	Start(chain ChainReader) error
+
+	// Stop stops processing the consensus messages.
+	Stop() error
+
+	// NewChainHead notifies the engine of a new canonical chain head, ending the
+	// agreement on the block at its height.
+	NewChainHead(head *types.Header)
+
+	// Protocols returns the p2p protocols the consensus messages are exchanged over,
+	// which the node needs to run alongside its own.
+	Protocols() []p2p.Protocol
+}
@@ -729,7 +729,7 @@ func TestConcurrentDiskCacheGeneration(t *testing.T) {
 
 		go func(idx int) {
 			defer pend.Done()
-			echash := New(Config{cachedir, 0, 1, "", 0, 0, ModeNormal}, nil, false)
+			echash := New(Config{cachedir, 0, 1, "", 0, 0, ModeNormal, "", 0}, nil, false)
 			defer echash.Close()
 			if err := echash.VerifySeal(nil, block.Header()); err != nil {
 				t.Errorf("proc %d: block verification failed: %v", idx, err)
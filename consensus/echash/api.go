@@ -65,18 +65,27 @@ func (api *API) GetWork() ([4]string, error) {
 // SubmitWork can be used by external miner to submit their POW solution.
 // It returns an indication if the work was accepted.
 // Note either an invalid solution, a stale work a non-existent work will return false.
-func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash) bool {
+//
+// The optional id is the one the miner submits its hash rate with, attributing
+// the solution to it in the worker statistics.
+func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash, id *common.Hash) bool {
 	if api.echash.config.PowMode != ModeNormal && api.echash.config.PowMode != ModeTest {
 		return false
 	}
 
-	var errc = make(chan error, 1)
-
+	var (
+		errc   = make(chan error, 1)
+		worker common.Hash
+	)
+	if id != nil {
+		worker = *id
+	}
 	select {
 	case api.echash.submitWorkCh <- &mineResult{
 		nonce:     nonce,
 		mixDigest: digest,
 		hash:      hash,
+		worker:    worker.Hex(),
 		errc:      errc,
 	}:
 	case <-api.echash.exitCh:
@@ -116,3 +125,12 @@ func (api *API) SubmitHashRate(rate hexutil.Uint64, id common.Hash) bool {
 func (api *API) GetHashrate() uint64 {
 	return uint64(api.echash.Hashrate())
 }
+
+// GetWorkers returns the statistics of the remote workers, keyed by the id they
+// submit their hash rate with, or by their stratum worker name.
+func (api *API) GetWorkers() (map[string]*WorkerStats, error) {
+	if api.echash.config.PowMode != ModeNormal && api.echash.config.PowMode != ModeTest {
+		return nil, errors.New("not supported")
+	}
+	return api.echash.workers.stats(), nil
+}
@@ -506,49 +506,50 @@ func (echash *Ethash) verifySeal(chain consensus.ChainReader, header *types.Head
 		return errInvalidDifficulty
 	}
 	// Recompute the digest and PoW values
-	number := header.Number.Uint64()
+	digest, result := echash.hashimoto(header.Number.Uint64(), echash.SealHash(header).Bytes(), header.Nonce.Uint64(), fulldag)
 
-	var (
-		digest []byte
-		result []byte
-	)
+	// Verify the calculated values against the ones provided in the header
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		return errInvalidMixDigest
+	}
+	target := new(big.Int).Div(two256, header.Difficulty)
+	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
+		return errInvalidPoW
+	}
+	return nil
+}
+
+// hashimoto computes the mix digest and the PoW value of a nonce for the given
+// seal hash at the given block number. If fulldag is requested and the dataset is
+// already generated, the fast-but-heavy dataset is used, otherwise the slow-but-
+// light verification cache.
+func (echash *Ethash) hashimoto(number uint64, hash []byte, nonce uint64, fulldag bool) (digest []byte, result []byte) {
 	// If fast-but-heavy PoW verification was requested, use an echash dataset
 	if fulldag {
 		dataset := echash.dataset(number, true)
 		if dataset.generated() {
-			digest, result = hashimotoFull(dataset.dataset, echash.SealHash(header).Bytes(), header.Nonce.Uint64())
+			digest, result = hashimotoFull(dataset.dataset, hash, nonce)
 
 			// Datasets are unmapped in a finalizer. Ensure that the dataset stays alive
 			// until after the call to hashimotoFull so it's not unmapped while being used.
 			runtime.KeepAlive(dataset)
-		} else {
-			// Dataset not yet generated, don't hang, use a cache instead
-			fulldag = false
+			return digest, result
 		}
+		// Dataset not yet generated, don't hang, use a cache instead
 	}
 	// If slow-but-light PoW verification was requested (or DAG not yet ready), use an echash cache
-	if !fulldag {
-		cache := echash.cache(number)
-
-		size := datasetSize(number)
-		if echash.config.PowMode == ModeTest {
-			size = 32 * 1024
-		}
-		digest, result = hashimotoLight(size, cache.cache, echash.SealHash(header).Bytes(), header.Nonce.Uint64())
+	cache := echash.cache(number)
 
-		// Caches are unmapped in a finalizer. Ensure that the cache stays alive
-		// until after the call to hashimotoLight so it's not unmapped while being used.
-		runtime.KeepAlive(cache)
-	}
-	// Verify the calculated values against the ones provided in the header
-	if !bytes.Equal(header.MixDigest[:], digest) {
-		return errInvalidMixDigest
+	size := datasetSize(number)
+	if echash.config.PowMode == ModeTest {
+		size = 32 * 1024
 	}
-	target := new(big.Int).Div(two256, header.Difficulty)
-	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
-		return errInvalidPoW
-	}
-	return nil
+	digest, result = hashimotoLight(size, cache.cache, hash, nonce)
+
+	// Caches are unmapped in a finalizer. Ensure that the cache stays alive
+	// until after the call to hashimotoLight so it's not unmapped while being used.
+	runtime.KeepAlive(cache)
+	return digest, result
 }
 
 // Prepare implements consensus.Engine, initializing the difficulty field of a
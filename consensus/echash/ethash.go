@@ -50,7 +50,7 @@ var (
 	two256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 
 	// sharedEthash is a full instance that can be shared between multiple users.
-	sharedEthash = New(Config{"", 3, 0, "", 1, 0, ModeNormal}, nil, false)
+	sharedEthash = New(Config{"", 3, 0, "", 1, 0, ModeNormal, "", 0}, nil, false)
 
 	// algorithmRevision is the data structure version used for file naming.
 	algorithmRevision = 23
@@ -403,6 +403,9 @@ type Config struct {
 	DatasetsInMem  int
 	DatasetsOnDisk int
 	PowMode        Mode
+
+	StratumAddr       string // Listening address of the stratum mining server (disabled if empty)
+	StratumDifficulty uint64 // Difficulty of the shares accepted by the stratum mining server
 }
 
 // sealTask wraps a seal block with relative result channel for remote sealer thread.
@@ -416,6 +419,8 @@ type mineResult struct {
 	nonce     types.BlockNonce
 	mixDigest common.Hash
 	hash      common.Hash
+	worker    string // Remote worker submitting the solution
+	share     bool   // Whetvchain the solution was already accounted as a share of the worker
 
 	errc chan error
 }
@@ -455,6 +460,8 @@ type Ethash struct {
 	submitWorkCh chan *mineResult // Channel used for remote sealer to submit their mining result
 	fetchRateCh  chan chan uint64 // Channel used to gather submitted hash rate for local or remote sealer.
 	submitRateCh chan *hashrate   // Channel used for remote sealer to submit their mining hashrate
+	stratum      *stratumServer   // Stratum mining server pushing the remote sealer's work
+	workers      *workerSet       // Statistics of the remote workers
 
 	// The fields below are hooks for testing
 	shared    *Ethash       // Shared PoW verifier to avoid cache regeneration
@@ -491,8 +498,16 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 		submitWorkCh: make(chan *mineResult),
 		fetchRateCh:  make(chan chan uint64),
 		submitRateCh: make(chan *hashrate),
+		workers:      newWorkerSet(),
 		exitCh:       make(chan chan error),
 	}
+	if config.StratumAddr != "" {
+		stratum, err := newStratumServer(echash, config.StratumAddr, config.StratumDifficulty)
+		if err != nil {
+			log.Error("Failed to start stratum mining server", "addr", config.StratumAddr, "err", err)
+		}
+		echash.stratum = stratum
+	}
 	go echash.remote(notify, noverify)
 	return echash
 }
@@ -511,6 +526,7 @@ func NewTester(notify []string, noverify bool) *Ethash {
 		submitWorkCh: make(chan *mineResult),
 		fetchRateCh:  make(chan chan uint64),
 		submitRateCh: make(chan *hashrate),
+		workers:      newWorkerSet(),
 		exitCh:       make(chan chan error),
 	}
 	go echash.remote(notify, noverify)
@@ -580,6 +596,11 @@ func (echash *Ethash) Close() error {
 		echash.exitCh <- errc
 		err = <-errc
 		close(echash.exitCh)
+
+		if echash.stratum != nil {
+			echash.stratum.close()
+		}
+		echash.workers.close()
 	})
 	return err
 }
@@ -248,18 +248,29 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 		currentBlock = block
 		works[hash] = block
 	}
+	// reject accounts a rejected pow solution to the remote worker submitting it,
+	// unless it was already accounted as a valid share.
+	reject := func(result *mineResult, stale bool) {
+		if !result.share {
+			echash.workers.reject(result.worker, stale)
+		}
+	}
 	// submitWork verifies the submitted pow solution, returning
 	// whetvchain the solution was accepted or not (not can be both a bad pow as well as
 	// any other error, like no pending work or stale mining result).
-	submitWork := func(nonce types.BlockNonce, mixDigest common.Hash, sealhash common.Hash) bool {
+	submitWork := func(result *mineResult) bool {
+		nonce, mixDigest, sealhash := result.nonce, result.mixDigest, result.hash
+
 		if currentBlock == nil {
 			log.Error("Pending work without block", "sealhash", sealhash)
+			reject(result, true)
 			return false
 		}
 		// Make sure the work submitted is present
 		block := works[sealhash]
 		if block == nil {
 			log.Warn("Work submitted but none pending", "sealhash", sealhash, "curnumber", currentBlock.NumberU64())
+			reject(result, true)
 			return false
 		}
 		// Verify the correctness of submitted result.
@@ -271,6 +282,7 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 		if !noverify {
 			if err := echash.verifySeal(nil, header, true); err != nil {
 				log.Warn("Invalid proof-of-work submitted", "sealhash", sealhash, "elapsed", time.Since(start), "err", err)
+				reject(result, false)
 				return false
 			}
 		}
@@ -289,6 +301,10 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 			select {
 			case results <- solution:
 				log.Debug("Work submitted is acceptable", "number", solution.NumberU64(), "sealhash", sealhash, "hash", solution.Hash())
+				if !result.share {
+					echash.workers.accept(result.worker, solution.Difficulty())
+				}
+				echash.workers.sealed(result.worker)
 				return true
 			default:
 				log.Warn("Sealing result is not read by miner", "mode", "remote", "sealhash", sealhash)
@@ -297,6 +313,7 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 		}
 		// The submitted block is too old to accept, drop it.
 		log.Warn("Work submitted is too old", "number", solution.NumberU64(), "sealhash", sealhash, "hash", solution.Hash())
+		reject(result, true)
 		return false
 	}
 
@@ -314,6 +331,9 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 
 			// Notify and requested URLs of the new work availability
 			notifyWork()
+			if echash.stratum != nil {
+				echash.stratum.notify(work.block)
+			}
 
 		case work := <-echash.fetchWorkCh:
 			// Return current mining work to remote miner.
@@ -325,7 +345,7 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 
 		case result := <-echash.submitWorkCh:
 			// Verify submitted PoW solution based on maintained mining blocks.
-			if submitWork(result.nonce, result.mixDigest, result.hash) {
+			if submitWork(result) {
 				result.errc <- nil
 			} else {
 				result.errc <- errInvalidSealResult
@@ -334,6 +354,7 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 		case result := <-echash.submitRateCh:
 			// Trace remote sealer's hash rate by submitted value.
 			rates[result.id] = hashrate{rate: result.rate, ping: time.Now()}
+			echash.workers.reportHashrate(result.id.Hex(), result.rate)
 			close(result.done)
 
 		case req := <-echash.fetchRateCh:
@@ -352,6 +373,9 @@ func (echash *Ethash) remote(notify []string, noverify bool) {
 					delete(rates, id)
 				}
 			}
+			// Clear the statistics of silent workers
+			echash.workers.expire()
+
 			// Clear stale pending blocks
 			if currentBlock != nil {
 				for hash, block := range works {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
 	"github.com/etvchaineum/go-etvchaineum/core/types"
 )
 
@@ -181,7 +182,7 @@ func TestStaleSubmission(t *testing.T) {
 		for _, h := range c.headers {
 			echash.Seal(nil, types.NewBlockWithHeader(h), results, nil)
 		}
-		if res := api.SubmitWork(fakeNonce, echash.SealHash(c.headers[c.submitIndex]), fakeDigest); res != c.submitRes {
+		if res := api.SubmitWork(fakeNonce, echash.SealHash(c.headers[c.submitIndex]), fakeDigest, nil); res != c.submitRes {
 			t.Errorf("case %d submit result mismatch, want %t, get %t", id+1, c.submitRes, res)
 		}
 		if !c.submitRes {
@@ -209,3 +210,78 @@ func TestStaleSubmission(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the remote sealer keeps track of the statistics of the remote
+// workers submitting their hash rate and work.
+func TestRemoteWorkerStats(t *testing.T) {
+	echash := NewTester(nil, false)
+	defer echash.Close()
+	echash.SetThreads(-1)
+
+	api := &API{echash}
+
+	var (
+		rig1 = common.HexToHash("0x01")
+		rig2 = common.HexToHash("0x02")
+	)
+	api.SubmitHashRate(hexutil.Uint64(1000), rig1)
+	api.SubmitHashRate(hexutil.Uint64(2000), rig2)
+
+	// Push some work and find a valid solution for it
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(10)}
+	results := make(chan *types.Block, 1)
+	echash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	sealhash := echash.SealHash(header)
+	target := new(big.Int).Div(two256, header.Difficulty)
+
+	var (
+		nonce  uint64
+		digest []byte
+	)
+	for ; ; nonce++ {
+		var result []byte
+		if digest, result = echash.hashimoto(1, sealhash.Bytes(), nonce, false); new(big.Int).SetBytes(result).Cmp(target) <= 0 {
+			break
+		}
+	}
+	// Submit various valid, invalid and stale solutions
+	if !api.SubmitWork(types.EncodeNonce(nonce), sealhash, common.BytesToHash(digest), &rig1) {
+		t.Fatalf("valid solution rejected")
+	}
+	if api.SubmitWork(types.EncodeNonce(nonce+1), sealhash, common.BytesToHash(digest), &rig1) {
+		t.Fatalf("invalid solution accepted")
+	}
+	if api.SubmitWork(types.EncodeNonce(nonce), common.HexToHash("0xdeadbeef"), common.BytesToHash(digest), &rig2) {
+		t.Fatalf("stale solution accepted")
+	}
+	if api.SubmitWork(types.EncodeNonce(nonce+1), sealhash, common.BytesToHash(digest), nil) {
+		t.Fatalf("anonymous invalid solution accepted")
+	}
+	workers, err := api.GetWorkers()
+	if err != nil {
+		t.Fatalf("failed to retrieve worker stats: %v", err)
+	}
+	want := map[string]WorkerStats{
+		rig1.Hex():          {ReportedHashrate: 1000, Accepted: 1, Invalid: 1, Blocks: 1},
+		rig2.Hex():          {ReportedHashrate: 2000, Stale: 1},
+		common.Hash{}.Hex(): {Invalid: 1},
+	}
+	if len(workers) != len(want) {
+		t.Fatalf("worker count mismatch: have %d, want %d", len(workers), len(want))
+	}
+	for id, stats := range want {
+		have, ok := workers[id]
+		if !ok {
+			t.Errorf("worker %s: missing", id)
+			continue
+		}
+		if have.LastSeen == 0 {
+			t.Errorf("worker %s: last seen not set", id)
+		}
+		have.EffectiveHashrate, have.LastSeen = 0, 0
+		if *have != stats {
+			t.Errorf("worker %s: stats mismatch: have %+v, want %+v", id, *have, stats)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package echash
+
+import (
+	"bufio"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/log"
+)
+
+const (
+	// stratumProtocol is the stratum dialect spoken by the server.
+	stratumProtocol = "EthereumStratum/1.0.0"
+
+	// defaultStratumDifficulty is the share difficulty used if none is configured,
+	// corresponding to a stratum difficulty of 1.
+	defaultStratumDifficulty = 1 << 32
+
+	stratumMaxLineSize  = 16 * 1024        // Maximum size of a single stratum request
+	stratumMaxSessions  = 1 << 16          // Number of distinct 2 byte extranonces, limiting the connected clients
+	stratumQueueSize    = 64               // Number of messages queued to a connection before dropping it
+	stratumIdleTimeout  = 10 * time.Minute // Time after which silent connections are dropped
+	stratumWriteTimeout = 10 * time.Second // Time allowed to write a message to a connection
+)
+
+// stratumError is an error returned to a stratum client, encoded as the triplet
+// of code, message and traceback.
+type stratumError struct {
+	code    int
+	message string
+}
+
+func (err *stratumError) Error() string { return err.message }
+
+// MarshalJSON implements json.Marshaler.
+func (err *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{err.code, err.message, nil})
+}
+
+var (
+	errStratumUnknown       = &stratumError{20, "Other/Unknown"}
+	errStratumJobNotFound   = &stratumError{21, "Job not found"}
+	errStratumDuplicate     = &stratumError{22, "Duplicate share"}
+	errStratumLowDifficulty = &stratumError{23, "Low difficulty share"}
+	errStratumUnauthorized  = &stratumError{24, "Unauthorized worker"}
+	errStratumNotSubscribed = &stratumError{25, "Not subscribed"}
+)
+
+// stratumRequest is a request sent by a stratum client.
+type stratumRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// param returns the request parameter at the given index as a string, or the
+// empty string if it's missing or not a string.
+func (req *stratumRequest) param(index int) string {
+	if index >= len(req.Params) {
+		return ""
+	}
+	var param string
+	json.Unmarshal(req.Params[index], &param)
+	return param
+}
+
+// stratumResponse is the response to a stratum request.
+type stratumResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+// stratumNotification is a message pushed to a stratum client.
+type stratumNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// stratumJob is a work package pushed to the stratum clients.
+type stratumJob struct {
+	id     string              // Identifier of the job announced to the clients
+	number uint64              // Number of the block being sealed
+	hash   common.Hash         // Seal hash of the block
+	seed   common.Hash         // Seed hash of the block's DAG
+	target *big.Int            // Boundary condition of the block
+	shares map[uint64]struct{} // Nonces submitted for the job to reject duplicates
+}
+
+// notification assembles the stratum notification announcing the job.
+func (job *stratumJob) notification() *stratumNotification {
+	return &stratumNotification{
+		Method: "mining.notify",
+		Params: []interface{}{job.id, hex.EncodeToString(job.seed[:]), hex.EncodeToString(job.hash[:]), true},
+	}
+}
+
+// stratumServer is a TCP server pushing the work of the remote sealer to miners
+// speaking the EthereumStratum/1.0.0 protocol, validating their shares against
+// the share difficulty and submitting the block solutions among them.
+type stratumServer struct {
+	echash     *Ethash
+	listener   net.Listener
+	difficulty *big.Int // Share difficulty of the accepted shares
+	target     *big.Int // Boundary condition of the accepted shares
+
+	sessions    map[*stratumSession]struct{} // Currently connected clients
+	extranonces map[uint16]struct{}          // Extranonces assigned to the connected clients
+	nextNonce   uint16                       // Extranonce to try assigning to the next client
+	jobs        map[string]*stratumJob       // Recent jobs shares are accepted for
+	current     *stratumJob                  // Latest job pushed to the clients
+	closing     bool                         // Whetvchain the server is shutting down
+	lock        sync.Mutex                   // Protects the fields above
+
+	wg sync.WaitGroup
+}
+
+// newStratumServer starts a stratum server for the echash remote sealer on the
+// given listening address, accepting shares of the given difficulty.
+func newStratumServer(echash *Ethash, addr string, difficulty uint64) (*stratumServer, error) {
+	if difficulty == 0 {
+		difficulty = defaultStratumDifficulty
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &stratumServer{
+		echash:      echash,
+		listener:    listener,
+		difficulty:  new(big.Int).SetUint64(difficulty),
+		target:      new(big.Int).Div(two256, new(big.Int).SetUint64(difficulty)),
+		sessions:    make(map[*stratumSession]struct{}),
+		extranonces: make(map[uint16]struct{}),
+		jobs:        make(map[string]*stratumJob),
+	}
+	server.wg.Add(1)
+	go server.loop()
+
+	log.Info("Stratum mining server started", "addr", listener.Addr(), "difficulty", difficulty)
+	return server, nil
+}
+
+// close stops accepting connections and disconnects all the clients.
+func (s *stratumServer) close() {
+	s.listener.Close()
+
+	s.lock.Lock()
+	s.closing = true
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+}
+
+// loop accepts the incoming stratum connections.
+func (s *stratumServer) loop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				time.Sleep(time.Second)
+				continue
+			}
+			return
+		}
+		session := &stratumSession{
+			server:  s,
+			conn:    conn,
+			workers: make(map[string]struct{}),
+			queue:   make(chan interface{}, stratumQueueSize),
+			closed:  make(chan struct{}),
+		}
+		s.lock.Lock()
+		if s.closing {
+			s.lock.Unlock()
+			conn.Close()
+			return
+		}
+		extranonce, ok := s.allocExtranonce()
+		if !ok {
+			s.lock.Unlock()
+			log.Warn("Rejecting stratum client, too many connections", "addr", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		session.nonce, session.extranonce = extranonce, fmt.Sprintf("%04x", extranonce)
+		s.sessions[session] = struct{}{}
+		s.lock.Unlock()
+
+		s.wg.Add(2)
+		go session.readLoop()
+		go session.writeLoop()
+	}
+}
+
+// allocExtranonce assigns an extranonce not used by any connected client, so the
+// nonce ranges searched by the clients never overlap. It returns false if all of
+// them are taken. The caller must hold the server lock.
+func (s *stratumServer) allocExtranonce() (uint16, bool) {
+	if len(s.extranonces) >= stratumMaxSessions {
+		return 0, false
+	}
+	for {
+		extranonce := s.nextNonce
+		s.nextNonce++
+		if _, ok := s.extranonces[extranonce]; !ok {
+			s.extranonces[extranonce] = struct{}{}
+			return extranonce, true
+		}
+	}
+}
+
+// notify announces the new work of the remote sealer to all the authorized
+// clients, dropping the jobs too old to be accepted anymore.
+func (s *stratumServer) notify(block *types.Block) {
+	hash := s.echash.SealHash(block.Header())
+	id := hex.EncodeToString(hash[:8])
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		job = &stratumJob{
+			id:     id,
+			number: block.NumberU64(),
+			hash:   hash,
+			seed:   common.BytesToHash(SeedHash(block.NumberU64())),
+			target: new(big.Int).Div(two256, block.Difficulty()),
+			shares: make(map[uint64]struct{}),
+		}
+		s.jobs[id] = job
+	}
+	s.current = job
+
+	for id, job := range s.jobs {
+		if job.number+staleThreshold <= block.NumberU64() {
+			delete(s.jobs, id)
+		}
+	}
+	notification := job.notification()
+	for session := range s.sessions {
+		if session.authorized() {
+			session.send(notification)
+		}
+	}
+}
+
+// submit validates a share submitted by a worker, submitting it to the remote
+// sealer if it also satisfies the block difficulty.
+func (s *stratumServer) submit(name string, id string, nonce uint64) *stratumError {
+	s.lock.Lock()
+	job, ok := s.jobs[id]
+	if !ok {
+		s.lock.Unlock()
+		s.echash.workers.reject(name, true)
+		return errStratumJobNotFound
+	}
+	if _, ok := job.shares[nonce]; ok {
+		s.lock.Unlock()
+		s.echash.workers.reject(name, false)
+		return errStratumDuplicate
+	}
+	job.shares[nonce] = struct{}{}
+	s.lock.Unlock()
+
+	// Verify the share outside of the lock, it may need to generate a cache
+	digest, result := s.echash.hashimoto(job.number, job.hash.Bytes(), nonce, true)
+	value := new(big.Int).SetBytes(result)
+
+	if value.Cmp(s.target) > 0 {
+		s.echash.workers.reject(name, false)
+		return errStratumLowDifficulty
+	}
+	s.echash.workers.accept(name, s.difficulty)
+
+	// If the share seals the block, submit it to the remote sealer
+	if value.Cmp(job.target) <= 0 {
+		errc := make(chan error, 1)
+		select {
+		case s.echash.submitWorkCh <- &mineResult{
+			nonce:     types.EncodeNonce(nonce),
+			mixDigest: common.BytesToHash(digest),
+			hash:      job.hash,
+			worker:    name,
+			share:     true,
+			errc:      errc,
+		}:
+		case <-s.echash.exitCh:
+			return nil
+		}
+		if err := <-errc; err != nil {
+			log.Warn("Stratum block solution rejected", "worker", name, "number", job.number, "sealhash", job.hash, "err", err)
+			return nil
+		}
+		log.Info("Stratum worker sealed block", "worker", name, "number", job.number, "sealhash", job.hash)
+	}
+	return nil
+}
+
+// stratumSession is a connection of a stratum client, which may submit shares on
+// behalf of multiple workers.
+type stratumSession struct {
+	server     *stratumServer
+	conn       net.Conn
+	extranonce string              // Hex encoded 2 byte nonce prefix assigned to the client
+	nonce      uint16              // Numeric value of the extranonce, released on disconnect
+	subscribed bool                // Whetvchain the client subscribed to the work
+	workers    map[string]struct{} // Workers authorized over the connection
+
+	queue  chan interface{} // Messages waiting to be written to the client
+	closed chan struct{}    // Closed when the connection is torn down
+}
+
+// authorized returns whetvchain any workers were authorized over the connection.
+// The caller must hold the server lock.
+func (sess *stratumSession) authorized() bool {
+	return len(sess.workers) > 0
+}
+
+// send queues a message to the client, dropping the connection if the client
+// doesn't keep up with them.
+func (sess *stratumSession) send(msg interface{}) {
+	select {
+	case sess.queue <- msg:
+	case <-sess.closed:
+	default:
+		log.Debug("Dropping slow stratum client", "addr", sess.conn.RemoteAddr())
+		sess.conn.Close()
+	}
+}
+
+// writeLoop writes the queued messages to the client.
+func (sess *stratumSession) writeLoop() {
+	defer sess.server.wg.Done()
+
+	for {
+		select {
+		case msg := <-sess.queue:
+			blob, err := json.Marshal(msg)
+			if err != nil {
+				log.Error("Failed to encode stratum message", "err", err)
+				continue
+			}
+			sess.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+			if _, err := sess.conn.Write(append(blob, '\n')); err != nil {
+				sess.conn.Close()
+				return
+			}
+		case <-sess.closed:
+			return
+		}
+	}
+}
+
+// readLoop reads and handles the requests of the client until the connection is
+// torn down.
+func (sess *stratumSession) readLoop() {
+	defer sess.server.wg.Done()
+	defer func() {
+		sess.server.lock.Lock()
+		delete(sess.server.sessions, sess)
+		delete(sess.server.extranonces, sess.nonce)
+		sess.server.lock.Unlock()
+
+		close(sess.closed)
+		sess.conn.Close()
+	}()
+	log.Debug("Stratum client connected", "addr", sess.conn.RemoteAddr())
+
+	scanner := bufio.NewScanner(sess.conn)
+	scanner.Buffer(make([]byte, 0, 1024), stratumMaxLineSize)
+	for {
+		sess.conn.SetReadDeadline(time.Now().Add(stratumIdleTimeout))
+		if !scanner.Scan() {
+			log.Debug("Stratum client disconnected", "addr", sess.conn.RemoteAddr(), "err", scanner.Err())
+			return
+		}
+		req := new(stratumRequest)
+		if err := json.Unmarshal(scanner.Bytes(), req); err != nil {
+			log.Debug("Dropping stratum client sending invalid request", "addr", sess.conn.RemoteAddr(), "err", err)
+			return
+		}
+		result, err := sess.handle(req)
+		if err != nil {
+			result = nil
+		}
+		sess.send(&stratumResponse{ID: req.ID, Result: result, Error: err})
+	}
+}
+
+// handle processes a single request of the client.
+func (sess *stratumSession) handle(req *stratumRequest) (interface{}, *stratumError) {
+	s := sess.server
+
+	switch req.Method {
+	case "mining.subscribe":
+		if protocol := req.param(1); protocol != "" && !strings.HasPrefix(protocol, "EthereumStratum/") {
+			return nil, &stratumError{20, "Unsupported protocol " + protocol}
+		}
+		var id [16]byte
+		crand.Read(id[:])
+
+		s.lock.Lock()
+		sess.subscribed = true
+		s.lock.Unlock()
+
+		return []interface{}{
+			[]string{"mining.notify", hex.EncodeToString(id[:]), stratumProtocol},
+			sess.extranonce,
+		}, nil
+
+	case "mining.extranonce.subscribe":
+		return true, nil
+
+	case "mining.authorize":
+		name := req.param(0)
+		if name == "" {
+			return nil, errStratumUnauthorized
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if !sess.subscribed {
+			return nil, errStratumNotSubscribed
+		}
+		sess.workers[name] = struct{}{}
+
+		// Push the share difficulty and the current work to the new worker. The
+		// stratum difficulty 1 corresponds to 2^32 hashes.
+		difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(s.difficulty), big.NewFloat(1<<32)).Float64()
+		sess.send(&stratumNotification{Method: "mining.set_difficulty", Params: []interface{}{difficulty}})
+		if s.current != nil {
+			sess.send(s.current.notification())
+		}
+		log.Debug("Stratum worker authorized", "addr", sess.conn.RemoteAddr(), "worker", name)
+		return true, nil
+
+	case "mining.submit":
+		name, id := req.param(0), req.param(1)
+
+		s.lock.Lock()
+		_, authorized := sess.workers[name]
+		s.lock.Unlock()
+
+		if !authorized {
+			return nil, errStratumUnauthorized
+		}
+		// The full nonce is the extranonce of the connection followed by the one
+		// found by the miner
+		nonce, err := strconv.ParseUint(sess.extranonce+strings.TrimPrefix(req.param(2), "0x"), 16, 64)
+		if err != nil || len(sess.extranonce)+len(strings.TrimPrefix(req.param(2), "0x")) != 16 {
+			s.echash.workers.reject(name, false)
+			return nil, &stratumError{20, "Malformed nonce"}
+		}
+		if err := s.submit(name, id, nonce); err != nil {
+			return nil, err
+		}
+		return true, nil
+
+	default:
+		log.Debug("Unknown stratum request", "addr", sess.conn.RemoteAddr(), "method", req.Method)
+		return nil, errStratumUnknown
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package echash
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+)
+
+// stratumTester is a stratum client connected to a test server.
+type stratumTester struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	id     int
+
+	notifications []map[string]interface{}
+}
+
+// call sends a stratum request and waits for its response, collecting the
+// notifications received meanwhile.
+func (st *stratumTester) call(method string, params ...interface{}) (interface{}, []interface{}) {
+	st.id++
+	blob, _ := json.Marshal(map[string]interface{}{"id": st.id, "method": method, "params": params})
+	st.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := st.conn.Write(append(blob, '\n')); err != nil {
+		st.t.Fatalf("failed to send %s: %v", method, err)
+	}
+	for {
+		msg := st.read()
+		if msg["id"] == nil {
+			st.notifications = append(st.notifications, msg)
+			continue
+		}
+		if id := msg["id"].(float64); int(id) != st.id {
+			st.t.Fatalf("response id mismatch: have %v, want %d", id, st.id)
+		}
+		fail, _ := msg["error"].([]interface{})
+		return msg["result"], fail
+	}
+}
+
+// read reads the next message sent by the server.
+func (st *stratumTester) read() map[string]interface{} {
+	st.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	line, err := st.reader.ReadBytes('\n')
+	if err != nil {
+		st.t.Fatalf("failed to read stratum message: %v", err)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		st.t.Fatalf("failed to decode stratum message: %v", err)
+	}
+	return msg
+}
+
+// notification returns the next notification of the given kind, waiting for it
+// if none was received yet.
+func (st *stratumTester) notification(method string) []interface{} {
+	for {
+		for i, msg := range st.notifications {
+			if msg["method"] == method {
+				st.notifications = append(st.notifications[:i], st.notifications[i+1:]...)
+				return msg["params"].([]interface{})
+			}
+		}
+		st.notifications = append(st.notifications, st.read())
+	}
+}
+
+// Tests that the stratum server pushes the sealing work to the miners, accepts
+// their valid shares and submits the ones solving the block.
+func TestStratum(t *testing.T) {
+	echash := New(Config{PowMode: ModeTest, StratumAddr: "127.0.0.1:0", StratumDifficulty: 10}, nil, false)
+	defer echash.Close()
+	echash.SetThreads(-1)
+
+	conn, err := net.Dial("tcp", echash.stratum.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect to stratum server: %v", err)
+	}
+	defer conn.Close()
+	st := &stratumTester{t: t, conn: conn, reader: bufio.NewReader(conn)}
+
+	// Requests before the subscription must be rejected
+	if _, fail := st.call("mining.authorize", "miner.rig1", "x"); fail == nil || fail[0].(float64) != 25 {
+		t.Fatalf("authorization before subscription: error mismatch: have %v, want code 25", fail)
+	}
+	result, fail := st.call("mining.subscribe", "tester/1.0", stratumProtocol)
+	if fail != nil {
+		t.Fatalf("failed to subscribe: %v", fail)
+	}
+	extranonce := result.([]interface{})[1].(string)
+	if len(extranonce) != 4 {
+		t.Fatalf("extranonce length mismatch: have %d, want 4", len(extranonce))
+	}
+	if _, fail := st.call("mining.authorize", "miner.rig1", "x"); fail != nil {
+		t.Fatalf("failed to authorize: %v", fail)
+	}
+	if params := st.notification("mining.set_difficulty"); params[0].(float64) != 10.0/(1<<32) {
+		t.Errorf("share difficulty mismatch: have %v, want %v", params[0], 10.0/(1<<32))
+	}
+	// Push new work and ensure it's announced to the miner
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1000)}
+	block := types.NewBlockWithHeader(header)
+	results := make(chan *types.Block, 1)
+	echash.Seal(nil, block, results, nil)
+
+	job := st.notification("mining.notify")
+	sealhash := echash.SealHash(header)
+	if have := common.HexToHash(job[2].(string)); have != sealhash {
+		t.Fatalf("job header hash mismatch: have %x, want %x", have, sealhash)
+	}
+	if have, want := common.HexToHash(job[1].(string)), common.BytesToHash(SeedHash(1)); have != want {
+		t.Errorf("job seed hash mismatch: have %x, want %x", have, want)
+	}
+	// Find nonces of various qualities for the job
+	var (
+		shareTarget = new(big.Int).Div(two256, big.NewInt(10))
+		blockTarget = new(big.Int).Div(two256, big.NewInt(1000))
+
+		low, share, solution string
+	)
+	prefix, _ := strconv.ParseUint(extranonce, 16, 64)
+	for nonce := uint64(0); low == "" || share == "" || solution == ""; nonce++ {
+		_, result := echash.hashimoto(1, sealhash.Bytes(), prefix<<48|nonce, false)
+		value := new(big.Int).SetBytes(result)
+		switch {
+		case value.Cmp(blockTarget) <= 0:
+			solution = fmt.Sprintf("%012x", nonce)
+		case value.Cmp(shareTarget) <= 0:
+			share = fmt.Sprintf("%012x", nonce)
+		default:
+			low = fmt.Sprintf("%012x", nonce)
+		}
+	}
+	tests := []struct {
+		worker string
+		job    string
+		nonce  string
+		code   float64 // Expected error code, 0 if accepted
+	}{
+		{"miner.rig2", job[0].(string), share, 24},
+		{"miner.rig1", "deadbeef", share, 21},
+		{"miner.rig1", job[0].(string), "0x12", 20},
+		{"miner.rig1", job[0].(string), low, 23},
+		{"miner.rig1", job[0].(string), share, 0},
+		{"miner.rig1", job[0].(string), share, 22},
+		{"miner.rig1", job[0].(string), solution, 0},
+	}
+	for i, tt := range tests {
+		result, fail := st.call("mining.submit", tt.worker, tt.job, tt.nonce)
+		switch {
+		case tt.code == 0 && (fail != nil || result != true):
+			t.Errorf("test %d: share rejected: %v", i, fail)
+		case tt.code != 0 && (fail == nil || fail[0].(float64) != tt.code):
+			t.Errorf("test %d: error mismatch: have %v, want code %v", i, fail, tt.code)
+		}
+	}
+	// Ensure the solution was submitted to the miner and the shares accounted
+	select {
+	case sealed := <-results:
+		if want := fmt.Sprintf("%s%s", extranonce, solution); fmt.Sprintf("%016x", sealed.Nonce()) != want {
+			t.Errorf("sealed nonce mismatch: have %016x, want %s", sealed.Nonce(), want)
+		}
+		if err := echash.verifySeal(nil, sealed.Header(), false); err != nil {
+			t.Errorf("sealed block invalid: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("sealed block not delivered")
+	}
+	want := WorkerStats{Accepted: 2, Stale: 1, Invalid: 3, Blocks: 1}
+	have := *echash.workers.stats()["miner.rig1"]
+	have.EffectiveHashrate, have.LastSeen = 0, 0
+	if have != want {
+		t.Errorf("worker stats mismatch: have %+v, want %+v", have, want)
+	}
+}
+
+// Tests that the extranonces of disconnected clients are reused and that clients
+// are refused once all of them are assigned.
+func TestStratumExtranonces(t *testing.T) {
+	echash := New(Config{PowMode: ModeTest, StratumAddr: "127.0.0.1:0"}, nil, false)
+	defer echash.Close()
+
+	// Assign all extranonces but the first one
+	server := echash.stratum
+	server.lock.Lock()
+	for i := 1; i < stratumMaxSessions; i++ {
+		server.extranonces[uint16(i)] = struct{}{}
+	}
+	server.lock.Unlock()
+
+	subscribe := func() (net.Conn, interface{}) {
+		conn, err := net.Dial("tcp", server.listener.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to connect to stratum server: %v", err)
+		}
+		st := &stratumTester{t: t, conn: conn, reader: bufio.NewReader(conn)}
+		if _, err := fmt.Fprintf(conn, `{"id":1,"method":"mining.subscribe","params":[]}`+"\n"); err != nil {
+			t.Fatalf("failed to send subscription: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		line, err := st.reader.ReadBytes('\n')
+		if err != nil {
+			return conn, nil
+		}
+		var res stratumResponse
+		if err := json.Unmarshal(line, &res); err != nil {
+			t.Fatalf("failed to decode subscription: %v", err)
+		}
+		return conn, res.Result.([]interface{})[1]
+	}
+	conn, extranonce := subscribe()
+	if extranonce != "0000" {
+		t.Fatalf("extranonce mismatch: have %v, want 0000", extranonce)
+	}
+	refused, extranonce := subscribe()
+	refused.Close()
+	if extranonce != nil {
+		t.Fatalf("client subscribed with all extranonces assigned: %v", extranonce)
+	}
+	// Disconnect the first client and ensure its extranonce is reassigned
+	conn.Close()
+	for i := 0; ; i++ {
+		server.lock.Lock()
+		_, ok := server.extranonces[0]
+		server.lock.Unlock()
+		if !ok {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("extranonce of disconnected client not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn, extranonce = subscribe()
+	defer conn.Close()
+	if extranonce != "0000" {
+		t.Fatalf("extranonce mismatch: have %v, want 0000", extranonce)
+	}
+}
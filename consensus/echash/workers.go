@@ -0,0 +1,192 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package echash
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/metrics"
+)
+
+const (
+	// workerTimeout is the time after which the statistics of a silent remote
+	// worker are dropped.
+	workerTimeout = time.Hour
+
+	// maxWorkers is the maximum number of remote workers tracked individually.
+	maxWorkers = 1024
+
+	// otherWorkers is the name the statistics of the remote workers exceeding
+	// the tracked ones are accumulated under.
+	otherWorkers = "other"
+)
+
+var (
+	workersGauge           = metrics.NewRegisteredGauge("echash/workers/count", nil)
+	reportedHashrateGauge  = metrics.NewRegisteredGauge("echash/workers/hashrate/reported", nil)
+	effectiveHashrateGauge = metrics.NewRegisteredGauge("echash/workers/hashrate/effective", nil)
+	acceptedGauge          = metrics.NewRegisteredGauge("echash/workers/accepted", nil)
+	staleGauge             = metrics.NewRegisteredGauge("echash/workers/stale", nil)
+	invalidGauge           = metrics.NewRegisteredGauge("echash/workers/invalid", nil)
+	blocksGauge            = metrics.NewRegisteredGauge("echash/workers/blocks", nil)
+)
+
+// WorkerStats are the statistics of a remote worker of the echash sealer.
+type WorkerStats struct {
+	ReportedHashrate  uint64 `json:"reportedHashrate"`  // Hashrate last reported by the worker itself
+	EffectiveHashrate uint64 `json:"effectiveHashrate"` // Hashrate estimated from the work accepted from the worker
+	Accepted          uint64 `json:"accepted"`          // Number of valid solutions or shares submitted
+	Stale             uint64 `json:"stale"`             // Number of solutions or shares submitted for expired work
+	Invalid           uint64 `json:"invalid"`           // Number of invalid or duplicate solutions or shares submitted
+	Blocks            uint64 `json:"blocks"`            // Number of submissions that sealed a block
+	LastSeen          uint64 `json:"lastSeen"`          // Unix timestamp of the last activity of the worker
+}
+
+// workerStats are the live statistics of a remote worker.
+type workerStats struct {
+	WorkerStats
+	effective metrics.Meter // Meter tracking the difficulty of the accepted work per second
+	seen      time.Time     // Time of the last activity of the worker
+}
+
+// workerSet tracks the statistics of the remote workers, identified by the id
+// they report their hashrate with or by their stratum worker name.
+type workerSet struct {
+	workers map[string]*workerStats
+	lock    sync.Mutex
+}
+
+// newWorkerSet creates an empty set of remote worker statistics.
+func newWorkerSet() *workerSet {
+	return &workerSet{workers: make(map[string]*workerStats)}
+}
+
+// worker retrieves the statistics of a remote worker, creating them if needed and
+// marking the worker as seen. Once the maximum number of workers is tracked, new
+// ones are accounted under a shared bucket. The caller must hold the lock.
+func (ws *workerSet) worker(name string) *workerStats {
+	worker, ok := ws.workers[name]
+	if !ok && len(ws.workers) >= maxWorkers {
+		name = otherWorkers
+		worker, ok = ws.workers[name]
+	}
+	if !ok {
+		worker = &workerStats{effective: metrics.NewMeterForced()}
+		ws.workers[name] = worker
+	}
+	worker.seen = time.Now()
+	return worker
+}
+
+// reportHashrate records the hashrate reported by a remote worker.
+func (ws *workerSet) reportHashrate(name string, rate uint64) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	ws.worker(name).ReportedHashrate = rate
+}
+
+// accept records a valid solution or share of the given difficulty submitted by
+// a remote worker.
+func (ws *workerSet) accept(name string, difficulty *big.Int) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	worker := ws.worker(name)
+	worker.Accepted++
+	if difficulty.IsInt64() {
+		worker.effective.Mark(difficulty.Int64())
+	}
+}
+
+// reject records a stale or invalid solution or share submitted by a remote
+// worker.
+func (ws *workerSet) reject(name string, stale bool) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	worker := ws.worker(name)
+	if stale {
+		worker.Stale++
+	} else {
+		worker.Invalid++
+	}
+}
+
+// sealed records a block sealed by a remote worker.
+func (ws *workerSet) sealed(name string) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	ws.worker(name).Blocks++
+}
+
+// stats retrieves the statistics of all the remote workers.
+func (ws *workerSet) stats() map[string]*WorkerStats {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	stats := make(map[string]*WorkerStats, len(ws.workers))
+	for name, worker := range ws.workers {
+		stat := worker.WorkerStats
+		stat.EffectiveHashrate = uint64(worker.effective.Rate1())
+		stat.LastSeen = uint64(worker.seen.Unix())
+		stats[name] = &stat
+	}
+	return stats
+}
+
+// expire drops the statistics of the remote workers silent for too long, and
+// updates the aggregate metrics of the remaining ones.
+func (ws *workerSet) expire() {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	var reported, effective, accepted, stale, invalid, blocks uint64
+	for name, worker := range ws.workers {
+		if time.Since(worker.seen) > workerTimeout {
+			worker.effective.Stop()
+			delete(ws.workers, name)
+			continue
+		}
+		reported += worker.ReportedHashrate
+		effective += uint64(worker.effective.Rate1())
+		accepted += worker.Accepted
+		stale += worker.Stale
+		invalid += worker.Invalid
+		blocks += worker.Blocks
+	}
+	workersGauge.Update(int64(len(ws.workers)))
+	reportedHashrateGauge.Update(int64(reported))
+	effectiveHashrateGauge.Update(int64(effective))
+	acceptedGauge.Update(int64(accepted))
+	staleGauge.Update(int64(stale))
+	invalidGauge.Update(int64(invalid))
+	blocksGauge.Update(int64(blocks))
+}
+
+// close stops tracking the hashrate of the remote workers.
+func (ws *workerSet) close() {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	for _, worker := range ws.workers {
+		worker.effective.Stop()
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package echash
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Tests that the number of tracked remote workers is capped, and the statistics
+// of the workers exceeding it are accumulated under a shared bucket.
+func TestWorkerSetLimit(t *testing.T) {
+	ws := newWorkerSet()
+	defer ws.close()
+
+	for i := 0; i < maxWorkers+10; i++ {
+		ws.reject(fmt.Sprintf("worker-%d", i), true)
+	}
+	// Known workers are still tracked individually
+	ws.reject("worker-0", false)
+
+	stats := ws.stats()
+	if len(stats) != maxWorkers+1 {
+		t.Fatalf("tracked worker count mismatch: have %d, want %d", len(stats), maxWorkers+1)
+	}
+	if have := stats["worker-0"]; have.Stale != 1 || have.Invalid != 1 {
+		t.Errorf("tracked worker stats mismatch: have %+v", have)
+	}
+	if have := stats[otherWorkers]; have == nil || have.Stale != 10 {
+		t.Errorf("other worker stats mismatch: have %+v", have)
+	}
+	if _, ok := stats[fmt.Sprintf("worker-%d", maxWorkers)]; ok {
+		t.Errorf("worker beyond the limit tracked individually")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"encoding/json"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+)
+
+// LoadProposals loads the authorization proposals a voting based consensus
+// engine persisted under key. A missing entry is not an error, there are no
+// proposals then.
+func LoadProposals(db echdb.Database, key []byte) (map[common.Address]bool, error) {
+	proposals := make(map[common.Address]bool)
+	if has, err := db.Has(key); err != nil || !has {
+		return proposals, err
+	}
+	blob, err := db.Get(key)
+	if err != nil {
+		return proposals, err
+	}
+	if err := json.Unmarshal(blob, &proposals); err != nil {
+		return make(map[common.Address]bool), err
+	}
+	return proposals, nil
+}
+
+// StoreProposals inserts the proposals into the database under key, replacing
+// any previous set of them.
+func StoreProposals(db echdb.Database, key []byte, proposals map[common.Address]bool) error {
+	blob, err := json.Marshal(proposals)
+	if err != nil {
+		return err
+	}
+	return db.Put(key, blob)
+}
+
+// SetProposal adds or updates a proposal and persists the new set. If it can't
+// be persisted, the in-memory set is rolled back so it never diverges from the
+// database.
+func SetProposal(db echdb.Database, key []byte, proposals map[common.Address]bool, address common.Address, auth bool) error {
+	prev, existed := proposals[address]
+	proposals[address] = auth
+	if err := StoreProposals(db, key, proposals); err != nil {
+		if existed {
+			proposals[address] = prev
+		} else {
+			delete(proposals, address)
+		}
+		return err
+	}
+	return nil
+}
+
+// DropProposal removes a proposal and persists the new set, restoring it in
+// memory if the removal can't be persisted.
+func DropProposal(db echdb.Database, key []byte, proposals map[common.Address]bool, address common.Address) error {
+	prev, existed := proposals[address]
+	if !existed {
+		return nil
+	}
+	delete(proposals, address)
+	if err := StoreProposals(db, key, proposals); err != nil {
+		proposals[address] = prev
+		return err
+	}
+	return nil
+}
@@ -69,12 +69,13 @@ const (
 )
 
 // CacheConfig contains the configuration values for the trie caching/pruning
-// that's resident in a blockchain.
+// and the transaction index pruning that's resident in a blockchain.
 type CacheConfig struct {
 	Disabled       bool          // Whetvchain to disable trie write caching (archive node)
 	TrieCleanLimit int           // Memory allowance (MB) to use for caching trie nodes in memory
 	TrieDirtyLimit int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
+	TxLookupLimit  uint64        // Number of recent blocks to maintain transaction lookup indices for (0 = entire chain)
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -123,6 +124,9 @@ type BlockChain struct {
 	blockCache    *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
 
+	txLookupLimit uint64        // Number of recent blocks to keep transactions indexed for, accessed atomically
+	txIndexKick   chan struct{} // Notification channel to reconsider the transaction index range
+
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
 	// procInterrupt must be atomically called
@@ -162,6 +166,8 @@ func NewBlockChain(db echdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		db:             db,
 		triegc:         prque.New(nil),
 		stateCache:     state.NewDatabaseWithCache(db, cacheConfig.TrieCleanLimit),
+		txLookupLimit:  cacheConfig.TxLookupLimit,
+		txIndexKick:    make(chan struct{}, 1),
 		quit:           make(chan struct{}),
 		shouldPreserve: shouldPreserve,
 		bodyCache:      bodyCache,
@@ -188,6 +194,11 @@ func NewBlockChain(db echdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	// Make sure the ancient store holds no blocks beyond the head header, which
+	// happens if a previous rewind was interrupted before truncating it
+	if err := bc.truncateAncients(bc.CurrentHeader().Number.Uint64()); err != nil {
+		return nil, err
+	}
 	// Check the current state of the block hashes and make sure that we do not have any of the bad blocks in our chain
 	for hash := range BadHashes {
 		if header := bc.GetHeaderByHash(hash); header != nil {
@@ -203,6 +214,15 @@ func NewBlockChain(db echdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	// Subscribe before the indexer starts, so a quick Stop can't close the
+	// subscription scope under it
+	headCh := make(chan ChainHeadEvent, 1)
+	headSub := bc.SubscribeChainHeadEvent(headCh)
+
+	bc.wg.Add(1)
+	go bc.maintainTxIndex(headCh, headSub)
+
 	return bc, nil
 }
 
@@ -291,6 +311,11 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Discard any frozen blocks above the new head from the ancient store
+	if err := bc.truncateAncients(currentHeader.Number.Uint64()); err != nil {
+		return err
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -328,6 +353,16 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	return bc.loadLastState()
 }
 
+// truncateAncients discards all the blocks above head from the ancient store of
+// the chain database, if the database has one.
+func (bc *BlockChain) truncateAncients(head uint64) error {
+	ancients, ok := bc.db.(rawdb.AncientWriter)
+	if !ok {
+		return nil
+	}
+	return ancients.TruncateAncients(head + 1)
+}
+
 // FastSyncCommitHead sets the current head block to the one defined by the hash
 // irrelevant what the chain contents were prior.
 func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
@@ -1541,6 +1576,105 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// SetTxLookupLimit changes the number of recent blocks to maintain transaction
+// lookup indices for, with zero meaning the entire chain. Raising the limit
+// reindexes the older blocks in the background, lowering it unindexes them.
+func (bc *BlockChain) SetTxLookupLimit(limit uint64) {
+	atomic.StoreUint64(&bc.txLookupLimit, limit)
+
+	select {
+	case bc.txIndexKick <- struct{}{}:
+	default:
+	}
+}
+
+// TxLookupLimit retrieves the number of recent blocks to maintain transaction
+// lookup indices for, with zero meaning the entire chain.
+func (bc *BlockChain) TxLookupLimit() uint64 {
+	return atomic.LoadUint64(&bc.txLookupLimit)
+}
+
+// TxIndexInProgress reports whetvchain blocks within the configured lookup window
+// are still waiting to be indexed, so their transactions can't be found yet.
+// Blocks below the window are unindexed on purpose and don't count.
+func (bc *BlockChain) TxIndexInProgress() bool {
+	tail := rawdb.ReadTxIndexTail(bc.db)
+	if tail == nil {
+		return false // All blocks were indexed by earlier versions
+	}
+	head, limit := bc.CurrentBlock().NumberU64(), bc.TxLookupLimit()
+
+	wanted := uint64(0)
+	if limit > 0 && head >= limit {
+		wanted = head - limit + 1
+	}
+	return *tail > wanted
+}
+
+// maintainTxIndex keeps the transaction lookup indices of the canonical chain in
+// line with the configured limit, indexing or unindexing the old blocks in the
+// background every time the chain head changes.
+func (bc *BlockChain) maintainTxIndex(headCh chan ChainHeadEvent, sub event.Subscription) {
+	defer bc.wg.Done()
+
+	var (
+		done      chan struct{} // Non-nil if an indexing run is in progress
+		interrupt chan struct{} // Channel to abort an in-progress indexing run
+		pending   bool          // Set if the range needs to be reconsidered after the current run
+	)
+	run := func() {
+		done, interrupt = make(chan struct{}), make(chan struct{})
+		go bc.indexTransactions(bc.CurrentBlock().NumberU64(), bc.TxLookupLimit(), done, interrupt)
+	}
+	defer sub.Unsubscribe()
+
+	run()
+	for {
+		select {
+		case <-headCh:
+			pending = true
+		case <-bc.txIndexKick:
+			pending = true
+		case <-done:
+			done = nil
+		case <-bc.quit:
+			if done != nil {
+				close(interrupt)
+				<-done
+			}
+			return
+		}
+		if done == nil && pending {
+			pending = false
+			run()
+		}
+	}
+}
+
+// indexTransactions moves the transaction index tail to cover the last limit
+// blocks up to the given head, indexing or unindexing blocks as necessary.
+func (bc *BlockChain) indexTransactions(head uint64, limit uint64, done chan struct{}, interrupt chan struct{}) {
+	defer close(done)
+
+	// A missing tail means all blocks were indexed by earlier versions
+	tail := uint64(0)
+	if stored := rawdb.ReadTxIndexTail(bc.db); stored != nil {
+		tail = *stored
+	} else {
+		rawdb.WriteTxIndexTail(bc.db, 0)
+	}
+	wanted := uint64(0)
+	if limit > 0 && head >= limit {
+		wanted = head - limit + 1
+	}
+	switch {
+	case wanted < tail:
+		rawdb.IndexTransactions(bc.db, wanted, tail, interrupt)
+	case wanted > tail:
+		rawdb.UnindexTransactions(bc.db, tail, wanted, interrupt)
+	}
+}
+
 // BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
 func (bc *BlockChain) BadBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, bc.badBlocks.Len())
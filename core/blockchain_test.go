@@ -1538,3 +1538,82 @@ func TestLowDiffLongChain(t *testing.T) {
 		header = chain.GetHeader(header.ParentHash, number-1)
 	}
 }
+
+// Tests that the transaction lookup indices are maintained only for the most
+// recent blocks when a lookup limit is configured, and that changing the limit
+// indexes or unindexes the older blocks accordingly.
+func TestTransactionIndices(t *testing.T) {
+	var (
+		gendb   = echdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	height := uint64(128)
+	blocks, _ := GenerateChain(gspec.Config, genesis, echash.NewFaker(), gendb, int(height), func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	// check waits for the background indexer to settle at the expected tail and
+	// verifies that exactly the blocks from the tail onwards are indexed
+	check := func(db echdb.Database, tail uint64) {
+		for i := 0; i < 100; i++ {
+			if stored := rawdb.ReadTxIndexTail(db); stored != nil && *stored == tail {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if stored := rawdb.ReadTxIndexTail(db); stored == nil || *stored != tail {
+			t.Fatalf("transaction index tail mismatch: have %v, want %d", stored, tail)
+		}
+		for _, block := range blocks {
+			for _, tx := range block.Transactions() {
+				hash, _, _ := rawdb.ReadTxLookupEntry(db, tx.Hash())
+				if indexed := hash != (common.Hash{}); indexed != (block.NumberU64() >= tail) {
+					t.Fatalf("block #%d: index presence mismatch: have %v, want %v", block.NumberU64(), indexed, block.NumberU64() >= tail)
+				}
+			}
+		}
+	}
+	db := echdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, &CacheConfig{TrieCleanLimit: 256, TrieDirtyLimit: 256, TrieTimeLimit: 5 * time.Minute, TxLookupLimit: 32}, gspec.Config, echash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	check(db, height-32+1)
+
+	// Raise the limit and ensure the older blocks get reindexed
+	chain.SetTxLookupLimit(64)
+	check(db, height-64+1)
+
+	// Lift the limit entirely and ensure everything gets indexed
+	chain.SetTxLookupLimit(0)
+	check(db, 0)
+
+	// Lower the limit again and ensure old blocks get unindexed
+	chain.SetTxLookupLimit(16)
+	check(db, height-16+1)
+
+	// Unindexed blocks below the window don't count as indexing in progress,
+	// missing ones within it do
+	if chain.TxIndexInProgress() {
+		t.Fatalf("indexing reported in progress with complete window")
+	}
+	rawdb.WriteTxIndexTail(db, height)
+	if !chain.TxIndexInProgress() {
+		t.Fatalf("indexing not reported in progress with incomplete window")
+	}
+}
@@ -24,6 +24,83 @@ import (
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// TxPoolEventType is the kind of change a TxPoolEvent reports.
+type TxPoolEventType uint
+
+const (
+	TxPoolAdded    TxPoolEventType = iota // Transaction entered the pool
+	TxPoolPromoted                        // Transaction became executable
+	TxPoolDemoted                         // Executable transaction was moved back to the queue
+	TxPoolReplaced                        // Transaction was replaced by one with the same nonce
+	TxPoolDropped                         // Transaction was removed from the pool
+)
+
+// String implements fmt.Stringer.
+func (typ TxPoolEventType) String() string {
+	switch typ {
+	case TxPoolAdded:
+		return "added"
+	case TxPoolPromoted:
+		return "promoted"
+	case TxPoolDemoted:
+		return "demoted"
+	case TxPoolReplaced:
+		return "replaced"
+	case TxPoolDropped:
+		return "dropped"
+	default:
+		return "unknown"
+	}
+}
+
+// TxPoolEventReason is the cause of a transaction being demoted, replaced or
+// dropped by the pool.
+type TxPoolEventReason uint
+
+const (
+	TxReasonNone              TxPoolEventReason = iota // Transaction was added or promoted
+	TxReasonUnderpriced                                // Priced below the pool or a competing transaction
+	TxReasonNonceTooLow                                // Nonce already used on chain (e.g. mined)
+	TxReasonInsufficientFunds                          // Sender can no longer pay for the transaction
+	TxReasonLifetimeExpired                            // Queued for longer than the pool lifetime
+	TxReasonPoolOverflow                               // Evicted to respect the pool capacity limits
+	TxReasonReplaced                                   // Replaced by a higher priced one with the same nonce
+	TxReasonNonceGap                                   // A transaction with a lower nonce was removed
+)
+
+// String implements fmt.Stringer.
+func (reason TxPoolEventReason) String() string {
+	switch reason {
+	case TxReasonNone:
+		return ""
+	case TxReasonUnderpriced:
+		return "underpriced"
+	case TxReasonNonceTooLow:
+		return "nonce too low"
+	case TxReasonInsufficientFunds:
+		return "insufficient funds"
+	case TxReasonLifetimeExpired:
+		return "lifetime expired"
+	case TxReasonPoolOverflow:
+		return "pool overflow"
+	case TxReasonReplaced:
+		return "replaced"
+	case TxReasonNonceGap:
+		return "nonce gap"
+	default:
+		return "unknown"
+	}
+}
+
+// TxPoolEvent is posted when a transaction enters, moves within or leaves the
+// transaction pool.
+type TxPoolEvent struct {
+	Type       TxPoolEventType
+	Tx         *types.Transaction
+	Reason     TxPoolEventReason
+	ReplacedBy common.Hash // Hash of the replacing transaction, if replaced
+}
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
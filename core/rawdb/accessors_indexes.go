@@ -17,12 +17,33 @@
 package rawdb
 
 import (
+	"encoding/binary"
+
 	"github.com/etvchaineum/go-etvchaineum/common"
 	"github.com/etvchaineum/go-etvchaineum/core/types"
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/rlp"
 )
 
+// ReadTxIndexTail retrieves the number of the oldest block whose transactions
+// are indexed. If the tail is missing, all the blocks are indexed.
+func ReadTxIndexTail(db DatabaseReader) *uint64 {
+	data, _ := db.Get(txIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteTxIndexTail stores the number of the oldest block whose transactions are
+// indexed.
+func WriteTxIndexTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(txIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the transaction index tail", "err", err)
+	}
+}
+
 // ReadTxLookupEntry retrieves the positional metadata associated with a transaction
 // hash to allow retrieving the transaction or receipt by hash.
 func ReadTxLookupEntry(db DatabaseReader, hash common.Hash) (common.Hash, uint64, uint64) {
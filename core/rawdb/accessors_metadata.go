@@ -95,3 +95,28 @@ func WritePreimages(db DatabaseWriter, preimages map[common.Hash][]byte) {
 	preimageCounter.Inc(int64(len(preimages)))
 	preimageHitCounter.Inc(int64(len(preimages)))
 }
+
+// ReadStatePruningProgress retrieves the key of the last trie node swept by an
+// interrupted offline state pruning, or nil if there is none.
+func ReadStatePruningProgress(db DatabaseReader) []byte {
+	data, _ := db.Get(statePruningProgressKey)
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
+
+// WriteStatePruningProgress stores the key of the last trie node swept by the
+// offline state pruning to allow resuming it after a crash.
+func WriteStatePruningProgress(db DatabaseWriter, key []byte) {
+	if err := db.Put(statePruningProgressKey, key); err != nil {
+		log.Crit("Failed to store state pruning progress", "err", err)
+	}
+}
+
+// DeleteStatePruningProgress removes the state pruning progress marker.
+func DeleteStatePruningProgress(db DatabaseDeleter) {
+	if err := db.Delete(statePruningProgressKey); err != nil {
+		log.Crit("Failed to delete state pruning progress", "err", err)
+	}
+}
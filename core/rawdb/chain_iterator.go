@@ -0,0 +1,132 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/log"
+)
+
+// IndexTransactions creates the transaction lookup entries of the canonical
+// blocks in the range [from, to), moving the index tail down to from.
+//
+// The blocks are processed starting with the most recent one and the tail is
+// checkpointed together with the written entries, so if the indexing is
+// interrupted, the indexed blocks still form a contiguous range.
+func IndexTransactions(db echdb.Database, from uint64, to uint64, interrupt chan struct{}) {
+	if from >= to {
+		return
+	}
+	var (
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+		tail   = to
+		txs    int
+	)
+	flush := func() {
+		WriteTxIndexTail(batch, tail)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write transaction indices", "err", err)
+		}
+		batch.Reset()
+	}
+	for tail > from {
+		select {
+		case <-interrupt:
+			flush()
+			log.Debug("Transaction indexing interrupted", "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+			return
+		default:
+		}
+		number := tail - 1
+		block := ReadBlock(db, ReadCanonicalHash(db, number), number)
+		if block == nil {
+			flush()
+			log.Error("Canonical block missing, aborting transaction indexing", "number", number)
+			return
+		}
+		WriteTxLookupEntries(batch, block)
+		tail, txs = number, txs+len(block.Transactions())
+
+		if batch.ValueSize() >= echdb.IdealBatchSize {
+			flush()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing transactions", "blocks", to-tail, "txs", txs, "tail", tail, "total", to-from, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	flush()
+	log.Info("Indexed transactions", "blocks", to-from, "txs", txs, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// UnindexTransactions removes the transaction lookup entries of the canonical
+// blocks in the range [from, to), moving the index tail up to to.
+//
+// The blocks are processed starting with the oldest one and the tail is
+// checkpointed together with the deleted entries, so if the unindexing is
+// interrupted, the indexed blocks still form a contiguous range.
+func UnindexTransactions(db echdb.Database, from uint64, to uint64, interrupt chan struct{}) {
+	if from >= to {
+		return
+	}
+	var (
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+		tail   = from
+		txs    int
+	)
+	flush := func() {
+		WriteTxIndexTail(batch, tail)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to delete transaction indices", "err", err)
+		}
+		batch.Reset()
+	}
+	for tail < to {
+		select {
+		case <-interrupt:
+			flush()
+			log.Debug("Transaction unindexing interrupted", "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+			return
+		default:
+		}
+		// Blocks without a body have no transactions to unindex either
+		if body := ReadBody(db, ReadCanonicalHash(db, tail), tail); body != nil {
+			for _, tx := range body.Transactions {
+				DeleteTxLookupEntry(batch, tx.Hash())
+			}
+			txs += len(body.Transactions)
+		}
+		tail++
+
+		if batch.ValueSize() >= echdb.IdealBatchSize {
+			flush()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Unindexing transactions", "blocks", tail-from, "txs", txs, "tail", tail, "total", to-from, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	flush()
+	log.Info("Unindexed transactions", "blocks", to-from, "txs", txs, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+)
+
+// Tests that transaction lookup entries can be created and removed for ranges
+// of canonical blocks, tracking the index tail along the way.
+func TestIndexTransactions(t *testing.T) {
+	db := echdb.NewMemDatabase()
+
+	var txs []*types.Transaction
+	for i := uint64(0); i < 10; i++ {
+		var blockTxs []*types.Transaction
+		if i > 0 {
+			tx := types.NewTransaction(i, common.BytesToAddress([]byte{0x11}), big.NewInt(111), 1111, big.NewInt(11111), nil)
+			blockTxs = append(blockTxs, tx)
+			txs = append(txs, tx)
+		}
+		block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(i)}, blockTxs, nil, nil)
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), i)
+	}
+	// verify checks that exactly the transactions of blocks [tail, 10) are indexed
+	verify := func(tail uint64) {
+		if have := ReadTxIndexTail(db); have == nil || *have != tail {
+			t.Fatalf("index tail mismatch: have %v, want %d", have, tail)
+		}
+		for i, tx := range txs {
+			number := uint64(i + 1)
+			hash, _, _ := ReadTxLookupEntry(db, tx.Hash())
+			if indexed := hash != (common.Hash{}); indexed != (number >= tail) {
+				t.Fatalf("block #%d: index presence mismatch: have %v, want %v", number, indexed, number >= tail)
+			}
+		}
+	}
+	if tail := ReadTxIndexTail(db); tail != nil {
+		t.Fatalf("index tail present in pristine database: %d", *tail)
+	}
+	IndexTransactions(db, 5, 10, nil)
+	verify(5)
+
+	IndexTransactions(db, 0, 5, nil)
+	verify(0)
+
+	UnindexTransactions(db, 0, 7, nil)
+	verify(7)
+
+	// Interrupted operations must leave the tail untouched
+	interrupt := make(chan struct{})
+	close(interrupt)
+
+	IndexTransactions(db, 2, 7, interrupt)
+	verify(7)
+	UnindexTransactions(db, 7, 9, interrupt)
+	verify(7)
+
+	UnindexTransactions(db, 7, 10, nil)
+	verify(10)
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/log"
+)
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	echdb.Database
+	*freezer
+}
+
+// Close implements echdb.Database, closing both the fast key-value store as
+// well as the slow ancient tables.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into cold
+// storage in the freezer directory.
+//
+// The consistency of the key-value store and the freezer is verified before
+// use. The freezer tables themselves are repaired on open if a crash left them
+// with diverging lengths, and chain heads below the frozen segments are rolled
+// back by core.BlockChain when the chain is loaded.
+func NewDatabaseWithFreezer(db echdb.Database, freezer string, namespace string) (echdb.Database, error) {
+	// Create the idle freezer instance
+	frdb, err := newFreezer(freezer, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFreezer(db, frdb); err != nil {
+		frdb.Close()
+		return nil, err
+	}
+	// Freezer is consistent with the key-value database, permit combining the two
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// validateFreezer ensures that the freezer and the key-value store belong to
+// the same chain and that the frozen and the active segments are contiguous.
+func validateFreezer(db echdb.Database, frdb *freezer) error {
+	frozen, err := frdb.Ancients()
+	if err != nil {
+		return err
+	}
+	if frozen == 0 {
+		// The freezer is empty, make sure nothing was moved out of the key-value
+		// store yet. The genesis is never deleted, so check the block after it.
+		if number := ReadHeaderNumber(db, ReadHeadHeaderHash(db)); number != nil && *number > 0 {
+			if kvhash, _ := db.Get(headerHashKey(1)); len(kvhash) == 0 {
+				return errors.New("ancient chain segments already extracted, please set --datadir.ancient to the correct path")
+			}
+		}
+		return nil
+	}
+	// If the freezer already contains something, ensure that the genesis blocks
+	// match, otherwise we might mix up freezers across chains and destroy both
+	// the freezer and the key-value store.
+	kvgenesis, _ := db.Get(headerHashKey(0))
+	if len(kvgenesis) == 0 {
+		// Empty key-value store on top of an existing freezer, chain rebuilt on top
+		return nil
+	}
+	frgenesis, err := frdb.Ancient(freezerHashTable, 0)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve genesis from ancient %v", err)
+	}
+	if !bytes.Equal(kvgenesis, frgenesis) {
+		return fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+	}
+	// Key-value store and freezer belong to the same network. Ensure that they
+	// are contiguous, otherwise we might end up with a non-functional freezer.
+	if kvhash, _ := db.Get(headerHashKey(frozen)); len(kvhash) == 0 {
+		// Subsequent header after the freezer limit is missing from the database.
+		// Reject startup if the database has a more recent head.
+		if number := ReadHeaderNumber(db, ReadHeadHeaderHash(db)); number != nil && *number > frozen-1 {
+			return fmt.Errorf("gap (#%d) in the chain between ancients and leveldb", frozen)
+		}
+		// Database contains only older data than the freezer, this happens if the
+		// state was wiped and reinited from an existing freezer.
+	}
+	return nil
+}
+
+// DatabaseStat is the storage usage of a single category of database entries.
+type DatabaseStat struct {
+	Name  string             // Human readable name of the data category
+	Count uint64             // Number of entries belonging to the category
+	Size  common.StorageSize // Total size of the keys and values in the category
+}
+
+// InspectDatabase iterates over the entire database once, tallying the number
+// and total size of the entries belonging to each key prefix of the schema.
+// Entries that cannot be attributed to any known data type are reported as
+// unaccounted.
+func InspectDatabase(db echdb.Iteratee) ([]DatabaseStat, error) {
+	it := db.NewIterator()
+	defer it.Release()
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+
+		headers      DatabaseStat
+		tds          DatabaseStat
+		hashes       DatabaseStat
+		numbers      DatabaseStat
+		bodies       DatabaseStat
+		receipts     DatabaseStat
+		txLookups    DatabaseStat
+		bloomBits    DatabaseStat
+		preimages    DatabaseStat
+		tries        DatabaseStat
+		indexes      DatabaseStat
+		configs      DatabaseStat
+		metadata     DatabaseStat
+		unaccounted  DatabaseStat
+		totalEntries uint64
+	)
+	for it.Next() {
+		var (
+			key  = it.Key()
+			size = common.StorageSize(len(key) + len(it.Value()))
+			stat *DatabaseStat
+		)
+		switch {
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength:
+			stat = &headers
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+common.HashLength+len(headerTDSuffix) && bytes.HasSuffix(key, headerTDSuffix):
+			stat = &tds
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+len(headerHashSuffix) && bytes.HasSuffix(key, headerHashSuffix):
+			stat = &hashes
+		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == len(headerNumberPrefix)+common.HashLength:
+			stat = &numbers
+		case bytes.HasPrefix(key, blockBodyPrefix) && len(key) == len(blockBodyPrefix)+8+common.HashLength:
+			stat = &bodies
+		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == len(blockReceiptsPrefix)+8+common.HashLength:
+			stat = &receipts
+		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == len(txLookupPrefix)+common.HashLength:
+			stat = &txLookups
+		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == len(bloomBitsPrefix)+10+common.HashLength:
+			stat = &bloomBits
+		case bytes.HasPrefix(key, preimagePrefix) && len(key) == len(preimagePrefix)+common.HashLength:
+			stat = &preimages
+		case bytes.HasPrefix(key, configPrefix) && len(key) == len(configPrefix)+common.HashLength:
+			stat = &configs
+		case len(key) == common.HashLength:
+			stat = &tries
+		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
+			stat = &indexes
+		case isMetadataKey(key):
+			stat = &metadata
+		default:
+			stat = &unaccounted
+		}
+		stat.Count++
+		stat.Size += size
+
+		// Report progress on long running inspections
+		totalEntries++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "entries", totalEntries, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	log.Info("Inspected database", "entries", totalEntries, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	headers.Name = "Headers"
+	tds.Name = "Total difficulties"
+	hashes.Name = "Canonical hashes"
+	numbers.Name = "Header number index"
+	bodies.Name = "Block bodies"
+	receipts.Name = "Block receipts"
+	txLookups.Name = "Transaction lookups"
+	bloomBits.Name = "Bloombits"
+	preimages.Name = "Trie preimages"
+	tries.Name = "Trie nodes and contract codes"
+	indexes.Name = "Chain indexer data"
+	configs.Name = "Chain configurations"
+	metadata.Name = "Database metadata"
+	unaccounted.Name = "Unaccounted"
+
+	stats := []DatabaseStat{
+		headers, tds, hashes, numbers, bodies, receipts, txLookups, bloomBits,
+		preimages, tries, indexes, configs, metadata, unaccounted,
+	}
+	// Append the chain segments moved into the ancient store, if there's one
+	if ancients, ok := db.(AncientReader); ok {
+		frozen, err := ancients.Ancients()
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range []struct {
+			kind string
+			name string
+		}{
+			{freezerHeaderTable, "Ancient headers"},
+			{freezerDifficultyTable, "Ancient total difficulties"},
+			{freezerHashTable, "Ancient canonical hashes"},
+			{freezerBodiesTable, "Ancient block bodies"},
+			{freezerReceiptTable, "Ancient block receipts"},
+		} {
+			size, err := ancients.AncientSize(table.kind)
+			if err != nil {
+				return nil, err
+			}
+			stats = append(stats, DatabaseStat{Name: table.name, Count: frozen, Size: common.StorageSize(size)})
+		}
+	}
+	return stats, nil
+}
+
+// isMetadataKey reports whether the key is one of the singleton entries used
+// to track the state of the database itself.
+func isMetadataKey(key []byte) bool {
+	for _, meta := range [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey, txIndexTailKey, statePruningProgressKey} {
+		if bytes.Equal(key, meta) {
+			return true
+		}
+	}
+	return false
+}
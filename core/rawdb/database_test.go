@@ -0,0 +1,81 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+)
+
+// Tests that the database inspection attributes every entry to the correct
+// data category.
+func TestInspectDatabase(t *testing.T) {
+	db := echdb.NewMemDatabase()
+
+	tx := types.NewTransaction(1, common.BytesToAddress([]byte{0x11}), big.NewInt(111), 1111, big.NewInt(11111), []byte{0x11, 0x11, 0x11})
+	block := types.NewBlock(&types.Header{Number: big.NewInt(314)}, []*types.Transaction{tx}, nil, nil)
+
+	WriteBlock(db, block)
+	WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(1))
+	WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	WriteReceipts(db, block.Hash(), block.NumberU64(), nil)
+	WriteTxLookupEntries(db, block)
+	WriteBloomBits(db, 1, 2, block.Hash(), []byte{0x01})
+	WritePreimages(db, map[common.Hash][]byte{common.HexToHash("0x01"): {0x01}})
+	WriteHeadBlockHash(db, block.Hash())
+	WriteDatabaseVersion(db, 3)
+	db.Put(common.HexToHash("0xdeadbeef").Bytes(), []byte{0x80}) // trie node
+	db.Put([]byte("unknown-key"), []byte{0x01})
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatalf("failed to inspect database: %v", err)
+	}
+	want := map[string]uint64{
+		"Headers":                       1,
+		"Total difficulties":            1,
+		"Canonical hashes":              1,
+		"Header number index":           1,
+		"Block bodies":                  1,
+		"Block receipts":                1,
+		"Transaction lookups":           1,
+		"Bloombits":                     1,
+		"Trie preimages":                1,
+		"Trie nodes and contract codes": 1,
+		"Chain indexer data":            0,
+		"Chain configurations":          0,
+		"Database metadata":             2,
+		"Unaccounted":                   1,
+	}
+	var total uint64
+	for _, stat := range stats {
+		if stat.Count != want[stat.Name] {
+			t.Errorf("%s: entry count mismatch: have %d, want %d", stat.Name, stat.Count, want[stat.Name])
+		}
+		if stat.Count > 0 && stat.Size == 0 {
+			t.Errorf("%s: zero size reported for %d entries", stat.Name, stat.Count)
+		}
+		total += stat.Count
+	}
+	if total != uint64(db.Len()) {
+		t.Errorf("total entry count mismatch: have %d, want %d", total, db.Len())
+	}
+}
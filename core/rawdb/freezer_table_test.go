@@ -0,0 +1,183 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/metrics"
+)
+
+// getChunk returns a chunk of data of the given size, filled with the given byte.
+func getChunk(size int, b int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(b)
+	}
+	return data
+}
+
+// newTestTable opens a freezer table with tiny data files in the given directory.
+func newTestTable(t *testing.T, dir string, noCompression bool) *freezerTable {
+	table, err := newCustomTable(dir, "test", metrics.NilMeter{}, metrics.NilMeter{}, 50, noCompression)
+	if err != nil {
+		t.Fatalf("failed to open table: %v", err)
+	}
+	return table
+}
+
+// Tests that appended items can be retrieved, also after reopening the table,
+// with the data spread over multiple data files.
+func TestFreezerBasics(t *testing.T) {
+	for _, noCompression := range []bool{false, true} {
+		t.Run(fmt.Sprintf("nocompression=%v", noCompression), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "freezer-")
+			if err != nil {
+				t.Fatalf("failed to create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			table := newTestTable(t, dir, noCompression)
+			for i := 0; i < 255; i++ {
+				if err := table.Append(uint64(i), getChunk(15, i)); err != nil {
+					t.Fatalf("item %d: failed to append: %v", i, err)
+				}
+			}
+			if err := table.Append(300, getChunk(15, 0)); err == nil {
+				t.Fatalf("out of order append succeeded")
+			}
+			check := func(table *freezerTable) {
+				for i := 0; i < 255; i++ {
+					blob, err := table.Retrieve(uint64(i))
+					if err != nil {
+						t.Fatalf("item %d: failed to retrieve: %v", i, err)
+					}
+					if !bytes.Equal(blob, getChunk(15, i)) {
+						t.Fatalf("item %d: data mismatch: have %x, want %x", i, blob, getChunk(15, i))
+					}
+				}
+				if _, err := table.Retrieve(255); err != errOutOfBounds {
+					t.Fatalf("out of bounds retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+				}
+			}
+			check(table)
+			table.Close()
+
+			table = newTestTable(t, dir, noCompression)
+			defer table.Close()
+			check(table)
+		})
+	}
+}
+
+// Tests that a table with a partially written index or data file is repaired on
+// open by dropping the items that were not completely persisted.
+func TestFreezerRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := newTestTable(t, dir, true)
+	for i := 0; i < 10; i++ {
+		table.Append(uint64(i), getChunk(20, i))
+	}
+	table.Close()
+
+	// Crop a few bytes off the head data file, losing the last item
+	head := filepath.Join(dir, "test.0004.rdat")
+	stat, err := os.Stat(head)
+	if err != nil {
+		t.Fatalf("failed to stat head file: %v", err)
+	}
+	if err := os.Truncate(head, stat.Size()-4); err != nil {
+		t.Fatalf("failed to truncate head file: %v", err)
+	}
+	table = newTestTable(t, dir, true)
+	if table.items != 9 {
+		t.Fatalf("item count mismatch after data loss: have %d, want %d", table.items, 9)
+	}
+	table.Close()
+
+	// Crop half an entry off the index, losing the next item
+	index := filepath.Join(dir, "test.ridx")
+	if stat, err = os.Stat(index); err != nil {
+		t.Fatalf("failed to stat index file: %v", err)
+	}
+	if err := os.Truncate(index, stat.Size()-indexEntrySize/2); err != nil {
+		t.Fatalf("failed to truncate index file: %v", err)
+	}
+	table = newTestTable(t, dir, true)
+	defer table.Close()
+
+	if table.items != 8 {
+		t.Fatalf("item count mismatch after index loss: have %d, want %d", table.items, 8)
+	}
+	for i := 0; i < 8; i++ {
+		if blob, err := table.Retrieve(uint64(i)); err != nil || !bytes.Equal(blob, getChunk(20, i)) {
+			t.Fatalf("item %d: retrieval mismatch: have %x (%v), want %x", i, blob, err, getChunk(20, i))
+		}
+	}
+	// Appending must continue where the repaired table ends
+	if err := table.Append(8, getChunk(20, 0xff)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	if blob, _ := table.Retrieve(8); !bytes.Equal(blob, getChunk(20, 0xff)) {
+		t.Fatalf("appended item mismatch: have %x, want %x", blob, getChunk(20, 0xff))
+	}
+}
+
+// Tests that truncating a table drops the items above the limit, including any
+// data files becoming unused.
+func TestFreezerTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := newTestTable(t, dir, true)
+	for i := 0; i < 30; i++ {
+		table.Append(uint64(i), getChunk(15, i))
+	}
+	if err := table.truncate(10); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+	table.Close()
+
+	table = newTestTable(t, dir, true)
+	defer table.Close()
+
+	if table.items != 10 {
+		t.Fatalf("item count mismatch: have %d, want %d", table.items, 10)
+	}
+	if _, err := table.Retrieve(10); err != errOutOfBounds {
+		t.Fatalf("truncated item retrievable: %v", err)
+	}
+	if blob, err := table.Retrieve(9); err != nil || !bytes.Equal(blob, getChunk(15, 9)) {
+		t.Fatalf("retained item mismatch: have %x (%v), want %x", blob, err, getChunk(15, 9))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.0009.rdat")); !os.IsNotExist(err) {
+		t.Fatalf("truncated data file still present: %v", err)
+	}
+}
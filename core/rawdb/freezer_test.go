@@ -0,0 +1,220 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+)
+
+// writeTestChain writes a canonical chain of the given length into the database,
+// together with a side block at every height above the genesis.
+func writeTestChain(db echdb.Database, length int) (canon []*types.Block, side []*types.Block) {
+	parent := common.Hash{}
+	for i := 0; i < length; i++ {
+		tx := types.NewTransaction(uint64(i), common.BytesToAddress([]byte{0x11}), big.NewInt(111), 1111, big.NewInt(11111), nil)
+		block := types.NewBlock(&types.Header{ParentHash: parent, Number: big.NewInt(int64(i)), Extra: []byte("canon")}, []*types.Transaction{tx}, nil, nil)
+		receipts := types.Receipts{{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: uint64(i), Logs: []*types.Log{}}}
+
+		WriteBlock(db, block)
+		WriteReceipts(db, block.Hash(), block.NumberU64(), receipts)
+		WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(int64(i+1)))
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		canon = append(canon, block)
+
+		if i > 0 {
+			sideBlock := types.NewBlock(&types.Header{ParentHash: parent, Number: big.NewInt(int64(i)), Extra: []byte("side")}, nil, nil, nil)
+			WriteBlock(db, sideBlock)
+			WriteTd(db, sideBlock.Hash(), sideBlock.NumberU64(), big.NewInt(int64(i)))
+			side = append(side, sideBlock)
+		}
+		parent = block.Hash()
+	}
+	head := canon[len(canon)-1]
+	WriteHeadHeaderHash(db, head.Hash())
+	WriteHeadBlockHash(db, head.Hash())
+
+	return canon, side
+}
+
+// Tests that frozen canonical blocks are moved out of the key-value store but
+// remain accessible through the database accessors, while side chains at the
+// frozen heights are deleted.
+func TestFreezerDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb := echdb.NewMemDatabase()
+	canon, side := writeTestChain(kvdb, 16)
+
+	db, err := NewDatabaseWithFreezer(kvdb, dir, "")
+	if err != nil {
+		t.Fatalf("failed to open freezer database: %v", err)
+	}
+	db.(*freezerdb).freezeRange(kvdb, 10)
+
+	if frozen, _ := db.(AncientReader).Ancients(); frozen != 10 {
+		t.Fatalf("frozen block count mismatch: have %d, want %d", frozen, 10)
+	}
+	for _, block := range canon {
+		hash, number := block.Hash(), block.NumberU64()
+
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block #%d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if header := ReadHeader(db, hash, number); header == nil || header.Hash() != hash {
+			t.Errorf("block #%d: header mismatch: have %v", number, header)
+		}
+		if !HasHeader(db, hash, number) || !HasBody(db, hash, number) || !HasReceipts(db, hash, number) {
+			t.Errorf("block #%d: block data reported missing", number)
+		}
+		if body := ReadBody(db, hash, number); body == nil || types.DeriveSha(types.Transactions(body.Transactions)) != block.TxHash() {
+			t.Errorf("block #%d: body mismatch: have %v", number, body)
+		}
+		if receipts := ReadReceipts(db, hash, number); len(receipts) != 1 || receipts[0].CumulativeGasUsed != number {
+			t.Errorf("block #%d: receipts mismatch: have %v", number, receipts)
+		}
+		if td := ReadTd(db, hash, number); td == nil || td.Uint64() != number+1 {
+			t.Errorf("block #%d: total difficulty mismatch: have %v, want %d", number, td, number+1)
+		}
+		if stored := ReadHeaderNumber(db, hash); stored == nil || *stored != number {
+			t.Errorf("block #%d: hash to number mapping mismatch: have %v", number, stored)
+		}
+		// Frozen blocks must be gone from the key-value store, except the genesis
+		frozen := number > 0 && number < 10
+		if has, _ := kvdb.Has(headerKey(number, hash)); has == frozen {
+			t.Errorf("block #%d: key-value header presence mismatch: have %v, want %v", number, has, !frozen)
+		}
+		if has, _ := kvdb.Has(blockBodyKey(number, hash)); has == frozen {
+			t.Errorf("block #%d: key-value body presence mismatch: have %v, want %v", number, has, !frozen)
+		}
+	}
+	for _, block := range side {
+		hash, number := block.Hash(), block.NumberU64()
+		if have := ReadHeader(db, hash, number) != nil; have != (number >= 10) {
+			t.Errorf("side block #%d: presence mismatch: have %v, want %v", number, have, number >= 10)
+		}
+	}
+	// Reopening the database must retain the frozen blocks
+	db.Close()
+	if db, err = NewDatabaseWithFreezer(kvdb, dir, ""); err != nil {
+		t.Fatalf("failed to reopen freezer database: %v", err)
+	}
+	defer db.Close()
+
+	if frozen, _ := db.(AncientReader).Ancients(); frozen != 10 {
+		t.Fatalf("frozen block count mismatch after reopen: have %d, want %d", frozen, 10)
+	}
+	if block := ReadBlock(db, canon[5].Hash(), 5); block == nil || block.Hash() != canon[5].Hash() {
+		t.Fatalf("frozen block unavailable after reopen: %v", block)
+	}
+}
+
+// Tests that the freezer and the key-value store are cross checked on open.
+func TestFreezerValidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb := echdb.NewMemDatabase()
+	writeTestChain(kvdb, 16)
+
+	db, err := NewDatabaseWithFreezer(kvdb, dir, "")
+	if err != nil {
+		t.Fatalf("failed to open freezer database: %v", err)
+	}
+	db.(*freezerdb).freezeRange(kvdb, 10)
+	db.Close()
+
+	// A chain already moved into a freezer must not be opened with an empty one
+	empty, err := ioutil.TempDir("", "freezer-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(empty)
+
+	if _, err := NewDatabaseWithFreezer(kvdb, empty, ""); err == nil {
+		t.Fatalf("extracted chain opened with empty freezer")
+	}
+	// A freezer must not be combined with a different chain
+	other := echdb.NewMemDatabase()
+	writeTestChain(other, 4)
+	WriteCanonicalHash(other, common.HexToHash("0xdeadbeef"), 0)
+
+	if _, err := NewDatabaseWithFreezer(other, dir, ""); err == nil {
+		t.Fatalf("freezer opened with mismatching genesis")
+	}
+	// Losing frozen blocks must be detected as a gap in the chain
+	db, err = NewDatabaseWithFreezer(kvdb, dir, "")
+	if err != nil {
+		t.Fatalf("failed to reopen freezer database: %v", err)
+	}
+	db.(AncientWriter).TruncateAncients(8)
+	db.Close()
+
+	if _, err := NewDatabaseWithFreezer(kvdb, dir, ""); err == nil {
+		t.Fatalf("freezer opened with gap in the chain")
+	}
+}
+
+// Tests that truncations concurrent with the background freezing leave the data
+// tables aligned with each other and the frozen block count.
+func TestFreezerConcurrentTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kvdb := echdb.NewMemDatabase()
+	writeTestChain(kvdb, 64)
+
+	db, err := NewDatabaseWithFreezer(kvdb, dir, "")
+	if err != nil {
+		t.Fatalf("failed to open freezer database: %v", err)
+	}
+	defer db.Close()
+
+	done := make(chan struct{})
+	go func() {
+		db.(*freezerdb).freezeRange(kvdb, 64)
+		close(done)
+	}()
+	for i := 0; i < 16; i++ {
+		db.(AncientWriter).TruncateAncients(uint64(i))
+	}
+	<-done
+
+	frozen, _ := db.(AncientReader).Ancients()
+	for name, table := range db.(*freezerdb).tables {
+		if items := atomic.LoadUint64(&table.items); items != frozen {
+			t.Errorf("table %s: item count mismatch: have %d, want %d", name, items, frozen)
+		}
+	}
+}
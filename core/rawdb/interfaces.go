@@ -31,3 +31,34 @@ type DatabaseWriter interface {
 type DatabaseDeleter interface {
 	Delete(key []byte) error
 }
+
+// AncientReader wraps the retrieval operations of an immutable ancient data
+// store, holding the finalized canonical chain segments moved out of the
+// key-value store.
+type AncientReader interface {
+	// HasAncient returns an indicator if the specified ancient data exists.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only store.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of items in the ancient store.
+	Ancients() (uint64, error)
+
+	// AncientSize returns the size of the specified category in bytes.
+	AncientSize(kind string) (uint64, error)
+}
+
+// AncientWriter wraps the modification operations of an immutable ancient data
+// store.
+type AncientWriter interface {
+	// AppendAncient injects all binary blobs belonging to a block at the end of
+	// the append-only immutable table files.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n ancient items.
+	TruncateAncients(n uint64) error
+
+	// Sync flushes all in-memory ancient store data to disk.
+	Sync() error
+}
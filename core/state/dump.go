@@ -21,61 +21,172 @@ import (
 	"fmt"
 
 	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
 	"github.com/etvchaineum/go-etvchaineum/rlp"
 	"github.com/etvchaineum/go-etvchaineum/trie"
 )
 
+// DumpAccount represents an account in the state.
 type DumpAccount struct {
-	Balance  string            `json:"balance"`
-	Nonce    uint64            `json:"nonce"`
-	Root     string            `json:"root"`
-	CodeHash string            `json:"codeHash"`
-	Code     string            `json:"code"`
-	Storage  map[string]string `json:"storage"`
+	Balance   string            `json:"balance"`
+	Nonce     uint64            `json:"nonce"`
+	Root      string            `json:"root"`
+	CodeHash  string            `json:"codeHash"`
+	Code      string            `json:"code"`
+	Storage   map[string]string `json:"storage"`
+	Address   *common.Address   `json:"address,omitempty"` // Address only present in iterative (line-by-line) mode
+	SecureKey hexutil.Bytes     `json:"key,omitempty"`     // If we don't have address, we can output the key
 }
 
+// Dump represents the full dump in a collected format, as one large map.
 type Dump struct {
 	Root     string                 `json:"root"`
 	Accounts map[string]DumpAccount `json:"accounts"`
 }
 
-func (self *StateDB) RawDump() Dump {
-	dump := Dump{
-		Root:     fmt.Sprintf("%x", self.trie.Hash()),
-		Accounts: make(map[string]DumpAccount),
+// dumpCollector is the interface the state dumper passes the walked accounts
+// through to the different output formats.
+type dumpCollector interface {
+	onRoot(common.Hash)
+	onAccount(*common.Address, DumpAccount)
+}
+
+func (d *Dump) onRoot(root common.Hash) {
+	d.Root = fmt.Sprintf("%x", root)
+}
+
+func (d *Dump) onAccount(addr *common.Address, account DumpAccount) {
+	d.Accounts[dumpKey(addr, account)] = account
+}
+
+// IteratorDump is a single page of a state dump, holding up to a maximum number
+// of accounts and the key to continue the dump from.
+type IteratorDump struct {
+	Root     string                 `json:"root"`
+	Accounts map[string]DumpAccount `json:"accounts"`
+	Next     hexutil.Bytes          `json:"next,omitempty"` // nil if no more accounts
+}
+
+func (d *IteratorDump) onRoot(root common.Hash) {
+	d.Root = fmt.Sprintf("%x", root)
+}
+
+func (d *IteratorDump) onAccount(addr *common.Address, account DumpAccount) {
+	d.Accounts[dumpKey(addr, account)] = account
+}
+
+// iterativeDump is a dumpCollector writing every account as a separate JSON
+// object, one per line, as soon as it is walked.
+type iterativeDump struct {
+	*json.Encoder
+}
+
+func (d iterativeDump) onRoot(root common.Hash) {
+	d.Encode(struct {
+		Root common.Hash `json:"root"`
+	}{root})
+}
+
+func (d iterativeDump) onAccount(addr *common.Address, account DumpAccount) {
+	account.Address = addr
+	d.Encode(account)
+}
+
+// dumpKey returns the key to file an account under in a collected dump, which
+// is the address, or the hashed trie key if the address preimage is unknown.
+func dumpKey(addr *common.Address, account DumpAccount) string {
+	if addr == nil {
+		return common.Bytes2Hex(account.SecureKey)
 	}
+	return common.Bytes2Hex(addr[:])
+}
+
+// dump walks the account trie starting at the given hashed key, passing every
+// account to the collector. If maxResults is positive, the walk stops after
+// that many accounts and the key of the next account is returned, otherwise
+// the entire trie is walked and nil is returned.
+func (self *StateDB) dump(c dumpCollector, excludeCode, excludeStorage bool, start []byte, maxResults int) (nextKey []byte) {
+	c.onRoot(self.trie.Hash())
 
-	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	var count int
+	it := trie.NewIterator(self.trie.NodeIterator(start))
 	for it.Next() {
-		addr := self.trie.GetKey(it.Key)
 		var data Account
 		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
 			panic(err)
 		}
-
-		obj := newObject(nil, common.BytesToAddress(addr), data)
 		account := DumpAccount{
 			Balance:  data.Balance.String(),
 			Nonce:    data.Nonce,
 			Root:     common.Bytes2Hex(data.Root[:]),
 			CodeHash: common.Bytes2Hex(data.CodeHash),
-			Code:     common.Bytes2Hex(obj.Code(self.db)),
-			Storage:  make(map[string]string),
 		}
-		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
-		for storageIt.Next() {
-			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+		var (
+			address common.Address
+			addr    *common.Address
+		)
+		if addrBytes := self.trie.GetKey(it.Key); addrBytes != nil {
+			address = common.BytesToAddress(addrBytes)
+			addr = &address
+		} else {
+			account.SecureKey = common.CopyBytes(it.Key)
+		}
+		obj := newObject(nil, address, data)
+		if !excludeCode {
+			account.Code = common.Bytes2Hex(obj.Code(self.db))
+		}
+		if !excludeStorage {
+			account.Storage = make(map[string]string)
+			storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
+			for storageIt.Next() {
+				account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+			}
+		}
+		c.onAccount(addr, account)
+
+		count++
+		if maxResults > 0 && count >= maxResults {
+			if it.Next() {
+				nextKey = common.CopyBytes(it.Key)
+			}
+			break
 		}
-		dump.Accounts[common.Bytes2Hex(addr)] = account
 	}
+	return nextKey
+}
+
+// RawDump returns the entire state as a single large object.
+func (self *StateDB) RawDump() Dump {
+	dump := Dump{
+		Accounts: make(map[string]DumpAccount),
+	}
+	self.dump(&dump, false, false, nil, 0)
 	return dump
 }
 
+// Dump returns a JSON string representing the entire state as a single json-object.
 func (self *StateDB) Dump() []byte {
 	json, err := json.MarshalIndent(self.RawDump(), "", "    ")
 	if err != nil {
 		fmt.Println("dump err", err)
 	}
-
 	return json
 }
+
+// IterativeDump dumps the accounts of the state as line-delimited JSON objects
+// into the output while walking the account trie, without collecting them in
+// memory. The walk starts at the given hashed account key, and if maxResults is
+// positive, stops after that many accounts, returning the key to continue from.
+func (self *StateDB) IterativeDump(excludeCode, excludeStorage bool, start []byte, maxResults int, output *json.Encoder) []byte {
+	return self.dump(iterativeDump{output}, excludeCode, excludeStorage, start, maxResults)
+}
+
+// IteratorDump collects a single page of the state dump, starting at the given
+// hashed account key and holding at most maxResults accounts.
+func (self *StateDB) IteratorDump(excludeCode, excludeStorage bool, start []byte, maxResults int) IteratorDump {
+	iterator := IteratorDump{
+		Accounts: make(map[string]DumpAccount),
+	}
+	iterator.Next = self.dump(&iterator, excludeCode, excludeStorage, start, maxResults)
+	return iterator
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+)
+
+// stateBloomHashes is the number of bit positions set for every inserted key.
+const stateBloomHashes = 4
+
+// errInvalidBloom is returned if a persisted state bloom cannot be decoded.
+var errInvalidBloom = errors.New("invalid state bloom file")
+
+// stateBloom is a bloom filter used by the offline state pruning to mark the
+// trie nodes and contract codes that need to be retained.
+//
+// All the keys inserted into the filter are Keccak256 hashes, which are already
+// uniformly distributed, so instead of rehashing, the bit positions are taken
+// directly from consecutive 8 byte chunks of the key.
+//
+// A false positive only means that an unreachable entry is kept around, so the
+// filter never causes live state to be deleted.
+type stateBloom struct {
+	head common.Hash // Head block hash the filter was generated for
+	bits []byte      // Bit array of the filter
+}
+
+// newStateBloom creates a new, empty state bloom of the given size in bytes.
+func newStateBloom(head common.Hash, size uint64) *stateBloom {
+	if size == 0 {
+		size = 1
+	}
+	return &stateBloom{head: head, bits: make([]byte, size)}
+}
+
+// add inserts a hash key into the filter.
+func (b *stateBloom) add(key []byte) {
+	for i := 0; i < stateBloomHashes; i++ {
+		bit := b.position(key, i)
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// contains reports whether the hash key might have been inserted into the filter.
+func (b *stateBloom) contains(key []byte) bool {
+	for i := 0; i < stateBloomHashes; i++ {
+		bit := b.position(key, i)
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// position calculates the bit index belonging to the i-th hash function.
+func (b *stateBloom) position(key []byte, i int) uint64 {
+	return binary.BigEndian.Uint64(key[i*8:]) % (uint64(len(b.bits)) * 8)
+}
+
+// commit flushes the filter into the given file. The content is first written
+// into a temporary file which is then atomically moved into place, so the file
+// either does not exist or contains a complete filter.
+func (b *stateBloom) commit(filename string) error {
+	tmpname := filename + ".tmp"
+
+	f, err := os.OpenFile(tmpname, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b.head[:]); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b.bits); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpname, filename)
+}
+
+// loadStateBloom reads a state bloom previously persisted by commit.
+func loadStateBloom(filename string) (*stateBloom, error) {
+	blob, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) <= common.HashLength {
+		return nil, errInvalidBloom
+	}
+	return &stateBloom{
+		head: common.BytesToHash(blob[:common.HashLength]),
+		bits: blob[common.HashLength:],
+	}, nil
+}
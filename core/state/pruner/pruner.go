@@ -0,0 +1,309 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements the offline pruning of historical state data.
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/rawdb"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+const (
+	// stateBloomFileName is the name of the file the state bloom is persisted
+	// into once the marking phase completes. Its existence signals that a sweep
+	// is pending and needs to be finished before the node can be used.
+	stateBloomFileName = "statebloom.bf"
+
+	// logInterval is the time between two progress reports of a long running
+	// pruning phase.
+	logInterval = 8 * time.Second
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = types.EmptyRootHash
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	// errNoState is returned if none of the blocks to retain has its state
+	// available in the database.
+	errNoState = errors.New("no state available to retain")
+
+	// errHeadChanged is returned if an interrupted pruning is resumed on top of
+	// a database that was modified since the marking phase.
+	errHeadChanged = errors.New("head block changed since the state pruning was interrupted")
+)
+
+// Pruner is an offline tool to delete the historical state data which is not
+// reachable from the most recent states anymore.
+//
+// Pruning is done in two phases. First all the trie nodes and contract codes
+// reachable from the retained state roots are marked in a bloom filter, which
+// is then persisted to disk. Afterwards all the entries of the database which
+// are not contained in the filter are swept. If the process crashes during the
+// sweep, it can be resumed from the persisted filter and the last checkpoint.
+type Pruner struct {
+	db        echdb.Database
+	datadir   string
+	bloomSize uint64
+}
+
+// NewPruner creates a pruner operating on the given database. The bloom filter
+// is persisted into datadir, and is allocated with bloomSize megabytes.
+func NewPruner(db echdb.Database, datadir string, bloomSize uint64) *Pruner {
+	return &Pruner{
+		db:        db,
+		datadir:   datadir,
+		bloomSize: bloomSize,
+	}
+}
+
+// Prune deletes all the trie nodes and contract codes of the database which are
+// not reachable from the state of the genesis block or of any of the last retain
+// canonical blocks. Any previously interrupted pruning is completed first.
+func (p *Pruner) Prune(retain uint64) error {
+	if err := RecoverPruning(p.datadir, p.db); err != nil {
+		return err
+	}
+	head, roots, err := retainedRoots(p.db, retain)
+	if err != nil {
+		return err
+	}
+	// Mark all the state data reachable from the retained roots
+	bloom := newStateBloom(head, p.bloomSize*1024*1024)
+	for _, root := range roots {
+		if err := markState(p.db, root, bloom); err != nil {
+			return err
+		}
+	}
+	// Persist the filter so an interrupted sweep can be resumed, then sweep
+	filename := filepath.Join(p.datadir, stateBloomFileName)
+	if err := bloom.commit(filename); err != nil {
+		return err
+	}
+	return sweepState(p.db, bloom, filename, nil)
+}
+
+// PruningPending checks whether an offline state pruning was interrupted in the
+// given data directory and awaits recovery.
+func PruningPending(datadir string) bool {
+	return common.FileExist(filepath.Join(datadir, stateBloomFileName))
+}
+
+// RecoverPruning checks whether an offline state pruning was interrupted in the
+// given data directory and if so, finishes sweeping the stale state data.
+//
+// The database must not be used to process blocks while a pruning is pending,
+// otherwise newly written state would not be contained in the filter. Hence
+// every user of the chain database has to call this before accessing it.
+func RecoverPruning(datadir string, db echdb.Database) error {
+	if !PruningPending(datadir) {
+		return nil
+	}
+	filename := filepath.Join(datadir, stateBloomFileName)
+	bloom, err := loadStateBloom(filename)
+	if err != nil {
+		return err
+	}
+	if head := rawdb.ReadHeadBlockHash(db); head != bloom.head {
+		return fmt.Errorf("%v: have %x, want %x", errHeadChanged, head, bloom.head)
+	}
+	start := rawdb.ReadStatePruningProgress(db)
+	log.Info("Resuming interrupted state pruning", "from", common.Bytes2Hex(start))
+
+	return sweepState(db, bloom, filename, start)
+}
+
+// retainedRoots collects the state roots of the genesis block and of the last
+// retain canonical blocks which have their state available in the database.
+func retainedRoots(db echdb.Database, retain uint64) (common.Hash, []common.Hash, error) {
+	if retain == 0 {
+		return common.Hash{}, nil, errors.New("at least one state must be retained")
+	}
+	head := rawdb.ReadHeadBlockHash(db)
+	number := rawdb.ReadHeaderNumber(db, head)
+	if number == nil {
+		return common.Hash{}, nil, errors.New("head block missing")
+	}
+	var (
+		roots []common.Hash
+		seen  = make(map[common.Hash]bool)
+	)
+	for i := uint64(0); i < retain && i <= *number; i++ {
+		n := *number - i
+		header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, n), n)
+		if header == nil {
+			return common.Hash{}, nil, fmt.Errorf("canonical header #%d missing", n)
+		}
+		if seen[header.Root] {
+			continue
+		}
+		if has, _ := db.Has(header.Root[:]); !has {
+			continue
+		}
+		if i > 0 && len(roots) == 0 {
+			log.Warn("Head state missing, retaining older state", "number", n, "root", header.Root)
+		}
+		roots = append(roots, header.Root)
+		seen[header.Root] = true
+	}
+	if len(roots) == 0 {
+		return common.Hash{}, nil, errNoState
+	}
+	// The genesis state is always retained, it's needed to rebuild the chain
+	if genesis := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, 0), 0); genesis != nil && !seen[genesis.Root] {
+		if has, _ := db.Has(genesis.Root[:]); has {
+			roots = append(roots, genesis.Root)
+		}
+	}
+	return head, roots, nil
+}
+
+// markState adds all the trie nodes and contract codes reachable from the given
+// state root into the bloom filter.
+func markState(db echdb.Database, root common.Hash, bloom *stateBloom) error {
+	var (
+		sdb     = state.NewDatabase(db)
+		start   = time.Now()
+		logged  = time.Now()
+		nodes   int
+		storage = make(map[common.Hash]struct{})
+	)
+	log.Info("Marking state for retention", "root", root)
+
+	accTrie, err := sdb.OpenTrie(root)
+	if err != nil {
+		return err
+	}
+	accIt := accTrie.NodeIterator(nil)
+	for accIt.Next(true) {
+		if hash := accIt.Hash(); hash != (common.Hash{}) {
+			bloom.add(hash[:])
+			nodes++
+		}
+		if time.Since(logged) > logInterval {
+			log.Info("Marking state for retention", "root", root, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		if !accIt.Leaf() {
+			continue
+		}
+		var account state.Account
+		if err := rlp.Decode(bytes.NewReader(accIt.LeafBlob()), &account); err != nil {
+			return err
+		}
+		if !bytes.Equal(account.CodeHash, emptyCode[:]) {
+			bloom.add(account.CodeHash)
+			nodes++
+		}
+		// Storage tries are often shared between accounts and across the retained
+		// states, so each one only needs to be walked once
+		if account.Root == emptyRoot {
+			continue
+		}
+		if _, ok := storage[account.Root]; ok {
+			continue
+		}
+		storage[account.Root] = struct{}{}
+
+		stTrie, err := sdb.OpenStorageTrie(common.BytesToHash(accIt.LeafKey()), account.Root)
+		if err != nil {
+			return err
+		}
+		stIt := stTrie.NodeIterator(nil)
+		for stIt.Next(true) {
+			if hash := stIt.Hash(); hash != (common.Hash{}) {
+				bloom.add(hash[:])
+				nodes++
+			}
+		}
+		if err := stIt.Error(); err != nil {
+			return err
+		}
+	}
+	if err := accIt.Error(); err != nil {
+		return err
+	}
+	log.Info("Marked state for retention", "root", root, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// sweepState deletes all the trie nodes and contract codes from the database
+// which are not contained in the bloom filter, starting at the given key. The
+// sweep progress is checkpointed atomically with the deletions, and once done,
+// the persisted filter is removed.
+func sweepState(db echdb.Database, bloom *stateBloom, filename string, start []byte) error {
+	var (
+		pstart = time.Now()
+		logged = time.Now()
+		count  int
+		size   common.StorageSize
+		batch  = db.NewBatch()
+		it     = db.NewIteratorWithStart(start)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+
+		// All state entries are keyed by their hash, everything else is skipped
+		if len(key) != common.HashLength || bloom.contains(key) {
+			continue
+		}
+		count++
+		size += common.StorageSize(len(key) + len(it.Value()))
+		batch.Delete(key)
+
+		if batch.ValueSize() >= echdb.IdealBatchSize {
+			rawdb.WriteStatePruningProgress(batch, key)
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > logInterval {
+			log.Info("Pruning state data", "count", count, "size", size, "at", common.Bytes2Hex(key), "elapsed", common.PrettyDuration(time.Since(pstart)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	rawdb.DeleteStatePruningProgress(batch)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil {
+		return err
+	}
+	log.Info("Pruned state data", "count", count, "size", size, "elapsed", common.PrettyDuration(time.Since(pstart)))
+	return nil
+}
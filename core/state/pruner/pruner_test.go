@@ -0,0 +1,203 @@
+// Copyright 2018 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/consensus/echash"
+	"github.com/etvchaineum/go-etvchaineum/core"
+	"github.com/etvchaineum/go-etvchaineum/core/rawdb"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/core/vm"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	// testContract stores the current block number into its first storage slot
+	testContract = common.HexToAddress("0xc0de")
+	testCode     = []byte{byte(vm.NUMBER), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP)}
+)
+
+// newTestChain creates an archive chain of the given length, where every block
+// modifies both the account trie and the storage trie of a contract. It returns
+// the database and the state roots of all the blocks, genesis included.
+func newTestChain(t *testing.T, blocks int) (echdb.Database, []common.Hash) {
+	db := echdb.NewMemDatabase()
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			testAddress:  {Balance: big.NewInt(1000000000000000)},
+			testContract: {Balance: big.NewInt(0), Code: testCode, Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0x01")}},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, echash.NewFaker(), db, blocks, func(i int, gen *core.BlockGen) {
+		signer := types.HomesteadSigner{}
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testAddress), testContract, big.NewInt(1), 100000, big.NewInt(1), nil), signer, testKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	blockchain, err := core.NewBlockChain(db, &core.CacheConfig{Disabled: true}, gspec.Config, echash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	roots := []common.Hash{genesis.Root()}
+	for _, block := range chain {
+		roots = append(roots, block.Root())
+	}
+	return db, roots
+}
+
+// checkPruned verifies that the states of the genesis and the last retain blocks
+// are complete, while the root nodes of all other states are gone.
+func checkPruned(t *testing.T, db echdb.Database, roots []common.Hash, retain int) {
+	for i, root := range roots {
+		if i == 0 || i >= len(roots)-retain {
+			statedb, err := state.New(root, state.NewDatabase(db))
+			if err != nil {
+				t.Fatalf("state #%d: failed to open retained state: %v", i, err)
+			}
+			it := state.NewNodeIterator(statedb)
+			for it.Next() {
+			}
+			if it.Error != nil {
+				t.Fatalf("state #%d: retained state incomplete: %v", i, it.Error)
+			}
+			continue
+		}
+		if has, _ := db.Has(root[:]); has {
+			t.Errorf("state #%d: pruned state root still present", i)
+		}
+	}
+	if progress := rawdb.ReadStatePruningProgress(db); progress != nil {
+		t.Errorf("pruning progress marker left behind: %x", progress)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "pruner-")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db, roots := newTestChain(t, 16)
+	if err := NewPruner(db, datadir, 1).Prune(4); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	checkPruned(t, db, roots, 4)
+
+	if common.FileExist(filepath.Join(datadir, stateBloomFileName)) {
+		t.Errorf("state bloom left behind after pruning")
+	}
+}
+
+// Tests that a pruning interrupted after the marking phase is finished on recovery.
+func TestPruneRecovery(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "pruner-")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db, roots := newTestChain(t, 16)
+
+	// Run the marking phase and persist the filter, but crash before the sweep
+	head, retained, err := retainedRoots(db, 4)
+	if err != nil {
+		t.Fatalf("failed to collect retained roots: %v", err)
+	}
+	bloom := newStateBloom(head, 1024*1024)
+	for _, root := range retained {
+		if err := markState(db, root, bloom); err != nil {
+			t.Fatalf("failed to mark state: %v", err)
+		}
+	}
+	if err := bloom.commit(filepath.Join(datadir, stateBloomFileName)); err != nil {
+		t.Fatalf("failed to persist state bloom: %v", err)
+	}
+	// Pretend part of the sweep was already done up to a checkpoint
+	checkpoint := common.HexToHash("0x8000000000000000000000000000000000000000000000000000000000000000")
+	rawdb.WriteStatePruningProgress(db, checkpoint[:])
+
+	if !PruningPending(datadir) {
+		t.Fatalf("interrupted pruning not reported as pending")
+	}
+	if err := RecoverPruning(datadir, db); err != nil {
+		t.Fatalf("failed to recover pruning: %v", err)
+	}
+	if PruningPending(datadir) {
+		t.Errorf("state bloom left behind after recovery")
+	}
+	// Stale states before the checkpoint must be untouched, the rest swept
+	for i, root := range roots[1 : len(roots)-4] {
+		has, _ := db.Has(root[:])
+		if before := bytes.Compare(root[:], checkpoint[:]) < 0; has != before {
+			t.Errorf("state #%d: root presence mismatch: have %v, want %v", i+1, has, before)
+		}
+	}
+	// A new pruning must finish the job without touching retained states
+	if err := NewPruner(db, datadir, 1).Prune(4); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	checkPruned(t, db, roots, 4)
+}
+
+// Tests that an interrupted pruning is not resumed if the database was modified.
+func TestPruneRecoveryHeadChanged(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "pruner-")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db, roots := newTestChain(t, 4)
+
+	bloom := newStateBloom(common.HexToHash("0xdeadbeef"), 1024)
+	if err := bloom.commit(filepath.Join(datadir, stateBloomFileName)); err != nil {
+		t.Fatalf("failed to persist state bloom: %v", err)
+	}
+	if err := RecoverPruning(datadir, db); err == nil {
+		t.Fatalf("pruning resumed on modified database")
+	}
+	for i, root := range roots {
+		if has, _ := db.Has(root[:]); !has {
+			t.Errorf("state #%d: root deleted by rejected recovery", i)
+		}
+	}
+}
@@ -18,7 +18,10 @@ package state
 
 import (
 	"bytes"
+	"encoding/json"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/etvchaineum/go-etvchaineum/common"
@@ -86,6 +89,83 @@ func (s *StateSuite) TestDump(c *checker.C) {
 	}
 }
 
+func (s *StateSuite) TestIterativeDump(c *checker.C) {
+	obj1 := s.state.GetOrNewStateObject(toAddr([]byte{0x01}))
+	obj1.AddBalance(big.NewInt(22))
+	obj2 := s.state.GetOrNewStateObject(toAddr([]byte{0x01, 0x02}))
+	obj2.SetCode(crypto.Keccak256Hash([]byte{3, 3, 3, 3, 3, 3, 3}), []byte{3, 3, 3, 3, 3, 3, 3})
+	obj2.SetState(s.state.db, common.Hash{0x01}, common.Hash{0x02})
+	obj3 := s.state.GetOrNewStateObject(toAddr([]byte{0x02}))
+	obj3.SetBalance(big.NewInt(44))
+	s.state.Commit(false)
+
+	// A full iterative dump must contain the same accounts as the collected one
+	full := s.state.RawDump()
+
+	var buf bytes.Buffer
+	if next := s.state.IterativeDump(false, false, nil, 0, json.NewEncoder(&buf)); next != nil {
+		c.Fatalf("unbounded dump returned continuation key %x", next)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(full.Accounts)+1 {
+		c.Fatalf("dump line count mismatch: have %d, want %d", len(lines), len(full.Accounts)+1)
+	}
+	for _, line := range lines[1:] {
+		var account DumpAccount
+		if err := json.Unmarshal([]byte(line), &account); err != nil {
+			c.Fatalf("failed to decode dumped account %q: %v", line, err)
+		}
+		if account.Address == nil {
+			c.Fatalf("dumped account without address: %q", line)
+		}
+		want := full.Accounts[common.Bytes2Hex(account.Address[:])]
+		want.Address = account.Address
+		if !reflect.DeepEqual(account, want) {
+			c.Errorf("dumped account mismatch: have %+v, want %+v", account, want)
+		}
+	}
+	// Excluded fields must be left out
+	buf.Reset()
+	s.state.IterativeDump(true, true, nil, 0, json.NewEncoder(&buf))
+	if strings.Contains(buf.String(), "03030303030303") || strings.Contains(buf.String(), "\"storage\":{") {
+		c.Errorf("excluded code or storage dumped: %s", buf.String())
+	}
+}
+
+func (s *StateSuite) TestIteratorDump(c *checker.C) {
+	for i := byte(0); i < 10; i++ {
+		s.state.AddBalance(toAddr([]byte{i}), big.NewInt(int64(i)+1))
+	}
+	s.state.Commit(false)
+
+	// Page through the state and ensure every account is visited exactly once
+	var (
+		seen  = make(map[string]bool)
+		start []byte
+		pages int
+	)
+	for {
+		page := s.state.IteratorDump(true, true, start, 3)
+		if len(page.Accounts) > 3 {
+			c.Fatalf("page %d: too many accounts: have %d, want at most %d", pages, len(page.Accounts), 3)
+		}
+		for addr := range page.Accounts {
+			if seen[addr] {
+				c.Fatalf("page %d: account %s dumped twice", pages, addr)
+			}
+			seen[addr] = true
+		}
+		pages++
+		if page.Next == nil {
+			break
+		}
+		start = page.Next
+	}
+	if len(seen) != 10 || pages != 4 {
+		c.Errorf("paginated dump mismatch: have %d accounts in %d pages, want 10 in 4", len(seen), pages)
+	}
+}
+
 func (s *StateSuite) SetUpTest(c *checker.C) {
 	s.db = echdb.NewMemDatabase()
 	s.state, _ = New(common.Hash{}, NewDatabase(s.db))
@@ -95,6 +95,13 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
+	return ApplyTransactionWithEVM(msg, config, gp, statedb, header, tx, usedGas, vmenv)
+}
+
+// ApplyTransactionWithEVM applies a transaction like ApplyTransaction, but in an
+// EVM environment created by the caller for the message of the transaction. This
+// allows the caller to cancel the execution.
+func ApplyTransactionWithEVM(msg Message, config *params.ChainConfig, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, vmenv *vm.EVM) (*types.Receipt, uint64, error) {
 	// Apply the transaction to the current state (included in the env)
 	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
 	if err != nil {
@@ -251,14 +251,8 @@ func (l *txList) Overlaps(tx *types.Transaction) bool {
 func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Transaction) {
 	// If there's an older better transaction, abort
 	old := l.txs.Get(tx.Nonce())
-	if old != nil {
-		threshold := new(big.Int).Div(new(big.Int).Mul(old.GasPrice(), big.NewInt(100+int64(priceBump))), big.NewInt(100))
-		// Have to ensure that the new gas price is higher than the old gas
-		// price as well as checking the percentage threshold to ensure that
-		// this is accurate for low (Wei-level) gas price replacements
-		if old.GasPrice().Cmp(tx.GasPrice()) >= 0 || threshold.Cmp(tx.GasPrice()) > 0 {
-			return false, nil
-		}
+	if old != nil && replacementPrice(old.GasPrice(), priceBump).Cmp(tx.GasPrice()) > 0 {
+		return false, nil
 	}
 	// Otherwise overwrite the old transaction with the current one
 	l.txs.Put(tx)
@@ -271,6 +265,28 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	return true, old
 }
 
+// ReplacementPrice returns the minimum gas price a transaction needs to replace
+// the one with the given nonce in the list, or nil if there's no such transaction.
+func (l *txList) ReplacementPrice(nonce uint64, priceBump uint64) *big.Int {
+	old := l.txs.Get(nonce)
+	if old == nil {
+		return nil
+	}
+	return replacementPrice(old.GasPrice(), priceBump)
+}
+
+// replacementPrice calculates the minimum gas price needed to replace a transaction
+// with the given price. Have to ensure that the new gas price is higher than the
+// old gas price as well as reaching the percentage threshold to ensure that this
+// is accurate for low (Wei-level) gas price replacements.
+func replacementPrice(price *big.Int, priceBump uint64) *big.Int {
+	threshold := new(big.Int).Div(new(big.Int).Mul(price, big.NewInt(100+int64(priceBump))), big.NewInt(100))
+	if threshold.Cmp(price) <= 0 {
+		threshold.Add(price, common.Big1)
+	}
+	return threshold
+}
+
 // Forward removes all transactions from the list with a nonce lower than the
 // provided threshold. Every removed transaction is returned for any post-removal
 // maintenance.
@@ -0,0 +1,207 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+)
+
+var (
+	// ErrSenderNotAllowed is returned if the sender of a transaction is not on
+	// the sender allow list of the pool.
+	ErrSenderNotAllowed = errors.New("sender not allowed")
+
+	// ErrSenderDenied is returned if the sender of a transaction is on the sender
+	// deny list of the pool.
+	ErrSenderDenied = errors.New("sender denied")
+
+	// ErrRecipientNotAllowed is returned if the recipient of a transaction is not
+	// on the recipient allow list of the pool.
+	ErrRecipientNotAllowed = errors.New("recipient not allowed")
+
+	// ErrRecipientDenied is returned if the recipient of a transaction is on the
+	// recipient deny list of the pool.
+	ErrRecipientDenied = errors.New("recipient denied")
+
+	// ErrContractCreation is returned if a transaction creates a contract but the
+	// pool is configured to reject contract creations.
+	ErrContractCreation = errors.New("contract creation not allowed")
+)
+
+// TxPolicyErrorCode is the JSON-RPC error code transactions rejected by a pool
+// policy are reported with.
+const TxPolicyErrorCode = -32003
+
+// TxPolicyError is returned if a transaction pool policy rejects a transaction.
+type TxPolicyError struct {
+	Policy string // Name of the policy rejecting the transaction
+	Err    error  // Reason of the rejection
+}
+
+func (e *TxPolicyError) Error() string {
+	return fmt.Sprintf("transaction rejected by %s policy: %v", e.Policy, e.Err)
+}
+
+// ErrorCode returns the JSON-RPC error code of the rejection, so that it can be
+// told apart from other failures by RPC clients.
+func (e *TxPolicyError) ErrorCode() int { return TxPolicyErrorCode }
+
+// TxPolicy is an admission hook consulted by the transaction pools before
+// accepting a transaction, after all built-in validity checks passed.
+type TxPolicy interface {
+	// Name returns the name the policy is reported by when rejecting a transaction.
+	Name() string
+
+	// Validate checks whetvchain a transaction sent by the given account may enter
+	// the pool, returning the reason of the rejection if not.
+	Validate(tx *types.Transaction, from common.Address, local bool) error
+}
+
+// ValidateTxPolicies runs a transaction through a chain of policies, returning a
+// TxPolicyError for the first one rejecting it.
+func ValidateTxPolicies(policies []TxPolicy, tx *types.Transaction, from common.Address, local bool) error {
+	for _, policy := range policies {
+		if err := policy.Validate(tx, from, local); err != nil {
+			if _, ok := err.(*TxPolicyError); ok {
+				return err
+			}
+			return &TxPolicyError{Policy: policy.Name(), Err: err}
+		}
+	}
+	return nil
+}
+
+// TxPoliciesFromConfig creates the built-in policies enabled by a transaction
+// pool configuration.
+func TxPoliciesFromConfig(config TxPoolConfig) []TxPolicy {
+	var policies []TxPolicy
+	if len(config.AllowedSenders) > 0 || len(config.DeniedSenders) > 0 {
+		policies = append(policies, NewSenderPolicy(config.AllowedSenders, config.DeniedSenders))
+	}
+	if len(config.AllowedRecipients) > 0 || len(config.DeniedRecipients) > 0 {
+		policies = append(policies, NewRecipientPolicy(config.AllowedRecipients, config.DeniedRecipients))
+	}
+	if config.NoContractCreation {
+		policies = append(policies, NewContractCreationPolicy())
+	}
+	return policies
+}
+
+// addressList is a set of accounts used by the allow and deny list policies.
+type addressList map[common.Address]struct{}
+
+// newAddressList creates a set from a list of accounts.
+func newAddressList(addrs []common.Address) addressList {
+	list := make(addressList, len(addrs))
+	for _, addr := range addrs {
+		list[addr] = struct{}{}
+	}
+	return list
+}
+
+// contains checks if a given account is within the set.
+func (list addressList) contains(addr common.Address) bool {
+	_, ok := list[addr]
+	return ok
+}
+
+// senderPolicy rejects transactions based on an allow and a deny list of senders.
+type senderPolicy struct {
+	allowed addressList
+	denied  addressList
+}
+
+// NewSenderPolicy creates a policy only accepting transactions from the allowed
+// senders, or from anyone if the allow list is empty, unless they are denied.
+func NewSenderPolicy(allowed, denied []common.Address) TxPolicy {
+	return &senderPolicy{
+		allowed: newAddressList(allowed),
+		denied:  newAddressList(denied),
+	}
+}
+
+// Name implements TxPolicy, returning the name of the sender policy.
+func (p *senderPolicy) Name() string { return "sender" }
+
+// Validate implements TxPolicy, checking the sender against the lists.
+func (p *senderPolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	if p.denied.contains(from) {
+		return ErrSenderDenied
+	}
+	if len(p.allowed) > 0 && !p.allowed.contains(from) {
+		return ErrSenderNotAllowed
+	}
+	return nil
+}
+
+// recipientPolicy rejects transactions based on an allow and a deny list of
+// recipients.
+type recipientPolicy struct {
+	allowed addressList
+	denied  addressList
+}
+
+// NewRecipientPolicy creates a policy only accepting transactions to the allowed
+// recipients, or to anyone if the allow list is empty, unless they are denied.
+// Contract creations have no recipient and are not affected by this policy.
+func NewRecipientPolicy(allowed, denied []common.Address) TxPolicy {
+	return &recipientPolicy{
+		allowed: newAddressList(allowed),
+		denied:  newAddressList(denied),
+	}
+}
+
+// Name implements TxPolicy, returning the name of the recipient policy.
+func (p *recipientPolicy) Name() string { return "recipient" }
+
+// Validate implements TxPolicy, checking the recipient against the lists.
+func (p *recipientPolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	to := tx.To()
+	if to == nil {
+		return nil
+	}
+	if p.denied.contains(*to) {
+		return ErrRecipientDenied
+	}
+	if len(p.allowed) > 0 && !p.allowed.contains(*to) {
+		return ErrRecipientNotAllowed
+	}
+	return nil
+}
+
+// contractCreationPolicy rejects all contract creation transactions.
+type contractCreationPolicy struct{}
+
+// NewContractCreationPolicy creates a policy rejecting all contract creations.
+func NewContractCreationPolicy() TxPolicy {
+	return contractCreationPolicy{}
+}
+
+// Name implements TxPolicy, returning the name of the contract creation policy.
+func (contractCreationPolicy) Name() string { return "no-contract-creation" }
+
+// Validate implements TxPolicy, rejecting transactions without a recipient.
+func (contractCreationPolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	if tx.To() == nil {
+		return ErrContractCreation
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/event"
+	"github.com/etvchaineum/go-etvchaineum/params"
+)
+
+// testPolicy is a transaction pool policy rejecting transactions with a given
+// nonce, recording the transactions it was consulted for.
+type testPolicy struct {
+	nonce uint64
+	seen  []common.Hash
+}
+
+func (p *testPolicy) Name() string { return "test" }
+
+func (p *testPolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	p.seen = append(p.seen, tx.Hash())
+	if tx.Nonce() == p.nonce {
+		return errors.New("forbidden nonce")
+	}
+	return nil
+}
+
+// Tests that the built-in policies enabled by the pool configuration accept and
+// reject transactions as expected.
+func TestTxPoliciesFromConfig(t *testing.T) {
+	t.Parallel()
+
+	var (
+		alice = common.HexToAddress("0xa1")
+		bob   = common.HexToAddress("0xb0")
+		carol = common.HexToAddress("0xca")
+	)
+	transfer := types.NewTransaction(0, bob, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+	creation := types.NewContractCreation(0, big.NewInt(1), 100000, big.NewInt(1), nil)
+
+	tests := []struct {
+		config TxPoolConfig
+		tx     *types.Transaction
+		from   common.Address
+		err    error
+	}{
+		{TxPoolConfig{}, transfer, alice, nil},
+		{TxPoolConfig{}, creation, alice, nil},
+
+		{TxPoolConfig{AllowedSenders: []common.Address{alice}}, transfer, alice, nil},
+		{TxPoolConfig{AllowedSenders: []common.Address{alice}}, transfer, carol, ErrSenderNotAllowed},
+		{TxPoolConfig{DeniedSenders: []common.Address{alice}}, transfer, alice, ErrSenderDenied},
+		{TxPoolConfig{DeniedSenders: []common.Address{alice}}, transfer, carol, nil},
+		{TxPoolConfig{AllowedSenders: []common.Address{alice}, DeniedSenders: []common.Address{alice}}, transfer, alice, ErrSenderDenied},
+
+		{TxPoolConfig{AllowedRecipients: []common.Address{bob}}, transfer, alice, nil},
+		{TxPoolConfig{AllowedRecipients: []common.Address{carol}}, transfer, alice, ErrRecipientNotAllowed},
+		{TxPoolConfig{AllowedRecipients: []common.Address{carol}}, creation, alice, nil},
+		{TxPoolConfig{DeniedRecipients: []common.Address{bob}}, transfer, alice, ErrRecipientDenied},
+
+		{TxPoolConfig{NoContractCreation: true}, transfer, alice, nil},
+		{TxPoolConfig{NoContractCreation: true}, creation, alice, ErrContractCreation},
+	}
+	for i, tt := range tests {
+		err := ValidateTxPolicies(TxPoliciesFromConfig(tt.config), tt.tx, tt.from, false)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("test %d: unexpected rejection: %v", i, err)
+			}
+			continue
+		}
+		perr, ok := err.(*TxPolicyError)
+		if !ok {
+			t.Errorf("test %d: error type mismatch: have %T, want %T", i, err, perr)
+			continue
+		}
+		if perr.Err != tt.err {
+			t.Errorf("test %d: rejection reason mismatch: have %v, want %v", i, perr.Err, tt.err)
+		}
+		if perr.ErrorCode() != TxPolicyErrorCode {
+			t.Errorf("test %d: error code mismatch: have %d, want %d", i, perr.ErrorCode(), TxPolicyErrorCode)
+		}
+	}
+}
+
+// Tests that the transaction pool consults both the configured and the registered
+// policies, and only for transactions passing the built-in validity checks.
+func TestTransactionPoolPolicies(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.NoContractCreation = true
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	policy := &testPolicy{nonce: 1}
+	pool.AddPolicy(policy)
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	// Transactions failing the built-in checks never reach the policies
+	if err := pool.AddRemote(transaction(0, 100, key)); err != ErrIntrinsicGas {
+		t.Fatalf("intrinsic gas check mismatch: have %v, want %v", err, ErrIntrinsicGas)
+	}
+	if len(policy.seen) != 0 {
+		t.Fatalf("policy consulted for invalid transaction")
+	}
+	// Contract creations are rejected by the configured policy
+	creation, _ := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	if err := pool.AddLocal(creation); err == nil {
+		t.Fatalf("contract creation accepted")
+	} else if perr, ok := err.(*TxPolicyError); !ok || perr.Err != ErrContractCreation {
+		t.Fatalf("contract creation rejection mismatch: have %v, want %v", err, ErrContractCreation)
+	}
+	// Transactions are rejected by the registered policy
+	if err := pool.AddRemote(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(transaction(1, 100000, key)); err == nil {
+		t.Fatalf("transaction with forbidden nonce accepted")
+	} else if perr, ok := err.(*TxPolicyError); !ok || perr.Policy != "test" {
+		t.Fatalf("rejection policy mismatch: have %v, want test", err)
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 1/0", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
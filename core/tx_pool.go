@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -81,6 +82,7 @@ var (
 var (
 	evictionInterval    = time.Minute     // Time interval to check for evictable transactions
 	statsReportInterval = 8 * time.Second // Time interval to report transaction pool stats
+	maxQueuedEvents     = 4096            // Maximum number of pool events waiting for slow subscribers
 )
 
 var (
@@ -99,6 +101,9 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+
+	// Pool event metrics
+	droppedEventCounter = metrics.NewRegisteredCounter("txpool/events/dropped", nil) // Dropped due to slow subscribers
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -128,6 +133,9 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	RemoteJournal      string // Snapshot of remote transactions to survive node restarts (disabled if empty)
+	RemoteJournalLimit uint64 // Maximum number of remote transactions to snapshot
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -137,6 +145,12 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	AllowedSenders     []common.Address // Senders allowed to submit transactions (anyone if empty)
+	DeniedSenders      []common.Address // Senders whose transactions are rejected
+	AllowedRecipients  []common.Address // Recipients transactions may be sent to (anyone if empty)
+	DeniedRecipients   []common.Address // Recipients transactions are rejected to
+	NoContractCreation bool             // Whetvchain contract creation transactions should be rejected
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -145,6 +159,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
 
+	RemoteJournalLimit: 4096,
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -164,6 +180,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.RemoteJournal != "" && conf.RemoteJournalLimit < 1 {
+		log.Warn("Sanitizing invalid txpool remote journal limit", "provided", conf.RemoteJournalLimit, "updated", DefaultTxPoolConfig.RemoteJournalLimit)
+		conf.RemoteJournalLimit = DefaultTxPoolConfig.RemoteJournalLimit
+	}
 	if conf.PriceLimit < 1 {
 		log.Warn("Sanitizing invalid txpool price limit", "provided", conf.PriceLimit, "updated", DefaultTxPoolConfig.PriceLimit)
 		conf.PriceLimit = DefaultTxPoolConfig.PriceLimit
@@ -208,6 +228,7 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	eventFeed    event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -218,8 +239,10 @@ type TxPool struct {
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals        *accountSet      // Set of local transaction to exempt from eviction rules
+	journal       *txJournal       // Journal of local transaction to back up to disk
+	remoteJournal *remoteTxJournal // Snapshot of remote transactions to back up to disk
+	policies      []TxPolicy       // Admission hooks consulted before accepting a transaction
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -227,6 +250,12 @@ type TxPool struct {
 	all     *txLookup                    // All transactions to allow lookups
 	priced  *txPricedList                // All transactions sorted by price
 
+	events         []TxPoolEvent // Pool events waiting to be dispatched, in order
+	eventsMu       sync.Mutex    // Mutex protecting the pending pool events
+	eventsOverflow bool          // Whetvchain events were dropped since the queue was last drained
+	eventWake      chan struct{} // Notification channel for newly queued pool events
+	eventQuit      chan struct{} // Quit channel for the pool event dispatcher
+
 	wg sync.WaitGroup // for shutdown sync
 
 	homestead bool
@@ -250,6 +279,9 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		all:         newTxLookup(),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		eventWake:   make(chan struct{}, 1),
+		eventQuit:   make(chan struct{}),
+		policies:    TxPoliciesFromConfig(config),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
@@ -270,12 +302,18 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If remote transaction journaling is enabled, load the last snapshot
+	if config.RemoteJournal != "" {
+		pool.remoteJournal = newRemoteTxJournal(config.RemoteJournal, config.RemoteJournalLimit)
+		pool.loadRemotes()
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
-	// Start the event loop and return
-	pool.wg.Add(1)
+	// Start the event loops and return
+	pool.wg.Add(2)
 	go pool.loop()
+	go pool.eventLoop()
 
 	return pool
 }
@@ -343,7 +381,7 @@ func (pool *TxPool) loop() {
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
-						pool.removeTx(tx.Hash(), true)
+						pool.removeTx(tx.Hash(), true, TxReasonLifetimeExpired)
 					}
 				}
 			}
@@ -358,10 +396,63 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.remoteJournal != nil {
+				pool.mu.RLock()
+				remotes := pool.remotes()
+				pool.mu.RUnlock()
+
+				if err := pool.remoteJournal.write(remotes); err != nil {
+					log.Warn("Failed to snapshot remote tx journal", "err", err)
+				}
+			}
 		}
 	}
 }
 
+// eventLoop dispatches the queued pool events to the subscribers in the order
+// they were posted, without blocking the pool operations posting them.
+func (pool *TxPool) eventLoop() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case <-pool.eventWake:
+			pool.eventsMu.Lock()
+			events := pool.events
+			pool.events, pool.eventsOverflow = nil, false
+			pool.eventsMu.Unlock()
+
+			for _, ev := range events {
+				pool.eventFeed.Send(ev)
+			}
+		case <-pool.eventQuit:
+			return
+		}
+	}
+}
+
+// postEvent queues a pool event for dispatching to the subscribers. If the queue
+// is full because a subscriber doesn't keep up, the oldest event is dropped.
+func (pool *TxPool) postEvent(ev TxPoolEvent) {
+	pool.eventsMu.Lock()
+	if len(pool.events) >= maxQueuedEvents {
+		if !pool.eventsOverflow {
+			log.Warn("Transaction pool event subscriber too slow, dropping events", "queued", len(pool.events))
+			pool.eventsOverflow = true
+		}
+		copy(pool.events, pool.events[1:])
+		pool.events = pool.events[:len(pool.events)-1]
+		droppedEventCounter.Inc(1)
+	}
+	pool.events = append(pool.events, ev)
+	pool.eventsMu.Unlock()
+
+	select {
+	case pool.eventWake <- struct{}{}:
+	default:
+	}
+}
+
 // lockedReset is a wrapper around reset to allow calling it in a thread safe
 // manner. This mechod is only ever used in the tester!
 func (pool *TxPool) lockedReset(oldHead, newHead *types.Header) {
@@ -462,11 +553,21 @@ func (pool *TxPool) Stop() {
 
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
+	close(pool.eventQuit)
 	pool.wg.Wait()
 
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.remoteJournal != nil {
+		pool.mu.RLock()
+		remotes := pool.remotes()
+		pool.mu.RUnlock()
+
+		if err := pool.remoteJournal.write(remotes); err != nil {
+			log.Warn("Failed to snapshot remote tx journal", "err", err)
+		}
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -476,6 +577,13 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxPoolEvent registers a subscription of TxPoolEvent and starts sending
+// the events describing the transactions entering, moving within and leaving the
+// pool to the given channel.
+func (pool *TxPool) SubscribeTxPoolEvent(ch chan<- TxPoolEvent) event.Subscription {
+	return pool.scope.Track(pool.eventFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -492,11 +600,20 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price, pool.locals) {
-		pool.removeTx(tx.Hash(), false)
+		pool.removeTx(tx.Hash(), false, TxReasonUnderpriced)
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// AddPolicy appends an admission hook to the chain of policies consulted before
+// accepting a new transaction. Transactions already in the pool are not affected.
+func (pool *TxPool) AddPolicy(policy TxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policies = append(pool.policies, policy)
+}
+
 // State returns the virtual managed state of the transaction pool.
 func (pool *TxPool) State() *state.ManagedState {
 	pool.mu.RLock()
@@ -545,6 +662,71 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the data content of the transaction pool for a single
+// account, returning its pending as well as queued transactions sorted by nonce.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending, queued types.Transactions
+	if list, ok := pool.pending[addr]; ok {
+		pending = list.Flatten()
+	}
+	if list, ok := pool.queue[addr]; ok {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
+// TxPoolAccountStatus is a summary of the transactions of a single account in
+// the pool, meant to diagnose why its transactions are not being executed.
+type TxPoolAccountStatus struct {
+	Pending int    // Number of executable transactions of the account
+	Queued  int    // Number of non-executable transactions of the account
+	Nonce   uint64 // Nonce of the account in the current state
+
+	// NonceGap is the first missing nonce blocking the promotion of the queued
+	// transactions, or nil if nothing is queued.
+	NonceGap *uint64
+
+	// ReplacementPrices maps the nonce of every queued transaction to the minimum
+	// gas price needed to replace it, as required by the price bump.
+	ReplacementPrices map[uint64]*big.Int
+}
+
+// AccountStatus retrieves the pool status of a single account, namely the number
+// of its pending and queued transactions, its state nonce, the nonce gap blocking
+// its queued transactions and the gas prices needed to replace them.
+func (pool *TxPool) AccountStatus(addr common.Address) *TxPoolAccountStatus {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	status := &TxPoolAccountStatus{
+		Nonce:             pool.currentState.GetNonce(addr),
+		ReplacementPrices: make(map[uint64]*big.Int),
+	}
+	next := status.Nonce
+	if list, ok := pool.pending[addr]; ok {
+		txs := list.Flatten()
+		status.Pending, next = len(txs), txs[len(txs)-1].Nonce()+1
+	}
+	if list, ok := pool.queue[addr]; ok {
+		status.Queued = list.Len()
+
+		// Find the first nonce missing in front of the queued transactions
+		gap := next
+		for list.txs.Get(gap) != nil {
+			gap++
+		}
+		status.NonceGap = &gap
+
+		for _, tx := range list.Flatten() {
+			status.ReplacementPrices[tx.Nonce()] = list.ReplacementPrice(tx.Nonce(), pool.config.PriceBump)
+		}
+	}
+	return status
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -583,6 +765,90 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remotes retrieves all currently known remote transactions along with the last
+// heartbeat of their senders, executable ones first. The transactions of the
+// senders are interleaved by nonce, so truncating the returned entries drops the
+// highest nonces of the senders with the most transactions first, never leaving
+// a nonce gap. The caller must hold at least the read lock of the pool.
+func (pool *TxPool) remotes() []*remoteTx {
+	var (
+		now     = time.Now()
+		entries []*remoteTx
+	)
+	collect := func(lists map[common.Address]*txList) {
+		var (
+			addrs []common.Address
+			txs   = make(map[common.Address]types.Transactions)
+		)
+		for addr, list := range lists {
+			if !pool.locals.contains(addr) && !list.Empty() {
+				addrs = append(addrs, addr)
+				txs[addr] = list.Flatten()
+			}
+		}
+		sort.Slice(addrs, func(i, j int) bool {
+			return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+		})
+		// Take the next transaction of every sender having any left in each round
+		for i := 0; len(addrs) > 0; i++ {
+			remaining := addrs[:0]
+			for _, addr := range addrs {
+				beat := pool.beats[addr]
+				if beat.IsZero() {
+					beat = now
+				}
+				entries = append(entries, &remoteTx{Time: uint64(beat.Unix()), Tx: txs[addr][i]})
+				if i+1 < len(txs[addr]) {
+					remaining = append(remaining, addr)
+				}
+			}
+			addrs = remaining
+		}
+	}
+	collect(pool.pending)
+	collect(pool.queue)
+
+	return entries
+}
+
+// loadRemotes injects the transactions of the remote transaction journal into
+// the pool, restoring the heartbeats of their senders so that they don't outlive
+// the configured lifetime by being journaled.
+func (pool *TxPool) loadRemotes() {
+	entries, err := pool.remoteJournal.load(pool.config.Lifetime)
+	if err != nil {
+		log.Warn("Failed to load remote transaction journal", "err", err)
+	}
+	if len(entries) == 0 {
+		return
+	}
+	txs := make([]*types.Transaction, len(entries))
+	for i, entry := range entries {
+		txs[i] = entry.Tx
+	}
+	errs := pool.AddRemotes(txs)
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	beats := make(map[common.Address]time.Time)
+	for i, entry := range entries {
+		if errs[i] != nil {
+			log.Debug("Failed to add journaled remote transaction", "err", errs[i])
+			continue
+		}
+		from, _ := types.Sender(pool.signer, entry.Tx) // already validated
+		if beat := time.Unix(int64(entry.Time), 0); beat.After(beats[from]) {
+			beats[from] = beat
+		}
+	}
+	for addr, beat := range beats {
+		if pool.pending[addr] != nil || pool.queue[addr] != nil {
+			pool.beats[addr] = beat
+		}
+	}
+}
+
 // validateTx checks whetvchain a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -625,7 +891,8 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
-	return nil
+	// Let the admission policies have the last word
+	return ValidateTxPolicies(pool.policies, tx, from, local)
 }
 
 // add validates a transaction and inserts it into the non-executable queue for
@@ -662,7 +929,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
-			pool.removeTx(tx.Hash(), false)
+			pool.removeTx(tx.Hash(), false, TxReasonUnderpriced)
 		}
 	}
 	// If the transaction is replacing an already pending one, do directly
@@ -679,11 +946,16 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+
+			pool.postEvent(TxPoolEvent{Type: TxPoolReplaced, Tx: old, Reason: TxReasonReplaced, ReplacedBy: hash})
 		}
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
 		pool.journalTx(from, tx)
 
+		pool.postEvent(TxPoolEvent{Type: TxPoolAdded, Tx: tx})
+		pool.postEvent(TxPoolEvent{Type: TxPoolPromoted, Tx: tx})
+
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// We've directly injected a replacement transaction, notify subsystems
@@ -696,6 +968,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	pool.postEvent(TxPoolEvent{Type: TxPoolAdded, Tx: tx})
 	// Mark local addresses and journal local transactions
 	if local {
 		if !pool.locals.contains(from) {
@@ -729,6 +1002,8 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+
+		pool.postEvent(TxPoolEvent{Type: TxPoolReplaced, Tx: old, Reason: TxReasonReplaced, ReplacedBy: hash})
 	}
 	if pool.all.Get(hash) == nil {
 		pool.all.Add(tx)
@@ -767,6 +1042,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonUnderpriced})
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -775,6 +1051,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.postEvent(TxPoolEvent{Type: TxPoolReplaced, Tx: old, Reason: TxReasonReplaced, ReplacedBy: hash})
 	}
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all.Get(hash) == nil {
@@ -785,6 +1062,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
 
+	pool.postEvent(TxPoolEvent{Type: TxPoolPromoted, Tx: tx})
 	return true
 }
 
@@ -893,15 +1171,23 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all.Get(hash)
 }
 
+// ArrivalTime returns the time a transaction entered the pool, or the zero time
+// if it's not contained within the pool.
+func (pool *TxPool) ArrivalTime(hash common.Hash) time.Time {
+	return pool.all.Arrival(hash)
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
-// transactions back to the future queue.
-func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
+// transactions back to the future queue. The reason is reported to the pool
+// event subscribers.
+func (pool *TxPool) removeTx(hash common.Hash, outofbound bool, reason TxPoolEventReason) {
 	// Fetch the transaction we wish to delete
 	tx := pool.all.Get(hash)
 	if tx == nil {
 		return
 	}
 	addr, _ := types.Sender(pool.signer, tx) // already validated during insertion
+	pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: reason})
 
 	// Remove it from the list of known transactions
 	pool.all.Remove(hash)
@@ -919,6 +1205,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 			// Postpone any invalidated transactions
 			for _, tx := range invalids {
 				pool.enqueueTx(tx.Hash(), tx)
+				pool.postEvent(TxPoolEvent{Type: TxPoolDemoted, Tx: tx, Reason: TxReasonNonceGap})
 			}
 			// Update the account nonce if needed
 			if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -962,6 +1249,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			log.Trace("Removed old queued transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonNonceTooLow})
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -971,6 +1259,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			pool.all.Remove(hash)
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
+			pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonInsufficientFunds})
 		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
@@ -988,6 +1277,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
+				pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonPoolOverflow})
 			}
 		}
 		// Delete the entire queue entry if it became empty.
@@ -1041,6 +1331,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 								pool.pendingState.SetNonce(offenders[i], nonce)
 							}
 							log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+							pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonPoolOverflow})
 						}
 						pending--
 					}
@@ -1063,6 +1354,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 							pool.pendingState.SetNonce(addr, nonce)
 						}
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+						pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonPoolOverflow})
 					}
 					pending--
 				}
@@ -1095,7 +1387,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Drop all transactions if they are less than the overflow
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
-					pool.removeTx(tx.Hash(), true)
+					pool.removeTx(tx.Hash(), true, TxReasonPoolOverflow)
 				}
 				drop -= size
 				queuedRateLimitCounter.Inc(int64(size))
@@ -1104,7 +1396,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Otherwise drop only last few transactions
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
-				pool.removeTx(txs[i].Hash(), true)
+				pool.removeTx(txs[i].Hash(), true, TxReasonPoolOverflow)
 				drop--
 				queuedRateLimitCounter.Inc(1)
 			}
@@ -1126,6 +1418,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed old pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonNonceTooLow})
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -1135,11 +1428,13 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
+			pool.postEvent(TxPoolEvent{Type: TxPoolDropped, Tx: tx, Reason: TxReasonInsufficientFunds})
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
 			pool.enqueueTx(hash, tx)
+			pool.postEvent(TxPoolEvent{Type: TxPoolDemoted, Tx: tx, Reason: TxReasonNonceGap})
 		}
 		// If there's a gap in front, alert (should never happen) and postpone all transactions
 		if list.Len() > 0 && list.txs.Get(nonce) == nil {
@@ -1147,6 +1442,7 @@ func (pool *TxPool) demoteUnexecutables() {
 				hash := tx.Hash()
 				log.Error("Demoting invalidated transaction", "hash", hash)
 				pool.enqueueTx(hash, tx)
+				pool.postEvent(TxPoolEvent{Type: TxPoolDemoted, Tx: tx, Reason: TxReasonNonceGap})
 			}
 		}
 		// Delete the entire queue entry if it became empty.
@@ -1230,14 +1526,16 @@ func (as *accountSet) flatten() []common.Address {
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all  map[common.Hash]*types.Transaction
-	lock sync.RWMutex
+	all      map[common.Hash]*types.Transaction
+	arrivals map[common.Hash]time.Time
+	lock     sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		all: make(map[common.Hash]*types.Transaction),
+		all:      make(map[common.Hash]*types.Transaction),
+		arrivals: make(map[common.Hash]time.Time),
 	}
 }
 
@@ -1269,12 +1567,25 @@ func (t *txLookup) Count() int {
 	return len(t.all)
 }
 
+// Arrival returns the time a transaction was added to the lookup, or the zero
+// time if not found.
+func (t *txLookup) Arrival(hash common.Hash) time.Time {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.arrivals[hash]
+}
+
 // Add adds a transaction to the lookup.
 func (t *txLookup) Add(tx *types.Transaction) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	t.all[tx.Hash()] = tx
+	hash := tx.Hash()
+	if _, ok := t.arrivals[hash]; !ok {
+		t.arrivals[hash] = time.Now()
+	}
+	t.all[hash] = tx
 }
 
 // Remove removes a transaction from the lookup.
@@ -1283,4 +1594,5 @@ func (t *txLookup) Remove(hash common.Hash) {
 	defer t.lock.Unlock()
 
 	delete(t.all, hash)
+	delete(t.arrivals, hash)
 }
@@ -347,7 +347,7 @@ func TestTransactionChainFork(t *testing.T) {
 	if _, err := pool.add(tx, false); err != nil {
 		t.Error("didn't expect error", err)
 	}
-	pool.removeTx(tx.Hash(), true)
+	pool.removeTx(tx.Hash(), true, TxReasonNone)
 
 	// reset the pool's internal state
 	resetState()
@@ -1832,3 +1832,198 @@ func benchmarkPoolBatchInsert(b *testing.B, size int) {
 		pool.AddRemotes(batch)
 	}
 }
+
+// Tests that the pool reports transactions entering, moving within and leaving
+// it on the pool event feed, along with the reasons of replacements and drops.
+func TestTransactionPoolEvents(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+	statedb.AddBalance(addr, big.NewInt(1000000000))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	events := make(chan TxPoolEvent, 32)
+	sub := pool.SubscribeTxPoolEvent(events)
+	defer sub.Unsubscribe()
+
+	check := func(step string, want ...TxPoolEvent) {
+		t.Helper()
+		for i, w := range want {
+			select {
+			case ev := <-events:
+				if ev.Type != w.Type || ev.Tx.Hash() != w.Tx.Hash() || ev.Reason != w.Reason || ev.ReplacedBy != w.ReplacedBy {
+					t.Fatalf("%s: event %d mismatch: have %v %x (%v, %x), want %v %x (%v, %x)", step, i,
+						ev.Type, ev.Tx.Hash(), ev.Reason, ev.ReplacedBy, w.Type, w.Tx.Hash(), w.Reason, w.ReplacedBy)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: event %d not fired", step, i)
+			}
+		}
+		select {
+		case ev := <-events:
+			t.Fatalf("%s: unexpected event: %v %x", step, ev.Type, ev.Tx.Hash())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	// Add an executable transaction and replace it with a higher priced one
+	tx0 := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.AddRemote(tx0); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	check("add", TxPoolEvent{Type: TxPoolAdded, Tx: tx0}, TxPoolEvent{Type: TxPoolPromoted, Tx: tx0})
+
+	tx0b := pricedTransaction(0, 100000, big.NewInt(2), key)
+	if err := pool.AddRemote(tx0b); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	check("replace",
+		TxPoolEvent{Type: TxPoolReplaced, Tx: tx0, Reason: TxReasonReplaced, ReplacedBy: tx0b.Hash()},
+		TxPoolEvent{Type: TxPoolAdded, Tx: tx0b},
+		TxPoolEvent{Type: TxPoolPromoted, Tx: tx0b},
+	)
+	// Add a future transaction and fill the nonce gap in front of it
+	tx1 := pricedTransaction(1, 100000, big.NewInt(1), key)
+	tx2 := pricedTransaction(2, 100000, big.NewInt(2), key)
+	if err := pool.AddRemote(tx2); err != nil {
+		t.Fatalf("failed to add future transaction: %v", err)
+	}
+	check("queue", TxPoolEvent{Type: TxPoolAdded, Tx: tx2})
+
+	if err := pool.AddRemote(tx1); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	check("promote",
+		TxPoolEvent{Type: TxPoolAdded, Tx: tx1},
+		TxPoolEvent{Type: TxPoolPromoted, Tx: tx1},
+		TxPoolEvent{Type: TxPoolPromoted, Tx: tx2},
+	)
+	// Include the first transaction in a block and ensure it's dropped
+	statedb.SetNonce(addr, 1)
+	pool.lockedReset(nil, nil)
+	check("include", TxPoolEvent{Type: TxPoolDropped, Tx: tx0b, Reason: TxReasonNonceTooLow})
+
+	// Raise the minimum price and ensure the cheaper one drops, demoting the next
+	pool.SetGasPrice(big.NewInt(3))
+	check("reprice",
+		TxPoolEvent{Type: TxPoolDropped, Tx: tx1, Reason: TxReasonUnderpriced},
+		TxPoolEvent{Type: TxPoolDemoted, Tx: tx2, Reason: TxReasonNonceGap},
+		TxPoolEvent{Type: TxPoolDropped, Tx: tx2, Reason: TxReasonUnderpriced},
+	)
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that a subscriber not reading the pool events doesn't make the queue of
+// undispatched events grow without bounds.
+func TestTransactionPoolEventsSlowSubscriber(t *testing.T) {
+	defer func(old int) { maxQueuedEvents = old }(maxQueuedEvents)
+	maxQueuedEvents = 8
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxPoolEvent)
+	sub := pool.SubscribeTxPoolEvent(events)
+	defer sub.Unsubscribe()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	for i := uint64(0); i < 64; i++ {
+		if err := pool.AddRemote(transaction(i, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	pool.eventsMu.Lock()
+	queued := len(pool.events)
+	pool.eventsMu.Unlock()
+
+	if queued > maxQueuedEvents {
+		t.Fatalf("queued event count mismatch: have %d, want at most %d", queued, maxQueuedEvents)
+	}
+}
+
+// Tests that the pool content and status of a single account are reported
+// correctly, including the nonce gap and the replacement prices of queued
+// transactions.
+func TestTransactionPoolAccountStatus(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+	statedb.SetNonce(from, 2)
+	statedb.AddBalance(from, big.NewInt(1000000000))
+	statedb.AddBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	txs := []*types.Transaction{
+		pricedTransaction(2, 100000, big.NewInt(1), key),
+		pricedTransaction(3, 100000, big.NewInt(1), key),
+		pricedTransaction(5, 100000, big.NewInt(100), key),
+		pricedTransaction(7, 100000, big.NewInt(1), key),
+		pricedTransaction(0, 100000, big.NewInt(1), other),
+	}
+	for i, tx := range txs {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	// Ensure only the transactions of the requested account are returned
+	pending, queued := pool.ContentFrom(from)
+	if len(pending) != 2 || pending[0] != txs[0] || pending[1] != txs[1] {
+		t.Fatalf("pending content mismatch: have %v, want %v", pending, txs[:2])
+	}
+	if len(queued) != 2 || queued[0] != txs[2] || queued[1] != txs[3] {
+		t.Fatalf("queued content mismatch: have %v, want %v", queued, txs[2:4])
+	}
+	// Ensure the status pinpoints the gap and the required price bumps
+	status := pool.AccountStatus(from)
+	if status.Pending != 2 || status.Queued != 2 {
+		t.Fatalf("account stats mismatch: have %d/%d, want 2/2", status.Pending, status.Queued)
+	}
+	if status.Nonce != 2 {
+		t.Fatalf("state nonce mismatch: have %d, want 2", status.Nonce)
+	}
+	if status.NonceGap == nil || *status.NonceGap != 4 {
+		t.Fatalf("nonce gap mismatch: have %v, want 4", status.NonceGap)
+	}
+	prices := map[uint64]*big.Int{5: big.NewInt(110), 7: big.NewInt(2)}
+	if len(status.ReplacementPrices) != len(prices) {
+		t.Fatalf("replacement price count mismatch: have %d, want %d", len(status.ReplacementPrices), len(prices))
+	}
+	for nonce, price := range prices {
+		if have := status.ReplacementPrices[nonce]; have == nil || have.Cmp(price) != 0 {
+			t.Errorf("replacement price mismatch for nonce %d: have %v, want %v", nonce, have, price)
+		}
+	}
+	// Ensure the reported prices are exactly the ones accepted by the pool
+	if err := pool.AddRemote(pricedTransaction(5, 100000, big.NewInt(109), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("underpriced replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(5, 100000, big.NewInt(110), key)); err != nil {
+		t.Fatalf("failed to replace queued transaction: %v", err)
+	}
+	// Ensure filling the gap clears it from the status
+	if err := pool.AddRemote(pricedTransaction(4, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	if status = pool.AccountStatus(from); status.NonceGap == nil || *status.NonceGap != 6 {
+		t.Fatalf("nonce gap mismatch: have %v, want 6", status.NonceGap)
+	}
+	// Ensure unknown accounts report an empty status
+	status = pool.AccountStatus(common.Address{0x01})
+	if status.Pending != 0 || status.Queued != 0 || status.NonceGap != nil || len(status.ReplacementPrices) != 0 {
+		t.Fatalf("unknown account status mismatch: have %+v", status)
+	}
+}
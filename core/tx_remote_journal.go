@@ -0,0 +1,148 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+// remoteTxJournalVersion is the version of the remote transaction snapshot format,
+// stored as the first item of the snapshot.
+const remoteTxJournalVersion = 1
+
+// remoteTx is a remote transaction stored in the remote transaction journal,
+// along with the last time its sender was seen active in the pool.
+type remoteTx struct {
+	Time uint64 // Unix timestamp of the sender's last heartbeat
+	Tx   *types.Transaction
+}
+
+// remoteTxJournal is a bounded snapshot of the remote transactions of the pool
+// with the aim of allowing them to survive node restarts. Contrary to the local
+// transaction journal, it is not appended to on every insertion, rather written
+// out in full periodically and when the pool is stopped.
+//
+// The snapshot is a version number followed by a stream of RLP encoded entries,
+// so that a truncated snapshot can still be loaded up to the first damaged entry.
+type remoteTxJournal struct {
+	path  string // Filesystem path to store the transactions at
+	limit uint64 // Maximum number of transactions to store
+}
+
+// newRemoteTxJournal creates a new remote transaction journal storing at most
+// limit transactions.
+func newRemoteTxJournal(path string, limit uint64) *remoteTxJournal {
+	return &remoteTxJournal{
+		path:  path,
+		limit: limit,
+	}
+}
+
+// load parses the remote transaction snapshot from disk, discarding the entries
+// older than the given lifetime. If the snapshot is corrupted, all entries before
+// the damaged one are returned along with the error.
+func (journal *remoteTxJournal) load(lifetime time.Duration) ([]*remoteTx, error) {
+	// Skip the parsing if the snapshot file doesn't exist at all
+	input, err := os.Open(journal.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	stream := rlp.NewStream(bufio.NewReader(input), 0)
+
+	version, err := stream.Uint()
+	if err != nil {
+		return nil, err
+	}
+	if version != remoteTxJournalVersion {
+		return nil, fmt.Errorf("unsupported remote journal version %d", version)
+	}
+	var (
+		entries []*remoteTx
+		failure error
+		stale   int
+		cutoff  = time.Now().Add(-lifetime)
+	)
+	for uint64(len(entries)) < journal.limit {
+		entry := new(remoteTx)
+		if err = stream.Decode(entry); err != nil {
+			if err != io.EOF {
+				failure = err
+			}
+			break
+		}
+		if time.Unix(int64(entry.Time), 0).Before(cutoff) {
+			stale++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	log.Info("Loaded remote transaction journal", "transactions", len(entries), "stale", stale)
+
+	return entries, failure
+}
+
+// write replaces the remote transaction snapshot on disk with the given entries,
+// storing at most the configured limit of them. The new snapshot is written to a
+// temporary file first, so a crash while writing leaves the old one intact.
+func (journal *remoteTxJournal) write(entries []*remoteTx) error {
+	if uint64(len(entries)) > journal.limit {
+		log.Debug("Truncating remote transaction journal", "transactions", len(entries), "limit", journal.limit)
+		entries = entries[:journal.limit]
+	}
+	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	output := bufio.NewWriter(replacement)
+	if err = rlp.Encode(output, uint64(remoteTxJournalVersion)); err == nil {
+		for _, entry := range entries {
+			if err = rlp.Encode(output, entry); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = output.Flush()
+	}
+	if err == nil {
+		err = replacement.Sync()
+	}
+	replacement.Close()
+	if err != nil {
+		os.Remove(journal.path + ".new")
+		return err
+	}
+	// Replace the live snapshot with the newly generated one
+	if err = os.Rename(journal.path+".new", journal.path); err != nil {
+		return err
+	}
+	log.Info("Regenerated remote transaction journal", "transactions", len(entries))
+	return nil
+}
@@ -0,0 +1,319 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/event"
+	"github.com/etvchaineum/go-etvchaineum/params"
+)
+
+// setupRemoteJournalPool creates a transaction pool snapshotting its remote
+// transactions into the given journal.
+func setupRemoteJournalPool(journal string, limit uint64, statedb *state.StateDB) *TxPool {
+	config := testTxPoolConfig
+	config.RemoteJournal = journal
+	config.RemoteJournalLimit = limit
+
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+	return NewTxPool(config, params.TestChainConfig, blockchain)
+}
+
+// newRemoteJournalState creates a state with the given accounts funded.
+func newRemoteJournalState(keys ...*ecdsa.PrivateKey) *state.StateDB {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+	for _, key := range keys {
+		statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	return statedb
+}
+
+// Tests that remote transactions are snapshotted when the pool is stopped and
+// restored on restart, while local ones are left to the local journal.
+func TestRemoteTransactionJournaling(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "remote-journal-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "remotes.rlp")
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	statedb := newRemoteJournalState(local, remote)
+
+	pool := setupRemoteJournalPool(journal, 16, statedb)
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	for _, nonce := range []uint64{0, 1, 3} {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), remote)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", nonce, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 3/1", pending, queued)
+	}
+	pool.Stop()
+
+	// Restart the pool and ensure only the remote transactions are restored
+	pool = setupRemoteJournalPool(journal, 16, statedb)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("restored pool stats mismatch: have %d/%d, want 2/1", pending, queued)
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Fatalf("restored remote account marked local")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the remote transaction journal stores at most the configured number
+// of transactions, preferring executable ones.
+func TestRemoteTransactionJournalLimit(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "remote-journal-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "remotes.rlp")
+
+	key, _ := crypto.GenerateKey()
+	statedb := newRemoteJournalState(key)
+
+	pool := setupRemoteJournalPool(journal, 3, statedb)
+	for _, nonce := range []uint64{0, 1, 2, 4} {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), key)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", nonce, err)
+		}
+	}
+	pool.Stop()
+
+	pool = setupRemoteJournalPool(journal, 3, statedb)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 3 || queued != 0 {
+		t.Fatalf("restored pool stats mismatch: have %d/%d, want 3/0", pending, queued)
+	}
+}
+
+// Tests that truncating the remote transaction journal drops the highest nonces
+// of the senders with the most transactions, never leaving nonce gaps.
+func TestRemoteTransactionJournalLimitSenders(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "remote-journal-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "remotes.rlp")
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	statedb := newRemoteJournalState(keys...)
+
+	// Add 4, 2 and 1 executable transactions for the senders, keeping 5 of them
+	pool := setupRemoteJournalPool(journal, 5, statedb)
+	for i, count := range []uint64{4, 2, 1} {
+		for nonce := uint64(0); nonce < count; nonce++ {
+			if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), keys[i])); err != nil {
+				t.Fatalf("failed to add remote transaction %d/%d: %v", i, nonce, err)
+			}
+		}
+	}
+	pool.Stop()
+
+	pool = setupRemoteJournalPool(journal, 5, statedb)
+	defer pool.Stop()
+
+	pending, queued := pool.Content()
+	if len(queued) != 0 {
+		t.Fatalf("restored transactions queued: %v", queued)
+	}
+	for i, want := range []int{2, 2, 1} {
+		if have := len(pending[crypto.PubkeyToAddress(keys[i].PublicKey)]); have != want {
+			t.Errorf("sender %d: restored transaction count mismatch: have %d, want %d", i, have, want)
+		}
+	}
+}
+
+// Tests that journaled remote transactions older than the pool lifetime are not
+// restored, and that the restored ones keep their original heartbeat.
+func TestRemoteTransactionJournalLifetime(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "remote-journal-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "remotes.rlp")
+
+	fresh, _ := crypto.GenerateKey()
+	stale, _ := crypto.GenerateKey()
+	statedb := newRemoteJournalState(fresh, stale)
+
+	var (
+		lifetime = testTxPoolConfig.Lifetime
+		seen     = time.Now().Add(-lifetime / 2).Truncate(time.Second)
+	)
+	entries := []*remoteTx{
+		{Time: uint64(seen.Unix()), Tx: pricedTransaction(0, 100000, big.NewInt(1), fresh)},
+		{Time: uint64(time.Now().Add(-2 * lifetime).Unix()), Tx: pricedTransaction(0, 100000, big.NewInt(1), stale)},
+	}
+	if err := newRemoteTxJournal(journal, 16).write(entries); err != nil {
+		t.Fatalf("failed to write remote journal: %v", err)
+	}
+	pool := setupRemoteJournalPool(journal, 16, statedb)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("restored pool stats mismatch: have %d/%d, want 1/0", pending, queued)
+	}
+	pool.mu.RLock()
+	beat := pool.beats[crypto.PubkeyToAddress(fresh.PublicKey)]
+	pool.mu.RUnlock()
+
+	if !beat.Equal(seen) {
+		t.Fatalf("restored heartbeat mismatch: have %v, want %v", beat, seen)
+	}
+}
+
+// Tests that a crash while writing a remote journal snapshot leaves the previous
+// snapshot intact and loadable.
+func TestRemoteTransactionJournalCrashRecovery(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "remote-journal-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "remotes.rlp")
+
+	key, _ := crypto.GenerateKey()
+	statedb := newRemoteJournalState(key)
+
+	// Snapshot a pool without stopping it before the restart, emulating a crash
+	pool := setupRemoteJournalPool(journal, 16, statedb)
+	defer pool.Stop()
+
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), key)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", nonce, err)
+		}
+	}
+	pool.mu.RLock()
+	remotes := pool.remotes()
+	pool.mu.RUnlock()
+
+	if err := pool.remoteJournal.write(remotes); err != nil {
+		t.Fatalf("failed to write remote journal: %v", err)
+	}
+	// Leave a half written replacement behind, as if crashed during the next snapshot
+	if err := ioutil.WriteFile(journal+".new", []byte{0xf8, 0x6b, 0x01}, 0644); err != nil {
+		t.Fatalf("failed to write partial snapshot: %v", err)
+	}
+	restarted := setupRemoteJournalPool(journal, 16, statedb)
+	defer restarted.Stop()
+
+	if pending, queued := restarted.Stats(); pending != 2 || queued != 0 {
+		t.Fatalf("restored pool stats mismatch: have %d/%d, want 2/0", pending, queued)
+	}
+}
+
+// Tests that corrupted remote journals are loaded up to the first damaged entry,
+// and that unreadable ones don't prevent the pool from starting.
+func TestRemoteTransactionJournalCorruption(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "remote-journal-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	journal := filepath.Join(dir, "remotes.rlp")
+
+	key, _ := crypto.GenerateKey()
+	statedb := newRemoteJournalState(key)
+
+	now := uint64(time.Now().Unix())
+	entries := []*remoteTx{
+		{Time: now, Tx: pricedTransaction(0, 100000, big.NewInt(1), key)},
+		{Time: now, Tx: pricedTransaction(1, 100000, big.NewInt(1), key)},
+		{Time: now, Tx: pricedTransaction(2, 100000, big.NewInt(1), key)},
+	}
+	if err := newRemoteTxJournal(journal, 16).write(entries); err != nil {
+		t.Fatalf("failed to write remote journal: %v", err)
+	}
+	// Chop off the end of the last entry and ensure the preceding ones load
+	blob, err := ioutil.ReadFile(journal)
+	if err != nil {
+		t.Fatalf("failed to read remote journal: %v", err)
+	}
+	if err := ioutil.WriteFile(journal, blob[:len(blob)-10], 0644); err != nil {
+		t.Fatalf("failed to truncate remote journal: %v", err)
+	}
+	loaded, err := newRemoteTxJournal(journal, 16).load(time.Hour)
+	if err == nil {
+		t.Fatalf("truncated journal loaded without error")
+	}
+	if len(loaded) != 2 {
+		t.Fatalf("loaded entry count mismatch: have %d, want 2", len(loaded))
+	}
+	pool := setupRemoteJournalPool(journal, 16, statedb)
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Fatalf("restored pool stats mismatch: have %d/%d, want 2/0", pending, queued)
+	}
+	pool.Stop()
+
+	// Replace the journal with garbage and ensure the pool still starts up
+	if err := ioutil.WriteFile(journal, []byte("definitely not rlp"), 0644); err != nil {
+		t.Fatalf("failed to corrupt remote journal: %v", err)
+	}
+	if _, err := newRemoteTxJournal(journal, 16).load(time.Hour); err == nil {
+		t.Fatalf("garbage journal loaded without error")
+	}
+	statedb = newRemoteJournalState(key)
+	pool = setupRemoteJournalPool(journal, 16, statedb)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want 0/0", pending, queued)
+	}
+}
@@ -18,14 +18,17 @@ package vm
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"math/big"
 
 	"github.com/etvchaineum/go-etvchaineum/common"
 	"github.com/etvchaineum/go-etvchaineum/common/math"
 	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/crypto/blake2b"
 	"github.com/etvchaineum/go-etvchaineum/crypto/bn256"
 	"github.com/etvchaineum/go-etvchaineum/params"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -59,6 +62,45 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
 }
 
+// PrecompiledContractsExtended contains the default set of pre-compiled Etvchain
+// contracts used after the extended precompiles fork.
+var PrecompiledContractsExtended = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):  &ecrecover{},
+	common.BytesToAddress([]byte{2}):  &sha256hash{},
+	common.BytesToAddress([]byte{3}):  &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):  &dataCopy{},
+	common.BytesToAddress([]byte{5}):  &bigModExp{},
+	common.BytesToAddress([]byte{6}):  &bn256Add{},
+	common.BytesToAddress([]byte{7}):  &bn256ScalarMul{},
+	common.BytesToAddress([]byte{8}):  &bn256Pairing{},
+	common.BytesToAddress([]byte{9}):  &blake2F{params.Blake2FRoundGas},
+	common.BytesToAddress([]byte{10}): &ed25519Verify{params.Ed25519VerifyBaseGas, params.Ed25519VerifyPerWordGas},
+}
+
+// ActivePrecompiledContracts returns the set of precompiled contracts active under
+// the given chain rules, priced as the chain configures them.
+func ActivePrecompiledContracts(rules params.Rules) map[common.Address]PrecompiledContract {
+	switch {
+	case rules.IsExtendedPrecompiles:
+		// Chains pricing the contracts at the protocol defaults share the builtin set
+		gas := rules.ExtendedPrecompilesGas
+		if gas == new(params.ChainConfig).ExtendedPrecompilesGas() {
+			return PrecompiledContractsExtended
+		}
+		precompiles := make(map[common.Address]PrecompiledContract, len(PrecompiledContractsExtended))
+		for addr, p := range PrecompiledContractsExtended {
+			precompiles[addr] = p
+		}
+		precompiles[common.BytesToAddress([]byte{9})] = &blake2F{gas.Blake2FRoundGas}
+		precompiles[common.BytesToAddress([]byte{10})] = &ed25519Verify{gas.Ed25519VerifyBaseGas, gas.Ed25519VerifyPerWordGas}
+		return precompiles
+	case rules.IsByzantium:
+		return PrecompiledContractsByzantium
+	default:
+		return PrecompiledContractsHomestead
+	}
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -358,3 +400,116 @@ func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
 	}
 	return false32Byte, nil
 }
+
+const (
+	// blake2FInputLength is the exact length of a blake2 compression input: the
+	// number of rounds, the state vector, the message block, the offset counter
+	// and the final block flag.
+	blake2FInputLength = 4 + 64 + 128 + 16 + 1
+
+	// blake2FFinalBlockBytes and blake2FNonFinalBlockBytes are the two valid
+	// values of the final block flag.
+	blake2FFinalBlockBytes    = byte(1)
+	blake2FNonFinalBlockBytes = byte(0)
+)
+
+var (
+	// errBlake2FInvalidInputLength is returned if the blake2 compression input is
+	// not exactly blake2FInputLength bytes long.
+	errBlake2FInvalidInputLength = errors.New("invalid input length")
+
+	// errBlake2FInvalidFinalFlag is returned if the final block flag is neither 0 nor 1.
+	errBlake2FInvalidFinalFlag = errors.New("invalid final flag")
+)
+
+// blake2F implements the BLAKE2b compression function F as a native contract,
+// with the input and output layout of EIP-152.
+type blake2F struct {
+	roundGas uint64 // Price charged per compression round
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *blake2F) RequiredGas(input []byte) uint64 {
+	// If the input is malformed, we can't calculate the gas, return 0 and let the
+	// actual call choke and fault.
+	if len(input) != blake2FInputLength {
+		return 0
+	}
+	return uint64(binary.BigEndian.Uint32(input[0:4])) * c.roundGas
+}
+
+func (c *blake2F) Run(input []byte) ([]byte, error) {
+	// Make sure the input is valid (correct length and final flag)
+	if len(input) != blake2FInputLength {
+		return nil, errBlake2FInvalidInputLength
+	}
+	if input[212] != blake2FNonFinalBlockBytes && input[212] != blake2FFinalBlockBytes {
+		return nil, errBlake2FInvalidFinalFlag
+	}
+	// Parse the input into the blake2b call parameters
+	var (
+		rounds = binary.BigEndian.Uint32(input[0:4])
+		final  = (input[212] == blake2FFinalBlockBytes)
+
+		h [8]uint64
+		m [16]uint64
+		t [2]uint64
+	)
+	for i := 0; i < 8; i++ {
+		offset := 4 + i*8
+		h[i] = binary.LittleEndian.Uint64(input[offset : offset+8])
+	}
+	for i := 0; i < 16; i++ {
+		offset := 68 + i*8
+		m[i] = binary.LittleEndian.Uint64(input[offset : offset+8])
+	}
+	t[0] = binary.LittleEndian.Uint64(input[196:204])
+	t[1] = binary.LittleEndian.Uint64(input[204:212])
+
+	// Execute the compression function, extract and return the result
+	blake2b.F(&h, m, t, final, rounds)
+
+	output := make([]byte, 64)
+	for i := 0; i < 8; i++ {
+		offset := i * 8
+		binary.LittleEndian.PutUint64(output[offset:offset+8], h[i])
+	}
+	return output, nil
+}
+
+// ed25519VerifyInputLength is the minimum length of an ed25519 verification input,
+// consisting of the public key and the signature, followed by the signed message.
+const ed25519VerifyInputLength = ed25519.PublicKeySize + ed25519.SignatureSize
+
+// errEd25519InvalidInputLength is returned if the ed25519 verification input is
+// too short to contain a public key and a signature.
+var errEd25519InvalidInputLength = errors.New("invalid input length")
+
+// ed25519Verify implements an ed25519 signature verifier as a native contract.
+type ed25519Verify struct {
+	baseGas uint64 // Price charged for every verification
+	wordGas uint64 // Price charged per word of input
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+//
+// This mechod does not require any overflow checking as the input size gas costs
+// required for anything significant is so high it's impossible to pay for.
+func (c *ed25519Verify) RequiredGas(input []byte) uint64 {
+	return uint64(len(input)+31)/32*c.wordGas + c.baseGas
+}
+
+func (c *ed25519Verify) Run(input []byte) ([]byte, error) {
+	if len(input) < ed25519VerifyInputLength {
+		return nil, errEd25519InvalidInputLength
+	}
+	var (
+		pubkey = ed25519.PublicKey(input[:ed25519.PublicKeySize])
+		sig    = input[ed25519.PublicKeySize:ed25519VerifyInputLength]
+		msg    = input[ed25519VerifyInputLength:]
+	)
+	if ed25519.Verify(pubkey, msg, sig) {
+		return true32Byte, nil
+	}
+	return false32Byte, nil
+}
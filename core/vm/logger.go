@@ -18,9 +18,11 @@ package vm
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/big"
+	"sort"
 	"time"
 
 	"github.com/etvchaineum/go-etvchaineum/common"
@@ -97,11 +99,64 @@ func (s *StructLog) ErrorString() string {
 // current VM state.
 // Note that reference types are actual VM data structures; make copies
 // if you need to retain them beyond the current call.
+//
+// CaptureStart and CaptureEnd bracket the top level call frame, whereas every
+// nested frame, including calls into precompiled contracts and frames failing
+// before executing any code, is bracketed by CaptureEnter and CaptureExit. The
+// type of a nested frame is one of CALL, CALLCODE, DELEGATECALL, STATICCALL,
+// CREATE and CREATE2; the value is nil for frames not transferring any.
 type Tracer interface {
-	CaptureStart(from common.Address, to common.Address, call bool, input []byte, gas uint64, value *big.Int) error
+	CaptureStart(env *EVM, from common.Address, to common.Address, call bool, input []byte, gas uint64, value *big.Int) error
 	CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
 	CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error
+	CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error
+	CaptureExit(output []byte, gasUsed uint64, err error) error
+}
+
+// NativeTracer is a Tracer implemented in Go, whose result can be retrieved as
+// JSON once the traced execution finished.
+type NativeTracer interface {
+	Tracer
+	GetResult() (json.RawMessage, error)
+}
+
+// TraceContext contains the information about a traced message which is not
+// observable through the Tracer hooks.
+type TraceContext struct {
+	// GasLimit is the gas purchased by the sender of a transaction before its
+	// execution started, or zero if not tracing a transaction (e.g. a plain EVM
+	// run, in which no gas was purchased and no nonce increased up front).
+	GasLimit uint64
+}
+
+// nativeTracers contains the constructors of the built-in native tracers, keyed
+// by the name they are selectable by.
+var nativeTracers = map[string]func(ctx *TraceContext) NativeTracer{
+	"callTracer":       func(*TraceContext) NativeTracer { return NewCallTracer() },
+	"prestateTracer":   func(ctx *TraceContext) NativeTracer { return NewPrestateTracer(ctx) },
+	"4byteTracer":      func(*TraceContext) NativeTracer { return NewFourByteTracer() },
+	"opcountTracer":    func(*TraceContext) NativeTracer { return NewOpcodeCountTracer() },
+	"accessListTracer": func(*TraceContext) NativeTracer { return NewAccessListTracer() },
+}
+
+// NewNativeTracer creates the built-in native tracer with the given name.
+func NewNativeTracer(name string, ctx *TraceContext) (NativeTracer, error) {
+	constructor, ok := nativeTracers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown native tracer %q", name)
+	}
+	return constructor(ctx), nil
+}
+
+// NativeTracers returns the names of all built-in native tracers, sorted.
+func NativeTracers() []string {
+	names := make([]string, 0, len(nativeTracers))
+	for name := range nativeTracers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // StructLogger is an EVM state logger and implements Tracer.
@@ -130,7 +185,7 @@ func NewStructLogger(cfg *LogConfig) *StructLogger {
 }
 
 // CaptureStart implements the Tracer interface to initialize the tracing operation.
-func (l *StructLogger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+func (l *StructLogger) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
 	return nil
 }
 
@@ -190,6 +245,17 @@ func (l *StructLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost ui
 	return nil
 }
 
+// CaptureEnter implements the Tracer interface, ignoring nested call frames
+// as the struct logs already carry the depth of every step.
+func (l *StructLogger) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, ignoring nested call frames.
+func (l *StructLogger) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
 // CaptureEnd is called after the call finishes to finalize the tracing.
 func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
 	l.output = output
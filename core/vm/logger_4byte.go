@@ -0,0 +1,98 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+)
+
+// FourByteTracer is a native EVM tracer counting the 4 byte function selectors
+// called during an execution, keyed by the selector and the size of the call
+// data following it (e.g. "0xa9059cbb-64"). Contract creations and calls into
+// precompiled contracts are not counted.
+type FourByteTracer struct {
+	env *EVM
+	ids map[string]int
+}
+
+// NewFourByteTracer returns a new 4 byte selector tracer.
+func NewFourByteTracer() *FourByteTracer {
+	return &FourByteTracer{
+		ids: make(map[string]int),
+	}
+}
+
+// store counts a call with the given input, if it carries a selector.
+func (t *FourByteTracer) store(to common.Address, input []byte) {
+	if len(input) < 4 || t.env.precompiles[to] != nil {
+		return
+	}
+	t.ids[fmt.Sprintf("0x%x-%d", input[:4], len(input)-4)]++
+}
+
+// CaptureStart implements the Tracer interface to count the top level call.
+func (t *FourByteTracer) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.env = env
+	if !create {
+		t.store(to, input)
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface, ignoring execution steps.
+func (t *FourByteTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, ignoring faults.
+func (t *FourByteTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface to count the nested calls.
+func (t *FourByteTracer) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if typ != CREATE && typ != CREATE2 {
+		t.store(to, input)
+	}
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, ignoring nested call exits.
+func (t *FourByteTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, ignoring the end of the execution.
+func (t *FourByteTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	return nil
+}
+
+// Result returns the number of calls per selector and call data size.
+func (t *FourByteTracer) Result() map[string]int {
+	return t.ids
+}
+
+// GetResult implements the NativeTracer interface, returning the selector
+// counts as JSON.
+func (t *FourByteTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.ids)
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+)
+
+// AccessTuple is an account accessed during an execution, along with the storage
+// slots accessed in it.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// AccessList is the set of accounts and storage slots accessed during an execution,
+// sorted by address and storage key.
+type AccessList []AccessTuple
+
+// AccessListTracer is a native EVM tracer collecting the accounts and storage slots
+// accessed during an execution. The sender, the recipient and the precompiled
+// contracts are always accessed, so they are only listed if any of their storage
+// slots were accessed.
+type AccessListTracer struct {
+	excl     map[common.Address]bool
+	accessed map[common.Address]map[common.Hash]struct{}
+}
+
+// NewAccessListTracer returns a new access list tracer.
+func NewAccessListTracer() *AccessListTracer {
+	return &AccessListTracer{
+		excl:     make(map[common.Address]bool),
+		accessed: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// addAddress records an accessed account, unless it's excluded from the list.
+func (t *AccessListTracer) addAddress(addr common.Address) {
+	if t.excl[addr] {
+		return
+	}
+	if _, ok := t.accessed[addr]; !ok {
+		t.accessed[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+// addSlot records an accessed storage slot along with its account.
+func (t *AccessListTracer) addSlot(addr common.Address, key common.Hash) {
+	slots, ok := t.accessed[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		t.accessed[addr] = slots
+	}
+	slots[key] = struct{}{}
+}
+
+// CaptureStart implements the Tracer interface to exclude the accounts accessed
+// regardless of the executed code.
+func (t *AccessListTracer) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.excl[from] = true
+	t.excl[to] = true
+	for addr := range env.precompiles {
+		t.excl[addr] = true
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to record the accounts and storage
+// slots accessed by the executed opcodes.
+func (t *AccessListTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	switch {
+	case (op == SLOAD || op == SSTORE) && stack.len() >= 1:
+		t.addSlot(contract.Address(), common.BigToHash(stack.Back(0)))
+
+	case (op == BALANCE || op == EXTCODESIZE || op == EXTCODECOPY || op == EXTCODEHASH || op == SELFDESTRUCT) && stack.len() >= 1:
+		t.addAddress(common.BigToAddress(stack.Back(0)))
+
+	case (op == CALL || op == CALLCODE || op == DELEGATECALL || op == STATICCALL) && stack.len() >= 2:
+		t.addAddress(common.BigToAddress(stack.Back(1)))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, ignoring faults.
+func (t *AccessListTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface, ignoring nested calls as their
+// recipients are recorded by the calling opcodes.
+func (t *AccessListTracer) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, ignoring nested call exits.
+func (t *AccessListTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, ignoring the end of the execution.
+func (t *AccessListTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	return nil
+}
+
+// AccessList returns the accounts and storage slots accessed during the execution.
+func (t *AccessListTracer) AccessList() AccessList {
+	list := make(AccessList, 0, len(t.accessed))
+	for addr, slots := range t.accessed {
+		tuple := AccessTuple{
+			Address:     addr,
+			StorageKeys: make([]common.Hash, 0, len(slots)),
+		}
+		for key := range slots {
+			tuple.StorageKeys = append(tuple.StorageKeys, key)
+		}
+		sort.Slice(tuple.StorageKeys, func(i, j int) bool {
+			return bytes.Compare(tuple.StorageKeys[i][:], tuple.StorageKeys[j][:]) < 0
+		})
+		list = append(list, tuple)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].Address[:], list[j].Address[:]) < 0
+	})
+	return list
+}
+
+// GetResult implements the NativeTracer interface, returning the access list
+// as JSON.
+func (t *AccessListTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.AccessList())
+}
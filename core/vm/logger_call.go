@@ -0,0 +1,152 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+)
+
+// CallFrame is a single call frame in the call tree collected by a CallTracer.
+type CallFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []*CallFrame    `json:"calls,omitempty"`
+}
+
+// newCallFrame creates a call frame entered with the given parameters, copying
+// any reference types owned by the EVM.
+func newCallFrame(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) *CallFrame {
+	frame := &CallFrame{
+		Type:  typ.String(),
+		From:  from,
+		To:    &to,
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if value != nil {
+		frame.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	return frame
+}
+
+// exit finalizes the call frame with the results of its execution.
+func (f *CallFrame) exit(output []byte, gasUsed uint64, err error) {
+	f.GasUsed = hexutil.Uint64(gasUsed)
+	if err == nil {
+		f.Output = common.CopyBytes(output)
+		return
+	}
+	f.Error = err.Error()
+	if err == errExecutionReverted {
+		f.Output = common.CopyBytes(output)
+	}
+	// Failed creations don't leave any contract behind
+	if f.Type == CREATE.String() || f.Type == CREATE2.String() {
+		f.To = nil
+	}
+}
+
+// CallTracer is a native EVM tracer collecting the tree of call frames executed
+// by a transaction, without recording any of the individual execution steps.
+type CallTracer struct {
+	callstack []*CallFrame
+}
+
+// NewCallTracer returns a new call tree tracer.
+func NewCallTracer() *CallTracer {
+	return new(CallTracer)
+}
+
+// CaptureStart implements the Tracer interface to open the top level call frame.
+func (t *CallTracer) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := CALL
+	if create {
+		typ = CREATE
+	}
+	t.callstack = []*CallFrame{newCallFrame(typ, from, to, input, gas, value)}
+	return nil
+}
+
+// CaptureState implements the Tracer interface, ignoring execution steps.
+func (t *CallTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, ignoring execution steps.
+func (t *CallTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface to open a nested call frame.
+func (t *CallTracer) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if len(t.callstack) == 0 {
+		return errors.New("call frame entered before the top level call")
+	}
+	t.callstack = append(t.callstack, newCallFrame(typ, from, to, input, gas, value))
+	return nil
+}
+
+// CaptureExit implements the Tracer interface to close the innermost nested call
+// frame, attaching it to its parent.
+func (t *CallTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	if len(t.callstack) < 2 {
+		return errors.New("call frame exited without being entered")
+	}
+	frame := t.callstack[len(t.callstack)-1]
+	frame.exit(output, gasUsed, err)
+
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, frame)
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface to close the top level call frame.
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	if len(t.callstack) != 1 {
+		return errors.New("top level call frame closed with nested frames open")
+	}
+	t.callstack[0].exit(output, gasUsed, err)
+	return nil
+}
+
+// Result returns the top level call frame along with all its nested frames, or
+// nil if no call was traced.
+func (t *CallTracer) Result() *CallFrame {
+	if len(t.callstack) == 0 {
+		return nil
+	}
+	return t.callstack[0]
+}
+
+// GetResult implements the NativeTracer interface, returning the call tree as JSON.
+func (t *CallTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.Result())
+}
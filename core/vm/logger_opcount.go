@@ -0,0 +1,102 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+)
+
+// OpcodeStats is the number of times an opcode was executed and the total gas
+// charged for executing it.
+type OpcodeStats struct {
+	Count uint64 `json:"count"`
+	Gas   uint64 `json:"gas"`
+}
+
+// OpcodeCountTracer is a native EVM tracer building a histogram of the executed
+// opcodes and the gas spent on each of them, across all call frames.
+type OpcodeCountTracer struct {
+	stats map[OpCode]*OpcodeStats
+}
+
+// NewOpcodeCountTracer returns a new opcode histogram tracer.
+func NewOpcodeCountTracer() *OpcodeCountTracer {
+	return &OpcodeCountTracer{
+		stats: make(map[OpCode]*OpcodeStats),
+	}
+}
+
+// CaptureStart implements the Tracer interface, ignoring the start of the execution.
+func (t *OpcodeCountTracer) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements the Tracer interface to count an executed opcode.
+func (t *OpcodeCountTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	// Steps failing before execution are reported with an error, skip them
+	if err != nil {
+		return nil
+	}
+	stats, ok := t.stats[op]
+	if !ok {
+		stats = new(OpcodeStats)
+		t.stats[op] = stats
+	}
+	stats.Count++
+	stats.Gas += cost
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, ignoring faults.
+func (t *OpcodeCountTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface, ignoring nested call frames.
+func (t *OpcodeCountTracer) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, ignoring nested call frames.
+func (t *OpcodeCountTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, ignoring the end of the execution.
+func (t *OpcodeCountTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	return nil
+}
+
+// Result returns the execution statistics of every executed opcode, keyed by
+// the opcode name.
+func (t *OpcodeCountTracer) Result() map[string]*OpcodeStats {
+	result := make(map[string]*OpcodeStats, len(t.stats))
+	for op, stats := range t.stats {
+		result[op.String()] = stats
+	}
+	return result
+}
+
+// GetResult implements the NativeTracer interface, returning the opcode
+// histogram as JSON.
+func (t *OpcodeCountTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.Result())
+}
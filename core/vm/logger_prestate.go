@@ -0,0 +1,180 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+)
+
+// PrestateAccount is the state of an account before the traced execution, limited
+// to the storage slots accessed during the execution.
+type PrestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// PrestateTracer is a native EVM tracer collecting every account and storage slot
+// touched during an execution, along with their values before the execution.
+type PrestateTracer struct {
+	ctx      TraceContext
+	env      *EVM
+	prestate map[common.Address]*PrestateAccount
+	created  map[common.Address]bool
+}
+
+// NewPrestateTracer returns a new prestate tracer. The trace context is needed to
+// reconstruct the sender's balance and nonce from before the purchase of gas.
+func NewPrestateTracer(ctx *TraceContext) *PrestateTracer {
+	tracer := &PrestateTracer{
+		prestate: make(map[common.Address]*PrestateAccount),
+		created:  make(map[common.Address]bool),
+	}
+	if ctx != nil {
+		tracer.ctx = *ctx
+	}
+	return tracer
+}
+
+// CaptureStart implements the Tracer interface to record the sender and the
+// recipient of the message, reverting the changes done before the execution.
+func (t *PrestateTracer) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.env = env
+
+	// The value was already transferred, the gas purchased and the nonce of the
+	// sender increased for transactions and contract creations
+	t.lookupAccount(from)
+	sender := t.prestate[from]
+
+	balance := new(big.Int).Set(sender.Balance.ToInt())
+	if create || to != from {
+		balance.Add(balance, value)
+	}
+	if t.ctx.GasLimit > 0 {
+		balance.Add(balance, new(big.Int).Mul(env.GasPrice, new(big.Int).SetUint64(t.ctx.GasLimit)))
+	}
+	sender.Balance = (*hexutil.Big)(balance)
+	if (create || t.ctx.GasLimit > 0) && sender.Nonce > 0 {
+		sender.Nonce--
+	}
+	if create {
+		t.created[to] = true
+		return nil
+	}
+	if to != from {
+		t.lookupAccount(to)
+		recipient := t.prestate[to]
+		recipient.Balance = (*hexutil.Big)(new(big.Int).Sub(recipient.Balance.ToInt(), value))
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to record the accounts and storage
+// slots accessed by the executed opcodes.
+func (t *PrestateTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if err != nil {
+		return nil
+	}
+	switch {
+	case (op == SLOAD || op == SSTORE) && stack.len() >= 1:
+		t.lookupStorage(contract.Address(), common.BigToHash(stack.Back(0)))
+
+	case (op == BALANCE || op == EXTCODESIZE || op == EXTCODECOPY || op == EXTCODEHASH || op == SELFDESTRUCT) && stack.len() >= 1:
+		t.lookupAccount(common.BigToAddress(stack.Back(0)))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, ignoring faults.
+func (t *PrestateTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface to record the accounts entered by
+// nested calls and creations before any value is transferred to them.
+func (t *PrestateTracer) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if (typ == CREATE || typ == CREATE2) && !t.env.StateDB.Exist(to) {
+		t.created[to] = true
+	}
+	t.lookupAccount(to)
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, ignoring nested call exits.
+func (t *PrestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, ignoring the end of the execution.
+func (t *PrestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	return nil
+}
+
+// Result returns the pre-execution state of the touched accounts, omitting the
+// ones created during the execution.
+func (t *PrestateTracer) Result() map[common.Address]*PrestateAccount {
+	result := make(map[common.Address]*PrestateAccount, len(t.prestate))
+	for addr, account := range t.prestate {
+		if !t.created[addr] {
+			result[addr] = account
+		}
+	}
+	return result
+}
+
+// GetResult implements the NativeTracer interface, returning the pre-execution
+// state as JSON.
+func (t *PrestateTracer) GetResult() (json.RawMessage, error) {
+	return json.Marshal(t.Result())
+}
+
+// lookupAccount records the current state of an account, unless it was already
+// recorded or created during the execution.
+func (t *PrestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate[addr]; ok || t.created[addr] {
+		return
+	}
+	t.prestate[addr] = &PrestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(t.env.StateDB.GetBalance(addr))),
+		Nonce:   t.env.StateDB.GetNonce(addr),
+		Code:    common.CopyBytes(t.env.StateDB.GetCode(addr)),
+	}
+}
+
+// lookupStorage records the current value of a storage slot, unless it was
+// already recorded or belongs to an account created during the execution.
+func (t *PrestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+
+	account, ok := t.prestate[addr]
+	if !ok {
+		return
+	}
+	if _, ok := account.Storage[key]; ok {
+		return
+	}
+	if account.Storage == nil {
+		account.Storage = make(map[common.Hash]common.Hash)
+	}
+	account.Storage[key] = t.env.StateDB.GetState(addr, key)
+}
@@ -17,12 +17,15 @@
 package runtime
 
 import (
+	"bytes"
 	"math/big"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/etvchaineum/go-etvchaineum/accounts/abi"
 	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
 	"github.com/etvchaineum/go-etvchaineum/core/state"
 	"github.com/etvchaineum/go-etvchaineum/core/vm"
 	"github.com/etvchaineum/go-etvchaineum/echdb"
@@ -117,6 +120,179 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// extendedPrecompilesConfig returns a chain config activating the extended
+// precompiles fork at the given block.
+func extendedPrecompilesConfig(fork int64) *params.ChainConfig {
+	return &params.ChainConfig{
+		ChainID:                  big.NewInt(1),
+		HomesteadBlock:           new(big.Int),
+		EIP150Block:              new(big.Int),
+		EIP155Block:              new(big.Int),
+		EIP158Block:              new(big.Int),
+		ByzantiumBlock:           new(big.Int),
+		ExtendedPrecompilesBlock: big.NewInt(fork),
+	}
+}
+
+// Tests that the extended precompiles are only callable from the fork block
+// configured in the chain config onward.
+func TestExtendedPrecompilesActivation(t *testing.T) {
+	var (
+		blake2F  = common.BytesToAddress([]byte{9})
+		ed25519V = common.BytesToAddress([]byte{10})
+
+		// Final compression of "abc" with 12 rounds, yielding its BLAKE2b-512 digest
+		blake2FInput  = common.Hex2Bytes("0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001")
+		blake2FOutput = common.Hex2Bytes("ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923")
+
+		// RFC 8032 test 1: the empty message signed with the test key
+		ed25519Input = common.Hex2Bytes("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511ae5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b")
+	)
+	for _, number := range []int64{9, 10} {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+		cfg := &Config{
+			ChainConfig: extendedPrecompilesConfig(10),
+			BlockNumber: big.NewInt(number),
+			GasLimit:    100000,
+			State:       statedb,
+		}
+		active := number >= 10
+
+		ret, gas, err := Call(blake2F, blake2FInput, cfg)
+		if err != nil {
+			t.Fatalf("block %d: blake2 compression failed: %v", number, err)
+		}
+		if bytes.Equal(ret, blake2FOutput) != active {
+			t.Errorf("block %d: blake2 compression output mismatch: have %x, active %v", number, ret, active)
+		}
+		if active && gas != cfg.GasLimit-12*params.Blake2FRoundGas {
+			t.Errorf("block %d: blake2 compression gas mismatch: have %d, want %d", number, cfg.GasLimit-gas, 12*params.Blake2FRoundGas)
+		}
+		ret, _, err = Call(ed25519V, ed25519Input, cfg)
+		if err != nil {
+			t.Fatalf("block %d: ed25519 verification failed: %v", number, err)
+		}
+		if valid := len(ret) == 32 && ret[31] == 1; valid != active {
+			t.Errorf("block %d: ed25519 verification mismatch: have %x, active %v", number, ret, active)
+		}
+	}
+}
+
+// Tests that the extended precompiles are charged the prices configured in the
+// chain config, falling back to the protocol defaults for unset ones.
+func TestExtendedPrecompilesPricing(t *testing.T) {
+	var (
+		ed25519V = common.BytesToAddress([]byte{10})
+		input    = make([]byte, 96) // Public key and signature with a 0 byte message
+	)
+	config := extendedPrecompilesConfig(0)
+	config.ExtendedPrecompiles = &params.PrecompilesConfig{Ed25519VerifyBaseGas: 5000}
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+	cfg := &Config{
+		ChainConfig: config,
+		GasLimit:    100000,
+		State:       statedb,
+	}
+	want := 5000 + 3*params.Ed25519VerifyPerWordGas
+	if _, gas, err := Call(ed25519V, input, cfg); err != nil || cfg.GasLimit-gas != want {
+		t.Errorf("repriced call mismatch: gas used %d, want %d, err %v", cfg.GasLimit-gas, want, err)
+	}
+	cfg.GasLimit = want - 1
+	if _, _, err := Call(ed25519V, input, cfg); err != vm.ErrOutOfGas {
+		t.Errorf("underpriced call error mismatch: have %v, want %v", err, vm.ErrOutOfGas)
+	}
+	// The default priced chains keep charging the protocol prices
+	cfg.ChainConfig, cfg.GasLimit = extendedPrecompilesConfig(0), 100000
+	want = params.Ed25519VerifyBaseGas + 3*params.Ed25519VerifyPerWordGas
+	if _, gas, err := Call(ed25519V, input, cfg); err != nil || cfg.GasLimit-gas != want {
+		t.Errorf("default priced call mismatch: gas used %d, want %d, err %v", cfg.GasLimit-gas, want, err)
+	}
+}
+
+// Tests that the call tracer reconstructs the call tree of nested calls into
+// precompiled contracts, immediately reverting contracts and contract creations.
+func TestCallTracer(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+
+	sha256 := common.BytesToAddress([]byte{0x02})
+	reverter := common.HexToAddress("0xbb")
+	statedb.SetCode(reverter, []byte{
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.REVERT),
+	})
+	code := []byte{
+		// Call the sha256 precompile without any input
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0x02, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		// Static and delegate call the reverting contract
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0xbb, byte(vm.GAS), byte(vm.STATICCALL), byte(vm.POP),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0xbb, byte(vm.GAS), byte(vm.DELEGATECALL), byte(vm.POP),
+		// Create a contract with empty init code
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.CREATE), byte(vm.POP),
+		byte(vm.STOP),
+	}
+	tracer := vm.NewCallTracer()
+	cfg := &Config{
+		ChainConfig: extendedPrecompilesConfig(0),
+		GasLimit:    1000000,
+		State:       statedb,
+		EVMConfig:   vm.Config{Debug: true, Tracer: tracer},
+	}
+	if _, _, err := Execute(code, nil, cfg); err != nil {
+		t.Fatalf("failed to execute code: %v", err)
+	}
+	root := tracer.Result()
+	if root == nil {
+		t.Fatalf("no call traced")
+	}
+	if root.Type != "CALL" || *root.To != common.BytesToAddress([]byte("contract")) || root.Error != "" {
+		t.Errorf("top level frame mismatch: type %s, to %x, error %q", root.Type, *root.To, root.Error)
+	}
+	if root.GasUsed == 0 || uint64(root.Gas) != cfg.GasLimit {
+		t.Errorf("top level gas mismatch: gas %d, used %d", root.Gas, root.GasUsed)
+	}
+	want := []struct {
+		typ string
+		to  *common.Address
+		err bool
+	}{
+		{"CALL", &sha256, false},
+		{"STATICCALL", &reverter, true},
+		{"DELEGATECALL", &reverter, true},
+		{"CREATE", nil, false},
+	}
+	if len(root.Calls) != len(want) {
+		t.Fatalf("nested frame count mismatch: have %d, want %d", len(root.Calls), len(want))
+	}
+	for i, frame := range root.Calls {
+		if frame.Type != want[i].typ {
+			t.Errorf("frame %d: type mismatch: have %s, want %s", i, frame.Type, want[i].typ)
+		}
+		if want[i].to != nil && (frame.To == nil || *frame.To != *want[i].to) {
+			t.Errorf("frame %d: recipient mismatch: have %v, want %x", i, frame.To, *want[i].to)
+		}
+		if (frame.Error != "") != want[i].err {
+			t.Errorf("frame %d: error mismatch: have %q, want failure %v", i, frame.Error, want[i].err)
+		}
+		if frame.From != *root.To {
+			t.Errorf("frame %d: sender mismatch: have %x, want %x", i, frame.From, *root.To)
+		}
+		if len(frame.Calls) != 0 {
+			t.Errorf("frame %d: unexpected nested frames: %d", i, len(frame.Calls))
+		}
+	}
+	if root.Calls[0].GasUsed != hexutil.Uint64(params.Sha256BaseGas) {
+		t.Errorf("precompile gas mismatch: have %d, want %d", root.Calls[0].GasUsed, params.Sha256BaseGas)
+	}
+	if root.Calls[1].Value != nil || root.Calls[2].Value != nil {
+		t.Errorf("value reported for frames not transferring any")
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -203,3 +379,44 @@ func BenchmarkEVM_CREATE2_1200(bench *testing.B) {
 	// initcode size 1200K, repeatedly calls CREATE2 and then modifies the mem contents
 	benchmarkEVM_Create(bench, "5b5862124f80600080f5600152600056")
 }
+
+func TestAccessListTracer(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(echdb.NewMemDatabase()))
+
+	callee := common.HexToAddress("0xcc")
+	statedb.SetCode(callee, []byte{
+		byte(vm.PUSH1), 0x07, byte(vm.SLOAD), byte(vm.POP),
+		byte(vm.STOP),
+	})
+	code := []byte{
+		// Read and write storage of the executing contract
+		byte(vm.PUSH1), 0x01, byte(vm.SLOAD), byte(vm.POP),
+		byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x02, byte(vm.SSTORE),
+		// Query the balance of an external account
+		byte(vm.PUSH1), 0xaa, byte(vm.BALANCE), byte(vm.POP),
+		// Call into the sha256 precompile and a contract reading its own storage
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0x02, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0xcc, byte(vm.GAS), byte(vm.STATICCALL), byte(vm.POP),
+		byte(vm.STOP),
+	}
+	tracer := vm.NewAccessListTracer()
+	cfg := &Config{
+		ChainConfig: extendedPrecompilesConfig(0),
+		GasLimit:    1000000,
+		State:       statedb,
+		EVMConfig:   vm.Config{Debug: true, Tracer: tracer},
+	}
+	if _, _, err := Execute(code, nil, cfg); err != nil {
+		t.Fatalf("failed to execute code: %v", err)
+	}
+	want := vm.AccessList{
+		{Address: common.HexToAddress("0xaa"), StorageKeys: []common.Hash{}},
+		{Address: callee, StorageKeys: []common.Hash{common.HexToHash("0x07")}},
+		{Address: common.BytesToAddress([]byte("contract")), StorageKeys: []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}},
+	}
+	if have := tracer.AccessList(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("access list mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+}
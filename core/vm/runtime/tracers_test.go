@@ -0,0 +1,148 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+	"github.com/etvchaineum/go-etvchaineum/common/math"
+	"github.com/etvchaineum/go-etvchaineum/core"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/core/vm"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+// tracerTestContext is the block context a tracer test transaction is executed in.
+type tracerTestContext struct {
+	Number     math.HexOrDecimal64   `json:"number"`
+	Difficulty *math.HexOrDecimal256 `json:"difficulty"`
+	Time       math.HexOrDecimal64   `json:"timestamp"`
+	GasLimit   math.HexOrDecimal64   `json:"gasLimit"`
+	Miner      common.Address        `json:"miner"`
+}
+
+// tracerTest defines a single test to check a native tracer against: a signed
+// transaction executed on top of a genesis state, and the expected trace. The
+// fixtures are transactions deploying and calling the ENS registry and public
+// resolver as mined on a chain, with the state and header of their parent block
+// as the genesis.
+type tracerTest struct {
+	Genesis *core.Genesis      `json:"genesis"`
+	Context *tracerTestContext `json:"context"`
+	Input   hexutil.Bytes      `json:"input"`
+	Tracer  string             `json:"tracer"`
+	Result  json.RawMessage    `json:"result"`
+}
+
+// run executes the transaction of the test with its native tracer attached and
+// returns the produced trace.
+func (test *tracerTest) run() (json.RawMessage, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(test.Input, tx); err != nil {
+		return nil, err
+	}
+	number := new(big.Int).SetUint64(uint64(test.Context.Number))
+
+	msg, err := tx.AsMessage(types.MakeSigner(test.Genesis.Config, number))
+	if err != nil {
+		return nil, err
+	}
+	db := echdb.NewMemDatabase()
+	statedb, _ := state.New(test.Genesis.ToBlock(db).Root(), state.NewDatabase(db))
+
+	tracer, err := vm.NewNativeTracer(test.Tracer, &vm.TraceContext{GasLimit: msg.Gas()})
+	if err != nil {
+		return nil, err
+	}
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      msg.From(),
+		Coinbase:    test.Context.Miner,
+		BlockNumber: number,
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+		GasPrice:    tx.GasPrice(),
+	}
+	evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+	if _, _, _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+		return nil, err
+	}
+	return tracer.GetResult()
+}
+
+// Tests that the native tracers produce the expected traces for the transactions
+// in the test fixtures.
+func TestNativeTracers(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "tracers", "*.json"))
+	if err != nil {
+		t.Fatalf("failed to list tracer tests: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no tracer tests found")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			blob, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read test: %v", err)
+			}
+			test := new(tracerTest)
+			if err := json.Unmarshal(blob, test); err != nil {
+				t.Fatalf("failed to parse test: %v", err)
+			}
+			res, err := test.run()
+			if err != nil {
+				t.Fatalf("failed to trace transaction: %v", err)
+			}
+			var have, want interface{}
+			if err := json.Unmarshal(res, &have); err != nil {
+				t.Fatalf("failed to parse trace: %v", err)
+			}
+			if err := json.Unmarshal(test.Result, &want); err != nil {
+				t.Fatalf("failed to parse expected trace: %v", err)
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("trace mismatch:\nhave %s\nwant %s", res, test.Result)
+			}
+		})
+	}
+}
+
+// Tests that native tracers can be looked up by name.
+func TestNewNativeTracer(t *testing.T) {
+	for _, name := range vm.NativeTracers() {
+		if _, err := vm.NewNativeTracer(name, nil); err != nil {
+			t.Errorf("failed to create tracer %q: %v", name, err)
+		}
+	}
+	if _, err := vm.NewNativeTracer("noopTracer", nil); err == nil {
+		t.Errorf("unknown tracer created")
+	}
+}
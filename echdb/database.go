@@ -121,15 +121,28 @@ func (db *LDBDatabase) Delete(key []byte) error {
 	return db.db.Delete(key, nil)
 }
 
-func (db *LDBDatabase) NewIterator() iterator.Iterator {
+// NewIterator returns an iterator to iterate over the entire database content.
+func (db *LDBDatabase) NewIterator() Iterator {
 	return db.db.NewIterator(nil, nil)
 }
 
+// NewIteratorWithStart returns an iterator to iterate over a subset of database
+// content starting at a particular initial key (or after, if it does not exist).
+func (db *LDBDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return db.db.NewIterator(&util.Range{Start: start}, nil)
+}
+
 // NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
-func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
 	return db.db.NewIterator(util.BytesPrefix(prefix), nil)
 }
 
+// NewReverseIteratorWithPrefix returns an iterator to iterate over a subset of
+// database content with a particular prefix, in descending key order.
+func (db *LDBDatabase) NewReverseIteratorWithPrefix(prefix []byte) Iterator {
+	return &ldbReverseIterator{it: db.db.NewIterator(util.BytesPrefix(prefix), nil)}
+}
+
 func (db *LDBDatabase) Close() {
 	// Stop the metrics collection to avoid internal database races
 	db.quitLock.Lock()
@@ -382,3 +395,57 @@ func (b *ldbBatch) Reset() {
 	b.b.Reset()
 	b.size = 0
 }
+
+// Replay replays the batch contents in insertion order into the writer.
+func (b *ldbBatch) Replay(w Writer) error {
+	r := &ldbReplayer{writer: w}
+	if err := b.b.Replay(r); err != nil {
+		return err
+	}
+	return r.failure
+}
+
+// ldbReplayer is a small wrapper implementing leveldb.BatchReplay on top of a Writer,
+// retaining the first error returned by it.
+type ldbReplayer struct {
+	writer  Writer
+	failure error
+}
+
+// Put inserts the given value into the key-value data store.
+func (r *ldbReplayer) Put(key, value []byte) {
+	// If the replay already failed, stop executing ops
+	if r.failure != nil {
+		return
+	}
+	r.failure = r.writer.Put(key, value)
+}
+
+// Delete removes the key from the key-value data store.
+func (r *ldbReplayer) Delete(key []byte) {
+	// If the replay already failed, stop executing ops
+	if r.failure != nil {
+		return
+	}
+	r.failure = r.writer.Delete(key)
+}
+
+// ldbReverseIterator walks a LevelDB iterator from its last entry backwards.
+type ldbReverseIterator struct {
+	it      iterator.Iterator
+	started bool
+}
+
+// Next moves the iterator to the previous key/value pair.
+func (it *ldbReverseIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		return it.it.Last()
+	}
+	return it.it.Prev()
+}
+
+func (it *ldbReverseIterator) Error() error  { return it.it.Error() }
+func (it *ldbReverseIterator) Key() []byte   { return it.it.Key() }
+func (it *ldbReverseIterator) Value() []byte { return it.it.Value() }
+func (it *ldbReverseIterator) Release()      { it.it.Release() }
@@ -66,3 +66,19 @@ func (db *LDBDatabase) Meter(prefix string) {
 func (db *LDBDatabase) NewBatch() Batch {
 	return nil
 }
+
+func (db *LDBDatabase) NewIterator() Iterator {
+	return nil
+}
+
+func (db *LDBDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return nil
+}
+
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return nil
+}
+
+func (db *LDBDatabase) NewReverseIteratorWithPrefix(prefix []byte) Iterator {
+	return nil
+}
@@ -212,3 +212,100 @@ func testParallelPutGet(db echdb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_Iterator(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIterator(db, t)
+}
+
+func TestMemoryDB_Iterator(t *testing.T) {
+	testIterator(echdb.NewMemDatabase(), t)
+}
+
+func TestTable_Iterator(t *testing.T) {
+	db := echdb.NewMemDatabase()
+
+	// Surround the table with foreign keys to ensure iteration stays inside it
+	db.Put([]byte("s"), []byte("before"))
+	db.Put([]byte("u"), []byte("after"))
+
+	testIterator(echdb.NewTable(db, "t"), t)
+}
+
+func testIterator(db echdb.Database, t *testing.T) {
+	keys := []string{"1", "2", "3", "4", "6", "10", "11", "12", "20", "21", "22"}
+	for _, k := range keys {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	tests := []struct {
+		name string
+		it   echdb.Iterator
+		want []string
+	}{
+		{"all", db.NewIterator(), []string{"1", "10", "11", "12", "2", "20", "21", "22", "3", "4", "6"}},
+		{"start", db.NewIteratorWithStart([]byte("2")), []string{"2", "20", "21", "22", "3", "4", "6"}},
+		{"start missing", db.NewIteratorWithStart([]byte("5")), []string{"6"}},
+		{"start past end", db.NewIteratorWithStart([]byte("7")), nil},
+		{"prefix", db.NewIteratorWithPrefix([]byte("1")), []string{"1", "10", "11", "12"}},
+		{"prefix missing", db.NewIteratorWithPrefix([]byte("5")), nil},
+		{"reverse prefix", db.NewReverseIteratorWithPrefix([]byte("2")), []string{"22", "21", "20", "2"}},
+		{"reverse all", db.NewReverseIteratorWithPrefix(nil), []string{"6", "4", "3", "22", "21", "20", "2", "12", "11", "10", "1"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for tt.it.Next() {
+			if !bytes.Equal(tt.it.Value(), []byte("v"+string(tt.it.Key()))) {
+				t.Errorf("%s: value mismatch for key %q: have %q", tt.name, tt.it.Key(), tt.it.Value())
+			}
+			got = append(got, string(tt.it.Key()))
+		}
+		if err := tt.it.Error(); err != nil {
+			t.Errorf("%s: iteration failed: %v", tt.name, err)
+		}
+		tt.it.Release()
+		tt.it.Release() // releasing twice must be safe
+
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: key mismatch: have %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLDB_BatchReplay(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testBatchReplay(db, t)
+}
+
+func TestMemoryDB_BatchReplay(t *testing.T) {
+	testBatchReplay(echdb.NewMemDatabase(), t)
+}
+
+func TestTable_BatchReplay(t *testing.T) {
+	testBatchReplay(echdb.NewTable(echdb.NewMemDatabase(), "t"), t)
+}
+
+func testBatchReplay(db echdb.Database, t *testing.T) {
+	batch := db.NewBatch()
+	batch.Put([]byte("a"), []byte("1"))
+	batch.Put([]byte("b"), []byte("2"))
+	batch.Delete([]byte("a"))
+
+	target := echdb.NewMemDatabase()
+	target.Put([]byte("a"), []byte("0"))
+	if err := batch.Replay(target); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if has, _ := target.Has([]byte("a")); has {
+		t.Errorf("deleted key present after replay")
+	}
+	if val, err := target.Get([]byte("b")); err != nil || !bytes.Equal(val, []byte("2")) {
+		t.Errorf("replayed key mismatch: have %q, %v; want %q", val, err, "2")
+	}
+	if target.Len() != 1 {
+		t.Errorf("replayed database size mismatch: have %d, want %d", target.Len(), 1)
+	}
+}
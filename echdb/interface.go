@@ -30,10 +30,18 @@ type Deleter interface {
 	Delete(key []byte) error
 }
 
+// Writer wraps the database write operations supported by both batches and
+// regular databases.
+type Writer interface {
+	Putter
+	Deleter
+}
+
 // Database wraps all database operations. All mechods are safe for concurrent use.
 type Database interface {
 	Putter
 	Deleter
+	Iteratee
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
 	Close()
@@ -49,4 +57,61 @@ type Batch interface {
 	Write() error
 	// Reset resets the batch for reuse
 	Reset()
+	// Replay replays the batch contents in insertion order into the writer
+	Replay(w Writer) error
+}
+
+// Iterator iterates over a database's key/value pairs in key order. Forward
+// iterators yield keys in ascending order, reverse iterators in descending order.
+//
+// When it encounters an error any seek will return false and will yield no key/
+// value pairs. The error can be queried by calling Error. Calling Release is
+// still necessary.
+//
+// An iterator must be released after use, but it is not necessary to read an
+// iterator until exhaustion. An iterator is not safe for concurrent use, but it
+// is safe to use multiple iterators concurrently.
+type Iterator interface {
+	// Next moves the iterator to the next key/value pair. It returns false if the
+	// iterator is exhausted.
+	Next() bool
+
+	// Error returns any accumulated error. Exhausting all the key/value pairs
+	// is not considered to be an error.
+	Error() error
+
+	// Key returns the key of the current key/value pair, or nil if done. The caller
+	// should not modify the contents of the returned slice, and its contents may
+	// change on the next call to Next.
+	Key() []byte
+
+	// Value returns the value of the current key/value pair, or nil if done. The
+	// caller should not modify the contents of the returned slice, and its contents
+	// may change on the next call to Next.
+	Value() []byte
+
+	// Release releases associated resources. Release should always succeed and can
+	// be called multiple times without causing error.
+	Release()
+}
+
+// Iteratee wraps the iterator constructors of a backing data store.
+type Iteratee interface {
+	// NewIterator creates a binary-alphabetical iterator over the entire keyspace
+	// contained within the key-value database.
+	NewIterator() Iterator
+
+	// NewIteratorWithStart creates a binary-alphabetical iterator over a subset of
+	// database content starting at a particular initial key (or after, if it does
+	// not exist).
+	NewIteratorWithStart(start []byte) Iterator
+
+	// NewIteratorWithPrefix creates a binary-alphabetical iterator over a subset
+	// of database content with a particular key prefix.
+	NewIteratorWithPrefix(prefix []byte) Iterator
+
+	// NewReverseIteratorWithPrefix creates an iterator over a subset of database
+	// content with a particular key prefix, yielding the keys in descending
+	// binary-alphabetical order.
+	NewReverseIteratorWithPrefix(prefix []byte) Iterator
 }
@@ -18,6 +18,8 @@ package echdb
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/etvchaineum/go-etvchaineum/common"
@@ -96,6 +98,57 @@ func (db *MemDatabase) NewBatch() Batch {
 
 func (db *MemDatabase) Len() int { return len(db.db) }
 
+// NewIterator returns an iterator over the entire database content.
+func (db *MemDatabase) NewIterator() Iterator {
+	return db.newIterator(nil, nil, false)
+}
+
+// NewIteratorWithStart returns an iterator over the database content starting
+// at a particular initial key (or after, if it does not exist).
+func (db *MemDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return db.newIterator(nil, start, false)
+}
+
+// NewIteratorWithPrefix returns an iterator over the database content with a
+// particular key prefix.
+func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return db.newIterator(prefix, nil, false)
+}
+
+// NewReverseIteratorWithPrefix returns an iterator over the database content
+// with a particular key prefix, in descending key order.
+func (db *MemDatabase) NewReverseIteratorWithPrefix(prefix []byte) Iterator {
+	return db.newIterator(prefix, nil, true)
+}
+
+// newIterator snapshots the matching subset of the database into a sorted
+// iterator. Later modifications of the database are not reflected in it.
+func (db *MemDatabase) newIterator(prefix []byte, start []byte, reverse bool) Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var (
+		pr     = string(prefix)
+		st     = string(start)
+		keys   = make([]string, 0, len(db.db))
+		values = make([][]byte, 0, len(db.db))
+	)
+	for key := range db.db {
+		if strings.HasPrefix(key, pr) && key >= st {
+			keys = append(keys, key)
+		}
+	}
+	if reverse {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	} else {
+		sort.Strings(keys)
+	}
+	for _, key := range keys {
+		values = append(values, common.CopyBytes(db.db[key]))
+	}
+	return &memIterator{keys: keys, values: values}
+}
+
 type kv struct {
 	k, v []byte
 	del  bool
@@ -141,3 +194,68 @@ func (b *memBatch) Reset() {
 	b.writes = b.writes[:0]
 	b.size = 0
 }
+
+// Replay replays the batch contents in insertion order into the writer.
+func (b *memBatch) Replay(w Writer) error {
+	for _, kv := range b.writes {
+		if kv.del {
+			if err := w.Delete(kv.k); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.Put(kv.k, kv.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memIterator is an iterator over a snapshot of a memory database's content.
+type memIterator struct {
+	inited bool
+	keys   []string
+	values [][]byte
+}
+
+// Next moves the iterator to the next key/value pair. It returns false if the
+// iterator is exhausted.
+func (it *memIterator) Next() bool {
+	// If the iterator was not yet initialized, do it now
+	if !it.inited {
+		it.inited = true
+		return len(it.keys) > 0
+	}
+	// Iterator already initialized, advance it
+	if len(it.keys) > 0 {
+		it.keys = it.keys[1:]
+		it.values = it.values[1:]
+	}
+	return len(it.keys) > 0
+}
+
+// Error returns any accumulated error. A memory iterator cannot fail.
+func (it *memIterator) Error() error {
+	return nil
+}
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *memIterator) Key() []byte {
+	if len(it.keys) > 0 {
+		return []byte(it.keys[0])
+	}
+	return nil
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *memIterator) Value() []byte {
+	if len(it.values) > 0 {
+		return it.values[0]
+	}
+	return nil
+}
+
+// Release releases the snapshot held by the iterator.
+func (it *memIterator) Release() {
+	it.keys, it.values = nil, nil
+}
@@ -16,6 +16,8 @@
 
 package echdb
 
+import "bytes"
+
 type table struct {
 	db     Database
 	prefix string
@@ -49,3 +51,73 @@ func (dt *table) Delete(key []byte) error {
 func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
+
+func (dt *table) NewIterator() Iterator {
+	return dt.NewIteratorWithPrefix(nil)
+}
+
+func (dt *table) NewIteratorWithStart(start []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithStart(append([]byte(dt.prefix), start...)),
+		prefix: []byte(dt.prefix),
+	}
+}
+
+func (dt *table) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...)),
+		prefix: []byte(dt.prefix),
+	}
+}
+
+func (dt *table) NewReverseIteratorWithPrefix(prefix []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewReverseIteratorWithPrefix(append([]byte(dt.prefix), prefix...)),
+		prefix: []byte(dt.prefix),
+	}
+}
+
+// tableIterator wraps an iterator of the underlying database, stripping the
+// table prefix from the keys and stopping once it leaves the table's keyspace.
+type tableIterator struct {
+	it     Iterator
+	prefix []byte
+	done   bool
+}
+
+func (ti *tableIterator) Next() bool {
+	if ti.done {
+		return false
+	}
+	if !ti.it.Next() || !bytes.HasPrefix(ti.it.Key(), ti.prefix) {
+		ti.done = true
+		return false
+	}
+	return true
+}
+
+func (ti *tableIterator) Error() error {
+	return ti.it.Error()
+}
+
+func (ti *tableIterator) Key() []byte {
+	if ti.done {
+		return nil
+	}
+	key := ti.it.Key()
+	if key == nil {
+		return nil
+	}
+	return key[len(ti.prefix):]
+}
+
+func (ti *tableIterator) Value() []byte {
+	if ti.done {
+		return nil
+	}
+	return ti.it.Value()
+}
+
+func (ti *tableIterator) Release() {
+	ti.it.Release()
+}
@@ -16,6 +16,8 @@
 
 package echdb
 
+import "bytes"
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
@@ -49,3 +51,22 @@ func (tb *tableBatch) ValueSize() int {
 func (tb *tableBatch) Reset() {
 	tb.batch.Reset()
 }
+
+func (tb *tableBatch) Replay(w Writer) error {
+	return tb.batch.Replay(&tableReplayer{w: w, prefix: []byte(tb.prefix)})
+}
+
+// tableReplayer is a writer wrapper that strips the table prefix from the keys
+// of a replayed batch before forwarding them.
+type tableReplayer struct {
+	w      Writer
+	prefix []byte
+}
+
+func (r *tableReplayer) Put(key []byte, value []byte) error {
+	return r.w.Put(bytes.TrimPrefix(key, r.prefix), value)
+}
+
+func (r *tableReplayer) Delete(key []byte) error {
+	return r.w.Delete(bytes.TrimPrefix(key, r.prefix))
+}
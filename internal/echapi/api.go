@@ -35,6 +35,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/consensus/echash"
 	"github.com/etvchaineum/go-etvchaineum/core"
 	"github.com/etvchaineum/go-etvchaineum/core/rawdb"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
 	"github.com/etvchaineum/go-etvchaineum/core/types"
 	"github.com/etvchaineum/go-etvchaineum/core/vm"
 	"github.com/etvchaineum/go-etvchaineum/crypto"
@@ -134,13 +135,64 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
-func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
-	pending, queue := s.b.Stats()
-	return map[string]hexutil.Uint{
-		"pending": hexutil.Uint(pending),
-		"queued":  hexutil.Uint(queue),
+// ContentFrom returns the transactions contained within the transaction pool
+// that were sent by the given account.
+func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCTransaction {
+	content := map[string]map[string]*RPCTransaction{
+		"pending": make(map[string]*RPCTransaction),
+		"queued":  make(map[string]*RPCTransaction),
 	}
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	for _, tx := range pending {
+		content["pending"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	for _, tx := range queue {
+		content["queued"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	return content
+}
+
+// RPCTxPoolAccountStatus is the JSON representation of the pool status of a
+// single account.
+type RPCTxPoolAccountStatus struct {
+	Pending           hexutil.Uint            `json:"pending"`
+	Queued            hexutil.Uint            `json:"queued"`
+	Nonce             hexutil.Uint64          `json:"nonce"`
+	NonceGap          *hexutil.Uint64         `json:"nonceGap"`
+	ReplacementPrices map[string]*hexutil.Big `json:"replacementPrices"`
+}
+
+// Status returns the number of pending and queued transaction in the pool. If an
+// account is given, the counts are restricted to its transactions, extended with
+// its state nonce, the nonce gap blocking the promotion of its queued transactions
+// and the minimum gas prices needed to replace them.
+func (s *PublicTxPoolAPI) Status(ctx context.Context, addr *common.Address) (interface{}, error) {
+	if addr == nil {
+		pending, queue := s.b.Stats()
+		return map[string]hexutil.Uint{
+			"pending": hexutil.Uint(pending),
+			"queued":  hexutil.Uint(queue),
+		}, nil
+	}
+	status, err := s.b.TxPoolAccountStatus(ctx, *addr)
+	if err != nil {
+		return nil, err
+	}
+	result := &RPCTxPoolAccountStatus{
+		Pending:           hexutil.Uint(status.Pending),
+		Queued:            hexutil.Uint(status.Queued),
+		Nonce:             hexutil.Uint64(status.Nonce),
+		ReplacementPrices: make(map[string]*hexutil.Big),
+	}
+	if status.NonceGap != nil {
+		gap := hexutil.Uint64(*status.NonceGap)
+		result.NonceGap = &gap
+	}
+	for nonce, price := range status.ReplacementPrices {
+		result.ReplacementPrices[fmt.Sprintf("%d", nonce)] = (*hexutil.Big)(price)
+	}
+	return result, nil
 }
 
 // Inspect retrieves the content of the transaction pool and flattens it into an
@@ -178,6 +230,53 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// RPCTxPoolEvent is the JSON representation of a transaction pool event.
+type RPCTxPoolEvent struct {
+	Type        string          `json:"type"`
+	Reason      string          `json:"reason,omitempty"`
+	ReplacedBy  *common.Hash    `json:"replacedBy,omitempty"`
+	Transaction *RPCTransaction `json:"transaction"`
+}
+
+// Events creates a subscription that is triggered whenever a transaction enters,
+// is promoted or demoted within, or leaves the transaction pool, along with the
+// reason of demotions, replacements and drops.
+func (s *PublicTxPoolAPI) Events(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan core.TxPoolEvent, 256)
+		sub := s.b.SubscribeTxPoolEvent(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				res := &RPCTxPoolEvent{
+					Type:        ev.Type.String(),
+					Reason:      ev.Reason.String(),
+					Transaction: newRPCPendingTransaction(ev.Tx),
+				}
+				if ev.Type == core.TxPoolReplaced {
+					res.ReplacedBy = &ev.ReplacedBy
+				}
+				notifier.Notify(rpcSub.ID, res)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only mechods that can retrieve accounts.
 type PublicAccountAPI struct {
@@ -696,7 +795,7 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, timeout time.Duration) ([]byte, uint64, bool, error) {
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
@@ -737,7 +836,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	defer cancel()
 
 	// Get a new instance of the EVM.
-	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header)
+	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
 		return nil, 0, false, err
 	}
@@ -761,7 +860,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, 5*time.Second)
+	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
 	return (hexutil.Bytes)(result), err
 }
 
@@ -790,7 +889,7 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	executable := func(gas uint64) bool {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, 0)
+		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0)
 		if err != nil || failed {
 			return false
 		}
@@ -814,6 +913,32 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	return hexutil.Uint64(hi), nil
 }
 
+// AccessListResult is the set of accounts and storage slots accessed by a call,
+// along with the amount of gas it used and whetvchain it failed.
+type AccessListResult struct {
+	AccessList vm.AccessList  `json:"accessList"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Failed     bool           `json:"failed"`
+}
+
+// CreateAccessList executes the given transaction on the state for the given
+// block number, like Call, and returns the accounts and storage slots it accessed.
+// The sender, the recipient and the precompiled contracts are only listed if any
+// of their storage slots were accessed.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (*AccessListResult, error) {
+	tracer := vm.NewAccessListTracer()
+
+	_, gas, failed, err := s.doCall(ctx, args, blockNr, vm.Config{Debug: true, Tracer: tracer}, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessListResult{
+		AccessList: tracer.AccessList(),
+		GasUsed:    hexutil.Uint64(gas),
+		Failed:     failed,
+	}, nil
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
@@ -1104,18 +1229,56 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
 
+// txIndexError is returned if a transaction is not found while blocks within the
+// configured lookup window are still being indexed, so the transaction may exist
+// unindexed.
+type txIndexError struct {
+	tail uint64
+}
+
+func (e *txIndexError) Error() string {
+	return fmt.Sprintf("transaction not found: indexing in progress, indexed tail #%d", e.tail)
+}
+
+// txRangeError is returned if a transaction is not found while the lookup window
+// configured on the node doesn't cover the entire chain, so the transaction may
+// exist in an unindexed block below the tail.
+type txRangeError struct {
+	tail uint64
+}
+
+func (e *txRangeError) Error() string {
+	return fmt.Sprintf("transaction not found: unknown or older than indexed tail #%d", e.tail)
+}
+
+// missingTxError returns the error to report for a transaction which could not
+// be found. It is nil only if the lookup index is known to cover all the blocks.
+func (s *PublicTransactionPoolAPI) missingTxError() error {
+	tail := rawdb.ReadTxIndexTail(s.b.ChainDb())
+	if tail == nil {
+		return nil
+	}
+	if s.b.TxIndexInProgress() {
+		return &txIndexError{tail: *tail}
+	}
+	if s.b.TxLookupLimit() > 0 && *tail > 0 {
+		return &txRangeError{tail: *tail}
+	}
+	return nil
+}
+
 // GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return newRPCTransaction(tx, blockHash, blockNumber, index), nil
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return newRPCPendingTransaction(tx), nil
 	}
 	// Transaction unknown, return as such
-	return nil
+	return nil, s.missingTxError()
 }
 
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
@@ -1126,7 +1289,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 	if tx, _, _, _ = rawdb.ReadTransaction(s.b.ChainDb(), hash); tx == nil {
 		if tx = s.b.GetPoolTransaction(hash); tx == nil {
 			// Transaction not found anywhere, abort
-			return nil, nil
+			return nil, s.missingTxError()
 		}
 	}
 	// Serialize to RLP and return
@@ -1137,7 +1300,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash)
 	if tx == nil {
-		return nil, nil
+		return nil, s.missingTxError()
 	}
 	receipts, err := s.b.GetReceipts(ctx, blockHash)
 	if err != nil {
@@ -1507,6 +1670,28 @@ func (api *PublicDebugAPI) SeedHash(ctx context.Context, number uint64) (string,
 	return fmt.Sprintf("0x%x", echash.SeedHash(number)), nil
 }
 
+// AccountRangeMaxResults is the maximum number of accounts returned by a single
+// AccountRange call.
+const AccountRangeMaxResults = 256
+
+// AccountRange enumerates the accounts of the state at the given block, starting
+// at the given hashed account key. At most maxResults accounts are returned (or
+// AccountRangeMaxResults, if lower or maxResults is not positive), along with
+// the key of the next account to continue the enumeration from.
+func (api *PublicDebugAPI) AccountRange(ctx context.Context, blockNr rpc.BlockNumber, start hexutil.Bytes, maxResults int, nocode, nostorage bool) (state.IteratorDump, error) {
+	statedb, _, err := api.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		if err == nil {
+			err = fmt.Errorf("state for block #%d not found", blockNr)
+		}
+		return state.IteratorDump{}, err
+	}
+	if maxResults <= 0 || maxResults > AccountRangeMaxResults {
+		maxResults = AccountRangeMaxResults
+	}
+	return statedb.IteratorDump(nocode, nostorage, start, maxResults), nil
+}
+
 // PrivateDebugAPI is the collection of Etvchain APIs exposed over the private
 // debugging endpoint.
 type PrivateDebugAPI struct {
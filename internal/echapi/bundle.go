@@ -0,0 +1,312 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package echapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/core"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/core/vm"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/params"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+	"github.com/etvchaineum/go-etvchaineum/rpc"
+)
+
+// callBundleTimeout is the maximum time a bundle simulation may take.
+const callBundleTimeout = 5 * time.Second
+
+// CallBundleArgs represents the arguments for simulating a bundle of signed
+// transactions in a new block on top of an existing one.
+type CallBundleArgs struct {
+	Txs         []hexutil.Bytes  `json:"txs"`
+	BlockNumber *rpc.BlockNumber `json:"blockNumber"` // Block to build on, latest if omitted
+	Coinbase    *common.Address  `json:"coinbase"`    // Coinbase of the simulated block, the parent's if omitted
+	Timestamp   *hexutil.Uint64  `json:"timestamp"`   // Timestamp of the simulated block, one past the parent's if omitted
+	GasLimit    *hexutil.Uint64  `json:"gasLimit"`    // Gas limit of the simulated block, the parent's if omitted
+}
+
+// StateDiff is the change of the state caused by a simulated bundle, containing
+// the touched accounts that changed, before and after the simulation. Only the
+// changed storage slots are listed, and the code only if it changed.
+type StateDiff struct {
+	Pre  map[common.Address]*vm.PrestateAccount `json:"pre"`
+	Post map[common.Address]*vm.PrestateAccount `json:"post"`
+}
+
+// CallBundleResult is the outcome of a bundle simulation.
+type CallBundleResult struct {
+	BlockNumber    hexutil.Uint64   `json:"blockNumber"`
+	StateBlockHash common.Hash      `json:"stateBlockHash"`
+	Receipts       []*types.Receipt `json:"receipts"`
+	GasUsed        hexutil.Uint64   `json:"gasUsed"`
+	GasFees        *hexutil.Big     `json:"gasFees"`
+	CoinbaseDiff   *hexutil.Big     `json:"coinbaseDiff"`
+	StateDiff      *StateDiff       `json:"stateDiff"`
+}
+
+// CallBundle applies the given signed transactions in order in a new block on top
+// of the requested one, without broadcasting them or changing the chain. It
+// returns the receipts and logs of the transactions, the gas used, the fees and
+// direct payments received by the coinbase and the resulting state changes.
+func (s *PublicBlockChainAPI) CallBundle(ctx context.Context, args CallBundleArgs) (*CallBundleResult, error) {
+	defer func(start time.Time) { log.Debug("Executing bundle call finished", "runtime", time.Since(start)) }(time.Now())
+
+	if len(args.Txs) == 0 {
+		return nil, errors.New("empty bundle")
+	}
+	txs := make(types.Transactions, len(args.Txs))
+	for i, encoded := range args.Txs {
+		txs[i] = new(types.Transaction)
+		if err := rlp.DecodeBytes(encoded, txs[i]); err != nil {
+			return nil, fmt.Errorf("invalid bundle transaction %d: %v", i, err)
+		}
+	}
+	blockNr := rpc.LatestBlockNumber
+	if args.BlockNumber != nil {
+		blockNr = *args.BlockNumber
+	}
+	statedb, parent, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       new(big.Int).Add(parent.Time, common.Big1),
+		Difficulty: parent.Difficulty,
+		Coinbase:   parent.Coinbase,
+	}
+	if args.Coinbase != nil {
+		header.Coinbase = *args.Coinbase
+	}
+	if args.Timestamp != nil {
+		header.Time = new(big.Int).SetUint64(uint64(*args.Timestamp))
+	}
+	if args.GasLimit != nil {
+		header.GasLimit = uint64(*args.GasLimit)
+	}
+	ctx, cancel := context.WithTimeout(ctx, callBundleTimeout)
+	defer cancel()
+
+	result, err := simulateBundle(ctx, s.b.ChainConfig(), &bundleChainContext{ctx: ctx, b: s.b}, statedb, header, txs)
+	if err != nil {
+		return nil, err
+	}
+	result.StateBlockHash = parent.Hash()
+	return result, nil
+}
+
+// simulateBundle applies a list of transactions to a copy of the given state in
+// a block with the given header, collecting the outcome of the simulation.
+func simulateBundle(ctx context.Context, config *params.ChainConfig, chain core.ChainContext, statedb *state.StateDB, header *types.Header, txs types.Transactions) (*CallBundleResult, error) {
+	var (
+		pre     = statedb.Copy()
+		post    = statedb.Copy()
+		gasPool = new(core.GasPool).AddGas(header.GasLimit)
+		touched = newTouchTracer()
+		fees    = new(big.Int)
+	)
+	header = types.CopyHeader(header)
+	header.GasUsed = 0
+
+	result := &CallBundleResult{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Receipts:    make([]*types.Receipt, 0, len(txs)),
+	}
+	touched.touch(header.Coinbase)
+	signer := types.MakeSigner(config, header.Number)
+	for i, tx := range txs {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("bundle simulation aborted at transaction %d: %v", i, err)
+		}
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, fmt.Errorf("bundle transaction %d failed: %v", i, err)
+		}
+		post.Prepare(tx.Hash(), common.Hash{}, i)
+		evm := vm.NewEVM(core.NewEVMContext(msg, header, chain, &header.Coinbase), post, config, vm.Config{Debug: true, Tracer: touched})
+
+		// Abort the execution of the transaction if the simulation times out
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		receipt, _, err := core.ApplyTransactionWithEVM(msg, config, gasPool, post, header, tx, &header.GasUsed, evm)
+		close(done)
+
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("bundle simulation aborted at transaction %d: %v", i, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle transaction %d failed: %v", i, err)
+		}
+		result.Receipts = append(result.Receipts, receipt)
+		fees.Add(fees, new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice()))
+	}
+	if err := post.Error(); err != nil {
+		return nil, err
+	}
+	result.GasUsed = hexutil.Uint64(header.GasUsed)
+	result.GasFees = (*hexutil.Big)(fees)
+	result.CoinbaseDiff = (*hexutil.Big)(new(big.Int).Sub(post.GetBalance(header.Coinbase), pre.GetBalance(header.Coinbase)))
+	result.StateDiff = touched.diff(pre, post)
+
+	return result, nil
+}
+
+// bundleChainContext adapts a backend to the chain context needed to apply the
+// bundle transactions. The author of the simulated block is always explicit, so
+// the consensus engine is never consulted.
+type bundleChainContext struct {
+	ctx context.Context
+	b   Backend
+}
+
+// Engine implements core.ChainContext, returning no consensus engine.
+func (c *bundleChainContext) Engine() consensus.Engine { return nil }
+
+// GetHeader implements core.ChainContext, retrieving a canonical header.
+func (c *bundleChainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header, err := c.b.HeaderByNumber(c.ctx, rpc.BlockNumber(number))
+	if err != nil || header == nil || header.Hash() != hash {
+		return nil
+	}
+	return header
+}
+
+// touchTracer is an EVM tracer collecting the accounts and storage slots which
+// may have been modified by an execution.
+type touchTracer struct {
+	accounts map[common.Address]map[common.Hash]struct{}
+}
+
+// newTouchTracer creates a tracer without any touched accounts.
+func newTouchTracer() *touchTracer {
+	return &touchTracer{accounts: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+// touch records a potentially modified account.
+func (t *touchTracer) touch(addr common.Address) map[common.Hash]struct{} {
+	slots, ok := t.accounts[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		t.accounts[addr] = slots
+	}
+	return slots
+}
+
+// CaptureStart implements the Tracer interface to record the sender and the
+// recipient of the message.
+func (t *touchTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.touch(from)
+	t.touch(to)
+	return nil
+}
+
+// CaptureState implements the Tracer interface to record the storage slots
+// written and the accounts destructed or credited by a self-destruct.
+func (t *touchTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil || len(stack.Data()) < 1 {
+		return nil
+	}
+	switch op {
+	case vm.SSTORE:
+		t.touch(contract.Address())[common.BigToHash(stack.Back(0))] = struct{}{}
+	case vm.SELFDESTRUCT:
+		t.touch(contract.Address())
+		t.touch(common.BigToAddress(stack.Back(0)))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, ignoring faults.
+func (t *touchTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface to record the accounts taking
+// part in nested calls and creations.
+func (t *touchTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	t.touch(from)
+	t.touch(to)
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, ignoring nested call exits.
+func (t *touchTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, ignoring the end of the execution.
+func (t *touchTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) error {
+	return nil
+}
+
+// diff compares the touched accounts between two states, returning the ones that
+// changed along with their changed storage slots.
+func (t *touchTracer) diff(pre, post *state.StateDB) *StateDiff {
+	diff := &StateDiff{
+		Pre:  make(map[common.Address]*vm.PrestateAccount),
+		Post: make(map[common.Address]*vm.PrestateAccount),
+	}
+	for addr, slots := range t.accounts {
+		before := &vm.PrestateAccount{
+			Balance: (*hexutil.Big)(pre.GetBalance(addr)),
+			Nonce:   pre.GetNonce(addr),
+		}
+		after := &vm.PrestateAccount{
+			Balance: (*hexutil.Big)(post.GetBalance(addr)),
+			Nonce:   post.GetNonce(addr),
+		}
+		changed := before.Balance.ToInt().Cmp(after.Balance.ToInt()) != 0 || before.Nonce != after.Nonce
+
+		if code := post.GetCode(addr); !bytes.Equal(pre.GetCode(addr), code) {
+			before.Code, after.Code = pre.GetCode(addr), code
+			changed = true
+		}
+		for key := range slots {
+			if from, to := pre.GetState(addr, key), post.GetState(addr, key); from != to {
+				if before.Storage == nil {
+					before.Storage = make(map[common.Hash]common.Hash)
+					after.Storage = make(map[common.Hash]common.Hash)
+				}
+				before.Storage[key], after.Storage[key] = from, to
+				changed = true
+			}
+		}
+		if changed {
+			diff.Pre[addr], diff.Post[addr] = before, after
+		}
+	}
+	return diff
+}
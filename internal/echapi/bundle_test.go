@@ -0,0 +1,207 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package echapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/consensus"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/echdb"
+	"github.com/etvchaineum/go-etvchaineum/params"
+)
+
+var (
+	bundleKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bundleSender   = crypto.PubkeyToAddress(bundleKey.PublicKey)
+	bundleMiner    = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	bundleStorer   = common.HexToAddress("0x00000000000000000000000000000000000000aa") // Stores 1 in slot 0
+	bundleReverter = common.HexToAddress("0x00000000000000000000000000000000000000bb") // Always reverts
+	bundleLooper   = common.HexToAddress("0x00000000000000000000000000000000000000cc") // Loops forever
+)
+
+// testChainContext is a chain context without any headers, the simulated
+// bundles never look up ancestors.
+type testChainContext struct{}
+
+func (testChainContext) Engine() consensus.Engine                                { return nil }
+func (testChainContext) GetHeader(hash common.Hash, number uint64) *types.Header { return nil }
+
+// newBundleState creates a state with a funded sender and the test contracts.
+func newBundleState(t *testing.T) *state.StateDB {
+	db := state.NewDatabase(echdb.NewMemDatabase())
+	statedb, _ := state.New(common.Hash{}, db)
+
+	statedb.AddBalance(bundleSender, big.NewInt(1000000000000000000))
+	statedb.SetCode(bundleStorer, common.FromHex("0x6001600055"))   // PUSH1 1 PUSH1 0 SSTORE
+	statedb.SetCode(bundleReverter, common.FromHex("0x60006000fd")) // PUSH1 0 PUSH1 0 REVERT
+	statedb.SetCode(bundleLooper, common.FromHex("0x5b600056"))     // JUMPDEST PUSH1 0 JUMP
+
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	statedb, _ = state.New(root, db)
+	return statedb
+}
+
+// bundleTx creates a signed transaction of the bundle sender.
+func bundleTx(key *ecdsa.PrivateKey, nonce uint64, to common.Address, value int64, gas uint64) *types.Transaction {
+	tx := types.NewTransaction(nonce, to, big.NewInt(value), gas, big.NewInt(1), nil)
+	tx, _ = types.SignTx(tx, types.NewEIP155Signer(params.TestChainConfig.ChainID), key)
+	return tx
+}
+
+func bundleHeader(gasLimit uint64) *types.Header {
+	return &types.Header{
+		Number:     big.NewInt(1),
+		GasLimit:   gasLimit,
+		Time:       big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		Coinbase:   bundleMiner,
+	}
+}
+
+// Tests that a bundle is applied in order, reporting the receipts, gas, fees and
+// the changes of the state, without modifying the state it's simulated on.
+func TestCallBundle(t *testing.T) {
+	statedb := newBundleState(t)
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000dd")
+	txs := types.Transactions{
+		bundleTx(bundleKey, 0, recipient, 1000, 21000),
+		bundleTx(bundleKey, 1, bundleStorer, 0, 100000),
+	}
+	result, err := simulateBundle(context.Background(), params.TestChainConfig, testChainContext{}, statedb, bundleHeader(8000000), txs)
+	if err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	if len(result.Receipts) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(result.Receipts))
+	}
+	for i, receipt := range result.Receipts {
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			t.Errorf("receipt %d: status mismatch: have %d, want %d", i, receipt.Status, types.ReceiptStatusSuccessful)
+		}
+		if receipt.TxHash != txs[i].Hash() {
+			t.Errorf("receipt %d: hash mismatch: have %x, want %x", i, receipt.TxHash, txs[i].Hash())
+		}
+	}
+	gasUsed := result.Receipts[0].GasUsed + result.Receipts[1].GasUsed
+	if uint64(result.GasUsed) != gasUsed {
+		t.Errorf("gas used mismatch: have %d, want %d", result.GasUsed, gasUsed)
+	}
+	if fees := result.GasFees.ToInt(); fees.Uint64() != gasUsed {
+		t.Errorf("gas fees mismatch: have %v, want %d", fees, gasUsed)
+	}
+	if diff := result.CoinbaseDiff.ToInt(); diff.Uint64() != gasUsed {
+		t.Errorf("coinbase diff mismatch: have %v, want %d", diff, gasUsed)
+	}
+	// The simulation must not leak into the state it was run on
+	if nonce := statedb.GetNonce(bundleSender); nonce != 0 {
+		t.Errorf("simulated on state modified: sender nonce %d", nonce)
+	}
+}
+
+// Tests that the state diff lists exactly the changed accounts and storage slots.
+func TestCallBundleStateDiff(t *testing.T) {
+	statedb := newBundleState(t)
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000dd")
+	txs := types.Transactions{
+		bundleTx(bundleKey, 0, recipient, 1000, 21000),
+		bundleTx(bundleKey, 1, bundleStorer, 0, 100000),
+	}
+	result, err := simulateBundle(context.Background(), params.TestChainConfig, testChainContext{}, statedb, bundleHeader(8000000), txs)
+	if err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	diff := result.StateDiff
+	if len(diff.Pre) != 4 || len(diff.Post) != 4 {
+		t.Fatalf("changed account count mismatch: have %d/%d, want 4", len(diff.Pre), len(diff.Post))
+	}
+	if pre, post := diff.Pre[bundleSender], diff.Post[bundleSender]; pre.Nonce != 0 || post.Nonce != 2 {
+		t.Errorf("sender nonce mismatch: have %d -> %d, want 0 -> 2", pre.Nonce, post.Nonce)
+	}
+	if pre, post := diff.Pre[recipient], diff.Post[recipient]; pre.Balance.ToInt().Sign() != 0 || post.Balance.ToInt().Int64() != 1000 {
+		t.Errorf("recipient balance mismatch: have %v -> %v, want 0 -> 1000", pre.Balance, post.Balance)
+	}
+	pre, post := diff.Pre[bundleStorer], diff.Post[bundleStorer]
+	if pre == nil || post == nil {
+		t.Fatalf("storing contract missing from diff")
+	}
+	if len(post.Storage) != 1 || post.Storage[common.Hash{}] != common.BigToHash(common.Big1) || pre.Storage[common.Hash{}] != (common.Hash{}) {
+		t.Errorf("storage diff mismatch: have %v -> %v", pre.Storage, post.Storage)
+	}
+	if pre.Code != nil || post.Code != nil {
+		t.Errorf("unchanged code reported in diff")
+	}
+	if _, ok := diff.Post[bundleMiner]; !ok {
+		t.Errorf("coinbase missing from diff")
+	}
+}
+
+// Tests that reverting transactions are reported as failed without failing the
+// bundle, while invalid ones abort the simulation.
+func TestCallBundleRevert(t *testing.T) {
+	statedb := newBundleState(t)
+	txs := types.Transactions{
+		bundleTx(bundleKey, 0, bundleReverter, 0, 100000),
+		bundleTx(bundleKey, 1, bundleStorer, 0, 100000),
+	}
+	result, err := simulateBundle(context.Background(), params.TestChainConfig, testChainContext{}, statedb, bundleHeader(8000000), txs)
+	if err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	if status := result.Receipts[0].Status; status != types.ReceiptStatusFailed {
+		t.Errorf("reverted transaction status mismatch: have %d, want %d", status, types.ReceiptStatusFailed)
+	}
+	if status := result.Receipts[1].Status; status != types.ReceiptStatusSuccessful {
+		t.Errorf("following transaction status mismatch: have %d, want %d", status, types.ReceiptStatusSuccessful)
+	}
+	if _, ok := result.StateDiff.Post[bundleReverter]; ok {
+		t.Errorf("reverted contract reported in diff")
+	}
+	// A transaction with a wrong nonce invalidates the whole bundle
+	txs = types.Transactions{bundleTx(bundleKey, 1, bundleStorer, 0, 100000)}
+	if _, err := simulateBundle(context.Background(), params.TestChainConfig, testChainContext{}, statedb, bundleHeader(8000000), txs); err == nil {
+		t.Fatalf("bundle with invalid nonce simulated successfully")
+	}
+}
+
+// Tests that a simulation exceeding its deadline is aborted, even in the middle
+// of a long running transaction.
+func TestCallBundleTimeout(t *testing.T) {
+	statedb := newBundleState(t)
+	txs := types.Transactions{bundleTx(bundleKey, 0, bundleLooper, 0, 500000000)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := simulateBundle(ctx, params.TestChainConfig, testChainContext{}, statedb, bundleHeader(500000000), txs); err == nil {
+		t.Fatalf("timed out bundle simulated successfully")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("simulation not aborted in time: took %v", elapsed)
+	}
+}
@@ -105,10 +105,10 @@ func (b *LesApiBackend) GetTd(hash common.Hash) *big.Int {
 	return b.ech.blockchain.GetTdByHash(hash)
 }
 
-func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
 	state.SetBalance(msg.From(), math.MaxBig256)
 	context := core.NewEVMContext(msg, header, b.ech.blockchain, nil)
-	return vm.NewEVM(context, state, b.ech.chainConfig, vm.Config{}), state.Error, nil
+	return vm.NewEVM(context, state, b.ech.chainConfig, vmCfg), state.Error, nil
 }
 
 func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
@@ -139,10 +139,27 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.ech.txPool.Content()
 }
 
+func (b *LesApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.ech.txPool.ContentFrom(addr)
+}
+
+func (b *LesApiBackend) TxPoolAccountStatus(ctx context.Context, addr common.Address) (*core.TxPoolAccountStatus, error) {
+	return b.ech.txPool.AccountStatus(ctx, addr)
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.ech.txPool.SubscribeNewTxsEvent(ch)
 }
 
+// SubscribeTxPoolEvent implements echapi.Backend. The light transaction pool only
+// tracks the locally submitted transactions, so no pool events are ever posted.
+func (b *LesApiBackend) SubscribeTxPoolEvent(ch chan<- core.TxPoolEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.ech.blockchain.SubscribeChainEvent(ch)
 }
@@ -175,6 +192,18 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+// TxIndexInProgress implements echapi.Backend. Light clients don't maintain
+// transaction lookup indices at all.
+func (b *LesApiBackend) TxIndexInProgress() bool {
+	return false
+}
+
+// TxLookupLimit implements echapi.Backend. Light clients don't maintain
+// transaction lookup indices at all.
+func (b *LesApiBackend) TxLookupLimit() uint64 {
+	return 0
+}
+
 func (b *LesApiBackend) ChainDb() echdb.Database {
 	return b.ech.chainDb
 }
@@ -40,7 +40,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/node"
 	"github.com/etvchaineum/go-etvchaineum/p2p"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
+	"github.com/etvchaineum/go-etvchaineum/p2p/discover"
 	"github.com/etvchaineum/go-etvchaineum/params"
 	rpc "github.com/etvchaineum/go-etvchaineum/rpc"
 )
@@ -136,6 +136,9 @@ func New(ctx *node.ServiceContext, config *ech.Config) (*LightEtvchain, error) {
 	}
 
 	lech.txPool = light.NewTxPool(lech.chainConfig, lech.blockchain, lech.relay)
+	for _, policy := range core.TxPoliciesFromConfig(config.TxPool) {
+		lech.txPool.AddPolicy(policy)
+	}
 	if lech.protocolManager, err = NewProtocolManager(lech.chainConfig, light.DefaultClientIndexerConfig, true, config.NetworkId, lech.eventMux, lech.engine, lech.peers, lech.blockchain, nil, chainDb, lech.odr, lech.relay, lech.serverPool, quitSync, &lech.wg); err != nil {
 		return nil, err
 	}
@@ -148,7 +151,7 @@ func New(ctx *node.ServiceContext, config *ech.Config) (*LightEtvchain, error) {
 	return lech, nil
 }
 
-func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
+func lesTopic(genesisHash common.Hash, protocolVersion uint) discover.Topic {
 	var name string
 	switch protocolVersion {
 	case lpv1:
@@ -158,7 +161,7 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 	default:
 		panic(nil)
 	}
-	return discv5.Topic(name + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
+	return discover.Topic(name + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
 }
 
 type LightDummyAPI struct{}
@@ -29,6 +29,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/core/types"
 	"github.com/etvchaineum/go-etvchaineum/light"
 	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/p2p"
 )
 
 const (
@@ -185,6 +186,7 @@ func (f *lightFetcher) syncLoop() {
 			if ok {
 				f.pm.serverPool.adjustResponseTime(req.peer.poolEntry, time.Duration(mclock.Now()-req.sent), true)
 				req.peer.Log().Debug("Fetching data timed out hard")
+				req.peer.Report(p2p.RepTimeout)
 				go f.pm.removePeer(req.peer.id)
 			}
 		case resp := <-f.deliverChn:
@@ -201,8 +203,17 @@ func (f *lightFetcher) syncLoop() {
 				f.pm.serverPool.adjustResponseTime(req.peer.poolEntry, time.Duration(mclock.Now()-req.sent), req.timeout)
 			}
 			f.lock.Lock()
-			if !ok || !(f.syncing || f.processResponse(req, resp)) {
+			switch {
+			case !ok:
 				resp.peer.Log().Debug("Failed processing response")
+				resp.peer.Report(p2p.RepUselessResponse)
+				go f.pm.removePeer(resp.peer.id)
+			case f.syncing:
+			case f.processResponse(req, resp):
+				resp.peer.Report(p2p.RepGoodDelivery)
+			default:
+				resp.peer.Log().Debug("Failed processing response")
+				resp.peer.Report(p2p.RepInvalidBlock)
 				go f.pm.removePeer(resp.peer.id)
 			}
 			f.lock.Unlock()
@@ -261,6 +272,7 @@ func (f *lightFetcher) announce(p *peer, head *announceData) {
 	if fp.lastAnnounced != nil && head.Td.Cmp(fp.lastAnnounced.td) <= 0 {
 		// announced tds should be strictly monotonic
 		p.Log().Debug("Received non-monotonic td", "current", head.Td, "previous", fp.lastAnnounced.td)
+		p.Report(p2p.RepProtocolError)
 		go f.pm.removePeer(p.id)
 		return
 	}
@@ -547,6 +559,7 @@ func (f *lightFetcher) newHeaders(headers []*types.Header, tds []*big.Int) {
 	for p, fp := range f.peers {
 		if !f.checkAnnouncedHeaders(fp, headers, tds) {
 			p.Log().Debug("Inconsistent announcement")
+			p.Report(p2p.RepInvalidBlock)
 			go f.pm.removePeer(p.id)
 		}
 		if fp.confirmedTd != nil && (maxTd == nil || maxTd.Cmp(fp.confirmedTd) > 0) {
@@ -651,6 +664,7 @@ func (f *lightFetcher) checkSyncedHeaders(p *peer) {
 	// now n is the latest downloaded header after syncing
 	if n == nil {
 		p.Log().Debug("Synchronisation failed")
+		p.Report(p2p.RepUselessResponse)
 		go f.pm.removePeer(p.id)
 	} else {
 		header := f.chain.GetHeader(n.hash, n.number)
@@ -682,6 +696,7 @@ func (f *lightFetcher) checkKnownNode(p *peer, n *fetcherTreeNode) bool {
 	}
 	if !f.checkAnnouncedHeaders(fp, []*types.Header{header}, []*big.Int{td}) {
 		p.Log().Debug("Inconsistent announcement")
+		p.Report(p2p.RepInvalidBlock)
 		go f.pm.removePeer(p.id)
 	}
 	if fp.confirmedTd != nil {
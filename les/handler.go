@@ -39,7 +39,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/light"
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/p2p"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
+	"github.com/etvchaineum/go-etvchaineum/p2p/discover"
 	"github.com/etvchaineum/go-etvchaineum/params"
 	"github.com/etvchaineum/go-etvchaineum/rlp"
 	"github.com/etvchaineum/go-etvchaineum/trie"
@@ -101,7 +101,7 @@ type ProtocolManager struct {
 	server      *LesServer
 	serverPool  *serverPool
 	clientPool  *freeClientPool
-	lesTopic    discv5.Topic
+	lesTopic    discover.Topic
 	reqDist     *requestDistributor
 	retriever   *retrieveManager
 
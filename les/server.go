@@ -33,7 +33,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/light"
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/p2p"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
+	"github.com/etvchaineum/go-etvchaineum/p2p/discover"
 	"github.com/etvchaineum/go-etvchaineum/params"
 	"github.com/etvchaineum/go-etvchaineum/rlp"
 )
@@ -44,7 +44,7 @@ type LesServer struct {
 	fcManager   *flowcontrol.ClientManager // nil if our node is client only
 	fcCostStats *requestCostStats
 	defParams   *flowcontrol.ServerParams
-	lesTopics   []discv5.Topic
+	lesTopics   []discover.Topic
 	privateKey  *ecdsa.PrivateKey
 	quitSync    chan struct{}
 }
@@ -56,7 +56,7 @@ func NewLesServer(ech *ech.Etvchain, config *ech.Config) (*LesServer, error) {
 		return nil, err
 	}
 
-	lesTopics := make([]discv5.Topic, len(AdvertiseProtocolVersions))
+	lesTopics := make([]discover.Topic, len(AdvertiseProtocolVersions))
 	for i, pv := range AdvertiseProtocolVersions {
 		lesTopics[i] = lesTopic(ech.BlockChain().Genesis().Hash(), pv)
 	}
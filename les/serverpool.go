@@ -33,7 +33,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/echdb"
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/p2p"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
+	"github.com/etvchaineum/go-etvchaineum/p2p/discover"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
 	"github.com/etvchaineum/go-etvchaineum/rlp"
 )
@@ -120,7 +120,7 @@ type serverPool struct {
 	wg     *sync.WaitGroup
 	connWg sync.WaitGroup
 
-	topic discv5.Topic
+	topic discover.Topic
 
 	discSetPeriod chan time.Duration
 	discNodes     chan *enode.Node
@@ -162,7 +162,7 @@ func newServerPool(db echdb.Database, quit chan struct{}, wg *sync.WaitGroup) *s
 	return pool
 }
 
-func (pool *serverPool) start(server *p2p.Server, topic discv5.Topic) {
+func (pool *serverPool) start(server *p2p.Server, topic discover.Topic) {
 	pool.server = server
 	pool.topic = topic
 	pool.dbKey = append([]byte("serverPool/"), []byte(topic)...)
@@ -179,20 +179,10 @@ func (pool *serverPool) start(server *p2p.Server, topic discv5.Topic) {
 	go pool.eventLoop()
 }
 
-// discoverNodes wraps SearchTopic, converting result nodes to enode.Node.
+// discoverNodes wraps SearchTopic, delivering the advertisers of the topic to
+// the discovered node channel until the search period channel is closed.
 func (pool *serverPool) discoverNodes() {
-	ch := make(chan *discv5.Node)
-	go func() {
-		pool.server.DiscV5.SearchTopic(pool.topic, pool.discSetPeriod, ch, pool.discLookups)
-		close(ch)
-	}()
-	for n := range ch {
-		pubkey, err := decodePubkey64(n.ID[:])
-		if err != nil {
-			continue
-		}
-		pool.discNodes <- enode.NewV4(pubkey, n.IP, int(n.TCP), int(n.UDP))
-	}
+	pool.server.DiscV5.SearchTopic(pool.topic, pool.discSetPeriod, pool.discNodes, pool.discLookups)
 }
 
 // connect should be called upon any incoming connection. If the connection has been
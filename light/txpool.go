@@ -19,6 +19,8 @@ package light
 import (
 	"context"
 	"fmt"
+	"math/big"
+	"sort"
 	"sync"
 	"time"
 
@@ -66,6 +68,7 @@ type TxPool struct {
 	pending      map[common.Hash]*types.Transaction   // pending transactions by tx hash
 	mined        map[common.Hash][]*types.Transaction // mined transactions by block hash
 	clearIdx     uint64                               // earliest block nr that can contain mined tx info
+	policies     []core.TxPolicy                      // admission hooks consulted before accepting a transaction
 
 	homestead bool
 }
@@ -323,6 +326,15 @@ func (pool *TxPool) Stop() {
 	log.Info("Transaction pool stopped")
 }
 
+// AddPolicy appends an admission hook to the chain of policies consulted before
+// accepting a new transaction. Transactions already in the pool are not affected.
+func (pool *TxPool) AddPolicy(policy core.TxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policies = append(pool.policies, policy)
+}
+
 // SubscribeNewTxsEvent registers a subscription of core.NewTxsEvent and
 // starts sending event to the given channel.
 func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
@@ -385,7 +397,12 @@ func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error
 	if tx.Gas() < gas {
 		return core.ErrIntrinsicGas
 	}
-	return currentState.Error()
+	if err := currentState.Error(); err != nil {
+		return err
+	}
+	// Let the admission policies have the last word, all transactions of the
+	// light pool are local ones
+	return core.ValidateTxPolicies(pool.policies, tx, from, true)
 }
 
 // add validates a new transaction and sets its state pending if processable.
@@ -502,6 +519,39 @@ func (self *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the data content of the transaction pool for a single
+// account, returning its pending transactions sorted by nonce. There are no queued
+// transactions in a light pool, so the second return value is always empty.
+func (self *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	var pending types.Transactions
+	for _, tx := range self.pending {
+		if account, _ := types.Sender(self.signer, tx); account == addr {
+			pending = append(pending, tx)
+		}
+	}
+	sort.Sort(types.TxByNonce(pending))
+	return pending, nil
+}
+
+// AccountStatus retrieves the pool status of a single account. As the light pool
+// has no queued transactions, only the pending count and state nonce are filled.
+func (self *TxPool) AccountStatus(ctx context.Context, addr common.Address) (*core.TxPoolAccountStatus, error) {
+	state := self.currentState(ctx)
+	nonce := state.GetNonce(addr)
+	if err := state.Error(); err != nil {
+		return nil, err
+	}
+	pending, _ := self.ContentFrom(addr)
+	return &core.TxPoolAccountStatus{
+		Pending:           len(pending),
+		Nonce:             nonce,
+		ReplacementPrices: make(map[uint64]*big.Int),
+	}, nil
+}
+
 // RemoveTransactions removes all given transactions from the pool.
 func (self *TxPool) RemoveTransactions(txs types.Transactions) {
 	self.mu.Lock()
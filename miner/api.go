@@ -0,0 +1,71 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"fmt"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/common/hexutil"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+// PrivateBundleAPI provides an API to submit transaction bundles to the miner.
+type PrivateBundleAPI struct {
+	miner *Miner
+}
+
+// NewPrivateBundleAPI creates a new bundle submission API for the miner.
+func NewPrivateBundleAPI(miner *Miner) *PrivateBundleAPI {
+	return &PrivateBundleAPI{miner: miner}
+}
+
+// SendBundleArgs represents the arguments to submit a transaction bundle.
+type SendBundleArgs struct {
+	Txs      []hexutil.Bytes `json:"txs"`
+	MinBlock *hexutil.Uint64 `json:"minBlock"`
+	MaxBlock *hexutil.Uint64 `json:"maxBlock"`
+}
+
+// SendBundle submits a group of signed transactions to be included contiguously
+// and atomically in a block within the target range, returning the bundle hash.
+// The range defaults to the next block only. Bundles reverting on top of the
+// pending block are rejected.
+func (api *PrivateBundleAPI) SendBundle(args SendBundleArgs) (common.Hash, error) {
+	bundle := &Bundle{
+		MinBlock: api.miner.ech.BlockChain().CurrentBlock().NumberU64() + 1,
+	}
+	if args.MinBlock != nil {
+		bundle.MinBlock = uint64(*args.MinBlock)
+	}
+	bundle.MaxBlock = bundle.MinBlock
+	if args.MaxBlock != nil {
+		bundle.MaxBlock = uint64(*args.MaxBlock)
+	}
+	for i, encoded := range args.Txs {
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(encoded, tx); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid bundle transaction %d: %v", i, err)
+		}
+		bundle.Txs = append(bundle.Txs, tx)
+	}
+	if err := api.miner.SendBundle(bundle); err != nil {
+		return common.Hash{}, err
+	}
+	return bundle.Hash(), nil
+}
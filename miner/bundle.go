@@ -0,0 +1,268 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core"
+	"github.com/etvchaineum/go-etvchaineum/core/state"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/log"
+)
+
+const (
+	// maxBundles is the maximum number of bundles the worker keeps track of.
+	maxBundles = 256
+
+	// maxBundleRange is the maximum number of blocks a bundle may target.
+	maxBundleRange = 256
+)
+
+var (
+	// errEmptyBundle is returned if a bundle contains no transactions.
+	errEmptyBundle = errors.New("empty bundle")
+
+	// errBundleRange is returned if the target block range of a bundle is invalid.
+	errBundleRange = errors.New("invalid bundle block range")
+
+	// errBundleExpired is returned if the target block range of a bundle is
+	// already in the past.
+	errBundleExpired = errors.New("bundle block range already passed")
+
+	// errTooManyBundles is returned if the worker already tracks the maximum
+	// number of bundles.
+	errTooManyBundles = errors.New("too many bundles")
+
+	// errBundleReverted is returned if a transaction of a bundle reverts.
+	errBundleReverted = errors.New("bundle transaction reverted")
+
+	// errNoPendingBlock is returned if a bundle is submitted before the worker
+	// assembled its first pending block to simulate it on.
+	errNoPendingBlock = errors.New("no pending block")
+
+	// errWorkerClosed is returned if a bundle is submitted to a closed worker.
+	errWorkerClosed = errors.New("worker closed")
+)
+
+// Bundle is a group of transactions to be included in a block contiguously and
+// atomically: either all of them are included in order, or none of them.
+type Bundle struct {
+	Txs      types.Transactions // Transactions to include, in order
+	MinBlock uint64             // First block number the bundle may be included in
+	MaxBlock uint64             // Last block number the bundle may be included in
+
+	price *big.Int // Effective gas price of the bundle, measured by its simulation
+}
+
+// Hash returns the hash identifying the bundle, which is the hash of the hashes
+// of its transactions.
+func (b *Bundle) Hash() common.Hash {
+	hashes := make([]byte, 0, len(b.Txs)*common.HashLength)
+	for _, tx := range b.Txs {
+		hashes = append(hashes, tx.Hash().Bytes()...)
+	}
+	return crypto.Keccak256Hash(hashes)
+}
+
+// bundleReq is a request to accept a new bundle into the worker, answered with
+// the outcome of the bundle simulation.
+type bundleReq struct {
+	bundle *Bundle
+	errc   chan error
+}
+
+// addBundle simulates a bundle on top of the current pending block and, if all
+// of its transactions execute without reverting, schedules it for inclusion.
+func (w *worker) addBundle(bundle *Bundle) error {
+	req := &bundleReq{bundle: bundle, errc: make(chan error, 1)}
+	select {
+	case w.bundleCh <- req:
+		return <-req.errc
+	case <-w.exitCh:
+		return errWorkerClosed
+	}
+}
+
+// acceptBundle validates and simulates a bundle, adding it to the set of bundles
+// awaiting inclusion.
+//
+// Note, this mechod is only called from the main loop, which owns the bundles.
+func (w *worker) acceptBundle(bundle *Bundle) error {
+	if len(bundle.Txs) == 0 {
+		return errEmptyBundle
+	}
+	if bundle.MaxBlock < bundle.MinBlock || bundle.MaxBlock-bundle.MinBlock >= maxBundleRange {
+		return errBundleRange
+	}
+	if w.current == nil {
+		return errNoPendingBlock
+	}
+	if bundle.MaxBlock < w.current.header.Number.Uint64() {
+		return errBundleExpired
+	}
+	if len(w.bundles) >= maxBundles {
+		return errTooManyBundles
+	}
+	result, err := w.simulateBundle(bundle, w.coinbase)
+	if err != nil {
+		return err
+	}
+	bundle.price = result.price
+	w.bundles = append(w.bundles, bundle)
+
+	log.Debug("Accepted transaction bundle", "hash", bundle.Hash(), "txs", len(bundle.Txs), "price", result.price, "min", bundle.MinBlock, "max", bundle.MaxBlock)
+	return nil
+}
+
+// bundleResult is the outcome of a successful bundle simulation.
+type bundleResult struct {
+	state    *state.StateDB // Copy of the pending state with the bundle applied
+	receipts types.Receipts // Receipts of the bundle transactions
+	gasUsed  uint64         // Gas used by the block including the bundle
+	gasLeft  uint64         // Gas left in the block after the bundle
+	price    *big.Int       // Effective gas price, the fees paid per unit of gas used
+}
+
+// simulateBundle executes a bundle on top of a copy of the pending state. As
+// the state can't be reverted across transactions, the pending block is left
+// untouched and the simulation result is adopted if the bundle is committed.
+func (w *worker) simulateBundle(bundle *Bundle, coinbase common.Address) (*bundleResult, error) {
+	var (
+		header  = types.CopyHeader(w.current.header)
+		gasPool = new(core.GasPool).AddGas(header.GasLimit - header.GasUsed)
+		result  = &bundleResult{state: w.current.state.Copy()}
+		fees    = new(big.Int)
+		gasUsed uint64
+	)
+	if w.current.gasPool != nil {
+		gasPool = new(core.GasPool).AddGas(w.current.gasPool.Gas())
+	}
+	for i, tx := range bundle.Txs {
+		result.state.Prepare(tx.Hash(), common.Hash{}, w.current.tcount+i)
+
+		receipt, _, err := core.ApplyTransaction(w.config, w.chain, &coinbase, gasPool, result.state, header, tx, &header.GasUsed, *w.chain.GetVMConfig())
+		if err != nil {
+			return nil, fmt.Errorf("bundle transaction %d failed: %v", i, err)
+		}
+		if receipt.Status == types.ReceiptStatusFailed {
+			return nil, fmt.Errorf("%v: transaction %d", errBundleReverted, i)
+		}
+		result.receipts = append(result.receipts, receipt)
+		fees.Add(fees, new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice()))
+		gasUsed += receipt.GasUsed
+	}
+	result.gasUsed, result.gasLeft = header.GasUsed, gasPool.Gas()
+	result.price = fees.Div(fees, new(big.Int).SetUint64(gasUsed))
+	return result, nil
+}
+
+// commitBundles drops the expired and stale bundles and commits the ones
+// targeting the current block, ordered by their effective gas price. Bundles
+// failing to execute in full are left out of the block entirely.
+func (w *worker) commitBundles(coinbase common.Address, interrupt *int32) bool {
+	// Short circuit if current is nil
+	if w.current == nil {
+		return true
+	}
+	if w.current.gasPool == nil {
+		w.current.gasPool = new(core.GasPool).AddGas(w.current.header.GasLimit)
+	}
+	number := w.current.header.Number.Uint64()
+
+	var (
+		live     = w.bundles[:0]
+		eligible []*Bundle
+	)
+	for _, bundle := range w.bundles {
+		if bundle.MaxBlock < number || w.staleBundle(bundle) {
+			continue
+		}
+		live = append(live, bundle)
+		if bundle.MinBlock <= number {
+			eligible = append(eligible, bundle)
+		}
+	}
+	for i := len(live); i < len(w.bundles); i++ {
+		w.bundles[i] = nil
+	}
+	w.bundles = live
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return eligible[i].price.Cmp(eligible[j].price) > 0
+	})
+	var coalescedLogs []*types.Log
+
+	for _, bundle := range eligible {
+		// Abort on the same interruptions as the pending transactions
+		if interrupt != nil && atomic.LoadInt32(interrupt) != commitInterruptNone {
+			return atomic.LoadInt32(interrupt) == commitInterruptNewHead
+		}
+		logs, err := w.commitBundle(bundle, coinbase)
+		if err != nil {
+			log.Trace("Skipping transaction bundle", "hash", bundle.Hash(), "err", err)
+			continue
+		}
+		coalescedLogs = append(coalescedLogs, logs...)
+	}
+	if !w.isRunning() && len(coalescedLogs) > 0 {
+		w.postPendingLogs(coalescedLogs)
+	}
+	return false
+}
+
+// staleBundle checks whetvchain any transaction of a bundle spends a nonce already
+// used in the parent state of the current block. Such bundles were either
+// included in the chain or superseded, and can never be committed again.
+func (w *worker) staleBundle(bundle *Bundle) bool {
+	for _, tx := range bundle.Txs {
+		from, err := types.Sender(w.current.signer, tx)
+		if err != nil || tx.Nonce() < w.current.state.GetNonce(from) {
+			return true
+		}
+	}
+	return false
+}
+
+// commitBundle applies all transactions of a bundle to the current block if all
+// of them execute without failing or reverting. The bundle is executed on a copy
+// of the pending state, which replaces the pending state only once the entire
+// bundle succeeded, so the block never contains a partial bundle.
+func (w *worker) commitBundle(bundle *Bundle, coinbase common.Address) ([]*types.Log, error) {
+	result, err := w.simulateBundle(bundle, coinbase)
+	if err != nil {
+		return nil, err
+	}
+	w.current.state = result.state
+	w.current.gasPool = new(core.GasPool).AddGas(result.gasLeft)
+	w.current.header.GasUsed = result.gasUsed
+	w.current.txs = append(w.current.txs, bundle.Txs...)
+	w.current.receipts = append(w.current.receipts, result.receipts...)
+	w.current.tcount += len(bundle.Txs)
+
+	var logs []*types.Log
+	for _, receipt := range result.receipts {
+		logs = append(logs, receipt.Logs...)
+	}
+	return logs, nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/consensus/echash"
+	"github.com/etvchaineum/go-etvchaineum/core"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+	"github.com/etvchaineum/go-etvchaineum/event"
+	"github.com/etvchaineum/go-etvchaineum/params"
+)
+
+// newBundleTestWorker creates a worker on top of a pool containing the given
+// remote transactions, waiting until its first pending block is assembled.
+func newBundleTestWorker(t *testing.T, engine *echash.Ethash, txs []*types.Transaction) (*worker, *testWorkerBackend) {
+	b := newTestWorkerBackend(t, echashChainConfig, engine, 0)
+	for i, tx := range txs {
+		if err := b.txPool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	w := newWorker(echashChainConfig, engine, b, new(event.TypeMux), time.Second, params.GenesisGasLimit, params.GenesisGasLimit, nil)
+	w.setEtvchainbase(testBankAddress)
+
+	w.skipSealHook = func(task *task) bool {
+		return true
+	}
+	// Ensure worker has finished initialization
+	for {
+		b := w.pendingBlock()
+		if b != nil && b.NumberU64() == 1 {
+			break
+		}
+	}
+	return w, b
+}
+
+// waitBundleTask starts the worker and waits for the first sealing task of the
+// next block containing any transactions.
+func waitBundleTask(t *testing.T, w *worker) *task {
+	taskCh := make(chan *task, 1)
+	w.newTaskHook = func(task *task) {
+		if task.block.NumberU64() == 1 && len(task.block.Transactions()) > 0 {
+			select {
+			case taskCh <- task:
+			default:
+			}
+		}
+	}
+	w.start()
+
+	select {
+	case task := <-taskCh:
+		return task
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("new task timeout")
+		return nil
+	}
+}
+
+// checkBlockTransactions checks that a block contains the expected transactions
+// in the expected order.
+func checkBlockTransactions(t *testing.T, block *types.Block, want []*types.Transaction) {
+	have := block.Transactions()
+	if len(have) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i].Hash() != want[i].Hash() {
+			t.Errorf("transaction %d mismatch: have %x, want %x", i, have[i].Hash(), want[i].Hash())
+		}
+	}
+}
+
+// Tests that bundles are included contiguously ahead of the pool transactions,
+// ordered by their effective gas price.
+func TestBundleInclusion(t *testing.T) {
+	engine := echash.NewFaker()
+	defer engine.Close()
+
+	pooled := orderingTransaction(0, 0, 100)
+	w, _ := newBundleTestWorker(t, engine, []*types.Transaction{pooled})
+	defer w.close()
+
+	cheap := &Bundle{
+		Txs:      types.Transactions{orderingTransaction(1, 0, 1), orderingTransaction(1, 1, 1)},
+		MinBlock: 1,
+		MaxBlock: 1,
+	}
+	pricey := &Bundle{
+		Txs:      types.Transactions{orderingTransaction(2, 0, 3)},
+		MinBlock: 1,
+		MaxBlock: 2,
+	}
+	future := &Bundle{
+		Txs:      types.Transactions{orderingTransaction(0, 1, 50)},
+		MinBlock: 2,
+		MaxBlock: 2,
+	}
+	for i, bundle := range []*Bundle{cheap, pricey, future} {
+		if err := w.addBundle(bundle); err != nil {
+			t.Fatalf("failed to add bundle %d: %v", i, err)
+		}
+	}
+	task := waitBundleTask(t, w)
+	checkBlockTransactions(t, task.block, []*types.Transaction{pricey.Txs[0], cheap.Txs[0], cheap.Txs[1], pooled})
+}
+
+// Tests that a bundle failing during block assembly is left out entirely, even
+// if some of its transactions could be executed.
+func TestBundleAtomicity(t *testing.T) {
+	engine := echash.NewFaker()
+	defer engine.Close()
+
+	pooled := orderingTransaction(0, 0, 1)
+	w, _ := newBundleTestWorker(t, engine, []*types.Transaction{pooled})
+	defer w.close()
+
+	// Both bundles pass the simulation on their own, but the cheaper one spends a
+	// nonce already used by the pricier one when assembling the block
+	pricey := &Bundle{
+		Txs:      types.Transactions{orderingTransaction(1, 0, 5)},
+		MinBlock: 1,
+		MaxBlock: 1,
+	}
+	cheap := &Bundle{
+		Txs:      types.Transactions{orderingTransaction(2, 0, 2), orderingTransaction(1, 0, 2)},
+		MinBlock: 1,
+		MaxBlock: 1,
+	}
+	for i, bundle := range []*Bundle{pricey, cheap} {
+		if err := w.addBundle(bundle); err != nil {
+			t.Fatalf("failed to add bundle %d: %v", i, err)
+		}
+	}
+	task := waitBundleTask(t, w)
+	checkBlockTransactions(t, task.block, []*types.Transaction{pricey.Txs[0], pooled})
+
+	if balance := task.state.GetBalance(testUserAddress); balance.Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("account balance mismatch: have %d, want %d", balance, 2000)
+	}
+}
+
+// Tests that invalid and reverting bundles are rejected on submission.
+func TestBundleRejection(t *testing.T) {
+	engine := echash.NewFaker()
+	defer engine.Close()
+
+	w, _ := newBundleTestWorker(t, engine, nil)
+	defer w.close()
+
+	revert, _ := types.SignTx(types.NewTransaction(0, testRevertAddress, new(big.Int), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, testSenderKeys[1])
+
+	tests := []struct {
+		bundle *Bundle
+		fail   bool
+	}{
+		{&Bundle{MinBlock: 1, MaxBlock: 1}, true},
+		{&Bundle{Txs: types.Transactions{orderingTransaction(0, 0, 1)}, MinBlock: 2, MaxBlock: 1}, true},
+		{&Bundle{Txs: types.Transactions{orderingTransaction(0, 0, 1)}, MinBlock: 1, MaxBlock: 1 + maxBundleRange}, true},
+		{&Bundle{Txs: types.Transactions{orderingTransaction(0, 0, 1)}, MinBlock: 0, MaxBlock: 0}, true},
+		{&Bundle{Txs: types.Transactions{orderingTransaction(0, 1, 1)}, MinBlock: 1, MaxBlock: 1}, true},
+		{&Bundle{Txs: types.Transactions{orderingTransaction(0, 0, 1), revert}, MinBlock: 1, MaxBlock: 1}, true},
+		{&Bundle{Txs: types.Transactions{orderingTransaction(0, 0, 1)}, MinBlock: 1, MaxBlock: 1}, false},
+	}
+	for i, tt := range tests {
+		err := w.addBundle(tt.bundle)
+		if tt.fail && err == nil {
+			t.Errorf("test %d: invalid bundle accepted", i)
+		}
+		if !tt.fail && err != nil {
+			t.Errorf("test %d: valid bundle rejected: %v", i, err)
+		}
+	}
+	if len(w.bundles) != 1 {
+		t.Errorf("tracked bundle count mismatch: have %d, want 1", len(w.bundles))
+	}
+}
+
+// Tests that bundles spending nonces already used in the chain are dropped once
+// the new head arrives, instead of failing on every block until they expire.
+func TestBundlePruning(t *testing.T) {
+	engine := echash.NewFaker()
+	defer engine.Close()
+
+	w, b := newBundleTestWorker(t, engine, nil)
+	defer w.close()
+
+	included := &Bundle{
+		Txs:      types.Transactions{orderingTransaction(1, 0, 1)},
+		MinBlock: 2,
+		MaxBlock: 3,
+	}
+	pending := &Bundle{
+		Txs:      types.Transactions{orderingTransaction(2, 0, 1)},
+		MinBlock: 2,
+		MaxBlock: 3,
+	}
+	for i, bundle := range []*Bundle{included, pending} {
+		if err := w.addBundle(bundle); err != nil {
+			t.Fatalf("failed to add bundle %d: %v", i, err)
+		}
+	}
+	// Import a block including the transaction of the first bundle
+	blocks, _ := core.GenerateChain(echashChainConfig, b.chain.Genesis(), engine, b.db, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(included.Txs[0])
+	})
+	if _, err := b.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	for {
+		if block := w.pendingBlock(); block != nil && block.NumberU64() == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Bundles are owned by the main loop, synchronise with it before checking
+	if err := w.addBundle(&Bundle{Txs: types.Transactions{orderingTransaction(0, 0, 1)}, MinBlock: 2, MaxBlock: 2}); err != nil {
+		t.Fatalf("failed to add bundle: %v", err)
+	}
+	if len(w.bundles) != 2 {
+		t.Fatalf("tracked bundle count mismatch: have %d, want 2", len(w.bundles))
+	}
+	for _, bundle := range w.bundles {
+		if bundle == included {
+			t.Errorf("included bundle not pruned")
+		}
+	}
+}
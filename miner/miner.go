@@ -31,6 +31,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/event"
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/params"
+	"github.com/etvchaineum/go-etvchaineum/rpc"
 )
 
 // Backend wraps all mechods required for mining.
@@ -150,6 +151,34 @@ func (self *Miner) SetRecommitInterval(interval time.Duration) {
 	self.worker.setRecommitInterval(interval)
 }
 
+// SetOrdering sets the strategy deciding the order of the transactions the blocks
+// are filled with.
+func (self *Miner) SetOrdering(config OrderingConfig) error {
+	ordering, err := NewTxOrdering(config, self.ech.TxPool().ArrivalTime)
+	if err != nil {
+		return err
+	}
+	self.worker.setOrdering(ordering)
+	return nil
+}
+
+// SendBundle submits a transaction bundle to be included atomically in a block
+// within its target range, if it executes without reverting on top of the
+// current pending block.
+func (self *Miner) SendBundle(bundle *Bundle) error {
+	return self.worker.addBundle(bundle)
+}
+
+// APIs returns the RPC APIs the miner offers on top of the ones of the node.
+func (self *Miner) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "miner",
+		Version:   "1.0",
+		Service:   NewPrivateBundleAPI(self),
+		Public:    false,
+	}}
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
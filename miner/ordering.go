@@ -0,0 +1,348 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common"
+	"github.com/etvchaineum/go-etvchaineum/core/types"
+)
+
+const (
+	// PriceOrdering includes the most profitable transactions first, honouring
+	// the nonce order of each account.
+	PriceOrdering = "price"
+
+	// PriorityOrdering includes the transactions of the priority senders first,
+	// ordering both them and the rest by price.
+	PriorityOrdering = "priority"
+
+	// FIFOOrdering includes the transactions in the order they arrived into the
+	// transaction pool, honouring the nonce order of each account.
+	FIFOOrdering = "fifo"
+)
+
+// OrderingConfig is the configuration of the transaction ordering strategy the
+// worker fills the blocks with.
+type OrderingConfig struct {
+	Strategy        string           // Name of the ordering strategy to use
+	PrioritySenders []common.Address // Senders to include first with the priority strategy
+	SenderGasCap    uint64           // Maximum gas a single sender may use in a block (0 = unlimited)
+}
+
+// DefaultOrderingConfig contains the default transaction ordering settings.
+var DefaultOrderingConfig = OrderingConfig{
+	Strategy: PriceOrdering,
+}
+
+// TransactionSet is a set of transactions the worker retrieves the transactions
+// to include in a block from, one at a time.
+type TransactionSet interface {
+	// Peek returns the next transaction to include, or nil if the set is empty.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one from the same
+	// account.
+	Shift()
+
+	// Pop removes the current transaction along with all subsequent ones from
+	// the same account, as they cannot be executed anymore.
+	Pop()
+}
+
+// TxOrdering is a strategy deciding the order in which the worker includes the
+// pending transactions in a block.
+type TxOrdering interface {
+	// Name returns the name of the ordering strategy.
+	Name() string
+
+	// NewSet creates a transaction set from the pending transactions grouped by
+	// account and sorted by nonce. The input map is reowned by the set.
+	NewSet(signer types.Signer, txs map[common.Address]types.Transactions) TransactionSet
+}
+
+// NewTxOrdering creates the transaction ordering strategy specified by the config.
+// The arrival function is used by the FIFO strategy to retrieve the time a pending
+// transaction arrived into the pool.
+func NewTxOrdering(config OrderingConfig, arrival func(common.Hash) time.Time) (TxOrdering, error) {
+	var ordering TxOrdering
+	switch config.Strategy {
+	case "", PriceOrdering:
+		ordering = priceOrdering{}
+	case PriorityOrdering:
+		ordering = newPriorityOrdering(config.PrioritySenders)
+	case FIFOOrdering:
+		ordering = &fifoOrdering{arrival: arrival}
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering strategy %q", config.Strategy)
+	}
+	if config.SenderGasCap > 0 {
+		ordering = &gasCapOrdering{TxOrdering: ordering, limit: config.SenderGasCap}
+	}
+	return ordering, nil
+}
+
+// priceOrdering is the default ordering strategy, including the transactions
+// with the highest gas price first.
+type priceOrdering struct{}
+
+// Name implements TxOrdering, returning the name of the price strategy.
+func (priceOrdering) Name() string { return PriceOrdering }
+
+// NewSet implements TxOrdering, creating a price and nonce sorted set.
+func (priceOrdering) NewSet(signer types.Signer, txs map[common.Address]types.Transactions) TransactionSet {
+	return types.NewTransactionsByPriceAndNonce(signer, txs)
+}
+
+// priorityOrdering includes the transactions of a set of senders before all
+// others, ordering both groups by price.
+type priorityOrdering struct {
+	senders map[common.Address]struct{}
+}
+
+// newPriorityOrdering creates an ordering strategy preferring the given senders.
+func newPriorityOrdering(senders []common.Address) *priorityOrdering {
+	ordering := &priorityOrdering{senders: make(map[common.Address]struct{}, len(senders))}
+	for _, sender := range senders {
+		ordering.senders[sender] = struct{}{}
+	}
+	return ordering
+}
+
+// Name implements TxOrdering, returning the name of the priority strategy.
+func (o *priorityOrdering) Name() string { return PriorityOrdering }
+
+// NewSet implements TxOrdering, splitting the transactions by the priority of
+// their senders.
+func (o *priorityOrdering) NewSet(signer types.Signer, txs map[common.Address]types.Transactions) TransactionSet {
+	priority, rest := make(map[common.Address]types.Transactions), txs
+	for sender := range o.senders {
+		if list := rest[sender]; len(list) > 0 {
+			delete(rest, sender)
+			priority[sender] = list
+		}
+	}
+	return &chainedSet{sets: []TransactionSet{
+		types.NewTransactionsByPriceAndNonce(signer, priority),
+		types.NewTransactionsByPriceAndNonce(signer, rest),
+	}}
+}
+
+// chainedSet is a transaction set draining multiple sets one after the other.
+type chainedSet struct {
+	sets []TransactionSet
+}
+
+// Peek implements TransactionSet, returning the next transaction from the first
+// non-empty set.
+func (s *chainedSet) Peek() *types.Transaction {
+	for len(s.sets) > 0 {
+		if tx := s.sets[0].Peek(); tx != nil {
+			return tx
+		}
+		s.sets = s.sets[1:]
+	}
+	return nil
+}
+
+// Shift implements TransactionSet, shifting the current set.
+func (s *chainedSet) Shift() {
+	if s.Peek() != nil {
+		s.sets[0].Shift()
+	}
+}
+
+// Pop implements TransactionSet, popping from the current set.
+func (s *chainedSet) Pop() {
+	if s.Peek() != nil {
+		s.sets[0].Pop()
+	}
+}
+
+// fifoOrdering includes the transactions in the order they arrived into the pool.
+type fifoOrdering struct {
+	arrival func(common.Hash) time.Time
+}
+
+// Name implements TxOrdering, returning the name of the FIFO strategy.
+func (o *fifoOrdering) Name() string { return FIFOOrdering }
+
+// NewSet implements TxOrdering, creating an arrival and nonce sorted set.
+func (o *fifoOrdering) NewSet(signer types.Signer, txs map[common.Address]types.Transactions) TransactionSet {
+	set := &fifoSet{
+		txs: make(map[common.Address]types.Transactions, len(txs)),
+		heads: txsByArrival{
+			txs:   make(types.Transactions, 0, len(txs)),
+			times: make(map[common.Hash]time.Time),
+		},
+		signer: signer,
+	}
+	for _, list := range txs {
+		if o.arrival != nil {
+			for _, tx := range list {
+				set.heads.times[tx.Hash()] = o.arrival(tx.Hash())
+			}
+		}
+		// Ensure the sender address is from the signer
+		acc, _ := types.Sender(signer, list[0])
+		set.heads.txs = append(set.heads.txs, list[0])
+		set.txs[acc] = list[1:]
+	}
+	heap.Init(&set.heads)
+	return set
+}
+
+// txsByArrival implements the heap interface, ordering transactions by their
+// arrival time, or by price if they arrived at the same time.
+type txsByArrival struct {
+	txs   types.Transactions
+	times map[common.Hash]time.Time
+}
+
+func (s txsByArrival) Len() int { return len(s.txs) }
+func (s txsByArrival) Less(i, j int) bool {
+	ti, tj := s.times[s.txs[i].Hash()], s.times[s.txs[j].Hash()]
+	if !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	return s.txs[i].GasPrice().Cmp(s.txs[j].GasPrice()) > 0
+}
+func (s txsByArrival) Swap(i, j int) { s.txs[i], s.txs[j] = s.txs[j], s.txs[i] }
+
+func (s *txsByArrival) Push(x interface{}) {
+	s.txs = append(s.txs, x.(*types.Transaction))
+}
+
+func (s *txsByArrival) Pop() interface{} {
+	old := s.txs
+	n := len(old)
+	x := old[n-1]
+	s.txs = old[0 : n-1]
+	return x
+}
+
+// fifoSet is a transaction set returning transactions in their arrival order,
+// while honouring the nonce order of each account.
+type fifoSet struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  txsByArrival                          // Next transaction for each unique account (arrival heap)
+	signer types.Signer                          // Signer for the set of transactions
+}
+
+// Peek implements TransactionSet, returning the earliest arrived transaction.
+func (s *fifoSet) Peek() *types.Transaction {
+	if len(s.heads.txs) == 0 {
+		return nil
+	}
+	return s.heads.txs[0]
+}
+
+// Shift implements TransactionSet, replacing the current head with the next
+// transaction from the same account.
+func (s *fifoSet) Shift() {
+	acc, _ := types.Sender(s.signer, s.heads.txs[0])
+	if txs, ok := s.txs[acc]; ok && len(txs) > 0 {
+		s.heads.txs[0], s.txs[acc] = txs[0], txs[1:]
+		heap.Fix(&s.heads, 0)
+	} else {
+		heap.Pop(&s.heads)
+	}
+}
+
+// Pop implements TransactionSet, dropping the current head without replacing it.
+func (s *fifoSet) Pop() {
+	heap.Pop(&s.heads)
+}
+
+// gasCapOrdering wraps an ordering strategy, limiting the gas the transactions
+// of a single sender may use in a block.
+type gasCapOrdering struct {
+	TxOrdering
+	limit uint64
+}
+
+// NewSet implements TxOrdering, wrapping the set of the underlying strategy.
+func (o *gasCapOrdering) NewSet(signer types.Signer, txs map[common.Address]types.Transactions) TransactionSet {
+	return &gasCapSet{
+		TransactionSet: o.TxOrdering.NewSet(signer, txs),
+		signer:         signer,
+		limit:          o.limit,
+		used:           make(map[common.Address]uint64),
+	}
+}
+
+// gasCharger is implemented by the transaction sets accounting for the gas used
+// by the transactions included in the block.
+type gasCharger interface {
+	// charge accounts the gas used by the current transaction once included in
+	// the block. It must be called before shifting the transaction out.
+	charge(gasUsed uint64)
+}
+
+// gasCapSet is a transaction set skipping the senders whose next transaction
+// would exceed their gas allowance. As the gas used by a transaction is unknown
+// before its execution, its gas limit needs to fit into the allowance, which is
+// then charged with the gas actually used once the transaction is included.
+type gasCapSet struct {
+	TransactionSet
+	signer types.Signer
+	limit  uint64
+	used   map[common.Address]uint64
+}
+
+// Peek implements TransactionSet, dropping all senders whose next transaction
+// doesn't fit into their allowance.
+func (s *gasCapSet) Peek() *types.Transaction {
+	for {
+		tx := s.TransactionSet.Peek()
+		if tx == nil {
+			return nil
+		}
+		from, _ := types.Sender(s.signer, tx)
+		if s.used[from]+tx.Gas() <= s.limit {
+			return tx
+		}
+		s.TransactionSet.Pop()
+	}
+}
+
+// charge implements gasCharger, charging the sender of the current transaction.
+func (s *gasCapSet) charge(gasUsed uint64) {
+	if tx := s.TransactionSet.Peek(); tx != nil {
+		from, _ := types.Sender(s.signer, tx)
+		s.used[from] += gasUsed
+	}
+}
+
+// Shift implements TransactionSet, shifting the current sender. The current
+// transaction is not checked against the allowance again, as it may have been
+// charged already.
+func (s *gasCapSet) Shift() {
+	if s.TransactionSet.Peek() != nil {
+		s.TransactionSet.Shift()
+	}
+}
+
+// Pop implements TransactionSet, dropping the current sender.
+func (s *gasCapSet) Pop() {
+	if s.Peek() != nil {
+		s.TransactionSet.Pop()
+	}
+}
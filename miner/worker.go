@@ -147,15 +147,18 @@ type worker struct {
 	exitCh             chan struct{}
 	resubmitIntervalCh chan time.Duration
 	resubmitAdjustCh   chan *intervalAdjust
+	bundleCh           chan *bundleReq
 
 	current      *environment                 // An environment for current running cycle.
 	localUncles  map[common.Hash]*types.Block // A set of side blocks generated locally as the possible uncle blocks.
 	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
 	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
+	bundles      []*Bundle                    // Transaction bundles awaiting inclusion, owned by the main loop.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
+	mu       sync.RWMutex // The lock used to protect the coinbase, extra and ordering fields
 	coinbase common.Address
 	extra    []byte
+	ordering TxOrdering // Strategy deciding the order of the transactions in a block
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
@@ -187,6 +190,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, ech Backend,
 		chain:              ech.BlockChain(),
 		gasFloor:           gasFloor,
 		gasCeil:            gasCeil,
+		ordering:           priceOrdering{},
 		isLocalBlock:       isLocalBlock,
 		localUncles:        make(map[common.Hash]*types.Block),
 		remoteUncles:       make(map[common.Hash]*types.Block),
@@ -202,6 +206,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, ech Backend,
 		startCh:            make(chan struct{}, 1),
 		resubmitIntervalCh: make(chan time.Duration),
 		resubmitAdjustCh:   make(chan *intervalAdjust, resubmitAdjustChanSize),
+		bundleCh:           make(chan *bundleReq),
 	}
 	// Subscribe NewTxsEvent for tx pool
 	worker.txsSub = ech.TxPool().SubscribeNewTxsEvent(worker.txsCh)
@@ -240,6 +245,13 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setOrdering sets the strategy deciding the order of the transactions in a block.
+func (w *worker) setOrdering(ordering TxOrdering) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ordering = ordering
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -266,6 +278,13 @@ func (w *worker) pendingBlock() *types.Block {
 
 // start sets the running status as 1 and triggers new work submitting.
 func (w *worker) start() {
+	// Engines agreeing on blocks with the other nodes need to run their own
+	// message processing while blocks are being sealed
+	if handler, ok := w.engine.(consensus.Handler); ok {
+		if err := handler.Start(w.chain); err != nil {
+			log.Error("Failed to start consensus engine", "err", err)
+		}
+	}
 	atomic.StoreInt32(&w.running, 1)
 	w.startCh <- struct{}{}
 }
@@ -273,6 +292,12 @@ func (w *worker) start() {
 // stop sets the running status as 0.
 func (w *worker) stop() {
 	atomic.StoreInt32(&w.running, 0)
+
+	if handler, ok := w.engine.(consensus.Handler); ok {
+		if err := handler.Stop(); err != nil {
+			log.Error("Failed to stop consensus engine", "err", err)
+		}
+	}
 }
 
 // isRunning returns an indicator whetvchain worker is running or not.
@@ -347,14 +372,21 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 			commit(false, commitInterruptNewHead)
 
 		case head := <-w.chainHeadCh:
+			if handler, ok := w.engine.(consensus.Handler); ok {
+				handler.NewChainHead(head.Block.Header())
+			}
+			if reporter, ok := w.engine.(consensus.ChainHeadReporter); ok {
+				reporter.ReportChainHead(w.chain, head.Block.Header())
+			}
 			clearPending(head.Block.NumberU64())
 			timestamp = time.Now().Unix()
 			commit(false, commitInterruptNewHead)
 
 		case <-timer.C:
 			// If mining is running resubmit a new work cycle periodically to pull in
-			// higher priced transactions. Disable this overhead for pending blocks.
-			if w.isRunning() && (w.config.Clique == nil || w.config.Clique.Period > 0) {
+			// higher priced transactions. Disable this overhead for pending blocks,
+			// and for BFT where a proposal in agreement must not be replaced.
+			if w.isRunning() && (w.config.Clique == nil || w.config.Clique.Period > 0) && w.config.BFT == nil {
 				// Short circuit if no new transaction arrives.
 				if atomic.LoadInt32(&w.newTxs) == 0 {
 					timer.Reset(recommit)
@@ -409,6 +441,9 @@ func (w *worker) mainLoop() {
 		case req := <-w.newWorkCh:
 			w.commitNewWork(req.interrupt, req.noempty, req.timestamp)
 
+		case req := <-w.bundleCh:
+			req.errc <- w.acceptBundle(req.bundle)
+
 		case ev := <-w.chainSideCh:
 			// Short circuit for duplicate side blocks
 			if _, exist := w.localUncles[ev.Block.Hash()]; exist {
@@ -456,7 +491,7 @@ func (w *worker) mainLoop() {
 			// be automatically eliminated.
 			if !w.isRunning() && w.current != nil {
 				w.mu.RLock()
-				coinbase := w.coinbase
+				coinbase, ordering := w.coinbase, w.ordering
 				w.mu.RUnlock()
 
 				txs := make(map[common.Address]types.Transactions)
@@ -464,7 +499,7 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
+				txset := ordering.NewSet(w.current.signer, txs)
 				w.commitTransactions(txset, coinbase, nil)
 				w.updateSnapshot()
 			} else {
@@ -557,6 +592,17 @@ func (w *worker) resultLoop() {
 			task, exist := w.pendingTasks[sealhash]
 			w.pendingMu.RUnlock()
 			if !exist {
+				// Engines agreeing on blocks with other nodes may hand over the block
+				// of another proposer, import and propagate it like our own
+				if _, ok := w.engine.(consensus.Handler); ok {
+					if _, err := w.chain.InsertChain(types.Blocks{block}); err != nil {
+						log.Error("Failed inserting agreed block", "number", block.Number(), "hash", hash, "err", err)
+						continue
+					}
+					log.Info("Successfully sealed agreed block", "number", block.Number(), "sealhash", sealhash, "hash", hash)
+					w.mux.Post(core.NewMinedBlockEvent{Block: block})
+					continue
+				}
 				log.Error("Block found but no relative pending task", "number", block.Number(), "sealhash", sealhash, "hash", hash)
 				continue
 			}
@@ -702,7 +748,7 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
+func (w *worker) commitTransactions(txs TransactionSet, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
 		return true
@@ -782,6 +828,9 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			w.current.tcount++
+			if charger, ok := txs.(gasCharger); ok {
+				charger.charge(w.current.receipts[len(w.current.receipts)-1].GasUsed)
+			}
 			txs.Shift()
 
 		default:
@@ -796,16 +845,7 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 		// We don't push the pendingLogsEvent while we are mining. The reason is that
 		// when we are mining, the worker will regenerate a mining block every 3 seconds.
 		// In order to avoid pushing the repeated pendingLog, we disable the pending log pushing.
-
-		// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
-		// logs by filling in the block hash when the block was mined by the local miner. This can
-		// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
-		cpy := make([]*types.Log, len(coalescedLogs))
-		for i, l := range coalescedLogs {
-			cpy[i] = new(types.Log)
-			*cpy[i] = *l
-		}
-		go w.mux.Post(core.PendingLogsEvent{Logs: cpy})
+		w.postPendingLogs(coalescedLogs)
 	}
 	// Notify resubmit loop to decrease resubmitting interval if current interval is larger
 	// than the user-specified one.
@@ -815,6 +855,19 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 	return false
 }
 
+// postPendingLogs posts a copy of the logs of the pending block.
+func (w *worker) postPendingLogs(logs []*types.Log) {
+	// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
+	// logs by filling in the block hash when the block was mined by the local miner. This can
+	// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
+	cpy := make([]*types.Log, len(logs))
+	for i, l := range logs {
+		cpy[i] = new(types.Log)
+		*cpy[i] = *l
+	}
+	go w.mux.Post(core.PendingLogsEvent{Logs: cpy})
+}
+
 // commitNewWork generates several new sealing tasks based on the parent block.
 func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64) {
 	w.mu.RLock()
@@ -908,14 +961,18 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.commit(uncles, nil, false, tstart)
 	}
 
+	// Fill the block with the transaction bundles first, ahead of the pool
+	if w.commitBundles(w.coinbase, interrupt) {
+		return
+	}
 	// Fill the block with all available pending transactions.
 	pending, err := w.ech.TxPool().Pending()
 	if err != nil {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	// Short circuit if there is no available pending transactions
-	if len(pending) == 0 {
+	// Short circuit if there is no available pending transactions nor bundles
+	if len(pending) == 0 && w.current.tcount == 0 {
 		w.updateSnapshot()
 		return
 	}
@@ -928,13 +985,13 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		}
 	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
+		txs := w.ordering.NewSet(w.current.signer, localTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+		txs := w.ordering.NewSet(w.current.signer, remoteTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
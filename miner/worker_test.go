@@ -17,6 +17,7 @@
 package miner
 
 import (
+	"crypto/ecdsa"
 	"math/big"
 	"testing"
 	"time"
@@ -48,11 +49,23 @@ var (
 	testUserKey, _  = crypto.GenerateKey()
 	testUserAddress = crypto.PubkeyToAddress(testUserKey.PublicKey)
 
+	// Test accounts sending the transactions of the ordering and bundle tests
+	testSenderKeys = []*ecdsa.PrivateKey{newTestKey(), newTestKey(), newTestKey()}
+
+	// Test contract reverting on every call
+	testRevertAddress = common.HexToAddress("0xfd")
+	testRevertCode    = common.FromHex("0x60006000fd")
+
 	// Test transactions
 	pendingTxs []*types.Transaction
 	newTxs     []*types.Transaction
 )
 
+func newTestKey() *ecdsa.PrivateKey {
+	key, _ := crypto.GenerateKey()
+	return key
+}
+
 func init() {
 	testTxPoolConfig = core.DefaultTxPoolConfig
 	testTxPoolConfig.Journal = ""
@@ -85,6 +98,10 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 			Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
 		}
 	)
+	for _, key := range testSenderKeys {
+		gspec.Alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: testBankFunds}
+	}
+	gspec.Alloc[testRevertAddress] = core.GenesisAccount{Balance: new(big.Int), Code: testRevertCode}
 
 	switch engine.(type) {
 	case *clique.Clique:
@@ -452,3 +469,137 @@ func testAdjustInterval(t *testing.T, chainConfig *params.ChainConfig, engine co
 		t.Error("interval reset timeout")
 	}
 }
+
+// orderingTransaction creates a transfer from one of the ordering test senders.
+func orderingTransaction(sender int, nonce uint64, gasPrice int64) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, testUserAddress, big.NewInt(1000), params.TxGas, big.NewInt(gasPrice), nil), types.HomesteadSigner{}, testSenderKeys[sender])
+	return tx
+}
+
+func TestTransactionOrderingPrice(t *testing.T) {
+	txs := []*types.Transaction{
+		orderingTransaction(0, 0, 1),
+		orderingTransaction(1, 0, 3),
+		orderingTransaction(2, 0, 2),
+		orderingTransaction(0, 1, 5),
+	}
+	testTransactionOrdering(t, DefaultOrderingConfig, txs, []*types.Transaction{txs[1], txs[2], txs[0], txs[3]})
+}
+
+func TestTransactionOrderingPriority(t *testing.T) {
+	config := OrderingConfig{
+		Strategy:        PriorityOrdering,
+		PrioritySenders: []common.Address{crypto.PubkeyToAddress(testSenderKeys[0].PublicKey)},
+	}
+	txs := []*types.Transaction{
+		orderingTransaction(0, 0, 1),
+		orderingTransaction(1, 0, 3),
+		orderingTransaction(2, 0, 2),
+		orderingTransaction(0, 1, 1),
+	}
+	testTransactionOrdering(t, config, txs, []*types.Transaction{txs[0], txs[3], txs[1], txs[2]})
+}
+
+func TestTransactionOrderingFIFO(t *testing.T) {
+	txs := []*types.Transaction{
+		orderingTransaction(2, 0, 2),
+		orderingTransaction(0, 0, 1),
+		orderingTransaction(1, 0, 3),
+		orderingTransaction(2, 1, 1),
+	}
+	testTransactionOrdering(t, OrderingConfig{Strategy: FIFOOrdering}, txs, []*types.Transaction{txs[0], txs[1], txs[2], txs[3]})
+}
+
+func TestTransactionOrderingSenderGasCap(t *testing.T) {
+	config := OrderingConfig{
+		Strategy:     PriceOrdering,
+		SenderGasCap: 2 * params.TxGas,
+	}
+	txs := []*types.Transaction{
+		orderingTransaction(0, 0, 3),
+		orderingTransaction(0, 1, 3),
+		orderingTransaction(0, 2, 3),
+		orderingTransaction(1, 0, 1),
+	}
+	testTransactionOrdering(t, config, txs, []*types.Transaction{txs[0], txs[1], txs[3]})
+}
+
+// Tests that the senders are charged with the gas their transactions used, not
+// with the gas limits of the transactions.
+func TestTransactionOrderingSenderGasUsed(t *testing.T) {
+	config := OrderingConfig{
+		Strategy:     PriceOrdering,
+		SenderGasCap: 3 * params.TxGas,
+	}
+	transfer := func(nonce uint64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, testUserAddress, big.NewInt(1000), 2*params.TxGas, big.NewInt(3), nil), types.HomesteadSigner{}, testSenderKeys[0])
+		return tx
+	}
+	txs := []*types.Transaction{
+		transfer(0),
+		transfer(1),
+		transfer(2),
+		orderingTransaction(1, 0, 1),
+	}
+	testTransactionOrdering(t, config, txs, []*types.Transaction{txs[0], txs[1], txs[3]})
+}
+
+// testTransactionOrdering adds the given remote transactions to the pool, arriving
+// one second apart, and checks that the worker includes them in the expected order.
+func testTransactionOrdering(t *testing.T, config OrderingConfig, txs []*types.Transaction, want []*types.Transaction) {
+	engine := echash.NewFaker()
+	defer engine.Close()
+
+	b := newTestWorkerBackend(t, echashChainConfig, engine, 0)
+	arrivals := make(map[common.Hash]time.Time)
+	for i, tx := range txs {
+		if err := b.txPool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+		arrivals[tx.Hash()] = time.Unix(int64(i), 0)
+	}
+	ordering, err := NewTxOrdering(config, func(hash common.Hash) time.Time { return arrivals[hash] })
+	if err != nil {
+		t.Fatalf("failed to create ordering: %v", err)
+	}
+	w := newWorker(echashChainConfig, engine, b, new(event.TypeMux), time.Second, params.GenesisGasLimit, params.GenesisGasLimit, nil)
+	w.setEtvchainbase(testBankAddress)
+	w.setOrdering(ordering)
+	defer w.close()
+
+	taskCh := make(chan *task, 1)
+	w.newTaskHook = func(task *task) {
+		if task.block.NumberU64() == 1 && len(task.block.Transactions()) > 0 {
+			select {
+			case taskCh <- task:
+			default:
+			}
+		}
+	}
+	w.skipSealHook = func(task *task) bool {
+		return true
+	}
+	// Ensure worker has finished initialization
+	for {
+		b := w.pendingBlock()
+		if b != nil && b.NumberU64() == 1 {
+			break
+		}
+	}
+	w.start()
+
+	select {
+	case task := <-taskCh:
+		have := task.block.Transactions()
+		if len(have) != len(want) {
+			t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(want))
+		}
+		for i := range want {
+			if have[i].Hash() != want[i].Hash() {
+				t.Errorf("transaction %d mismatch: have %x, want %x", i, have[i].Hash(), want[i].Hash())
+			}
+		}
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("new task timeout")
+	}
+}
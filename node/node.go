@@ -27,6 +27,7 @@ import (
 	"sync"
 
 	"github.com/etvchaineum/go-etvchaineum/accounts"
+	"github.com/etvchaineum/go-etvchaineum/core/rawdb"
 	"github.com/etvchaineum/go-etvchaineum/echdb"
 	"github.com/etvchaineum/go-etvchaineum/event"
 	"github.com/etvchaineum/go-etvchaineum/internal/debug"
@@ -585,6 +586,42 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (echdb.Database, er
 	return echdb.NewLDBDatabase(n.config.ResolvePath(name), cache, handles)
 }
 
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's instance
+// directory, also attaching a chain freezer to it that moves ancient chain
+// data from the database to immutable append-only files. If the node is an
+// ephemeral one, a memory database is returned.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer string) (echdb.Database, error) {
+	if n.config.DataDir == "" {
+		return echdb.NewMemDatabase(), nil
+	}
+	return openDatabaseWithFreezer(n.config, name, cache, handles, freezer)
+}
+
+// openDatabaseWithFreezer opens the named key-value database in the instance
+// directory and wraps it with a freezer. A relative freezer path is resolved
+// within the instance directory, an empty one defaults to the "ancient" folder
+// inside the database.
+func openDatabaseWithFreezer(config *Config, name string, cache, handles int, freezer string) (echdb.Database, error) {
+	root := config.ResolvePath(name)
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(root, "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = config.ResolvePath(freezer)
+	}
+	kvdb, err := echdb.NewLDBDatabase(root, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	db, err := rawdb.NewDatabaseWithFreezer(kvdb, freezer, "ech/db/"+name+"/")
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
 // ResolvePath returns the absolute path of a resource in the instance directory.
 func (n *Node) ResolvePath(x string) string {
 	return n.config.ResolvePath(x)
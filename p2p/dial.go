@@ -18,9 +18,11 @@ package p2p
 
 import (
 	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/etvchaineum/go-etvchaineum/log"
@@ -37,6 +39,12 @@ const (
 	// once every few seconds.
 	lookupInterval = 4 * time.Second
 
+	// Nodes of the DNS node lists are taken in the background, keeping up to
+	// dnsNodeBuffer of them queued for the dialer. If no node can be found, the
+	// lists are retried after dnsRetryInterval.
+	dnsNodeBuffer    = 16
+	dnsRetryInterval = 10 * time.Second
+
 	// If no peers are found for this amount of time, the initial bootnodes are
 	// attempted to be connected.
 	fallbackInterval = 20 * time.Second
@@ -72,6 +80,8 @@ type dialstate struct {
 	ntab        discoverTable
 	netrestrict *netutil.Netlist
 	self        enode.ID
+	rep         *reputation        // optional, filters and orders dynamic dial candidates
+	dnsNodes    <-chan *enode.Node // optional, nodes taken from the DNS node lists
 
 	lookupRunning bool
 	dialing       map[enode.ID]connFlag
@@ -166,7 +176,11 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 
 	var newtasks []task
 	addDial := func(flag connFlag, n *enode.Node) bool {
-		if err := s.checkDial(n, peers); err != nil {
+		err := s.checkDial(n, peers)
+		if err == nil && flag&dynDialedConn != 0 && s.rep != nil && s.rep.score(n.ID()) < minDialScore {
+			err = errBadReputation
+		}
+		if err != nil {
 			log.Trace("Skipping dial candidate", "id", n.ID(), "addr", &net.TCPAddr{IP: n.IP(), Port: n.TCP()}, "err", err)
 			return false
 		}
@@ -220,12 +234,23 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 	randomCandidates := needDynDials / 2
 	if randomCandidates > 0 {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
+		s.sortByScore(s.randomNodes[:n])
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
 				needDynDials--
 			}
 		}
 	}
+	// Take the nodes queued from the DNS node lists as lookup results.
+dns:
+	for len(s.lookupBuf) < needDynDials {
+		select {
+		case n := <-s.dnsNodes:
+			s.lookupBuf = append(s.lookupBuf, n)
+		default:
+			break dns
+		}
+	}
 	// Create dynamic dials from random lookup results, removing tried
 	// items from the result buffer.
 	i := 0
@@ -258,6 +283,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errBadReputation    = errors.New("reputation too low")
 )
 
 func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
@@ -277,6 +303,21 @@ func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 	return nil
 }
 
+// sortByScore orders dial candidates by descending reputation score. Nodes with
+// equal scores keep their relative order.
+func (s *dialstate) sortByScore(nodes []*enode.Node) {
+	if s.rep == nil {
+		return
+	}
+	scores := make(map[enode.ID]float64, len(nodes))
+	for _, n := range nodes {
+		scores[n.ID()] = s.rep.score(n.ID())
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return scores[nodes[i].ID()] > scores[nodes[j].ID()]
+	})
+}
+
 func (s *dialstate) taskDone(t task, now time.Time) {
 	switch t := t.(type) {
 	case *dialTask:
@@ -379,6 +420,38 @@ func (t *discoverTask) String() string {
 	return s
 }
 
+// dnsDiscoveryLoop takes random nodes from the DNS node lists in the background,
+// queueing them for the dialer. It blocks while the queue is full, so the lists
+// are only walked as fast as the dialer consumes their nodes.
+func (srv *Server) dnsDiscoveryLoop(nodes chan<- *enode.Node) {
+	defer srv.loopWG.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-srv.quit
+		cancel()
+	}()
+	for {
+		n := srv.dnsdisc.RandomNode(ctx)
+		if n == nil {
+			select {
+			case <-time.After(dnsRetryInterval):
+				continue
+			case <-srv.quit:
+				return
+			}
+		}
+		if n.IP() == nil || n.TCP() == 0 {
+			continue
+		}
+		select {
+		case nodes <- n:
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
 func (t waitExpireTask) Do(*Server) {
 	time.Sleep(t.Duration)
 }
@@ -17,13 +17,17 @@
 package p2p
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"net"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/p2p/dnsdisc"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enr"
 	"github.com/etvchaineum/go-etvchaineum/p2p/netutil"
@@ -665,6 +669,103 @@ func TestDialResolve(t *testing.T) {
 	}
 }
 
+// This test checks that dynamic dial candidates are chosen by reputation.
+func TestDialStateReputation(t *testing.T) {
+	table := fakeTable{
+		newNode(uintID(1), nil),
+		newNode(uintID(2), nil),
+		newNode(uintID(3), nil),
+		newNode(uintID(4), nil),
+	}
+	rep, _ := newTestReputation(t)
+	defer rep.db.Close()
+	rep.report(uintID(1), RepInvalidBlock)
+	rep.report(uintID(2), RepProtocolError)
+	rep.report(uintID(2), RepProtocolError)
+	rep.report(uintID(4), RepGoodDelivery)
+
+	state := newDialState(enode.ID{}, nil, nil, table, 8, nil)
+	state.rep = rep
+	tasks := state.newTasks(0, nil, time.Time{})
+	want := []task{
+		&dialTask{flags: dynDialedConn, dest: table[3]},
+		&dialTask{flags: dynDialedConn, dest: table[2]},
+		&discoverTask{},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Fatalf("wrong tasks:\ngot  %v\nwant %v", tasks, want)
+	}
+}
+
+// This test checks that nodes of DNS node lists are queued in the background and
+// dialed.
+func TestDialDNSDiscovery(t *testing.T) {
+	// Create a signed tree containing a single node.
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	r.Set(enr.TCP(30303))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	node, _ := enode.New(enode.ValidSchemes, &r)
+	tree, err := dnsdisc.MakeTree(1, []*enode.Node{node}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(key, "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := dnsResolverMock(tree.ToTXT("nodes.example.org"))
+	client, err := dnsdisc.NewClient(dnsdisc.Config{Resolver: resolver}, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run the background sync and check that it queues the node.
+	srv := &Server{dnsdisc: client, quit: make(chan struct{})}
+	nodes := make(chan *enode.Node, 1)
+	srv.loopWG.Add(1)
+	go srv.dnsDiscoveryLoop(nodes)
+	defer func() {
+		close(srv.quit)
+		srv.loopWG.Wait()
+	}()
+
+	var queued *enode.Node
+	select {
+	case queued = <-nodes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no node queued from DNS node list")
+	}
+	if queued.ID() != node.ID() {
+		t.Fatalf("unexpected node %v queued", queued.ID())
+	}
+	// The dialer takes queued nodes as dial candidates.
+	dnsNodes := make(chan *enode.Node, 1)
+	dnsNodes <- queued
+	dialer := newDialState(enode.ID{}, nil, nil, fakeTable{}, 1, nil)
+	dialer.dnsNodes = dnsNodes
+	dialer.start = time.Now()
+	for _, task := range dialer.newTasks(0, nil, time.Now()) {
+		if dt, ok := task.(*dialTask); ok && dt.dest.ID() == node.ID() {
+			return
+		}
+	}
+	t.Fatal("no dial task for queued DNS node")
+}
+
+// dnsResolverMock serves the TXT records of a DNS node list.
+type dnsResolverMock map[string]string
+
+func (r dnsResolverMock) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if txt, ok := r[name]; ok {
+		return []string{txt}, nil
+	}
+	return nil, errors.New("not found")
+}
+
 // compares task lists but doesn't care about the order.
 func sametasks(a, b []task) bool {
 	if len(a) != len(b) {
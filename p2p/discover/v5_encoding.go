@@ -0,0 +1,715 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common/math"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enr"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// Discovery v5 packet layout:
+//
+//   packet        = masking-iv || masked-header || message
+//   masked-header = aes-ctr(dest-id[:16], masking-iv, static-header || authdata)
+//   static-header = protocol-id || version || flag || nonce || authdata-size
+//   message       = aes-gcm(session-key, nonce, message-type || rlp(message), masking-iv || header)
+//
+// The authdata contains the source node ID for ordinary messages, the identity
+// nonce and known record sequence number for WHOAREYOU challenges, and the
+// identity proof, ephemeral key and optionally the record of the sender for
+// handshake messages.
+
+// Discovery v5 packet flags
+const (
+	flagMessage = iota
+	flagWhoareyou
+	flagHandshake
+)
+
+// Discovery v5 message types
+const (
+	v5PingMsg byte = iota + 1 // zero is 'reserved'
+	v5PongMsg
+	v5FindnodeMsg
+	v5NodesMsg
+	v5RegtopicMsg
+	v5TicketMsg
+	v5TopicQueryMsg
+
+	v5UnknownMsg   = 0xfe // undecryptable message, triggers a handshake
+	v5WhoareyouMsg = 0xff // handshake challenge
+)
+
+const (
+	v5Version        = 1
+	maskingIVSize    = 16
+	gcmNonceSize     = 12
+	gcmTagSize       = 16
+	idNonceSize      = 16
+	staticHeaderSize = 6 + 2 + 1 + gcmNonceSize + 2
+	sessionKeySize   = 16
+
+	minV5PacketSize     = maskingIVSize + staticHeaderSize + idNonceSize + 8 // size of a WHOAREYOU packet
+	maxV5PacketSize     = 1280
+	randomPacketMsgSize = 20 // size of the dummy message sent to trigger a handshake
+
+	handshakeTimeout   = time.Second // time after which a sent WHOAREYOU challenge is invalid
+	maxSentHandshakes  = 1000        // maximum number of simultaneously pending handshakes
+	sessionCacheSize   = 1024
+	nodesPerV5Response = 3 // records per NODES packet, keeping it below the size limit
+)
+
+var (
+	v5ProtocolID = [6]byte{'d', 'i', 's', 'c', 'v', '5'}
+
+	keyAgreementPrefix  = []byte("discovery v5 key agreement")
+	identityProofPrefix = []byte("discovery v5 identity proof")
+)
+
+// Errors
+var (
+	errTooShort            = errors.New("packet too short")
+	errInvalidHeader       = errors.New("invalid packet header")
+	errInvalidAuthSize     = errors.New("invalid authdata size")
+	errUnexpectedHandshake = errors.New("unexpected handshake")
+	errHandshakeExpired    = errors.New("handshake challenge expired")
+	errInvalidIDSignature  = errors.New("invalid identity signature")
+	errNoRecord            = errors.New("no record in handshake")
+	errRecordMismatch      = errors.New("record does not match sender")
+	errMessageTooShort     = errors.New("message too short")
+	errMessageDecrypt      = errors.New("cannot decrypt message")
+)
+
+// v5Nonce is the nonce of a discovery v5 packet, used to encrypt its message.
+type v5Nonce [gcmNonceSize]byte
+
+// v5StaticHeader is the unmasked fixed-size header of a discovery v5 packet.
+type v5StaticHeader struct {
+	ProtocolID [6]byte
+	Version    uint16
+	Flag       byte
+	Nonce      v5Nonce
+	AuthSize   uint16
+}
+
+// v5Packet is implemented by all discovery v5 messages.
+type v5Packet interface {
+	name() string       // name returns the name of the packet for logging purposes.
+	kind() byte         // kind returns the message type.
+	reqid() []byte      // reqid returns the request ID of the message.
+	setreqid(id []byte) // setreqid sets the request ID of the message.
+}
+
+// Discovery v5 messages
+type (
+	// v5Whoareyou is the handshake challenge sent in response to an undecryptable
+	// message. It is not a real message and doesn't travel encrypted.
+	v5Whoareyou struct {
+		Nonce     v5Nonce           // nonce of the packet which triggered the challenge
+		IDNonce   [idNonceSize]byte // random nonce to sign in the identity proof
+		RecordSeq uint64            // sequence number of the sender's record known to us
+
+		challengeData []byte      // masking IV and header of the challenge packet, set when encoding or decoding
+		node          *enode.Node // node the challenge is for, if known
+		sent          time.Time   // time the challenge was sent
+	}
+
+	// v5Unknown represents an undecryptable message.
+	v5Unknown struct {
+		Nonce v5Nonce
+	}
+
+	// v5Ping is a liveness check, carrying the record sequence number of the sender.
+	v5Ping struct {
+		ReqID  []byte
+		ENRSeq uint64
+	}
+
+	// v5Pong is the reply to v5Ping.
+	v5Pong struct {
+		ReqID  []byte
+		ENRSeq uint64
+		ToIP   net.IP // endpoint of the ping sender as seen by the replier
+		ToPort uint16
+	}
+
+	// v5Findnode is a query for the nodes at the given log distances to the
+	// recipient. Distance zero requests the record of the recipient itself.
+	v5Findnode struct {
+		ReqID     []byte
+		Distances []uint
+	}
+
+	// v5Nodes is the reply to v5Findnode and v5TopicQuery, which may be split
+	// across multiple packets.
+	v5Nodes struct {
+		ReqID []byte
+		Total uint8 // total number of packets in the reply
+		Nodes []*enr.Record
+	}
+
+	// v5Regtopic requests the registration of the sender as an advertiser of a
+	// topic, optionally presenting a ticket obtained from an earlier attempt.
+	v5Regtopic struct {
+		ReqID  []byte
+		Topic  []byte
+		Ticket []byte
+	}
+
+	// v5Ticket is the reply to v5Regtopic. A zero wait time means the registration
+	// was accepted, otherwise the ticket must be presented again after waiting.
+	v5Ticket struct {
+		ReqID    []byte
+		Ticket   []byte
+		WaitTime uint // in milliseconds
+	}
+
+	// v5TopicQuery requests the nodes advertising a topic.
+	v5TopicQuery struct {
+		ReqID []byte
+		Topic []byte
+	}
+)
+
+func (*v5Whoareyou) name() string          { return "WHOAREYOU/v5" }
+func (*v5Whoareyou) kind() byte            { return v5WhoareyouMsg }
+func (*v5Whoareyou) reqid() []byte         { return nil }
+func (*v5Whoareyou) setreqid([]byte)       {}
+func (*v5Unknown) name() string            { return "UNKNOWN/v5" }
+func (*v5Unknown) kind() byte              { return v5UnknownMsg }
+func (*v5Unknown) reqid() []byte           { return nil }
+func (*v5Unknown) setreqid([]byte)         {}
+func (*v5Ping) name() string               { return "PING/v5" }
+func (*v5Ping) kind() byte                 { return v5PingMsg }
+func (p *v5Ping) reqid() []byte            { return p.ReqID }
+func (p *v5Ping) setreqid(id []byte)       { p.ReqID = id }
+func (*v5Pong) name() string               { return "PONG/v5" }
+func (*v5Pong) kind() byte                 { return v5PongMsg }
+func (p *v5Pong) reqid() []byte            { return p.ReqID }
+func (p *v5Pong) setreqid(id []byte)       { p.ReqID = id }
+func (*v5Findnode) name() string           { return "FINDNODE/v5" }
+func (*v5Findnode) kind() byte             { return v5FindnodeMsg }
+func (p *v5Findnode) reqid() []byte        { return p.ReqID }
+func (p *v5Findnode) setreqid(id []byte)   { p.ReqID = id }
+func (*v5Nodes) name() string              { return "NODES/v5" }
+func (*v5Nodes) kind() byte                { return v5NodesMsg }
+func (p *v5Nodes) reqid() []byte           { return p.ReqID }
+func (p *v5Nodes) setreqid(id []byte)      { p.ReqID = id }
+func (*v5Regtopic) name() string           { return "REGTOPIC/v5" }
+func (*v5Regtopic) kind() byte             { return v5RegtopicMsg }
+func (p *v5Regtopic) reqid() []byte        { return p.ReqID }
+func (p *v5Regtopic) setreqid(id []byte)   { p.ReqID = id }
+func (*v5Ticket) name() string             { return "TICKET/v5" }
+func (*v5Ticket) kind() byte               { return v5TicketMsg }
+func (p *v5Ticket) reqid() []byte          { return p.ReqID }
+func (p *v5Ticket) setreqid(id []byte)     { p.ReqID = id }
+func (*v5TopicQuery) name() string         { return "TOPICQUERY/v5" }
+func (*v5TopicQuery) kind() byte           { return v5TopicQueryMsg }
+func (p *v5TopicQuery) reqid() []byte      { return p.ReqID }
+func (p *v5TopicQuery) setreqid(id []byte) { p.ReqID = id }
+
+// decodeV5Message decodes the plaintext of a discovery v5 message.
+func decodeV5Message(ptype byte, body []byte) (v5Packet, error) {
+	var p v5Packet
+	switch ptype {
+	case v5PingMsg:
+		p = new(v5Ping)
+	case v5PongMsg:
+		p = new(v5Pong)
+	case v5FindnodeMsg:
+		p = new(v5Findnode)
+	case v5NodesMsg:
+		p = new(v5Nodes)
+	case v5RegtopicMsg:
+		p = new(v5Regtopic)
+	case v5TicketMsg:
+		p = new(v5Ticket)
+	case v5TopicQueryMsg:
+		p = new(v5TopicQuery)
+	default:
+		return nil, fmt.Errorf("unknown message type %d", ptype)
+	}
+	if err := rlp.DecodeBytes(body, p); err != nil {
+		return nil, err
+	}
+	if len(p.reqid()) > 8 {
+		return nil, errors.New("request ID too long")
+	}
+	return p, nil
+}
+
+// v5SessionID identifies a session with a remote node.
+type v5SessionID struct {
+	id   enode.ID
+	addr string
+}
+
+// v5Session holds the keys of an established session.
+type v5Session struct {
+	writeKey     []byte
+	readKey      []byte
+	nonceCounter uint32
+}
+
+// v5Codec encodes and decodes discovery v5 packets, keeping track of the
+// established sessions and of the pending handshakes.
+type v5Codec struct {
+	localnode *enode.LocalNode
+	privkey   *ecdsa.PrivateKey
+	schemes   enr.IdentityScheme
+
+	sessions   *simplelru.LRU               // established sessions, keyed by v5SessionID
+	handshakes map[v5SessionID]*v5Whoareyou // sent challenges awaiting a handshake
+}
+
+// newV5Codec creates a discovery v5 packet codec for the given local node.
+func newV5Codec(ln *enode.LocalNode, key *ecdsa.PrivateKey, schemes enr.IdentityScheme) *v5Codec {
+	sessions, _ := simplelru.NewLRU(sessionCacheSize, nil)
+	return &v5Codec{
+		localnode:  ln,
+		privkey:    key,
+		schemes:    schemes,
+		sessions:   sessions,
+		handshakes: make(map[v5SessionID]*v5Whoareyou),
+	}
+}
+
+// session retrieves the established session with a remote node.
+func (c *v5Codec) session(id enode.ID, addr string) *v5Session {
+	if s, ok := c.sessions.Get(v5SessionID{id, addr}); ok {
+		return s.(*v5Session)
+	}
+	return nil
+}
+
+// storeSentHandshake remembers a sent challenge until the handshake completes or
+// the challenge expires.
+func (c *v5Codec) storeSentHandshake(id enode.ID, addr string, challenge *v5Whoareyou) {
+	if len(c.handshakes) >= maxSentHandshakes {
+		for key, ch := range c.handshakes {
+			if time.Since(ch.sent) > handshakeTimeout {
+				delete(c.handshakes, key)
+			}
+		}
+		if len(c.handshakes) >= maxSentHandshakes {
+			return
+		}
+	}
+	challenge.sent = time.Now()
+	c.handshakes[v5SessionID{id, addr}] = challenge
+}
+
+// encode encodes a packet to the given node. If a challenge is given, a handshake
+// packet establishing a new session is created. If there is no session with the
+// node, a random packet is sent to make it challenge us.
+func (c *v5Codec) encode(id enode.ID, addr string, p v5Packet, challenge *v5Whoareyou) ([]byte, v5Nonce, error) {
+	if w, ok := p.(*v5Whoareyou); ok {
+		return c.encodeWhoareyou(id, addr, w)
+	}
+	if challenge != nil {
+		return c.encodeHandshake(id, addr, p, challenge)
+	}
+	if s := c.session(id, addr); s != nil {
+		return c.encodeMessage(id, s, p)
+	}
+	return c.encodeRandom(id)
+}
+
+// encodeWhoareyou encodes a handshake challenge, remembering it to verify the
+// handshake response.
+func (c *v5Codec) encodeWhoareyou(id enode.ID, addr string, w *v5Whoareyou) ([]byte, v5Nonce, error) {
+	auth := make([]byte, idNonceSize+8)
+	copy(auth, w.IDNonce[:])
+	binary.BigEndian.PutUint64(auth[idNonceSize:], w.RecordSeq)
+
+	packet, header, err := c.encodeHeader(id, flagWhoareyou, w.Nonce, auth)
+	if err != nil {
+		return nil, w.Nonce, err
+	}
+	w.challengeData = header
+	c.storeSentHandshake(id, addr, w)
+	return packet, w.Nonce, nil
+}
+
+// encodeHandshake encodes a message in a handshake packet, deriving the keys of a
+// new session from the received challenge.
+func (c *v5Codec) encodeHandshake(id enode.ID, addr string, p v5Packet, challenge *v5Whoareyou) ([]byte, v5Nonce, error) {
+	var nonce v5Nonce
+	if challenge.node == nil {
+		return nil, nonce, errors.New("handshake with unknown node")
+	}
+	remote := challenge.node.Pubkey()
+	if remote == nil {
+		return nil, nonce, errors.New("handshake with node without secp256k1 key")
+	}
+	ephkey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, nonce, err
+	}
+	ephpub := crypto.CompressPubkey(&ephkey.PublicKey)
+
+	// Sign the identity proof and derive the session keys
+	sig, err := crypto.Sign(idProofHash(challenge.challengeData, ephpub, id), c.privkey)
+	if err != nil {
+		return nil, nonce, err
+	}
+	sig = sig[:len(sig)-1] // remove v
+
+	self := c.localnode.Node()
+	session := deriveSessionKeys(ephkey, remote, self.ID(), id, challenge.challengeData)
+
+	// Assemble the authdata, including our record if the remote doesn't have it
+	auth := new(bytes.Buffer)
+	auth.Write(self.ID().Bytes())
+	auth.WriteByte(byte(len(sig)))
+	auth.WriteByte(byte(len(ephpub)))
+	auth.Write(sig)
+	auth.Write(ephpub)
+	if challenge.RecordSeq < self.Seq() {
+		if err := rlp.Encode(auth, self.Record()); err != nil {
+			return nil, nonce, err
+		}
+	}
+	packet, nonce, err := c.encodeSealed(id, flagHandshake, auth.Bytes(), session, p)
+	if err != nil {
+		return nil, nonce, err
+	}
+	c.sessions.Add(v5SessionID{id, addr}, session)
+	return packet, nonce, nil
+}
+
+// encodeMessage encodes a message within an established session.
+func (c *v5Codec) encodeMessage(id enode.ID, s *v5Session, p v5Packet) ([]byte, v5Nonce, error) {
+	return c.encodeSealed(id, flagMessage, c.localnode.ID().Bytes(), s, p)
+}
+
+// encodeRandom encodes a dummy message which the recipient cannot decrypt, making
+// it respond with a handshake challenge.
+func (c *v5Codec) encodeRandom(id enode.ID) ([]byte, v5Nonce, error) {
+	var nonce v5Nonce
+	if _, err := crand.Read(nonce[:]); err != nil {
+		return nil, nonce, err
+	}
+	packet, _, err := c.encodeHeader(id, flagMessage, nonce, c.localnode.ID().Bytes())
+	if err != nil {
+		return nil, nonce, err
+	}
+	msg := make([]byte, randomPacketMsgSize)
+	crand.Read(msg)
+	return append(packet, msg...), nonce, nil
+}
+
+// encodeSealed encodes the header of a packet and appends the encrypted message.
+func (c *v5Codec) encodeSealed(id enode.ID, flag byte, auth []byte, s *v5Session, p v5Packet) ([]byte, v5Nonce, error) {
+	var nonce v5Nonce
+	s.nonceCounter++
+	binary.BigEndian.PutUint32(nonce[:4], s.nonceCounter)
+	if _, err := crand.Read(nonce[4:]); err != nil {
+		return nil, nonce, err
+	}
+	packet, header, err := c.encodeHeader(id, flag, nonce, auth)
+	if err != nil {
+		return nil, nonce, err
+	}
+	body, err := rlp.EncodeToBytes(p)
+	if err != nil {
+		return nil, nonce, err
+	}
+	aead, err := newGCM(s.writeKey)
+	if err != nil {
+		return nil, nonce, err
+	}
+	packet = aead.Seal(packet, nonce[:], append([]byte{p.kind()}, body...), header)
+	if len(packet) > maxV5PacketSize {
+		return nil, nonce, fmt.Errorf("packet too large (%d bytes)", len(packet))
+	}
+	return packet, nonce, nil
+}
+
+// encodeHeader creates the masked header of a packet. It returns the packet so
+// far and the unmasked header, which is authenticated with the message.
+func (c *v5Codec) encodeHeader(id enode.ID, flag byte, nonce v5Nonce, auth []byte) ([]byte, []byte, error) {
+	if len(auth) > int(^uint16(0)) {
+		return nil, nil, errInvalidAuthSize
+	}
+	header := new(bytes.Buffer)
+	header.Grow(maskingIVSize + staticHeaderSize + len(auth))
+
+	iv := make([]byte, maskingIVSize)
+	if _, err := crand.Read(iv); err != nil {
+		return nil, nil, err
+	}
+	header.Write(iv)
+	binary.Write(header, binary.BigEndian, &v5StaticHeader{
+		ProtocolID: v5ProtocolID,
+		Version:    v5Version,
+		Flag:       flag,
+		Nonce:      nonce,
+		AuthSize:   uint16(len(auth)),
+	})
+	header.Write(auth)
+
+	plain := header.Bytes()
+	packet := make([]byte, len(plain))
+	copy(packet, plain[:maskingIVSize])
+	newMasking(id, iv).XORKeyStream(packet[maskingIVSize:], plain[maskingIVSize:])
+	return packet, plain, nil
+}
+
+// decode decodes a packet received from the given address. It returns the ID of
+// the sender and, if the packet completed a handshake, its node record.
+func (c *v5Codec) decode(input []byte, addr string) (enode.ID, *enode.Node, v5Packet, error) {
+	if len(input) < minV5PacketSize {
+		return enode.ID{}, nil, nil, errTooShort
+	}
+	// Unmask and check the static header
+	iv := input[:maskingIVSize]
+	mask := newMasking(c.localnode.ID(), iv)
+
+	header := make([]byte, maskingIVSize+staticHeaderSize, maskingIVSize+staticHeaderSize+64)
+	copy(header, iv)
+	mask.XORKeyStream(header[maskingIVSize:], input[maskingIVSize:maskingIVSize+staticHeaderSize])
+
+	var static v5StaticHeader
+	binary.Read(bytes.NewReader(header[maskingIVSize:]), binary.BigEndian, &static)
+	if static.ProtocolID != v5ProtocolID || static.Version != v5Version {
+		return enode.ID{}, nil, nil, errInvalidHeader
+	}
+	authEnd := maskingIVSize + staticHeaderSize + int(static.AuthSize)
+	if authEnd > len(input) {
+		return enode.ID{}, nil, nil, errInvalidAuthSize
+	}
+	// Unmask the authdata and process the packet based on its kind
+	auth := make([]byte, static.AuthSize)
+	mask.XORKeyStream(auth, input[maskingIVSize+staticHeaderSize:authEnd])
+	header = append(header, auth...)
+	msg := input[authEnd:]
+
+	switch static.Flag {
+	case flagWhoareyou:
+		p, err := c.decodeWhoareyou(&static, auth, header)
+		return enode.ID{}, nil, p, err
+	case flagHandshake:
+		return c.decodeHandshake(&static, auth, header, msg, addr)
+	case flagMessage:
+		id, p, err := c.decodeMessage(&static, auth, header, msg, addr)
+		return id, nil, p, err
+	default:
+		return enode.ID{}, nil, nil, fmt.Errorf("unknown packet flag %d", static.Flag)
+	}
+}
+
+// decodeWhoareyou decodes a handshake challenge.
+func (c *v5Codec) decodeWhoareyou(static *v5StaticHeader, auth, header []byte) (v5Packet, error) {
+	if len(auth) != idNonceSize+8 {
+		return nil, errInvalidAuthSize
+	}
+	p := &v5Whoareyou{
+		Nonce:         static.Nonce,
+		RecordSeq:     binary.BigEndian.Uint64(auth[idNonceSize:]),
+		challengeData: header,
+	}
+	copy(p.IDNonce[:], auth)
+	return p, nil
+}
+
+// decodeHandshake verifies a handshake response to a challenge sent earlier,
+// establishing a new session and decrypting the message within.
+func (c *v5Codec) decodeHandshake(static *v5StaticHeader, auth, header, msg []byte, addr string) (enode.ID, *enode.Node, v5Packet, error) {
+	if len(auth) < len(enode.ID{})+2 {
+		return enode.ID{}, nil, nil, errInvalidAuthSize
+	}
+	var src enode.ID
+	copy(src[:], auth)
+	sigsize, keysize := int(auth[len(src)]), int(auth[len(src)+1])
+	rest := auth[len(src)+2:]
+	if len(rest) < sigsize+keysize {
+		return src, nil, nil, errInvalidAuthSize
+	}
+	sig, ephpub, rest := rest[:sigsize], rest[sigsize:sigsize+keysize], rest[sigsize+keysize:]
+
+	// Ensure we challenged the sender. The challenge is kept until the handshake
+	// is verified, a spoofed response must not cancel it.
+	key := v5SessionID{src, addr}
+	challenge := c.handshakes[key]
+	if challenge == nil {
+		return src, nil, nil, errUnexpectedHandshake
+	}
+	if time.Since(challenge.sent) > handshakeTimeout {
+		delete(c.handshakes, key)
+		return src, nil, nil, errHandshakeExpired
+	}
+	// Retrieve the sender's record, either attached or known from before
+	node := challenge.node
+	if len(rest) > 0 {
+		var record enr.Record
+		if err := rlp.DecodeBytes(rest, &record); err != nil {
+			return src, nil, nil, err
+		}
+		n, err := enode.New(c.schemes, &record)
+		if err != nil {
+			return src, nil, nil, err
+		}
+		if node == nil || node.Seq() < n.Seq() {
+			node = n
+		}
+	}
+	if node == nil {
+		return src, nil, nil, errNoRecord
+	}
+	if node.ID() != src {
+		return src, nil, nil, errRecordMismatch
+	}
+	// Verify the identity proof and derive the session keys
+	remote := node.Pubkey()
+	if remote == nil || !crypto.VerifySignature(crypto.FromECDSAPub(remote), idProofHash(challenge.challengeData, ephpub, c.localnode.ID()), sig) {
+		return src, nil, nil, errInvalidIDSignature
+	}
+	ephkey, err := crypto.DecompressPubkey(ephpub)
+	if err != nil {
+		return src, nil, nil, err
+	}
+	session := deriveSessionKeys(c.privkey, ephkey, src, c.localnode.ID(), challenge.challengeData)
+	session.writeKey, session.readKey = session.readKey, session.writeKey
+
+	p, err := decodeSealed(session, static.Nonce, header, msg)
+	if err != nil {
+		return src, nil, nil, err
+	}
+	delete(c.handshakes, key)
+	c.sessions.Add(key, session)
+	return src, node, p, nil
+}
+
+// decodeMessage decrypts a message received within an established session. If
+// there is no such session, or decryption fails, a v5Unknown packet is returned.
+func (c *v5Codec) decodeMessage(static *v5StaticHeader, auth, header, msg []byte, addr string) (enode.ID, v5Packet, error) {
+	if len(auth) != len(enode.ID{}) {
+		return enode.ID{}, nil, errInvalidAuthSize
+	}
+	var src enode.ID
+	copy(src[:], auth)
+
+	s := c.session(src, addr)
+	if s == nil {
+		return src, &v5Unknown{Nonce: static.Nonce}, nil
+	}
+	p, err := decodeSealed(s, static.Nonce, header, msg)
+	if err == errMessageDecrypt {
+		// The remote may have lost the session, make it redo the handshake
+		c.sessions.Remove(v5SessionID{src, addr})
+		return src, &v5Unknown{Nonce: static.Nonce}, nil
+	}
+	return src, p, err
+}
+
+// decodeSealed decrypts and decodes the message of a packet.
+func decodeSealed(s *v5Session, nonce v5Nonce, header, msg []byte) (v5Packet, error) {
+	aead, err := newGCM(s.readKey)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, nonce[:], msg, header)
+	if err != nil {
+		return nil, errMessageDecrypt
+	}
+	if len(plain) == 0 {
+		return nil, errMessageTooShort
+	}
+	return decodeV5Message(plain[0], plain[1:])
+}
+
+// idProofHash computes the hash signed in the identity proof of a handshake.
+func idProofHash(challenge, ephpub []byte, dest enode.ID) []byte {
+	h := sha256.New()
+	h.Write(identityProofPrefix)
+	h.Write(challenge)
+	h.Write(ephpub)
+	h.Write(dest[:])
+	return h.Sum(nil)
+}
+
+// deriveSessionKeys derives the keys of a session between the initiator n1 and the
+// recipient n2 of a handshake. The write key of the returned session is the one
+// of the initiator.
+func deriveSessionKeys(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey, n1, n2 enode.ID, challenge []byte) *v5Session {
+	info := make([]byte, 0, len(keyAgreementPrefix)+2*len(n1))
+	info = append(info, keyAgreementPrefix...)
+	info = append(info, n1[:]...)
+	info = append(info, n2[:]...)
+
+	// HKDF-SHA256 with the challenge as salt, a single expansion round suffices
+	extract := hmac.New(sha256.New, challenge)
+	extract.Write(ecdh(priv, pub))
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{0x01})
+	keys := expand.Sum(nil)
+
+	return &v5Session{
+		writeKey: keys[:sessionKeySize],
+		readKey:  keys[sessionKeySize : 2*sessionKeySize],
+	}
+}
+
+// ecdh computes the compressed shared point of a secp256k1 key agreement.
+func ecdh(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) []byte {
+	x, y := pub.Curve.ScalarMult(pub.X, pub.Y, math.PaddedBigBytes(priv.D, 32))
+	if x == nil {
+		return nil
+	}
+	secret := make([]byte, 33)
+	secret[0] = 0x02 | byte(y.Bit(0))
+	math.ReadBits(x, secret[1:])
+	return secret
+}
+
+// newMasking creates the cipher stream masking the header of packets sent to the
+// given node.
+func newMasking(dest enode.ID, iv []byte) cipher.Stream {
+	block, err := aes.NewCipher(dest[:16])
+	if err != nil {
+		panic("can't create masking cipher: " + err.Error())
+	}
+	return cipher.NewCTR(block, iv)
+}
+
+// newGCM creates the authenticated cipher of a session key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
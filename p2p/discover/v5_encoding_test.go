@@ -0,0 +1,220 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/ecdsa"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+)
+
+// handshakeTest is a pair of codecs exchanging packets.
+type handshakeTest struct {
+	t          *testing.T
+	nodeA      *enode.LocalNode
+	nodeB      *enode.LocalNode
+	codecA     *v5Codec
+	codecB     *v5Codec
+	addrA      string
+	addrB      string
+	keyA, keyB *ecdsa.PrivateKey
+}
+
+func newHandshakeTest(t *testing.T) *handshakeTest {
+	test := &handshakeTest{t: t, addrA: "127.0.0.1:30301", addrB: "127.0.0.1:30302"}
+	test.keyA, _ = crypto.GenerateKey()
+	test.keyB, _ = crypto.GenerateKey()
+	test.nodeA = newTestLocalNode(test.keyA, 30301)
+	test.nodeB = newTestLocalNode(test.keyB, 30302)
+	test.codecA = newV5Codec(test.nodeA, test.keyA, enode.ValidSchemes)
+	test.codecB = newV5Codec(test.nodeB, test.keyB, enode.ValidSchemes)
+	return test
+}
+
+func newTestLocalNode(key *ecdsa.PrivateKey, port int) *enode.LocalNode {
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, key)
+	ln.SetStaticIP(net.IP{127, 0, 0, 1})
+	ln.SetFallbackUDP(port)
+	return ln
+}
+
+// atoB sends a packet from A to B, returning what B decoded.
+func (test *handshakeTest) atoB(p v5Packet, challenge *v5Whoareyou) (enode.ID, *enode.Node, v5Packet, error) {
+	enc, _, err := test.codecA.encode(test.nodeB.ID(), test.addrB, p, challenge)
+	if err != nil {
+		test.t.Fatalf("can't encode %s: %v", p.name(), err)
+	}
+	return test.codecB.decode(enc, test.addrA)
+}
+
+// btoA sends a packet from B to A, returning what A decoded.
+func (test *handshakeTest) btoA(p v5Packet) (enode.ID, *enode.Node, v5Packet, error) {
+	enc, _, err := test.codecB.encode(test.nodeA.ID(), test.addrA, p, nil)
+	if err != nil {
+		test.t.Fatalf("can't encode %s: %v", p.name(), err)
+	}
+	return test.codecA.decode(enc, test.addrB)
+}
+
+// handshake performs the handshake of A with B, sending the given packet.
+func (test *handshakeTest) handshake(p v5Packet) v5Packet {
+	// A has no session, the random packet makes B challenge A
+	_, _, unknown, err := test.atoB(p, nil)
+	if err != nil {
+		test.t.Fatalf("can't decode random packet: %v", err)
+	}
+	if _, ok := unknown.(*v5Unknown); !ok {
+		test.t.Fatalf("wrong packet for random packet: %s", unknown.name())
+	}
+	challenge := &v5Whoareyou{
+		Nonce:     unknown.(*v5Unknown).Nonce,
+		IDNonce:   [idNonceSize]byte{1, 2, 3},
+		RecordSeq: 0,
+	}
+	_, _, packet, err := test.btoA(challenge)
+	if err != nil {
+		test.t.Fatalf("can't decode challenge: %v", err)
+	}
+	whoareyou, ok := packet.(*v5Whoareyou)
+	if !ok {
+		test.t.Fatalf("wrong packet for challenge: %s", packet.name())
+	}
+	if whoareyou.Nonce != challenge.Nonce || whoareyou.IDNonce != challenge.IDNonce {
+		test.t.Fatalf("challenge mismatch: have %+v, want %+v", whoareyou, challenge)
+	}
+	// A responds to the challenge with a handshake
+	whoareyou.node = test.nodeB.Node()
+	from, node, packet, err := test.atoB(p, whoareyou)
+	if err != nil {
+		test.t.Fatalf("can't decode handshake: %v", err)
+	}
+	if from != test.nodeA.ID() {
+		test.t.Fatalf("wrong handshake sender: have %v, want %v", from, test.nodeA.ID())
+	}
+	if node == nil || node.ID() != test.nodeA.ID() || node.Seq() != test.nodeA.Node().Seq() {
+		test.t.Fatalf("wrong node in handshake: %v", node)
+	}
+	return packet
+}
+
+func TestV5Encoding_handshake(t *testing.T) {
+	test := newHandshakeTest(t)
+
+	ping := &v5Ping{ReqID: []byte("reqid"), ENRSeq: 5}
+	if packet := test.handshake(ping); !reflect.DeepEqual(packet, ping) {
+		t.Fatalf("wrong packet in handshake: have %+v, want %+v", packet, ping)
+	}
+	// Both sides can exchange messages within the session now
+	pong := &v5Pong{ReqID: []byte("reqid"), ENRSeq: 7, ToIP: net.IP{127, 0, 0, 1}, ToPort: 30301}
+	from, _, packet, err := test.btoA(pong)
+	if err != nil {
+		t.Fatalf("can't decode message: %v", err)
+	}
+	if from != test.nodeB.ID() || !reflect.DeepEqual(packet, pong) {
+		t.Fatalf("wrong message: from %v, have %+v, want %+v", from, packet, pong)
+	}
+	findnode := &v5Findnode{ReqID: []byte("reqid2"), Distances: []uint{255, 256}}
+	from, _, packet, err = test.atoB(findnode, nil)
+	if err != nil {
+		t.Fatalf("can't decode message: %v", err)
+	}
+	if from != test.nodeA.ID() || !reflect.DeepEqual(packet, findnode) {
+		t.Fatalf("wrong message: from %v, have %+v, want %+v", from, packet, findnode)
+	}
+}
+
+// This test checks that handshakes are only accepted in response to a challenge
+// sent to the same address.
+func TestV5Encoding_unexpectedHandshake(t *testing.T) {
+	test := newHandshakeTest(t)
+
+	challenge := &v5Whoareyou{IDNonce: [idNonceSize]byte{1}, node: test.nodeB.Node()}
+	if _, _, _, err := test.atoB(&v5Ping{}, challenge); err != errUnexpectedHandshake {
+		t.Fatalf("wrong error for unsolicited handshake: %v", err)
+	}
+}
+
+// This test checks that a handshake response with an invalid identity proof does
+// not cancel the pending challenge.
+func TestV5Encoding_spoofedHandshake(t *testing.T) {
+	test := newHandshakeTest(t)
+
+	_, _, unknown, err := test.atoB(&v5Ping{}, nil)
+	if err != nil {
+		t.Fatalf("can't decode random packet: %v", err)
+	}
+	challenge := &v5Whoareyou{Nonce: unknown.(*v5Unknown).Nonce, IDNonce: [idNonceSize]byte{1}}
+	_, _, packet, err := test.btoA(challenge)
+	if err != nil {
+		t.Fatalf("can't decode challenge: %v", err)
+	}
+	whoareyou := packet.(*v5Whoareyou)
+	whoareyou.node = test.nodeB.Node()
+
+	// The spoofer claims the identity of A, but can't sign with A's key.
+	spoofKey, _ := crypto.GenerateKey()
+	spoofer := newV5Codec(test.nodeA, spoofKey, enode.ValidSchemes)
+	enc, _, err := spoofer.encode(test.nodeB.ID(), test.addrB, &v5Ping{}, whoareyou)
+	if err != nil {
+		t.Fatalf("can't encode spoofed handshake: %v", err)
+	}
+	if _, _, _, err := test.codecB.decode(enc, test.addrA); err != errInvalidIDSignature {
+		t.Fatalf("wrong error for spoofed handshake: %v", err)
+	}
+	// The real handshake still succeeds.
+	if _, _, _, err := test.atoB(&v5Ping{}, whoareyou); err != nil {
+		t.Fatalf("can't decode handshake after spoofed one: %v", err)
+	}
+}
+
+// This test checks that messages which can't be decrypted, for example after the
+// session was lost on one side, are reported as unknown and trigger a new
+// handshake.
+func TestV5Encoding_lostSession(t *testing.T) {
+	test := newHandshakeTest(t)
+	test.handshake(&v5Ping{})
+
+	test.codecB.sessions.Purge()
+	_, _, packet, err := test.atoB(&v5Ping{}, nil)
+	if err != nil {
+		t.Fatalf("can't decode message: %v", err)
+	}
+	if _, ok := packet.(*v5Unknown); !ok {
+		t.Fatalf("wrong packet for message without session: %s", packet.name())
+	}
+}
+
+func TestV5Encoding_invalidPackets(t *testing.T) {
+	test := newHandshakeTest(t)
+
+	if _, _, _, err := test.codecB.decode(make([]byte, minV5PacketSize-1), test.addrA); err != errTooShort {
+		t.Errorf("wrong error for short packet: %v", err)
+	}
+	if _, _, _, err := test.codecB.decode(make([]byte, 100), test.addrA); err != errInvalidHeader {
+		t.Errorf("wrong error for packet without header: %v", err)
+	}
+	// Packets masked for another node have no valid header
+	enc, _, _ := test.codecA.encode(test.nodeA.ID(), test.addrA, &v5Ping{}, nil)
+	if _, _, _, err := test.codecB.decode(enc, test.addrA); err != errInvalidHeader {
+		t.Errorf("wrong error for packet to other node: %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common/mclock"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+	"github.com/etvchaineum/go-etvchaineum/rlp"
+)
+
+const (
+	topicAdLifetime    = 15 * time.Minute // time an advertisement stays in the topic table
+	maxTopicAds        = 5000             // maximum number of advertisements stored
+	maxAdsPerTopic     = 100              // maximum number of advertisements of a single topic
+	maxTopicQueryNodes = 16               // maximum number of nodes returned by a topic query
+)
+
+var (
+	errTicketInvalid = errors.New("invalid ticket")
+	errTicketEarly   = errors.New("ticket used too early")
+)
+
+// Topic is the name of a service advertised through discovery.
+type Topic string
+
+// hash returns the ID around which the advertisements of the topic are placed.
+func (t Topic) hash() enode.ID {
+	return enode.ID(crypto.Keccak256Hash([]byte(t)))
+}
+
+// topicAd is an advertisement of a node for a topic.
+type topicAd struct {
+	node    *enode.Node
+	expires mclock.AbsTime
+}
+
+// topicTicket is issued to a registrant when the topic table has no room for its
+// advertisement, reserving the next free slot for the first registrant coming back
+// once it is available.
+type topicTicket struct {
+	Topic []byte
+	Node  enode.ID
+	IP    net.IP
+	Wait  uint64 // clock time after which the ticket can be used
+}
+
+// topicTable stores the topic advertisements placed at the local node. When the
+// table is full, registrants receive tickets which have to be presented again once
+// room becomes available.
+type topicTable struct {
+	clock  mclock.Clock
+	secret []byte // key authenticating the issued tickets
+	ads    map[Topic][]*topicAd
+	count  int
+}
+
+// newTopicTable creates an empty topic table.
+func newTopicTable(clock mclock.Clock) *topicTable {
+	secret := make([]byte, 16)
+	crand.Read(secret)
+	return &topicTable{
+		clock:  clock,
+		secret: secret,
+		ads:    make(map[Topic][]*topicAd),
+	}
+}
+
+// expire drops the expired advertisements.
+func (tt *topicTable) expire() {
+	now := tt.clock.Now()
+	for topic, ads := range tt.ads {
+		i := 0
+		for i < len(ads) && ads[i].expires <= now {
+			i++
+		}
+		tt.count -= i
+		if i == len(ads) {
+			delete(tt.ads, topic)
+		} else {
+			tt.ads[topic] = ads[i:]
+		}
+	}
+}
+
+// register attempts to place the advertisement of a node for a topic, returning
+// zero if it succeeded. Otherwise, the registrant has to present the returned
+// ticket again after the returned wait time.
+func (tt *topicTable) register(topic Topic, node *enode.Node, ip net.IP, ticket []byte) (time.Duration, []byte, error) {
+	tt.expire()
+	now := tt.clock.Now()
+
+	// Refresh the advertisement if the node is already registered
+	ads := tt.ads[topic]
+	for i, ad := range ads {
+		if ad.node.ID() == node.ID() {
+			ads = append(ads[:i:i], ads[i+1:]...)
+			tt.ads[topic] = append(ads, &topicAd{node: node, expires: now.Add(topicAdLifetime)})
+			return 0, nil, nil
+		}
+	}
+	// Validate the ticket presented, if any
+	if len(ticket) > 0 {
+		t, err := tt.decodeTicket(ticket)
+		if err != nil {
+			return 0, nil, err
+		}
+		if Topic(t.Topic) != topic || t.Node != node.ID() || !t.IP.Equal(ip) {
+			return 0, nil, errTicketInvalid
+		}
+		if wait := mclock.AbsTime(t.Wait); now < wait {
+			return time.Duration(wait - now), ticket, errTicketEarly
+		}
+	}
+	// Place the advertisement if there is room, or issue a ticket for the time
+	// the next slot frees up
+	if len(ads) < maxAdsPerTopic && tt.count < maxTopicAds {
+		tt.ads[topic] = append(ads, &topicAd{node: node, expires: now.Add(topicAdLifetime)})
+		tt.count++
+		return 0, nil, nil
+	}
+	next := tt.nextExpiry(topic)
+	ticket, err := tt.encodeTicket(&topicTicket{
+		Topic: []byte(topic),
+		Node:  node.ID(),
+		IP:    ip,
+		Wait:  uint64(next),
+	})
+	return time.Duration(next - now), ticket, err
+}
+
+// nextExpiry returns the time at which a slot for the topic frees up.
+func (tt *topicTable) nextExpiry(topic Topic) mclock.AbsTime {
+	if ads := tt.ads[topic]; len(ads) >= maxAdsPerTopic {
+		return ads[0].expires
+	}
+	var next mclock.AbsTime
+	for _, ads := range tt.ads {
+		if next == 0 || ads[0].expires < next {
+			next = ads[0].expires
+		}
+	}
+	return next
+}
+
+// query returns the nodes advertising a topic, the most recent ones first.
+func (tt *topicTable) query(topic Topic) []*enode.Node {
+	tt.expire()
+
+	ads := tt.ads[topic]
+	nodes := make([]*enode.Node, 0, maxTopicQueryNodes)
+	for i := len(ads) - 1; i >= 0 && len(nodes) < maxTopicQueryNodes; i-- {
+		nodes = append(nodes, ads[i].node)
+	}
+	return nodes
+}
+
+// encodeTicket serializes and authenticates a ticket.
+func (tt *topicTable) encodeTicket(t *topicTicket) ([]byte, error) {
+	blob, err := rlp.EncodeToBytes(t)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, tt.secret)
+	mac.Write(blob)
+	return mac.Sum(blob), nil
+}
+
+// decodeTicket authenticates and deserializes a ticket issued by the table.
+func (tt *topicTable) decodeTicket(ticket []byte) (*topicTicket, error) {
+	if len(ticket) <= sha256.Size {
+		return nil, errTicketInvalid
+	}
+	blob, sum := ticket[:len(ticket)-sha256.Size], ticket[len(ticket)-sha256.Size:]
+
+	mac := hmac.New(sha256.New, tt.secret)
+	mac.Write(blob)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, errTicketInvalid
+	}
+	t := new(topicTicket)
+	if err := rlp.Decode(bytes.NewReader(blob), t); err != nil {
+		return nil, errTicketInvalid
+	}
+	return t, nil
+}
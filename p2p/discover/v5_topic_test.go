@@ -0,0 +1,108 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common/mclock"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+)
+
+func TestTopicTable_registerQuery(t *testing.T) {
+	clock := new(mclock.Simulated)
+	tt := newTopicTable(clock)
+	ip := net.IP{127, 0, 0, 1}
+
+	var nodes []*enode.Node
+	for i := 0; i < 3; i++ {
+		n := unwrapNode(nodeAtDistance(enode.ID{}, 256, intIP(i)))
+		nodes = append(nodes, n)
+		if wait, ticket, err := tt.register("foo", n, ip, nil); wait != 0 || ticket != nil || err != nil {
+			t.Fatalf("registration %d failed: wait %v, ticket %x, err %v", i, wait, ticket, err)
+		}
+		clock.Run(time.Minute)
+	}
+	// Queries return the most recent advertisements first
+	if found := tt.query("foo"); len(found) != 3 || found[0] != nodes[2] || found[2] != nodes[0] {
+		t.Fatalf("wrong query result: %v", found)
+	}
+	if found := tt.query("bar"); len(found) != 0 {
+		t.Fatalf("query of unknown topic found %d nodes", len(found))
+	}
+	// Advertisements expire unless refreshed
+	tt.register("foo", nodes[0], ip, nil)
+	clock.Run(topicAdLifetime - time.Minute)
+	if found := tt.query("foo"); len(found) != 1 || found[0] != nodes[0] {
+		t.Fatalf("wrong query result after expiry: %v", found)
+	}
+	clock.Run(time.Minute)
+	if found := tt.query("foo"); len(found) != 0 {
+		t.Fatalf("expired advertisements found: %v", found)
+	}
+}
+
+func TestTopicTable_tickets(t *testing.T) {
+	clock := new(mclock.Simulated)
+	tt := newTopicTable(clock)
+	ip := net.IP{127, 0, 0, 1}
+
+	// Fill the table for the topic
+	for i := 0; i < maxAdsPerTopic; i++ {
+		n := unwrapNode(nodeAtDistance(enode.ID{}, 256, intIP(i)))
+		if wait, _, err := tt.register("foo", n, ip, nil); wait != 0 || err != nil {
+			t.Fatalf("registration %d failed: wait %v, err %v", i, wait, err)
+		}
+		clock.Run(time.Second)
+	}
+	// Further registrants receive a ticket for the first expiry
+	n := unwrapNode(nodeAtDistance(enode.ID{}, 255, intIP(1000)))
+	wait, ticket, err := tt.register("foo", n, ip, nil)
+	if err != nil || ticket == nil {
+		t.Fatalf("no ticket issued: err %v", err)
+	}
+	if want := topicAdLifetime - maxAdsPerTopic*time.Second; wait != want {
+		t.Fatalf("wrong ticket wait time: have %v, want %v", wait, want)
+	}
+	// Tickets are bound to the registrant and can't be used early
+	other := unwrapNode(nodeAtDistance(enode.ID{}, 254, intIP(1001)))
+	if _, _, err := tt.register("foo", other, ip, ticket); err != errTicketInvalid {
+		t.Fatalf("wrong error for ticket of other node: %v", err)
+	}
+	if _, _, err := tt.register("bar", n, ip, ticket); err != errTicketInvalid {
+		t.Fatalf("wrong error for ticket of other topic: %v", err)
+	}
+	forged := append([]byte{}, ticket...)
+	forged[0]++
+	if _, _, err := tt.register("foo", n, ip, forged); err != errTicketInvalid {
+		t.Fatalf("wrong error for forged ticket: %v", err)
+	}
+	clock.Run(wait / 2)
+	if early, _, err := tt.register("foo", n, ip, ticket); err != errTicketEarly || early != wait-wait/2 {
+		t.Fatalf("wrong result for early ticket: wait %v, err %v", early, err)
+	}
+	// Once the slot is free, the ticket is accepted
+	clock.Run(wait - wait/2)
+	if wait, _, err := tt.register("foo", n, ip, ticket); wait != 0 || err != nil {
+		t.Fatalf("registration with ticket failed: wait %v, err %v", wait, err)
+	}
+	if found := tt.query("foo"); found[0] != n {
+		t.Fatalf("ticket holder not advertised")
+	}
+}
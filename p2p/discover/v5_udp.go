@@ -0,0 +1,1054 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common/mclock"
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enr"
+	"github.com/etvchaineum/go-etvchaineum/p2p/netutil"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	v5RespTimeout           = 700 * time.Millisecond
+	lookupRequestLimit      = 3  // number of distances requested by a lookup query
+	findnodeResultLimit     = 16 // applies in handleFindnode
+	totalNodesResponseLimit = 5  // applies in waitForNodes
+	nodeCacheSize           = 4096
+
+	maxTopicLength        = 64
+	topicRegistrars       = 4                // number of nodes a topic is advertised at
+	topicSearchNodes      = 8                // number of nodes queried by a topic search
+	topicRegisterInterval = 10 * time.Minute // time between registration rounds
+	topicRetryInterval    = 10 * time.Second // time between rounds if no registration succeeded
+	maxTicketWait         = topicAdLifetime  // longest ticket wait period accepted
+)
+
+// Errors
+var (
+	errChallengeNoCall = errors.New("no matching call")
+	errChallengeTwice  = errors.New("second handshake")
+)
+
+// UDPv5 implements the discovery v5 UDP wire protocol. Sessions between nodes are
+// established by an encrypted handshake, after which nodes are queried for their
+// neighbors at given log distances and for the advertisers of topics.
+type UDPv5 struct {
+	// static fields
+	conn         conn
+	tab          *Table
+	netrestrict  *netutil.Netlist
+	priv         *ecdsa.PrivateKey
+	localNode    *enode.LocalNode
+	db           *enode.DB
+	validSchemes enr.IdentityScheme
+	nodes        *lru.Cache // recently seen node records, to resolve the node IDs of the table
+
+	// channels into dispatch
+	packetInCh    chan ReadPacket
+	readNextCh    chan struct{}
+	callCh        chan *v5Call
+	callDoneCh    chan *v5Call
+	respTimeoutCh chan *v5CallTimeout
+
+	// state of dispatch
+	codec            *v5Codec
+	topics           *topicTable
+	activeCallByNode map[enode.ID]*v5Call
+	activeCallByAuth map[v5Nonce]*v5Call
+	callQueue        map[enode.ID][]*v5Call
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	wg        sync.WaitGroup
+}
+
+// v5Call is a request sent to a remote node, waiting for its response.
+type v5Call struct {
+	node         *enode.Node
+	packet       v5Packet
+	responseType byte // expected packet type of response
+	reqid        []byte
+	ch           chan v5Packet // responses sent here
+	err          chan error    // errors sent here
+
+	// Valid for active calls only:
+	nonce          v5Nonce      // nonce of request packet
+	handshakeCount int          // # times we attempted handshake for this call
+	challenge      *v5Whoareyou // last sent handshake challenge
+	timeout        *time.Timer
+}
+
+// v5CallTimeout is the response timeout event of a call.
+type v5CallTimeout struct {
+	c     *v5Call
+	timer *time.Timer
+}
+
+// ListenV5 listens on the given connection for discovery v5 packets. The
+// connection may be shared with the v4 protocol, receiving the packets v4 could
+// not handle.
+func ListenV5(c conn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
+	t, err := newUDPv5(c, ln, cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.wg.Add(2)
+	go t.readLoop()
+	go t.dispatch()
+	return t, nil
+}
+
+func newUDPv5(c conn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
+	nodes, _ := lru.New(nodeCacheSize)
+	t := &UDPv5{
+		conn:         c,
+		netrestrict:  cfg.NetRestrict,
+		priv:         cfg.PrivateKey,
+		localNode:    ln,
+		db:           ln.Database(),
+		validSchemes: enode.ValidSchemes,
+		nodes:        nodes,
+		// channels into dispatch
+		packetInCh:    make(chan ReadPacket, 1),
+		readNextCh:    make(chan struct{}, 1),
+		callCh:        make(chan *v5Call),
+		callDoneCh:    make(chan *v5Call),
+		respTimeoutCh: make(chan *v5CallTimeout),
+		// state of dispatch
+		codec:            newV5Codec(ln, cfg.PrivateKey, enode.ValidSchemes),
+		topics:           newTopicTable(mclock.System{}),
+		activeCallByNode: make(map[enode.ID]*v5Call),
+		activeCallByAuth: make(map[v5Nonce]*v5Call),
+		callQueue:        make(map[enode.ID][]*v5Call),
+		closing:          make(chan struct{}),
+	}
+	for _, n := range cfg.Bootnodes {
+		t.rememberNode(n)
+	}
+	tab, err := newTable(t, t.db, cfg.Bootnodes)
+	if err != nil {
+		return nil, err
+	}
+	t.tab = tab
+	return t, nil
+}
+
+// Self returns the local node record.
+func (t *UDPv5) Self() *enode.Node {
+	return t.localNode.Node()
+}
+
+// Close shuts down the listener and the node table.
+func (t *UDPv5) Close() {
+	t.tab.Close()
+}
+
+// Ping sends a ping message to the given node and waits for the reply.
+func (t *UDPv5) Ping(n *enode.Node) error {
+	_, err := t.ping5(n)
+	return err
+}
+
+// RequestENR requests the current record of the given node.
+func (t *UDPv5) RequestENR(n *enode.Node) (*enode.Node, error) {
+	nodes, err := t.findnodeDistances(n, []uint{0})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("%d nodes in response for distance zero", len(nodes))
+	}
+	return nodes[0], nil
+}
+
+// Resolve searches for the most recent record of the given node. It returns nil
+// if the node could not be found.
+func (t *UDPv5) Resolve(n *enode.Node) *enode.Node {
+	if rn, err := t.RequestENR(n); err == nil {
+		return rn
+	}
+	for _, rn := range t.Lookup(n.ID()) {
+		if rn.ID() == n.ID() {
+			return rn
+		}
+	}
+	return nil
+}
+
+// Lookup performs a network search for the nodes closest to the given target.
+func (t *UDPv5) Lookup(target enode.ID) []*enode.Node {
+	return unwrapNodes(t.lookup(target))
+}
+
+// LookupRandom finds random nodes in the network.
+func (t *UDPv5) LookupRandom() []*enode.Node {
+	var target enode.ID
+	crand.Read(target[:])
+	return t.Lookup(target)
+}
+
+// ReadRandomNodes fills the given slice with random nodes from the table.
+func (t *UDPv5) ReadRandomNodes(buf []*enode.Node) int {
+	return t.tab.ReadRandomNodes(buf)
+}
+
+// RegisterTopic advertises the local node for the given topic at the nodes closest
+// to the topic hash, renewing the advertisements until stop is closed.
+func (t *UDPv5) RegisterTopic(topic Topic, stop <-chan struct{}) {
+	for {
+		var (
+			wg         sync.WaitGroup
+			registered int32
+		)
+		for _, n := range t.topicNodes(topic, topicRegistrars) {
+			wg.Add(1)
+			go func(n *enode.Node) {
+				defer wg.Done()
+				if t.registerAt(n, topic, stop) {
+					atomic.AddInt32(&registered, 1)
+				}
+			}(n)
+		}
+		wg.Wait()
+
+		delay := topicRegisterInterval
+		if registered == 0 {
+			delay = topicRetryInterval
+		}
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// SearchTopic searches for the nodes advertising the given topic, delivering them
+// on the found channel. A search round is started every period received on
+// setPeriod, the search ending when setPeriod is closed. After each round, whetvchain
+// any advertisers were found is reported on the lookup channel, if given.
+func (t *UDPv5) SearchTopic(topic Topic, setPeriod <-chan time.Duration, found chan<- *enode.Node, lookup chan<- bool) {
+	var period time.Duration
+	select {
+	case p, ok := <-setPeriod:
+		if !ok {
+			return
+		}
+		period = p
+	case <-t.closing:
+		return
+	}
+	next := time.NewTimer(0)
+	defer next.Stop()
+
+	for {
+		select {
+		case <-next.C:
+			if !t.searchTopic(topic, found, lookup) {
+				return
+			}
+			next.Reset(period)
+		case p, ok := <-setPeriod:
+			if !ok {
+				return
+			}
+			period = p
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// searchTopic runs a single topic search round, returning false if the listener
+// was closed meanwhile.
+func (t *UDPv5) searchTopic(topic Topic, found chan<- *enode.Node, lookup chan<- bool) bool {
+	seen := make(map[enode.ID]bool)
+	for _, n := range t.topicNodes(topic, topicSearchNodes) {
+		nodes, err := t.topicQuery(n, topic)
+		if err != nil {
+			log.Trace("Topic query failed", "topic", topic, "id", n.ID(), "err", err)
+		}
+		for _, ad := range nodes {
+			if seen[ad.ID()] || ad.ID() == t.Self().ID() {
+				continue
+			}
+			seen[ad.ID()] = true
+			select {
+			case found <- ad:
+			case <-t.closing:
+				return false
+			}
+		}
+	}
+	if lookup != nil {
+		select {
+		case lookup <- len(seen) > 0:
+		case <-t.closing:
+			return false
+		}
+	}
+	return true
+}
+
+// topicNodes returns the nodes closest to the hash of a topic, which store its
+// advertisements.
+func (t *UDPv5) topicNodes(topic Topic, n int) []*enode.Node {
+	nodes := t.Lookup(topic.hash())
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+	return nodes
+}
+
+// registerAt advertises the local node for a topic at the given node, waiting for
+// a free slot if needed. It returns whetvchain the registration succeeded.
+func (t *UDPv5) registerAt(n *enode.Node, topic Topic, stop <-chan struct{}) bool {
+	var ticket []byte
+	for {
+		resp, err := t.regtopic(n, topic, ticket)
+		if err != nil {
+			log.Debug("Topic registration failed", "topic", topic, "id", n.ID(), "err", err)
+			return false
+		}
+		if resp.WaitTime == 0 {
+			log.Trace("Registered topic", "topic", topic, "id", n.ID())
+			return true
+		}
+		wait := time.Duration(resp.WaitTime) * time.Millisecond
+		if wait > maxTicketWait {
+			log.Debug("Topic registration wait too long", "topic", topic, "id", n.ID(), "wait", wait)
+			return false
+		}
+		ticket = resp.Ticket
+
+		select {
+		case <-time.After(wait):
+		case <-stop:
+			return false
+		case <-t.closing:
+			return false
+		}
+	}
+}
+
+// self implements transport.
+func (t *UDPv5) self() *enode.Node {
+	return t.localNode.Node()
+}
+
+// close implements transport.
+func (t *UDPv5) close() {
+	t.closeOnce.Do(func() {
+		close(t.closing)
+		t.conn.Close()
+		t.wg.Wait()
+	})
+}
+
+// ping implements transport, checking the liveness of a node in the table.
+func (t *UDPv5) ping(toid enode.ID, toaddr *net.UDPAddr) error {
+	n := t.getNode(toid)
+	if n == nil {
+		return errUnknownNode
+	}
+	seq, err := t.ping5(n)
+	if err == nil && seq > n.Seq() {
+		// The node has updated its record, fetch the new one
+		if rn, err := t.RequestENR(n); err == nil {
+			t.rememberNode(rn)
+		}
+	}
+	return err
+}
+
+// findnode implements transport, querying a node in the table for the nodes
+// closest to the given target.
+func (t *UDPv5) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+	n := t.getNode(toid)
+	if n == nil {
+		return nil, errUnknownNode
+	}
+	nodes, err := t.findnodeDistances(n, lookupDistances(target.id(), toid))
+	return wrapNodes(nodes), err
+}
+
+// ping5 sends a ping to the given node and waits for the reply, returning the
+// record sequence number of the node.
+func (t *UDPv5) ping5(n *enode.Node) (uint64, error) {
+	resp := t.call(n, v5PongMsg, &v5Ping{ENRSeq: t.localNode.Node().Seq()})
+	defer t.callDone(resp)
+
+	select {
+	case pong := <-resp.ch:
+		return pong.(*v5Pong).ENRSeq, nil
+	case err := <-resp.err:
+		return 0, err
+	}
+}
+
+// findnodeDistances queries the given node for the nodes at the given log
+// distances to it.
+func (t *UDPv5) findnodeDistances(n *enode.Node, distances []uint) ([]*enode.Node, error) {
+	resp := t.call(n, v5NodesMsg, &v5Findnode{Distances: distances})
+	return t.waitForNodes(resp, func(rn *enode.Node) error {
+		dist := uint(enode.LogDist(n.ID(), rn.ID()))
+		for _, d := range distances {
+			if d == dist {
+				return nil
+			}
+		}
+		return errors.New("node not at requested distance")
+	})
+}
+
+// regtopic requests the registration of the local node for a topic at the given
+// node.
+func (t *UDPv5) regtopic(n *enode.Node, topic Topic, ticket []byte) (*v5Ticket, error) {
+	resp := t.call(n, v5TicketMsg, &v5Regtopic{Topic: []byte(topic), Ticket: ticket})
+	defer t.callDone(resp)
+
+	select {
+	case p := <-resp.ch:
+		return p.(*v5Ticket), nil
+	case err := <-resp.err:
+		return nil, err
+	}
+}
+
+// topicQuery queries the given node for the advertisers of a topic.
+func (t *UDPv5) topicQuery(n *enode.Node, topic Topic) ([]*enode.Node, error) {
+	resp := t.call(n, v5NodesMsg, &v5TopicQuery{Topic: []byte(topic)})
+	return t.waitForNodes(resp, nil)
+}
+
+// waitForNodes waits for the NODES responses of a call, verifying the records.
+func (t *UDPv5) waitForNodes(c *v5Call, verify func(*enode.Node) error) ([]*enode.Node, error) {
+	defer t.callDone(c)
+
+	var (
+		nodes           []*enode.Node
+		seen            = make(map[enode.ID]bool)
+		received, total = 0, -1
+	)
+	for {
+		select {
+		case p := <-c.ch:
+			resp := p.(*v5Nodes)
+			for _, record := range resp.Nodes {
+				n, err := t.verifyResponseNode(c, record, seen)
+				if err == nil && verify != nil {
+					err = verify(n)
+				}
+				if err != nil {
+					log.Debug("Invalid record in "+resp.name(), "id", c.node.ID(), "err", err)
+					continue
+				}
+				t.rememberNode(n)
+				nodes = append(nodes, n)
+			}
+			if total == -1 {
+				total = int(resp.Total)
+				if total > totalNodesResponseLimit {
+					total = totalNodesResponseLimit
+				}
+			}
+			if received++; received >= total {
+				return nodes, nil
+			}
+		case err := <-c.err:
+			return nodes, err
+		}
+	}
+}
+
+// verifyResponseNode checks the validity of a record received in a response.
+func (t *UDPv5) verifyResponseNode(c *v5Call, r *enr.Record, seen map[enode.ID]bool) (*enode.Node, error) {
+	n, err := enode.New(t.validSchemes, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := netutil.CheckRelayIP(c.node.IP(), n.IP()); err != nil {
+		return nil, err
+	}
+	if t.netrestrict != nil && !t.netrestrict.Contains(n.IP()) {
+		return nil, errors.New("not contained in netrestrict whitelist")
+	}
+	if n.UDP() <= 1024 {
+		return nil, errors.New("low port")
+	}
+	if seen[n.ID()] {
+		return nil, errors.New("duplicate record")
+	}
+	seen[n.ID()] = true
+	return n, nil
+}
+
+// lookup performs a network search for the nodes closest to the given target. In
+// contrast to the lookups of the table, targets are node IDs and any table node
+// may be asked, as nodes are only added after completing a handshake.
+func (t *UDPv5) lookup(target enode.ID) []*node {
+	var (
+		self           = t.self().ID()
+		asked          = map[enode.ID]bool{self: true}
+		seen           = map[enode.ID]bool{self: true}
+		reply          = make(chan []*node, alpha)
+		pendingQueries = 0
+		result         = t.closestNodes(target, bucketSize)
+	)
+	if len(result.entries) == 0 {
+		// The table is empty, wait for it to load the seed nodes.
+		<-t.tab.refresh()
+		result = t.closestNodes(target, bucketSize)
+	}
+	for _, n := range result.entries {
+		seen[n.ID()] = true
+	}
+	for {
+		// ask the alpha closest nodes that we haven't asked yet
+		for i := 0; i < len(result.entries) && pendingQueries < alpha; i++ {
+			n := result.entries[i]
+			if !asked[n.ID()] {
+				asked[n.ID()] = true
+				pendingQueries++
+				go t.lookupQuery(n, target, reply)
+			}
+		}
+		if pendingQueries == 0 {
+			// we have asked all closest nodes, stop the search
+			break
+		}
+		select {
+		case nodes := <-reply:
+			for _, n := range nodes {
+				if !seen[n.ID()] {
+					seen[n.ID()] = true
+					result.push(n, bucketSize)
+				}
+			}
+		case <-t.closing:
+			return nil // shutdown, no need to continue.
+		}
+		pendingQueries--
+	}
+	return result.entries
+}
+
+// lookupQuery queries a node for the nodes close to a lookup target.
+func (t *UDPv5) lookupQuery(n *node, target enode.ID, reply chan<- []*node) {
+	nodes, err := t.findnodeDistances(unwrapNode(n), lookupDistances(target, n.ID()))
+	if err != nil {
+		log.Trace("Findnode failed", "id", n.ID(), "err", err)
+	}
+	r := wrapNodes(nodes)
+	for _, n := range r {
+		t.tab.addSeenNode(n)
+	}
+	reply <- r
+}
+
+// lookupDistances computes the distances to query from a node during a lookup,
+// the distances of the nodes closest to the target.
+func lookupDistances(target, dest enode.ID) (dists []uint) {
+	td := enode.LogDist(target, dest)
+	dists = append(dists, uint(td))
+	for i := 1; len(dists) < lookupRequestLimit; i++ {
+		if td+i <= 256 {
+			dists = append(dists, uint(td+i))
+		}
+		if td-i > 0 {
+			dists = append(dists, uint(td-i))
+		}
+	}
+	return dists
+}
+
+// closestNodes returns the table nodes closest to the target.
+func (t *UDPv5) closestNodes(target enode.ID, nresults int) *nodesByDistance {
+	t.tab.mutex.Lock()
+	defer t.tab.mutex.Unlock()
+
+	close := &nodesByDistance{target: target}
+	for _, b := range &t.tab.buckets {
+		for _, n := range b.entries {
+			close.push(n, nresults)
+		}
+	}
+	return close
+}
+
+// nodesAtDistance returns the table nodes at the given log distance to the local
+// node.
+func (t *UDPv5) nodesAtDistance(dist uint) []*enode.Node {
+	self := t.self().ID()
+
+	t.tab.mutex.Lock()
+	defer t.tab.mutex.Unlock()
+
+	var nodes []*enode.Node
+	for _, b := range &t.tab.buckets {
+		for _, n := range b.entries {
+			if uint(enode.LogDist(self, n.ID())) == dist {
+				nodes = append(nodes, unwrapNode(n))
+			}
+		}
+	}
+	return nodes
+}
+
+// getNode looks up the most recent record of a node known locally.
+func (t *UDPv5) getNode(id enode.ID) *enode.Node {
+	if n, ok := t.nodes.Get(id); ok {
+		return n.(*enode.Node)
+	}
+	return t.db.Node(id)
+}
+
+// rememberNode caches a node record, unless a more recent one is known.
+func (t *UDPv5) rememberNode(n *enode.Node) {
+	if old, ok := t.nodes.Get(n.ID()); ok && old.(*enode.Node).Seq() > n.Seq() {
+		return
+	}
+	t.nodes.Add(n.ID(), n)
+}
+
+// call sends the given call and sets up a handler for response packets (of message
+// type responseType). Responses are dispatched to the call's response channel.
+func (t *UDPv5) call(node *enode.Node, responseType byte, packet v5Packet) *v5Call {
+	c := &v5Call{
+		node:         node,
+		packet:       packet,
+		responseType: responseType,
+		reqid:        make([]byte, 8),
+		ch:           make(chan v5Packet, 1),
+		err:          make(chan error, 1),
+	}
+	// Assign request ID.
+	crand.Read(c.reqid)
+	packet.setreqid(c.reqid)
+
+	// Send call to dispatch.
+	select {
+	case t.callCh <- c:
+	case <-t.closing:
+		c.err <- errClosed
+	}
+	return c
+}
+
+// callDone tells dispatch that the active call is done.
+func (t *UDPv5) callDone(c *v5Call) {
+	// This needs a loop because further responses may be incoming until the
+	// send to callDoneCh has completed. Such responses need to be discarded
+	// in order to avoid blocking the dispatch loop.
+	for {
+		select {
+		case <-c.ch:
+			// late response, discard.
+		case <-c.err:
+			// late error, discard.
+		case t.callDoneCh <- c:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// dispatch runs in its own goroutine, handles incoming packets and deals with calls.
+//
+// For any destination node there is at most one 'active call', stored in the
+// activeCallByNode map. A call is made active when it is sent. The active call can
+// be answered by a matching response, in which case c.ch receives the response; or
+// by timing out, in which case c.err receives the error. When the function that
+// created the call signals the active call is done through callDone, the next call
+// from the call queue is sent.
+//
+// Calls may also be answered by a WHOAREYOU packet referencing the call packet's
+// authentication nonce. When that happens the call is simply re-sent with a
+// handshake. We record the authentication nonce of each sent call in the
+// activeCallByAuth map.
+func (t *UDPv5) dispatch() {
+	defer t.wg.Done()
+
+	// Arm first read.
+	t.readNextCh <- struct{}{}
+
+	for {
+		select {
+		case c := <-t.callCh:
+			id := c.node.ID()
+			t.callQueue[id] = append(t.callQueue[id], c)
+			t.sendNextCall(id)
+
+		case ct := <-t.respTimeoutCh:
+			active := t.activeCallByNode[ct.c.node.ID()]
+			if ct.c == active && ct.timer == active.timeout {
+				ct.c.err <- errTimeout
+			}
+
+		case c := <-t.callDoneCh:
+			id := c.node.ID()
+			if t.activeCallByNode[id] != c {
+				panic("BUG: callDone for inactive call")
+			}
+			c.timeout.Stop()
+			delete(t.activeCallByAuth, c.nonce)
+			delete(t.activeCallByNode, id)
+			t.sendNextCall(id)
+
+		case p := <-t.packetInCh:
+			t.handlePacket(p.Data, p.Addr)
+			// Arm next read.
+			t.readNextCh <- struct{}{}
+
+		case <-t.closing:
+			close(t.readNextCh)
+			for id, queue := range t.callQueue {
+				for _, c := range queue {
+					c.err <- errClosed
+				}
+				delete(t.callQueue, id)
+			}
+			for id, c := range t.activeCallByNode {
+				c.timeout.Stop()
+				c.err <- errClosed
+				delete(t.activeCallByNode, id)
+				delete(t.activeCallByAuth, c.nonce)
+			}
+			return
+		}
+	}
+}
+
+// startResponseTimeout sets the response timer for a call.
+func (t *UDPv5) startResponseTimeout(c *v5Call) {
+	if c.timeout != nil {
+		c.timeout.Stop()
+	}
+	ct := &v5CallTimeout{c: c}
+	ct.timer = time.AfterFunc(v5RespTimeout, func() {
+		select {
+		case t.respTimeoutCh <- ct:
+		case <-t.closing:
+		}
+	})
+	c.timeout = ct.timer
+}
+
+// sendNextCall sends the next call in the call queue if there is no active call.
+func (t *UDPv5) sendNextCall(id enode.ID) {
+	queue := t.callQueue[id]
+	if len(queue) == 0 || t.activeCallByNode[id] != nil {
+		return
+	}
+	t.activeCallByNode[id] = queue[0]
+	t.sendCall(t.activeCallByNode[id])
+	if len(queue) == 1 {
+		delete(t.callQueue, id)
+	} else {
+		copy(queue, queue[1:])
+		t.callQueue[id] = queue[:len(queue)-1]
+	}
+}
+
+// sendCall encodes and sends a request packet to the call's recipient node.
+// This performs a handshake if needed.
+func (t *UDPv5) sendCall(c *v5Call) {
+	t.startResponseTimeout(c)
+
+	// Remove the call from the nonce index, it gets a new nonce when resent.
+	delete(t.activeCallByAuth, c.nonce)
+
+	addr := &net.UDPAddr{IP: c.node.IP(), Port: c.node.UDP()}
+	nonce, err := t.send(c.node.ID(), addr, c.packet, c.challenge)
+	if err != nil {
+		c.err <- err
+		return
+	}
+	c.nonce = nonce
+	t.activeCallByAuth[nonce] = c
+}
+
+// sendResponse sends a response packet to the given node.
+// This doesn't trigger a handshake even if no keys are available.
+func (t *UDPv5) sendResponse(toID enode.ID, toAddr *net.UDPAddr, packet v5Packet) error {
+	_, err := t.send(toID, toAddr, packet, nil)
+	return err
+}
+
+// send sends a packet to the given node.
+func (t *UDPv5) send(toID enode.ID, toAddr *net.UDPAddr, packet v5Packet, c *v5Whoareyou) (v5Nonce, error) {
+	addr := toAddr.String()
+	enc, nonce, err := t.codec.encode(toID, addr, packet, c)
+	if err != nil {
+		log.Warn(">> "+packet.name(), "id", toID, "addr", addr, "err", err)
+		return nonce, err
+	}
+	_, err = t.conn.WriteToUDP(enc, toAddr)
+	log.Trace(">> "+packet.name(), "id", toID, "addr", addr, "err", err)
+	return nonce, err
+}
+
+// readLoop runs in its own goroutine and reads packets from the network.
+func (t *UDPv5) readLoop() {
+	defer t.wg.Done()
+
+	buf := make([]byte, maxV5PacketSize)
+	for range t.readNextCh {
+		nbytes, from, err := t.read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case t.packetInCh <- ReadPacket{buf[:nbytes], from}:
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// read reads the next packet from the network, skipping temporary errors.
+func (t *UDPv5) read(buf []byte) (int, *net.UDPAddr, error) {
+	for {
+		nbytes, from, err := t.conn.ReadFromUDP(buf)
+		if netutil.IsTemporaryError(err) {
+			// Ignore temporary read errors.
+			log.Debug("Temporary UDP read error", "err", err)
+			continue
+		} else if err != nil {
+			// Shut down the loop for permament errors.
+			log.Debug("UDP read error", "err", err)
+		}
+		return nbytes, from, err
+	}
+}
+
+// handlePacket decodes and processes an incoming packet from the network.
+func (t *UDPv5) handlePacket(rawpacket []byte, fromAddr *net.UDPAddr) error {
+	addr := fromAddr.String()
+	fromID, fromNode, packet, err := t.codec.decode(rawpacket, addr)
+	if err != nil {
+		log.Debug("Bad discv5 packet", "id", fromID, "addr", addr, "err", err)
+		return err
+	}
+	if fromNode != nil {
+		// Handshake succeeded, add the node to the table if it's reachable.
+		t.rememberNode(fromNode)
+		if fromNode.IP().Equal(fromAddr.IP) && fromNode.UDP() == fromAddr.Port {
+			t.tab.addVerifiedNode(wrapNode(fromNode))
+		}
+	}
+	if packet.kind() != v5WhoareyouMsg {
+		// WHOAREYOU logged separately to report the sender ID.
+		log.Trace("<< "+packet.name(), "id", fromID, "addr", addr)
+	}
+	t.handle(packet, fromID, fromAddr)
+	return nil
+}
+
+// handle processes an incoming packet.
+func (t *UDPv5) handle(p v5Packet, fromID enode.ID, fromAddr *net.UDPAddr) {
+	switch p := p.(type) {
+	case *v5Unknown:
+		t.handleUnknown(p, fromID, fromAddr)
+	case *v5Whoareyou:
+		t.handleWhoareyou(p, fromID, fromAddr)
+	case *v5Ping:
+		t.handlePing(p, fromID, fromAddr)
+	case *v5Pong:
+		if t.handleCallResponse(fromID, fromAddr, p) {
+			t.localNode.UDPEndpointStatement(fromAddr, &net.UDPAddr{IP: p.ToIP, Port: int(p.ToPort)})
+		}
+	case *v5Findnode:
+		t.handleFindnode(p, fromID, fromAddr)
+	case *v5Nodes:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5Regtopic:
+		t.handleRegtopic(p, fromID, fromAddr)
+	case *v5Ticket:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5TopicQuery:
+		t.handleTopicQuery(p, fromID, fromAddr)
+	}
+}
+
+// handleCallResponse dispatches a response packet to the call waiting for it.
+func (t *UDPv5) handleCallResponse(fromID enode.ID, fromAddr *net.UDPAddr, p v5Packet) bool {
+	ac := t.activeCallByNode[fromID]
+	if ac == nil || !bytes.Equal(p.reqid(), ac.reqid) {
+		log.Debug(fmt.Sprintf("Unsolicited/late %s response", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	if !fromAddr.IP.Equal(ac.node.IP()) || fromAddr.Port != ac.node.UDP() {
+		log.Debug(fmt.Sprintf("%s from wrong endpoint", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	if p.kind() != ac.responseType {
+		log.Debug(fmt.Sprintf("Wrong discv5 response type %s", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	t.startResponseTimeout(ac)
+	ac.ch <- p
+	return true
+}
+
+// handleUnknown initiates a handshake by responding with WHOAREYOU.
+func (t *UDPv5) handleUnknown(p *v5Unknown, fromID enode.ID, fromAddr *net.UDPAddr) {
+	challenge := &v5Whoareyou{Nonce: p.Nonce}
+	crand.Read(challenge.IDNonce[:])
+	if n := t.getNode(fromID); n != nil {
+		challenge.node = n
+		challenge.RecordSeq = n.Seq()
+	}
+	t.sendResponse(fromID, fromAddr, challenge)
+}
+
+// handleWhoareyou resends the active call as a handshake packet.
+func (t *UDPv5) handleWhoareyou(p *v5Whoareyou, fromID enode.ID, fromAddr *net.UDPAddr) {
+	c, err := t.matchWithCall(fromAddr, p.Nonce)
+	if err != nil {
+		log.Debug("Invalid "+p.name(), "addr", fromAddr, "err", err)
+		return
+	}
+	// Resend the call that was answered by WHOAREYOU.
+	log.Trace("<< "+p.name(), "id", c.node.ID(), "addr", fromAddr)
+	c.handshakeCount++
+	c.challenge = p
+	p.node = c.node
+	t.sendCall(c)
+}
+
+// matchWithCall checks whetvchain a handshake attempt matches the active call.
+func (t *UDPv5) matchWithCall(fromAddr *net.UDPAddr, nonce v5Nonce) (*v5Call, error) {
+	c := t.activeCallByAuth[nonce]
+	if c == nil {
+		return nil, errChallengeNoCall
+	}
+	if c.handshakeCount > 0 {
+		return nil, errChallengeTwice
+	}
+	if !fromAddr.IP.Equal(c.node.IP()) || fromAddr.Port != c.node.UDP() {
+		return nil, errors.New("challenge from wrong endpoint")
+	}
+	return c, nil
+}
+
+// handlePing sends a PONG response.
+func (t *UDPv5) handlePing(p *v5Ping, fromID enode.ID, fromAddr *net.UDPAddr) {
+	ip := fromAddr.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	t.sendResponse(fromID, fromAddr, &v5Pong{
+		ReqID:  p.ReqID,
+		ENRSeq: t.localNode.Node().Seq(),
+		ToIP:   ip,
+		ToPort: uint16(fromAddr.Port),
+	})
+}
+
+// handleFindnode returns the table nodes at the requested distances.
+func (t *UDPv5) handleFindnode(p *v5Findnode, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var (
+		nodes     []*enode.Node
+		processed = make(map[uint]bool)
+	)
+	for _, dist := range p.Distances {
+		// Reject duplicate and invalid distances
+		if processed[dist] || dist > 256 {
+			continue
+		}
+		processed[dist] = true
+
+		candidates := []*enode.Node{t.self()}
+		if dist > 0 {
+			candidates = t.nodesAtDistance(dist)
+		}
+		for _, n := range candidates {
+			if len(nodes) < findnodeResultLimit && netutil.CheckRelayIP(fromAddr.IP, n.IP()) == nil {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	t.sendNodes(fromID, fromAddr, p.ReqID, nodes)
+}
+
+// handleRegtopic places an advertisement of the sender in the topic table, or
+// issues a ticket to come back later.
+func (t *UDPv5) handleRegtopic(p *v5Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if len(p.Topic) == 0 || len(p.Topic) > maxTopicLength {
+		log.Debug("Invalid topic registration", "id", fromID, "addr", fromAddr, "topic", len(p.Topic))
+		return
+	}
+	// Only advertise nodes reachable at the endpoint they contacted us from
+	n := t.getNode(fromID)
+	if n == nil || !n.IP().Equal(fromAddr.IP) || n.UDP() != fromAddr.Port {
+		log.Debug("Topic registration from unknown endpoint", "id", fromID, "addr", fromAddr)
+		return
+	}
+	wait, ticket, err := t.topics.register(Topic(p.Topic), n, fromAddr.IP, p.Ticket)
+	if err != nil && err != errTicketEarly {
+		log.Debug("Rejected topic registration", "id", fromID, "addr", fromAddr, "err", err)
+		return
+	}
+	t.sendResponse(fromID, fromAddr, &v5Ticket{
+		ReqID:    p.ReqID,
+		Ticket:   ticket,
+		WaitTime: uint((wait + time.Millisecond - 1) / time.Millisecond),
+	})
+}
+
+// handleTopicQuery returns the advertisers of the requested topic.
+func (t *UDPv5) handleTopicQuery(p *v5TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var nodes []*enode.Node
+	for _, n := range t.topics.query(Topic(p.Topic)) {
+		if netutil.CheckRelayIP(fromAddr.IP, n.IP()) == nil {
+			nodes = append(nodes, n)
+		}
+	}
+	t.sendNodes(fromID, fromAddr, p.ReqID, nodes)
+}
+
+// sendNodes sends the given nodes in as many NODES packets as needed.
+func (t *UDPv5) sendNodes(toID enode.ID, toAddr *net.UDPAddr, reqid []byte, nodes []*enode.Node) {
+	total := (len(nodes) + nodesPerV5Response - 1) / nodesPerV5Response
+	if total == 0 {
+		total = 1
+	}
+	for i := 0; i < total; i++ {
+		p := &v5Nodes{ReqID: reqid, Total: uint8(total)}
+		for j := i * nodesPerV5Response; j < len(nodes) && j < (i+1)*nodesPerV5Response; j++ {
+			p.Nodes = append(p.Nodes, nodes[j].Record())
+		}
+		t.sendResponse(toID, toAddr, p)
+	}
+}
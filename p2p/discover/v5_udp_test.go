@@ -0,0 +1,302 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+)
+
+// startLocalV5 starts a discovery v5 listener on the loopback interface.
+func startLocalV5(t *testing.T, bootnodes ...*enode.Node) *UDPv5 {
+	key, _ := crypto.GenerateKey()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := newTestLocalNode(key, conn.LocalAddr().(*net.UDPAddr).Port)
+	udp, err := ListenV5(conn, ln, Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return udp
+}
+
+// startLocalV4 starts a discovery v4 listener on the loopback interface.
+func startLocalV4(t *testing.T, bootnodes ...*enode.Node) (*ecdsa.PrivateKey, *Table, *udp) {
+	key, _ := crypto.GenerateKey()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := newTestLocalNode(key, conn.LocalAddr().(*net.UDPAddr).Port)
+	tab, udp, err := newUDP(conn, ln, Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, tab, udp
+}
+
+// sharedConn is the connection p2p.Server hands to discovery v5 if it shares the
+// socket with v4: writes go to the socket, reads return what v4 couldn't handle.
+type sharedConn struct {
+	*net.UDPConn
+	unhandled chan ReadPacket
+}
+
+func (s *sharedConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	packet, ok := <-s.unhandled
+	if !ok {
+		return 0, nil, errors.New("connection closed")
+	}
+	return copy(b, packet.Data), packet.Addr, nil
+}
+
+func (s *sharedConn) Close() error {
+	return nil
+}
+
+// startLocalV5Network starts a bootnode and the given number of nodes knowing it,
+// waiting until all nodes are in the table of the bootnode.
+func startLocalV5Network(t *testing.T, n int) (*UDPv5, []*UDPv5) {
+	boot := startLocalV5(t)
+	nodes := make([]*UDPv5, n)
+	for i := range nodes {
+		nodes[i] = startLocalV5(t, boot.Self())
+		if err := nodes[i].Ping(boot.Self()); err != nil {
+			t.Fatalf("node %d can't ping bootnode: %v", i, err)
+		}
+	}
+	return boot, nodes
+}
+
+func closeLocalV5Network(boot *UDPv5, nodes []*UDPv5) {
+	for _, n := range nodes {
+		n.Close()
+	}
+	boot.Close()
+}
+
+func containsNode(nodes []*enode.Node, id enode.ID) bool {
+	for _, n := range nodes {
+		if n.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// This test checks that nodes establish a session, after which they know each
+// other's records.
+func TestUDPv5_pingHandshake(t *testing.T) {
+	a, b := startLocalV5(t), startLocalV5(t)
+	defer a.Close()
+	defer b.Close()
+
+	if err := a.Ping(b.Self()); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	// The session is reused for further requests
+	if err := a.Ping(b.Self()); err != nil {
+		t.Fatalf("second ping failed: %v", err)
+	}
+	if n := b.getNode(a.Self().ID()); n == nil || n.Seq() != a.Self().Seq() {
+		t.Fatalf("handshake didn't transfer the record: %v", n)
+	}
+	if !containsNode(unwrapNodes(b.closestNodes(a.Self().ID(), 1).entries), a.Self().ID()) {
+		t.Fatalf("pinging node not added to the table")
+	}
+	// b can query a without a new handshake
+	n, err := b.RequestENR(a.Self())
+	if err != nil {
+		t.Fatalf("ENR request failed: %v", err)
+	}
+	if n.ID() != a.Self().ID() || n.Seq() != a.Self().Seq() {
+		t.Fatalf("wrong record returned: %v", n)
+	}
+}
+
+// This test checks that calls to unresponsive nodes time out.
+func TestUDPv5_pingTimeout(t *testing.T) {
+	a := startLocalV5(t)
+	defer a.Close()
+
+	key, _ := crypto.GenerateKey()
+	dead := enode.NewV4(&key.PublicKey, net.IP{127, 0, 0, 1}, 0, 1)
+	if err := a.Ping(dead); err != errTimeout {
+		t.Fatalf("wrong error for unresponsive node: %v", err)
+	}
+}
+
+// This test checks that lookups find the nodes known to the bootnode.
+func TestUDPv5_lookup(t *testing.T) {
+	boot, nodes := startLocalV5Network(t, 8)
+	defer closeLocalV5Network(boot, nodes)
+
+	for i, n := range nodes {
+		target := nodes[(i+1)%len(nodes)].Self().ID()
+		if found := n.Lookup(target); !containsNode(found, target) {
+			t.Errorf("node %d: lookup didn't find target %v", i, target)
+		}
+	}
+}
+
+// This test checks that topic advertisements placed by one node can be found by
+// another one.
+func TestUDPv5_topicSearch(t *testing.T) {
+	boot, nodes := startLocalV5Network(t, 4)
+	defer closeLocalV5Network(boot, nodes)
+
+	var (
+		topic     = Topic("foo")
+		stop      = make(chan struct{})
+		setPeriod = make(chan time.Duration, 1)
+		found     = make(chan *enode.Node)
+		lookup    = make(chan bool, 100)
+	)
+	defer close(stop)
+	defer close(setPeriod)
+
+	go nodes[0].RegisterTopic(topic, stop)
+	setPeriod <- 100 * time.Millisecond
+	go nodes[1].SearchTopic(topic, setPeriod, found, lookup)
+
+	select {
+	case n := <-found:
+		if n.ID() != nodes[0].Self().ID() {
+			t.Fatalf("found node %v not advertising the topic", n.ID())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("advertising node not found")
+	}
+}
+
+// This test checks that discovery v4 and v5 can share a single socket the way
+// p2p.Server sets them up, with both protocols answering pings and lookups.
+func TestUDPv5_sharedConn(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := newTestLocalNode(key, conn.LocalAddr().(*net.UDPAddr).Port)
+	unhandled := make(chan ReadPacket, 100)
+
+	tab4, udp4, err := newUDP(conn, ln, Config{PrivateKey: key, Unhandled: unhandled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v5, err := ListenV5(&sharedConn{conn, unhandled}, ln, Config{PrivateKey: key})
+	if err != nil {
+		tab4.Close()
+		t.Fatal(err)
+	}
+	// v4 owns the socket, closing it releases the v5 reader too
+	defer v5.Close()
+	defer tab4.Close()
+
+	shared := ln.Node()
+	addr := &net.UDPAddr{IP: shared.IP(), Port: shared.UDP()}
+
+	// Both v4 peers bond with the shared node in either direction. Tables only
+	// accept verified nodes once bootstrapped, so wait for that first.
+	<-tab4.initDone
+
+	var v4keys []*ecdsa.PrivateKey
+	var v4nodes []*udp
+	for i := 0; i < 2; i++ {
+		key, tab, udp := startLocalV4(t)
+		defer tab.Close()
+		<-tab.initDone
+
+		if err := udp.ping(shared.ID(), addr); err != nil {
+			t.Fatalf("v4 node %d can't ping shared node: %v", i, err)
+		}
+		if err := udp4.ping(udp.self().ID(), &net.UDPAddr{IP: udp.self().IP(), Port: udp.self().UDP()}); err != nil {
+			t.Fatalf("shared node can't ping v4 node %d: %v", i, err)
+		}
+		v4keys, v4nodes = append(v4keys, key), append(v4nodes, udp)
+	}
+	// Both v5 peers establish a session with the shared node
+	var v5nodes []*UDPv5
+	for i := 0; i < 2; i++ {
+		n := startLocalV5(t, shared)
+		defer n.Close()
+
+		if err := n.Ping(shared); err != nil {
+			t.Fatalf("v5 node %d can't ping shared node: %v", i, err)
+		}
+		if err := v5.Ping(n.Self()); err != nil {
+			t.Fatalf("shared node can't ping v5 node %d: %v", i, err)
+		}
+		v5nodes = append(v5nodes, n)
+	}
+	// The shared node adds the v4 peers once its own pings got answered. Mark
+	// them revalidated, as only those are handed out in lookups.
+	if !waitFor(func() bool {
+		tab4.mutex.Lock()
+		defer tab4.mutex.Unlock()
+		return tab4.len() == len(v4nodes)
+	}) {
+		t.Fatalf("shared node didn't add the v4 peers")
+	}
+	tab4.mutex.Lock()
+	for _, b := range &tab4.buckets {
+		for _, n := range b.entries {
+			n.livenessChecks = 1
+		}
+	}
+	tab4.mutex.Unlock()
+
+	// The shared node answers v4 lookup queries with the other v4 peer. With
+	// less than a full bucket of results, findnode waits out its timeout.
+	for i, n := range v4nodes {
+		target := encodePubkey(&v4keys[(i+1)%len(v4keys)].PublicKey)
+		found, err := n.findnode(shared.ID(), addr, target)
+		if err != nil && err != errTimeout {
+			t.Fatalf("v4 node %d: findnode failed: %v", i, err)
+		}
+		if !containsNode(unwrapNodes(found), target.id()) {
+			t.Errorf("v4 node %d: shared node didn't return the other v4 node", i)
+		}
+	}
+	// The v5 peers find each other through the shared node
+	for i, n := range v5nodes {
+		target := v5nodes[(i+1)%len(v5nodes)].Self().ID()
+		if found := n.Lookup(target); !containsNode(found, target) {
+			t.Errorf("v5 node %d: lookup didn't find the other v5 node", i)
+		}
+	}
+}
+
+// waitFor polls the given condition for a few seconds, reporting whetvchain it
+// was met.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if cond() {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
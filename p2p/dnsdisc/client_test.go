@@ -0,0 +1,208 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/common/mclock"
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+)
+
+// mapResolver is an in-process resolver serving TXT records from a map.
+type mapResolver map[string]string
+
+func (mr mapResolver) add(m map[string]string) {
+	for k, v := range m {
+		mr[k] = v
+	}
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+// makeTestTree creates and signs a tree, returning it together with its URL.
+func makeTestTree(t *testing.T, domain string, seq uint, nodes []*enode.Node, links []string) (*Tree, string, *ecdsa.PrivateKey) {
+	tree, err := MakeTree(seq, nodes, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree, url, key
+}
+
+func TestClientSyncTree(t *testing.T) {
+	nodes := testNodes(t, 2*maxChildren)
+	links := []string{testLink("other.example.org")}
+	tree, url, _ := makeTestTree(t, "n", 1, nodes, links)
+
+	r := mapResolver(tree.ToTXT("n"))
+	c, _ := NewClient(Config{Resolver: r})
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortedNodes(stree.Nodes()), sortedNodes(nodes)) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if !reflect.DeepEqual(stree.Links(), links) {
+		t.Errorf("wrong links in synced tree: %v", stree.Links())
+	}
+	if !reflect.DeepEqual(stree.ToTXT("n"), tree.ToTXT("n")) {
+		t.Errorf("synced tree records don't match original")
+	}
+}
+
+// This test checks that SyncTree rejects trees signed by a different key.
+func TestClientSyncTreeBadSignature(t *testing.T) {
+	tree, _, _ := makeTestTree(t, "n", 1, testNodes(t, 3), nil)
+	otherURL := testLink("n")
+
+	c, _ := NewClient(Config{Resolver: mapResolver(tree.ToTXT("n"))})
+	_, err := c.SyncTree(otherURL)
+	if want := (entryError{"root", errInvalidSig}); err != want {
+		t.Fatalf("wrong error: got %v, want %v", err, want)
+	}
+}
+
+// This test checks that entries not matching their hash are rejected.
+func TestClientSyncTreeHashMismatch(t *testing.T) {
+	nodes := testNodes(t, 3)
+	tree, url, _ := makeTestTree(t, "n", 1, nodes, nil)
+	r := mapResolver(tree.ToTXT("n"))
+
+	// Replace the record of the first node with the record of another node.
+	other := testNodes(t, 1)[0]
+	var name string
+	for k, v := range r {
+		if v == (&enrEntry{nodes[0]}).String() {
+			name = k
+			r[k] = (&enrEntry{other}).String()
+		}
+	}
+	c, _ := NewClient(Config{Resolver: r})
+	_, err := c.SyncTree(url)
+	if want := (nameError{name, errHashMismatch}); err != want {
+		t.Fatalf("wrong error: got %v, want %v", err, want)
+	}
+}
+
+// This test checks that RandomNode eventually returns all nodes of the tree and
+// the trees linked by it.
+func TestClientRandomNode(t *testing.T) {
+	nodes := testNodes(t, 20)
+	linked, linkURL, _ := makeTestTree(t, "linked", 1, nodes[10:], nil)
+	tree, url, _ := makeTestTree(t, "n", 1, nodes[:10], []string{linkURL})
+
+	r := mapResolver(tree.ToTXT("n"))
+	r.add(linked.ToTXT("linked"))
+	c, err := NewClient(Config{Resolver: r}, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRandomNode(t, c, nodes)
+}
+
+// This test checks that the client picks up tree updates after the recheck
+// interval has passed.
+func TestClientRandomNodeUpdate(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		nodes = testNodes(t, 10)
+		r     = make(mapResolver)
+	)
+	tree1, url, key := makeTestTree(t, "n", 1, nodes[:5], nil)
+	r.add(tree1.ToTXT("n"))
+	c, _ := NewClient(Config{Resolver: r, RecheckInterval: 20 * time.Minute}, url)
+	c.clock = clock
+	checkRandomNode(t, c, nodes[:5])
+
+	// Publish an update of the tree.
+	tree2, _ := MakeTree(2, nodes[5:], nil)
+	tree2.Sign(key, "n")
+	r.add(tree2.ToTXT("n"))
+
+	clock.Run(c.cfg.RecheckInterval + 1*time.Second)
+	checkRandomNode(t, c, nodes[5:])
+
+	// Outdated roots are ignored.
+	r.add(tree1.ToTXT("n"))
+	clock.Run(c.cfg.RecheckInterval + 1*time.Second)
+	checkRandomNode(t, c, nodes[5:])
+}
+
+// checkRandomNode calls RandomNode until all the given nodes have been returned,
+// failing the test if any other node is returned.
+func checkRandomNode(t *testing.T, c *Client, wantNodes []*enode.Node) {
+	t.Helper()
+
+	var (
+		want     = make(map[enode.ID]*enode.Node)
+		maxCalls = len(wantNodes) * 20
+		calls    = 0
+		ctx      = context.Background()
+	)
+	for _, n := range wantNodes {
+		want[n.ID()] = n
+	}
+	for ; len(want) > 0 && calls < maxCalls; calls++ {
+		n := c.RandomNode(ctx)
+		if n == nil {
+			t.Fatalf("RandomNode returned nil (call %d)", calls)
+		}
+		if !containsNode(wantNodes, n.ID()) {
+			t.Fatalf("RandomNode returned unexpected node %v", n.ID())
+		}
+		delete(want, n.ID())
+	}
+	if len(want) > 0 {
+		t.Fatalf("%d nodes not returned after %d calls", len(want), maxCalls)
+	}
+}
+
+func containsNode(nodes []*enode.Node, id enode.ID) bool {
+	for _, n := range nodes {
+		if n.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNodes(nodes []*enode.Node) []string {
+	urls := make([]string, len(nodes))
+	for i, n := range nodes {
+		urls[i] = n.String()
+	}
+	sort.Strings(urls)
+	return urls
+}
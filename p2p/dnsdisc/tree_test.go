@@ -0,0 +1,189 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/etvchaineum/go-etvchaineum/crypto"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enr"
+)
+
+// testNodes creates n nodes with signed records.
+func testNodes(t *testing.T, n int) []*enode.Node {
+	nodes := make([]*enode.Node, n)
+	for i := range nodes {
+		key, _ := crypto.GenerateKey()
+		var r enr.Record
+		r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+		r.Set(enr.TCP(30303 + i))
+		if err := enode.SignV4(&r, key); err != nil {
+			t.Fatal(err)
+		}
+		node, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[i] = node
+	}
+	sortByID(nodes)
+	return nodes
+}
+
+// testLink creates a link URL for a tree signed by a random key.
+func testLink(domain string) string {
+	key, _ := crypto.GenerateKey()
+	return (&linkEntry{domain, &key.PublicKey}).url()
+}
+
+func TestMakeTree(t *testing.T) {
+	nodes := testNodes(t, 2*maxChildren+1)
+	links := []string{testLink("a.nodes.example.org"), testLink("b.nodes.example.org")}
+	tree, err := MakeTree(3, nodes, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Seq() != 3 {
+		t.Errorf("wrong seq %d", tree.Seq())
+	}
+	if !reflect.DeepEqual(tree.Nodes(), nodes) {
+		t.Errorf("tree nodes don't match input nodes")
+	}
+	for i, l := range tree.Links() {
+		if l != links[0] && l != links[1] {
+			t.Errorf("unexpected link %d: %s", i, l)
+		}
+	}
+	if len(tree.Links()) != len(links) {
+		t.Errorf("wrong number of links: got %d, want %d", len(tree.Links()), len(links))
+	}
+	// All branch entries must fit into a TXT record.
+	for name, txt := range tree.ToTXT("nodes.example.org") {
+		if strings.HasPrefix(txt, branchPrefix) && len(txt) > 370 {
+			t.Errorf("branch entry %s too long: %d bytes", name, len(txt))
+		}
+	}
+}
+
+func TestTreeSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tree, err := MakeTree(1, testNodes(t, 3), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(key, "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		t.Fatalf("can't parse tree URL: %v", err)
+	}
+	if domain != "nodes.example.org" || !reflect.DeepEqual(pubkey, &key.PublicKey) {
+		t.Fatalf("wrong URL components: %s %x", domain, crypto.FromECDSAPub(pubkey))
+	}
+
+	// The signature can be transferred to an identical tree.
+	tree2, _ := MakeTree(1, tree.Nodes(), nil)
+	if err := tree2.SetSignature(pubkey, tree.Signature()); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	// It doesn't verify for a different tree or key.
+	tree3, _ := MakeTree(2, tree.Nodes(), nil)
+	if err := tree3.SetSignature(pubkey, tree.Signature()); err != errInvalidSig {
+		t.Fatalf("wrong error for signature of other tree: %v", err)
+	}
+	otherkey, _ := crypto.GenerateKey()
+	if err := tree2.SetSignature(&otherkey.PublicKey, tree.Signature()); err != errInvalidSig {
+		t.Fatalf("wrong error for signature of other key: %v", err)
+	}
+}
+
+func TestParseRoot(t *testing.T) {
+	tree, _ := MakeTree(5, testNodes(t, 2), nil)
+	key, _ := crypto.GenerateKey()
+	tree.Sign(key, "n")
+
+	root, err := parseRoot(tree.root.String())
+	if err != nil {
+		t.Fatalf("can't parse root: %v", err)
+	}
+	if !reflect.DeepEqual(root, *tree.root) {
+		t.Fatalf("wrong root:\ngot  %v\nwant %v", root.String(), tree.root.String())
+	}
+	if !root.verifySignature(&key.PublicKey) {
+		t.Fatal("parsed root signature invalid")
+	}
+
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3", entryError{"root", errSyntax}},
+		{"enrtree-root:v1 e=FOO l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=" + tree.Signature(), entryError{"root", errInvalidChild}},
+		{"enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=AAAA", entryError{"root", errInvalidSig}},
+	}
+	for _, test := range tests {
+		if _, err := parseRoot(test.input); !reflect.DeepEqual(err, test.err) {
+			t.Errorf("wrong error for %q: got %v, want %v", test.input, err, test.err)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	node := testNodes(t, 1)[0]
+	link := testLink("nodes.example.org")
+	branch := "enrtree-branch:2XS2367YHAXJFGLZHVAWLQD4ZY,H4FHT4B454P6UXFD7JCYQ5PWDY"
+
+	tests := []struct {
+		input string
+		e     entry
+		err   error
+	}{
+		{input: (&enrEntry{node}).String(), e: &enrEntry{node}},
+		{input: link, e: mustParseLink(link)},
+		{input: branch, e: &branchEntry{[]string{"2XS2367YHAXJFGLZHVAWLQD4ZY", "H4FHT4B454P6UXFD7JCYQ5PWDY"}}},
+		{input: "enrtree-branch:", e: &branchEntry{}},
+		{input: "enrtree-branch:AAA", err: entryError{"branch", errInvalidChild}},
+		{input: "enr:-----", err: entryError{"enr", errInvalidENR}},
+		{input: "enrtree://AAAA@nodes.example.org", err: entryError{"link", errBadPubkey}},
+		{input: "enrtree://nodes.example.org", err: entryError{"link", errNoPubkey}},
+		{input: "foo", err: errUnknownEntry},
+	}
+	for _, test := range tests {
+		e, err := parseEntry(test.input, enode.ValidSchemes)
+		if !reflect.DeepEqual(err, test.err) {
+			t.Errorf("wrong error for %q: got %v, want %v", test.input, err, test.err)
+			continue
+		}
+		if test.err == nil && e.String() != test.e.String() {
+			t.Errorf("wrong entry for %q:\ngot  %v\nwant %v", test.input, e, test.e)
+		}
+	}
+}
+
+func mustParseLink(url string) *linkEntry {
+	le, err := parseLink(url)
+	if err != nil {
+		panic(err)
+	}
+	return le
+}
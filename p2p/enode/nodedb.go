@@ -21,6 +21,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"sync"
@@ -40,6 +41,7 @@ const (
 	dbVersionKey   = "version" // Version of the database to flush if changes
 	dbNodePrefix   = "n:"      // Identifier to prefix node entries with
 	dbLocalPrefix  = "local:"
+	dbScorePrefix  = "score:"
 	dbDiscoverRoot = "v4"
 
 	// These fields are stored per ID and IP, the full key is "n:<ID>:v4:<IP>:findfail".
@@ -52,12 +54,18 @@ const (
 	// Local information is keyed by ID only, the full key is "local:<ID>:seq".
 	// Use localItemKey to create those keys.
 	dbLocalSeq = "seq"
+
+	// Reputation scores are keyed by ID only and outlive the node entries,
+	// the full key is "score:<ID>:value". Use scoreItemKey to create those keys.
+	dbScoreValue = "value"
+	dbScoreTime  = "time"
 )
 
 const (
-	dbNodeExpiration = 24 * time.Hour // Time after which an unseen node should be dropped.
-	dbCleanupCycle   = time.Hour      // Time period for running the expiration task.
-	dbVersion        = 8
+	dbNodeExpiration  = 24 * time.Hour // Time after which an unseen node should be dropped.
+	dbScoreExpiration = 24 * time.Hour // Time after which a decayed score should be dropped.
+	dbCleanupCycle    = time.Hour      // Time period for running the expiration task.
+	dbVersion         = 8
 )
 
 var zeroIP = make(net.IP, 16)
@@ -180,6 +188,14 @@ func localItemKey(id ID, field string) []byte {
 	return key
 }
 
+// scoreItemKey returns the key of a reputation score item.
+func scoreItemKey(id ID, field string) []byte {
+	key := append([]byte(dbScorePrefix), id[:]...)
+	key = append(key, ':')
+	key = append(key, field...)
+	return key
+}
+
 // fetchInt64 retrieves an integer associated with a particular key.
 func (db *DB) fetchInt64(key []byte) int64 {
 	blob, err := db.lvl.Get(key, nil)
@@ -300,6 +316,7 @@ func (db *DB) expirer() {
 		select {
 		case <-tick.C:
 			db.expireNodes()
+			db.expireScores()
 		case <-db.quit:
 			return
 		}
@@ -345,6 +362,27 @@ func (db *DB) expireNodes() {
 	}
 }
 
+// expireScores deletes the reputation scores that have not been updated for
+// some time. Those have decayed to practically zero.
+func (db *DB) expireScores() {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbScorePrefix)), nil)
+	defer it.Release()
+
+	threshold := time.Now().Add(-dbScoreExpiration).Unix()
+	for it.Next() {
+		key := it.Key()
+		if !bytes.HasSuffix(key, []byte(":"+dbScoreTime)) {
+			continue
+		}
+		if time, _ := binary.Varint(it.Value()); time < threshold {
+			var id ID
+			copy(id[:], key[len(dbScorePrefix):])
+			db.lvl.Delete(scoreItemKey(id, dbScoreValue), nil)
+			db.lvl.Delete(key, nil)
+		}
+	}
+}
+
 // LastPingReceived retrieves the time of the last ping packet received from
 // a remote node.
 func (db *DB) LastPingReceived(id ID, ip net.IP) time.Time {
@@ -378,6 +416,23 @@ func (db *DB) UpdateFindFails(id ID, ip net.IP, fails int) error {
 	return db.storeInt64(nodeItemKey(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// PeerScore retrieves the reputation score of a node and the time it was last
+// updated.
+func (db *DB) PeerScore(id ID) (float64, time.Time) {
+	score := math.Float64frombits(db.fetchUint64(scoreItemKey(id, dbScoreValue)))
+	return score, time.Unix(db.fetchInt64(scoreItemKey(id, dbScoreTime)), 0)
+}
+
+// UpdatePeerScore stores the reputation score of a node. Scores are kept apart
+// from the node entries, so they survive the expiry of unseen nodes and are only
+// dropped once they have decayed.
+func (db *DB) UpdatePeerScore(id ID, score float64, instance time.Time) error {
+	if err := db.storeUint64(scoreItemKey(id, dbScoreValue), math.Float64bits(score)); err != nil {
+		return err
+	}
+	return db.storeInt64(scoreItemKey(id, dbScoreTime), instance.Unix())
+}
+
 // LocalSeq retrieves the local record sequence counter.
 func (db *DB) localSeq(id ID) uint64 {
 	return db.fetchUint64(nodeItemKey(id, zeroIP, dbLocalSeq))
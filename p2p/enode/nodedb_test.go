@@ -154,6 +154,16 @@ func TestDBFetchStore(t *testing.T) {
 	if stored := db.FindFails(node.ID(), node.IP()); stored != num {
 		t.Errorf("find-node fails: value mismatch: have %v, want %v", stored, num)
 	}
+	// Check fetch/store operations on a node score object
+	if score, updated := db.PeerScore(node.ID()); score != 0 || updated.Unix() != 0 {
+		t.Errorf("score: non-existing object: %v %v", score, updated)
+	}
+	if err := db.UpdatePeerScore(node.ID(), -12.5, inst); err != nil {
+		t.Errorf("score: failed to update: %v", err)
+	}
+	if score, updated := db.PeerScore(node.ID()); score != -12.5 || updated.Unix() != inst.Unix() {
+		t.Errorf("score: value mismatch: have %v %v, want %v %v", score, updated, -12.5, inst)
+	}
 	// Check fetch/store operations on an actual node object
 	if stored := db.Node(node.ID()); stored != nil {
 		t.Errorf("node: non-existing object: %v", stored)
@@ -462,3 +472,34 @@ func TestDBExpiration(t *testing.T) {
 		}
 	}
 }
+
+// This test checks that reputation scores survive the expiry of their node and
+// are only dropped once they have decayed.
+func TestDBExpireScores(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var (
+		node  = NewV4(hexPubkey("1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439"), net.IP{127, 0, 0, 1}, 30303, 30303)
+		stale = ID{1}
+	)
+	if err := db.UpdateNode(node); err != nil {
+		t.Fatalf("failed to insert node: %v", err)
+	}
+	db.UpdateLastPongReceived(node.ID(), node.IP(), time.Now().Add(-dbNodeExpiration-time.Minute))
+	db.UpdatePeerScore(node.ID(), -10, time.Now())
+	db.UpdatePeerScore(stale, 5, time.Now().Add(-dbScoreExpiration-time.Minute))
+
+	db.expireNodes()
+	db.expireScores()
+
+	if db.Node(node.ID()) != nil {
+		t.Errorf("expired node still present")
+	}
+	if score, _ := db.PeerScore(node.ID()); score != -10 {
+		t.Errorf("score of expired node dropped: have %v, want %v", score, -10)
+	}
+	if score, updated := db.PeerScore(stale); score != 0 || updated.Unix() != 0 {
+		t.Errorf("decayed score still present: %v %v", score, updated)
+	}
+}
@@ -113,6 +113,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// rep receives reputation reports if set
+	rep *reputation
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -165,6 +168,20 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Report records an observation about the behaviour of the peer. Reports change
+// the reputation score of the node, which is taken into account when choosing
+// nodes to dial and inbound peers to evict. Peers which are not trusted are
+// disconnected once their score drops below minPeerScore.
+func (p *Peer) Report(ev ReputationEvent) {
+	if p.rep == nil {
+		return
+	}
+	if score := p.rep.report(p.ID(), ev); score < minPeerScore && !p.rw.is(trustedConn) {
+		p.log.Debug("Dropping peer with bad reputation", "score", score, "event", ev)
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	id := p.ID()
@@ -147,6 +147,54 @@ func TestPeerDisconnect(t *testing.T) {
 	}
 }
 
+// This test checks that peers are dropped once reports push their score below
+// minPeerScore, unless they are trusted.
+func TestPeerReportDisconnect(t *testing.T) {
+	rep, _ := newTestReputation(t)
+	defer rep.db.Close()
+
+	proto := Protocol{
+		Name:   "a",
+		Length: 1,
+		Run: func(p *Peer, rw MsgReadWriter) error {
+			p.Report(RepInvalidBlock)
+			p.Report(RepInvalidBlock)
+			_, err := rw.ReadMsg()
+			return err
+		},
+	}
+	for _, trusted := range []bool{false, true} {
+		fd1, fd2 := net.Pipe()
+		c1 := &conn{fd: fd1, node: newNode(randomID(), nil), transport: newTestTransport(&newkey().PublicKey, fd1)}
+		c2 := &conn{fd: fd2, node: newNode(randomID(), nil), transport: newTestTransport(&newkey().PublicKey, fd2)}
+		c1.caps, c2.caps = []Cap{proto.cap()}, []Cap{proto.cap()}
+		if trusted {
+			c1.flags |= trustedConn
+		}
+		peer := newPeer(c1, []Protocol{proto})
+		peer.rep = rep
+
+		disc := make(chan error, 1)
+		go func() {
+			_, err := peer.run()
+			disc <- err
+		}()
+		select {
+		case reason := <-disc:
+			if trusted {
+				t.Errorf("trusted peer dropped: %v", reason)
+			} else if reason != DiscUselessPeer {
+				t.Errorf("run returned wrong reason: got %v, want %v", reason, DiscUselessPeer)
+			}
+		case <-time.After(500 * time.Millisecond):
+			if !trusted {
+				t.Error("misbehaving peer not dropped")
+			}
+		}
+		c2.close(errors.New("test done"))
+	}
+}
+
 // This test is supposed to verify that Peer can reliably handle
 // multiple causes of disconnection occurring at the same time.
 func TestPeerDisconnectRace(t *testing.T) {
@@ -0,0 +1,355 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/log"
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+)
+
+const (
+	// Scores decay towards zero, halving every scoreHalfLife.
+	scoreHalfLife = time.Hour
+
+	// Scores are kept within [minScore, maxScore].
+	minScore = -100
+	maxScore = 100
+
+	// Nodes scoring below minDialScore are neither dialed as dynamic peers nor
+	// accepted as inbound peers.
+	minDialScore = -25
+
+	// Connected peers which are not trusted are dropped once a report makes them
+	// score below minPeerScore.
+	minPeerScore = -50
+
+	// An inbound connection replaces the worst scoring inbound peer when all
+	// inbound slots are taken if its score is at least evictScoreMargin higher.
+	evictScoreMargin = 10
+
+	// Maximum number of nodes whose scores are kept in memory. Scores of all
+	// other nodes are read from the node database.
+	maxTrackedScores = 1000
+
+	// Changed scores are written to the node database every scoreFlushInterval.
+	scoreFlushInterval = time.Minute
+)
+
+// ReputationEvent is an observation about the behaviour of a peer which
+// protocols report using Peer.Report.
+type ReputationEvent uint
+
+const (
+	RepGoodDelivery    ReputationEvent = iota // useful response delivered in time
+	RepUselessResponse                        // response without useful content
+	RepTimeout                                // request not answered in time
+	RepProtocolError                          // invalid or unexpected message
+	RepInvalidBlock                           // invalid block or header
+)
+
+var repEventToString = [...]string{
+	RepGoodDelivery:    "goodDelivery",
+	RepUselessResponse: "uselessResponse",
+	RepTimeout:         "timeout",
+	RepProtocolError:   "protocolError",
+	RepInvalidBlock:    "invalidBlock",
+}
+
+// repEventWeights are the score changes caused by events.
+var repEventWeights = [...]float64{
+	RepGoodDelivery:    1,
+	RepUselessResponse: -2,
+	RepTimeout:         -5,
+	RepProtocolError:   -20,
+	RepInvalidBlock:    -50,
+}
+
+func (ev ReputationEvent) String() string {
+	if int(ev) >= len(repEventToString) {
+		return fmt.Sprintf("unknown reputation event %d", ev)
+	}
+	return repEventToString[ev]
+}
+
+// discReasonEvents maps the reasons of locally initiated disconnects to the
+// events reported for them. Only the disconnects caused by the p2p layer itself
+// are reported, protocols dropping a peer report the offence themselves.
+var discReasonEvents = map[DiscReason]ReputationEvent{
+	DiscProtocolError: RepProtocolError,
+}
+
+// PeerScoreInfo represents the reputation of a node.
+type PeerScoreInfo struct {
+	ID        string          `json:"id"`               // Unique node identifier
+	Score     float64         `json:"score"`            // Current score, decayed to now
+	Updated   time.Time       `json:"updated"`          // Time of the last reported event
+	Connected bool            `json:"connected"`        // Whetvchain the node is a connected peer
+	Events    map[string]uint `json:"events,omitempty"` // Events reported since startup
+}
+
+// reputation tracks the scores of remote nodes. Scores change with reported
+// events, decay towards zero over time and are persisted in the node database.
+//
+// The scores of the connected peers and of the recently seen nodes are kept in
+// memory, changed ones are written to the database periodically.
+type reputation struct {
+	db  *enode.DB
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[enode.ID]*scoreEntry
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+type scoreEntry struct {
+	value     float64
+	updated   time.Time
+	events    map[ReputationEvent]uint
+	dirty     bool // changed since last written to the database
+	connected bool // node is a connected peer, the entry is never evicted
+}
+
+// newReputation creates a reputation tracker on top of the node database, which
+// writes changed scores in the background until closed.
+func newReputation(db *enode.DB) *reputation {
+	r := &reputation{
+		db:      db,
+		now:     time.Now,
+		entries: make(map[enode.ID]*scoreEntry),
+		quit:    make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.loop()
+	return r
+}
+
+// loop writes the changed scores to the database periodically.
+func (r *reputation) loop() {
+	defer r.wg.Done()
+
+	flush := time.NewTicker(scoreFlushInterval)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-flush.C:
+			r.flush()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// close stops the background writes and flushes all changed scores. The node
+// database must still be open.
+func (r *reputation) close() {
+	close(r.quit)
+	r.wg.Wait()
+	r.flush()
+}
+
+// flush writes the scores changed since the last flush to the database. The
+// writes are done without holding the lock, entries changed meanwhile remain
+// dirty and are written by the next flush.
+func (r *reputation) flush() {
+	type scoreUpdate struct {
+		id      enode.ID
+		value   float64
+		updated time.Time
+	}
+	r.mu.Lock()
+	var updates []scoreUpdate
+	for id, e := range r.entries {
+		if e.dirty {
+			updates = append(updates, scoreUpdate{id, e.value, e.updated})
+		}
+	}
+	r.mu.Unlock()
+
+	for i, u := range updates {
+		if err := r.db.UpdatePeerScore(u.id, u.value, u.updated); err != nil {
+			log.Warn("Failed to store peer score", "id", u.id, "err", err)
+			updates = updates[:i]
+			break
+		}
+	}
+	r.mu.Lock()
+	for _, u := range updates {
+		if e := r.entries[u.id]; e != nil && e.updated.Equal(u.updated) && e.value == u.value {
+			e.dirty = false
+		}
+	}
+	r.mu.Unlock()
+}
+
+// report applies an event to the score of a node, returning the new score.
+func (r *reputation) report(id enode.ID, ev ReputationEvent) float64 {
+	if int(ev) >= len(repEventWeights) {
+		return r.score(id)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	e := r.entry(id)
+	e.value = decayScore(e.value, now.Sub(e.updated)) + repEventWeights[ev]
+	e.value = math.Max(minScore, math.Min(maxScore, e.value))
+	e.updated = now
+	e.events[ev]++
+	e.dirty = true
+	return e.value
+}
+
+// reportDisconnect reports the event corresponding to a locally initiated
+// disconnect, if any.
+func (r *reputation) reportDisconnect(id enode.ID, reason DiscReason) {
+	if ev, ok := discReasonEvents[reason]; ok {
+		r.report(id, ev)
+	}
+}
+
+// connect keeps the score of a newly connected peer in memory until it
+// disconnects.
+func (r *reputation) connect(id enode.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entry(id).connected = true
+}
+
+// disconnect allows the score of a disconnected peer to be evicted from memory.
+func (r *reputation) disconnect(id enode.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e := r.entries[id]; e != nil {
+		e.connected = false
+	}
+}
+
+// score returns the current score of a node.
+func (r *reputation) score(id enode.ID) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.entry(id)
+	return decayScore(e.value, r.now().Sub(e.updated))
+}
+
+// entry returns the in-memory entry of a node, loading it from the database if
+// necessary. Loading an entry may evict another one. It must be called with
+// r.mu held.
+func (r *reputation) entry(id enode.ID) *scoreEntry {
+	if e := r.entries[id]; e != nil {
+		return e
+	}
+	if len(r.entries) >= maxTrackedScores {
+		r.evictEntry()
+	}
+	e := &scoreEntry{events: make(map[ReputationEvent]uint)}
+	e.value, e.updated = r.db.PeerScore(id)
+	r.entries[id] = e
+	return e
+}
+
+// evictEntry removes the in-memory entry closest to a zero score, which is
+// neither of a connected peer nor waiting to be written to the database. Its
+// score remains available in the database. If all entries are pinned, none is
+// evicted until the next flush.
+func (r *reputation) evictEntry() {
+	var (
+		now      = r.now()
+		evict    *enode.ID
+		minValue = math.Inf(1)
+	)
+	for id, e := range r.entries {
+		if e.dirty || e.connected {
+			continue
+		}
+		if v := math.Abs(decayScore(e.value, now.Sub(e.updated))); v < minValue {
+			id := id
+			evict, minValue = &id, v
+		}
+	}
+	if evict != nil {
+		delete(r.entries, *evict)
+	}
+}
+
+// scores returns the scores of the nodes with events reported since startup and
+// of the given connected peers, sorted by descending score.
+func (r *reputation) scores(connected map[enode.ID]bool) []*PeerScoreInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		now   = r.now()
+		infos = make([]*PeerScoreInfo, 0, len(r.entries)+len(connected))
+	)
+	for id, e := range r.entries {
+		// Skip the nodes whose scores were only looked up
+		if len(e.events) == 0 && !connected[id] {
+			continue
+		}
+		info := &PeerScoreInfo{
+			ID:        id.String(),
+			Score:     decayScore(e.value, now.Sub(e.updated)),
+			Updated:   e.updated,
+			Connected: connected[id],
+			Events:    make(map[string]uint, len(e.events)),
+		}
+		for ev, n := range e.events {
+			info.Events[ev.String()] = n
+		}
+		infos = append(infos, info)
+	}
+	for id := range connected {
+		if r.entries[id] != nil {
+			continue
+		}
+		value, updated := r.db.PeerScore(id)
+		infos = append(infos, &PeerScoreInfo{
+			ID:        id.String(),
+			Score:     decayScore(value, now.Sub(updated)),
+			Updated:   updated,
+			Connected: true,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Score != infos[j].Score {
+			return infos[i].Score > infos[j].Score
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// decayScore returns the value of a score after the given time has passed.
+func decayScore(value float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 || value == 0 {
+		return value
+	}
+	return value * math.Exp2(-float64(elapsed)/float64(scoreHalfLife))
+}
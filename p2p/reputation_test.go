@@ -0,0 +1,176 @@
+// Copyright 2019 The go-etvchaineum Authors
+// This file is part of the go-etvchaineum library.
+//
+// The go-etvchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-etvchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-etvchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
+)
+
+// newTestReputation creates a reputation tracker on an in-memory database with
+// a clock that only advances when the returned function is called.
+func newTestReputation(t *testing.T) (*reputation, func(time.Duration)) {
+	db, err := enode.OpenDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1500000000, 0)
+	r := newReputation(db)
+	r.now = func() time.Time { return now }
+	return r, func(d time.Duration) { now = now.Add(d) }
+}
+
+func checkScore(t *testing.T, r *reputation, id enode.ID, want float64) {
+	t.Helper()
+	if score := r.score(id); math.Abs(score-want) > 1e-9 {
+		t.Errorf("wrong score for %v: got %v, want %v", id, score, want)
+	}
+}
+
+func TestReputationDecay(t *testing.T) {
+	r, wait := newTestReputation(t)
+	defer r.db.Close()
+	id := uintID(1)
+
+	checkScore(t, r, id, 0)
+	r.report(id, RepInvalidBlock)
+	r.report(id, RepGoodDelivery)
+	checkScore(t, r, id, -49)
+
+	wait(scoreHalfLife)
+	checkScore(t, r, id, -24.5)
+	r.report(id, RepTimeout)
+	checkScore(t, r, id, -29.5)
+	wait(2 * scoreHalfLife)
+	checkScore(t, r, id, -29.5/4)
+}
+
+func TestReputationLimits(t *testing.T) {
+	r, _ := newTestReputation(t)
+	defer r.db.Close()
+	good, bad := uintID(1), uintID(2)
+
+	for i := 0; i < 3*maxScore; i++ {
+		r.report(good, RepGoodDelivery)
+	}
+	for i := 0; i < 3; i++ {
+		r.report(bad, RepInvalidBlock)
+	}
+	checkScore(t, r, good, maxScore)
+	checkScore(t, r, bad, minScore)
+}
+
+// This test checks that changed scores are written to the node database and
+// survive the eviction of in-memory entries.
+func TestReputationPersistence(t *testing.T) {
+	r, wait := newTestReputation(t)
+	defer r.db.Close()
+	id := uintID(1)
+
+	r.report(id, RepProtocolError)
+	r.report(uintID(2), RepGoodDelivery)
+	for i := 0; i < maxTrackedScores-2; i++ {
+		r.report(uintID(uint32(i+10)), RepInvalidBlock)
+	}
+	// Unwritten scores are never evicted.
+	r.report(uintID(3), RepTimeout)
+	if len(r.entries) != maxTrackedScores+1 {
+		t.Fatalf("wrong number of tracked entries: %d", len(r.entries))
+	}
+	if score, _ := r.db.PeerScore(id); score != 0 {
+		t.Fatalf("score written before flush: %v", score)
+	}
+	r.flush()
+	r.score(uintID(4))
+	if len(r.entries) != maxTrackedScores+1 {
+		t.Fatalf("wrong number of tracked entries: %d", len(r.entries))
+	}
+	if r.entries[uintID(2)] != nil {
+		t.Error("entry closest to zero not evicted")
+	}
+
+	// A new tracker on the same database knows the score.
+	r2 := newReputation(r.db)
+	defer r2.close()
+	r2.now = r.now
+	checkScore(t, r2, id, -20)
+	wait(scoreHalfLife)
+	checkScore(t, r2, id, -10)
+	checkScore(t, r2, uintID(2), 0.5)
+}
+
+// This test checks that the scores of connected peers are kept in memory.
+func TestReputationConnected(t *testing.T) {
+	r, _ := newTestReputation(t)
+	defer r.db.Close()
+
+	// Fill the tracked entries with nonzero scores, pinning the zero score of
+	// the connected peer.
+	r.connect(uintID(1))
+	for i := 0; i < maxTrackedScores; i++ {
+		r.report(uintID(uint32(i+10)), RepGoodDelivery)
+	}
+	r.flush()
+	if r.entries[uintID(1)] == nil {
+		t.Fatal("entry of connected peer evicted")
+	}
+	// Once disconnected, the zero score is the first to be evicted
+	r.disconnect(uintID(1))
+	r.score(uintID(2))
+	if r.entries[uintID(1)] != nil {
+		t.Fatal("entry of disconnected peer not evicted")
+	}
+}
+
+func TestReputationScores(t *testing.T) {
+	r, _ := newTestReputation(t)
+	defer r.db.Close()
+
+	r.report(uintID(1), RepGoodDelivery)
+	r.report(uintID(1), RepGoodDelivery)
+	r.report(uintID(2), RepTimeout)
+	infos := r.scores(map[enode.ID]bool{uintID(2): true, uintID(3): true})
+
+	if len(infos) != 3 {
+		t.Fatalf("wrong number of scores: %d", len(infos))
+	}
+	want := []struct {
+		id        enode.ID
+		score     float64
+		connected bool
+		events    map[string]uint
+	}{
+		{uintID(1), 2, false, map[string]uint{"goodDelivery": 2}},
+		{uintID(3), 0, true, nil},
+		{uintID(2), -5, true, map[string]uint{"timeout": 1}},
+	}
+	for i, w := range want {
+		info := infos[i]
+		if info.ID != w.id.String() || info.Score != w.score || info.Connected != w.connected || len(info.Events) != len(w.events) {
+			t.Errorf("info %d mismatch: %+v", i, info)
+			continue
+		}
+		for ev, n := range w.events {
+			if info.Events[ev] != n {
+				t.Errorf("info %d: wrong %s event count %d, want %d", i, ev, info.Events[ev], n)
+			}
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
+	"math"
 	"net"
 	"sort"
 	"sync"
@@ -34,7 +35,7 @@ import (
 	"github.com/etvchaineum/go-etvchaineum/event"
 	"github.com/etvchaineum/go-etvchaineum/log"
 	"github.com/etvchaineum/go-etvchaineum/p2p/discover"
-	"github.com/etvchaineum/go-etvchaineum/p2p/discv5"
+	"github.com/etvchaineum/go-etvchaineum/p2p/dnsdisc"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enode"
 	"github.com/etvchaineum/go-etvchaineum/p2p/enr"
 	"github.com/etvchaineum/go-etvchaineum/p2p/nat"
@@ -83,8 +84,8 @@ type Config struct {
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
 
-	// DiscoveryV5 specifies whetvchain the new topic-discovery based V5 discovery
-	// protocol should be started or not.
+	// DiscoveryV5 specifies whetvchain the topic-discovery based V5 discovery
+	// protocol should be started or not. It runs alongside V4 on the same port.
 	DiscoveryV5 bool `toml:",omitempty"`
 
 	// Name sets the node name of this server.
@@ -98,7 +99,12 @@ type Config struct {
 	// BootstrapNodesV5 are used to establish connectivity
 	// with the rest of the network using the V5 discovery
 	// protocol.
-	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
+	BootstrapNodesV5 []*enode.Node `toml:",omitempty"`
+
+	// DiscoveryDNS lists the enrtree:// URLs of signed DNS node lists (EIP-1459).
+	// Nodes taken from these lists are dialed alongside the results of
+	// discovery lookups.
+	DiscoveryDNS []string `toml:",omitempty"`
 
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
@@ -164,12 +170,14 @@ type Server struct {
 	running bool
 
 	nodedb       *enode.DB
+	rep          *reputation
 	localnode    *enode.LocalNode
 	ntab         discoverTable
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
-	DiscV5       *discv5.Network
+	DiscV5       *discover.UDPv5
+	dnsdisc      *dnsdisc.Client
 
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
@@ -386,7 +394,7 @@ type sharedUDPConn struct {
 	unhandled chan discover.ReadPacket
 }
 
-// ReadFromUDP implements discv5.conn
+// ReadFromUDP implements discover.conn
 func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
 	packet, ok := <-s.unhandled
 	if !ok {
@@ -400,7 +408,7 @@ func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err err
 	return l, packet.Addr, nil
 }
 
-// Close implements discv5.conn
+// Close implements discover.conn
 func (s *sharedUDPConn) Close() error {
 	return nil
 }
@@ -455,8 +463,16 @@ func (srv *Server) Start() (err error) {
 		return err
 	}
 
+	srv.rep = newReputation(srv.nodedb)
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.localnode.ID(), srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	dialer.rep = srv.rep
+	if srv.dnsdisc != nil {
+		dnsNodes := make(chan *enode.Node, dnsNodeBuffer)
+		dialer.dnsNodes = dnsNodes
+		srv.loopWG.Add(1)
+		go srv.dnsDiscoveryLoop(dnsNodes)
+	}
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	return nil
@@ -549,21 +565,31 @@ func (srv *Server) setupDiscovery() error {
 		}
 		srv.ntab = ntab
 	}
+	// DNS node lists
+	if !srv.NoDiscovery && len(srv.DiscoveryDNS) > 0 {
+		client, err := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log}, srv.DiscoveryDNS...)
+		if err != nil {
+			return err
+		}
+		srv.dnsdisc = client
+	}
 	// Discovery V5
 	if srv.DiscoveryV5 {
-		var ntab *discv5.Network
+		cfg := discover.Config{
+			PrivateKey:  srv.PrivateKey,
+			NetRestrict: srv.NetRestrict,
+			Bootnodes:   srv.BootstrapNodesV5,
+		}
+		var ntab *discover.UDPv5
 		var err error
 		if sconn != nil {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, sconn, "", srv.NetRestrict)
+			ntab, err = discover.ListenV5(sconn, srv.localnode, cfg)
 		} else {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, conn, "", srv.NetRestrict)
+			ntab, err = discover.ListenV5(conn, srv.localnode, cfg)
 		}
 		if err != nil {
 			return err
 		}
-		if err := ntab.SetFallbackNodes(srv.BootstrapNodesV5); err != nil {
-			return err
-		}
 		srv.DiscV5 = ntab
 	}
 	return nil
@@ -605,10 +631,12 @@ func (srv *Server) run(dialstate dialer) {
 	srv.log.Info("Started P2P networking", "self", srv.localnode.Node())
 	defer srv.loopWG.Done()
 	defer srv.nodedb.Close()
+	defer srv.rep.close()
 
 	var (
 		peers        = make(map[enode.ID]*Peer)
-		inboundCount = 0
+		evicting     = make(map[enode.ID]bool) // inbound peers disconnected to free their slot
+		inboundCount = 0                       // excludes evicted peers
 		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
 		taskdone     = make(chan task, maxActiveDialTasks)
 		runningTasks []task
@@ -712,18 +740,24 @@ running:
 				c.flags |= trustedConn
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
+			err := srv.encHandshakeChecks(peers, evicting, inboundCount, c)
+			if err == DiscTooManyPeers && srv.evictInbound(peers, evicting, c) {
+				inboundCount--
+				err = srv.encHandshakeChecks(peers, evicting, inboundCount, c)
+			}
 			select {
-			case c.cont <- srv.encHandshakeChecks(peers, inboundCount, c):
+			case c.cont <- err:
 			case <-srv.quit:
 				break running
 			}
 		case c := <-srv.addpeer:
 			// At this point the connection is past the protocol handshake.
 			// Its capabilities are known and the remote identity is verified.
-			err := srv.protoHandshakeChecks(peers, inboundCount, c)
+			err := srv.protoHandshakeChecks(peers, evicting, inboundCount, c)
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.rep = srv.rep
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -733,6 +767,7 @@ running:
 				srv.log.Debug("Adding p2p peer", "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				go srv.runPeer(p)
 				peers[c.node.ID()] = p
+				srv.rep.connect(c.node.ID())
 				if p.Inbound() {
 					inboundCount++
 				}
@@ -750,9 +785,14 @@ running:
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			pd.log.Debug("Removing p2p peer", "duration", d, "peers", len(peers)-1, "req", pd.requested, "err", pd.err)
 			delete(peers, pd.ID())
-			if pd.Inbound() {
+			if pd.Inbound() && !evicting[pd.ID()] {
 				inboundCount--
 			}
+			delete(evicting, pd.ID())
+			srv.rep.disconnect(pd.ID())
+			if !pd.requested {
+				srv.rep.reportDisconnect(pd.ID(), discReasonForError(pd.err))
+			}
 		}
 	}
 
@@ -779,19 +819,19 @@ running:
 	}
 }
 
-func (srv *Server) protoHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+func (srv *Server) protoHandshakeChecks(peers map[enode.ID]*Peer, evicting map[enode.ID]bool, inboundCount int, c *conn) error {
 	// Drop connections with no matching protocols.
 	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
 		return DiscUselessPeer
 	}
 	// Repeat the encryption handshake checks because the
 	// peer set might have changed between the handshakes.
-	return srv.encHandshakeChecks(peers, inboundCount, c)
+	return srv.encHandshakeChecks(peers, evicting, inboundCount, c)
 }
 
-func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, evicting map[enode.ID]bool, inboundCount int, c *conn) error {
 	switch {
-	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
+	case !c.is(trustedConn|staticDialedConn) && len(peers)-len(evicting) >= srv.MaxPeers:
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
 		return DiscTooManyPeers
@@ -799,11 +839,41 @@ func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case !c.is(trustedConn) && c.is(inboundConn) && srv.rep.score(c.node.ID()) < minDialScore:
+		return DiscUselessPeer
 	default:
 		return nil
 	}
 }
 
+// evictInbound disconnects the inbound peer with the lowest reputation score if
+// the inbound connection c scores better by at least evictScoreMargin. Trusted
+// peers are never evicted. It returns true if a peer was evicted.
+func (srv *Server) evictInbound(peers map[enode.ID]*Peer, evicting map[enode.ID]bool, c *conn) bool {
+	if !c.is(inboundConn) {
+		return false
+	}
+	var (
+		worst      *Peer
+		worstScore = math.Inf(1)
+	)
+	for id, p := range peers {
+		if !p.Inbound() || p.rw.is(trustedConn) || evicting[id] {
+			continue
+		}
+		if score := srv.rep.score(id); score < worstScore {
+			worst, worstScore = p, score
+		}
+	}
+	if worst == nil || srv.rep.score(c.node.ID()) < worstScore+evictScoreMargin {
+		return false
+	}
+	worst.log.Debug("Evicting inbound peer with low reputation", "score", worstScore)
+	evicting[worst.ID()] = true
+	worst.Disconnect(DiscTooManyPeers)
+	return true
+}
+
 func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
@@ -1078,3 +1148,19 @@ func (srv *Server) PeersInfo() []*PeerInfo {
 	}
 	return infos
 }
+
+// PeerScores returns the reputation scores of all connected peers and of the
+// nodes with recently reported events, sorted by descending score.
+func (srv *Server) PeerScores() []*PeerScoreInfo {
+	srv.lock.Lock()
+	rep := srv.rep
+	srv.lock.Unlock()
+	if rep == nil {
+		return []*PeerScoreInfo{}
+	}
+	connected := make(map[enode.ID]bool)
+	for _, peer := range srv.Peers() {
+		connected[peer.ID()] = true
+	}
+	return rep.scores(connected)
+}
@@ -230,6 +230,7 @@ func TestServerTaskScheduling(t *testing.T) {
 		Config:    Config{MaxPeers: 10},
 		localnode: enode.NewLocalNode(db, newkey()),
 		nodedb:    db,
+		rep:       newReputation(db),
 		quit:      make(chan struct{}),
 		ntab:      fakeTable{},
 		running:   true,
@@ -279,6 +280,7 @@ func TestServerManyTasks(t *testing.T) {
 			quit:      make(chan struct{}),
 			localnode: enode.NewLocalNode(db, newkey()),
 			nodedb:    db,
+			rep:       newReputation(db),
 			ntab:      fakeTable{},
 			running:   true,
 			log:       log.New(),
@@ -414,6 +416,117 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+// This test checks that inbound peers with a low reputation are evicted when a
+// better scoring node connects while all inbound slots are taken.
+func TestServerInboundEviction(t *testing.T) {
+	remoteKey := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   5,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remoteKey.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+
+	// Fill up the peer set.
+	var ids []enode.ID
+	for i := 0; i < 5; i++ {
+		ids = append(ids, randomID())
+		if err := srv.checkpoint(newconn(ids[i]), srv.addpeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	// Without reputation differences, no peer is replaced.
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for unknown node:", err)
+	}
+	// A node with a better reputation replaces a misbehaving peer.
+	badID := ids[2]
+	srv.rep.report(badID, RepInvalidBlock)
+	goodID := randomID()
+	srv.rep.report(goodID, RepGoodDelivery)
+	c := newconn(goodID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for good node @posthandshake:", err)
+	}
+	if err := srv.checkpoint(c, srv.addpeer); err != nil {
+		t.Fatal("unexpected error for good node @addpeer:", err)
+	}
+	// The bad peer is gone, its slot is not reused.
+	deadline := time.Now().Add(5 * time.Second)
+	for containsPeer(srv.Peers(), badID) {
+		if time.Now().After(deadline) {
+			t.Fatal("bad peer not evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !containsPeer(srv.Peers(), goodID) {
+		t.Fatal("good node not added")
+	}
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for unknown node after eviction:", err)
+	}
+	scores := srv.PeerScores()
+	if len(scores) != 6 || scores[0].ID != goodID.String() || !scores[0].Connected {
+		t.Fatalf("wrong peer scores: %+v", scores)
+	}
+}
+
+// This test checks that inbound connections of nodes with a bad reputation are
+// rejected even if slots are free, unless the node is trusted.
+func TestServerInboundReputation(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   5,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID, flags connFlag) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&newkey().PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: flags, node: node, cont: make(chan error)}
+	}
+	badID := randomID()
+	srv.rep.report(badID, RepInvalidBlock)
+
+	if err := srv.checkpoint(newconn(badID, inboundConn), srv.posthandshake); err != DiscUselessPeer {
+		t.Fatal("wrong error for node with bad reputation:", err)
+	}
+	if err := srv.checkpoint(newconn(randomID(), inboundConn), srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for unknown node:", err)
+	}
+	if err := srv.checkpoint(newconn(badID, inboundConn|trustedConn), srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for trusted node with bad reputation:", err)
+	}
+}
+
+func containsPeer(peers []*Peer, id enode.ID) bool {
+	for _, p := range peers {
+		if p.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()
@@ -141,16 +141,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Etvchain core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -191,9 +191,23 @@ type ChainConfig struct {
 	PetersburgBlock     *big.Int `json:"petersburgBlock,omitempty"`     // Petersburg switch block (nil = same as Constantinople)
 	EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          // EWASM switch block (nil = no fork, 0 = already activated)
 
+	// ExtendedPrecompilesBlock activates the blake2 compression and ed25519 verifier
+	// precompiles on top of the Byzantium ones, so it must not precede Byzantium.
+	ExtendedPrecompilesBlock *big.Int           `json:"extendedPrecompilesBlock,omitempty"` // Extended precompiles switch block (nil = no fork, 0 = already activated)
+	ExtendedPrecompiles      *PrecompilesConfig `json:"extendedPrecompiles,omitempty"`      // Extended precompiles pricing (nil = protocol defaults)
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"echash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+	BFT    *BFTConfig    `json:"bft,omitempty"`
+}
+
+// PrecompilesConfig is the pricing of the extended precompiled contracts. Unset
+// prices fall back to the protocol defaults.
+type PrecompilesConfig struct {
+	Blake2FRoundGas         uint64 `json:"blake2FRoundGas,omitempty"`         // Per-round price for a blake2 compression
+	Ed25519VerifyBaseGas    uint64 `json:"ed25519VerifyBaseGas,omitempty"`    // Base price for an ed25519 signature verification
+	Ed25519VerifyPerWordGas uint64 `json:"ed25519VerifyPerWordGas,omitempty"` // Per-word price for hashing the message of an ed25519 verification
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -215,6 +229,19 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// BFTConfig is the consensus engine configs for Byzantine fault tolerant sealing
+// with immediate finality.
+type BFTConfig struct {
+	Period         uint64 `json:"period"`         // Minimum number of seconds between blocks
+	Epoch          uint64 `json:"epoch"`          // Epoch length to reset votes and checkpoint
+	RequestTimeout uint64 `json:"requestTimeout"` // Milliseconds before a consensus round is abandoned
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *BFTConfig) String() string {
+	return "bft"
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -223,10 +250,12 @@ func (c *ChainConfig) String() string {
 		engine = c.Ethash
 	case c.Clique != nil:
 		engine = c.Clique
+	case c.BFT != nil:
+		engine = c.BFT
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v  ConstantinopleFix: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v  ConstantinopleFix: %v ExtendedPrecompiles: %v Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -237,6 +266,7 @@ func (c *ChainConfig) String() string {
 		c.ByzantiumBlock,
 		c.ConstantinopleBlock,
 		c.PetersburgBlock,
+		c.ExtendedPrecompilesBlock,
 		engine,
 	)
 }
@@ -288,6 +318,45 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+// IsExtendedPrecompiles returns whetvchain num is either equal to the extended
+// precompiles fork block or greater.
+func (c *ChainConfig) IsExtendedPrecompiles(num *big.Int) bool {
+	return isForked(c.ExtendedPrecompilesBlock, num)
+}
+
+// ExtendedPrecompilesGas returns the pricing of the extended precompiles, with the
+// protocol defaults filled in for any price the chain doesn't configure.
+func (c *ChainConfig) ExtendedPrecompilesGas() PrecompilesConfig {
+	gas := PrecompilesConfig{
+		Blake2FRoundGas:         Blake2FRoundGas,
+		Ed25519VerifyBaseGas:    Ed25519VerifyBaseGas,
+		Ed25519VerifyPerWordGas: Ed25519VerifyPerWordGas,
+	}
+	if cfg := c.ExtendedPrecompiles; cfg != nil {
+		if cfg.Blake2FRoundGas != 0 {
+			gas.Blake2FRoundGas = cfg.Blake2FRoundGas
+		}
+		if cfg.Ed25519VerifyBaseGas != 0 {
+			gas.Ed25519VerifyBaseGas = cfg.Ed25519VerifyBaseGas
+		}
+		if cfg.Ed25519VerifyPerWordGas != 0 {
+			gas.Ed25519VerifyPerWordGas = cfg.Ed25519VerifyPerWordGas
+		}
+	}
+	return gas
+}
+
+// CheckConfigForkOrder checks that forks depending on an earlier one are not
+// scheduled before it.
+func (c *ChainConfig) CheckConfigForkOrder() error {
+	if c.ExtendedPrecompilesBlock != nil {
+		if c.ByzantiumBlock == nil || c.ExtendedPrecompilesBlock.Cmp(c.ByzantiumBlock) < 0 {
+			return fmt.Errorf("unsupported fork ordering: extended precompiles enabled at %v, but byzantium at %v", c.ExtendedPrecompilesBlock, c.ByzantiumBlock)
+		}
+	}
+	return nil
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -359,6 +428,12 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.ExtendedPrecompilesBlock, newcfg.ExtendedPrecompilesBlock, head) {
+		return newCompatError("extended precompiles fork block", c.ExtendedPrecompilesBlock, newcfg.ExtendedPrecompilesBlock)
+	}
+	if isForked(c.ExtendedPrecompilesBlock, head) && c.ExtendedPrecompilesGas() != newcfg.ExtendedPrecompilesGas() {
+		return newCompatError("extended precompiles pricing", c.ExtendedPrecompilesBlock, newcfg.ExtendedPrecompilesBlock)
+	}
 	return nil
 }
 
@@ -426,6 +501,8 @@ type Rules struct {
 	ChainID                                     *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158   bool
 	IsByzantium, IsConstantinople, IsPetersburg bool
+	IsExtendedPrecompiles                       bool
+	ExtendedPrecompilesGas                      PrecompilesConfig
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -443,5 +520,8 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsByzantium:      c.IsByzantium(num),
 		IsConstantinople: c.IsConstantinople(num),
 		IsPetersburg:     c.IsPetersburg(num),
+
+		IsExtendedPrecompiles:  c.IsExtendedPrecompiles(num),
+		ExtendedPrecompilesGas: c.ExtendedPrecompilesGas(),
 	}
 }
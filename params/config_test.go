@@ -70,6 +70,29 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{ExtendedPrecompilesBlock: big.NewInt(10)},
+			new:     &ChainConfig{ExtendedPrecompilesBlock: big.NewInt(10), ExtendedPrecompiles: &PrecompilesConfig{Blake2FRoundGas: Blake2FRoundGas}},
+			head:    20,
+			wantErr: nil,
+		},
+		{
+			stored:  &ChainConfig{ExtendedPrecompilesBlock: big.NewInt(10)},
+			new:     &ChainConfig{ExtendedPrecompilesBlock: big.NewInt(10), ExtendedPrecompiles: &PrecompilesConfig{Blake2FRoundGas: 2}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{ExtendedPrecompilesBlock: big.NewInt(10)},
+			new:    &ChainConfig{ExtendedPrecompilesBlock: big.NewInt(10), ExtendedPrecompiles: &PrecompilesConfig{Blake2FRoundGas: 2}},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "extended precompiles pricing",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -79,3 +102,21 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfigForkOrder(t *testing.T) {
+	tests := []struct {
+		config  *ChainConfig
+		wantErr bool
+	}{
+		{config: &ChainConfig{}, wantErr: false},
+		{config: &ChainConfig{ByzantiumBlock: big.NewInt(10), ExtendedPrecompilesBlock: big.NewInt(10)}, wantErr: false},
+		{config: &ChainConfig{ByzantiumBlock: big.NewInt(10), ExtendedPrecompilesBlock: big.NewInt(20)}, wantErr: false},
+		{config: &ChainConfig{ByzantiumBlock: big.NewInt(10), ExtendedPrecompilesBlock: big.NewInt(5)}, wantErr: true},
+		{config: &ChainConfig{ExtendedPrecompilesBlock: big.NewInt(0)}, wantErr: true},
+	}
+	for i, test := range tests {
+		if err := test.config.CheckConfigForkOrder(); (err != nil) != test.wantErr {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, test.wantErr)
+		}
+	}
+}
@@ -85,6 +85,9 @@ const (
 	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
 	Bn256PairingBaseGas     uint64 = 100000 // Base price for an elliptic curve pairing check
 	Bn256PairingPerPointGas uint64 = 80000  // Per-point price for an elliptic curve pairing check
+	Blake2FRoundGas         uint64 = 1      // Per-round price for a blake2 compression
+	Ed25519VerifyBaseGas    uint64 = 2000   // Base price for an ed25519 signature verification
+	Ed25519VerifyPerWordGas uint64 = 12     // Per-word price for hashing the message of an ed25519 verification
 )
 
 var (